@@ -4,9 +4,28 @@
 package chains
 
 import (
+	"math/big"
+
 	"github.com/rjman-self/platdot-utils/msg"
 )
 
 type Router interface {
 	Send(message msg.Message) error
 }
+
+// RefundTransfer asks the chain a deposit was made on to refund it, because its proposal was cancelled
+// on the destination chain. The payload holds the amount of the proposal.
+var RefundTransfer msg.TransferType = "RefundTransfer"
+
+func NewRefundTransfer(source, dest msg.ChainId, nonce msg.Nonce, resourceId msg.ResourceId, amount *big.Int) msg.Message {
+	return msg.Message{
+		Source:       source,
+		Destination:  dest,
+		Type:         RefundTransfer,
+		DepositNonce: nonce,
+		ResourceId:   resourceId,
+		Payload: []interface{}{
+			amount.Bytes(),
+		},
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ChainSafe/log15"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	bridge "github.com/rjman-self/Platdot/bindings/Bridge"
+	connection "github.com/rjman-self/Platdot/connections/platdot"
+	"github.com/rjman-self/Platdot/shared/bech32"
+	"github.com/rjman-self/platdot-utils/core"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+// AdminCall is a call of an admin method of the bridge
+type AdminCall struct {
+	Method string
+	Args   []interface{}
+}
+
+func AddRelayerCall(relayer common.Address) AdminCall {
+	return AdminCall{Method: "adminAddRelayer", Args: []interface{}{relayer}}
+}
+
+func RemoveRelayerCall(relayer common.Address) AdminCall {
+	return AdminCall{Method: "adminRemoveRelayer", Args: []interface{}{relayer}}
+}
+
+func ChangeThresholdCall(threshold *big.Int) AdminCall {
+	return AdminCall{Method: "adminChangeRelayerThreshold", Args: []interface{}{threshold}}
+}
+
+func ChangeFeeCall(fee *big.Int) AdminCall {
+	return AdminCall{Method: "adminChangeFee", Args: []interface{}{fee}}
+}
+
+func PauseCall() AdminCall {
+	return AdminCall{Method: "adminPauseTransfers"}
+}
+
+func UnpauseCall() AdminCall {
+	return AdminCall{Method: "adminUnpauseTransfers"}
+}
+
+func SetResourceCall(handler common.Address, rId msg.ResourceId, token common.Address) AdminCall {
+	return AdminCall{Method: "adminSetResource", Args: []interface{}{handler, [32]byte(rId), token}}
+}
+
+func SetBurnableCall(handler, token common.Address) AdminCall {
+	return AdminCall{Method: "adminSetBurnable", Args: []interface{}{handler, token}}
+}
+
+func WithdrawCall(handler, token, recipient common.Address, amount *big.Int) AdminCall {
+	return AdminCall{Method: "adminWithdraw", Args: []interface{}{handler, token, recipient, amount}}
+}
+
+// Pack returns the calldata of the call, as submitted to the bridge
+func (c AdminCall) Pack() ([]byte, error) {
+	parsed, err := abi.JSON(strings.NewReader(bridge.BridgeABI))
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Pack(c.Method, c.Args...)
+}
+
+// BridgeInfo is the configuration of a deployed bridge
+type BridgeInfo struct {
+	ChainId       uint8
+	Admins        []common.Address
+	Relayers      []common.Address
+	Threshold     uint8
+	TotalRelayers *big.Int
+	Fee           *big.Int
+	Expiry        *big.Int
+	Paused        bool
+}
+
+// AdminClient manages the bridge contract of a chain config outside of a running relayer.
+// Calls are built without connecting, so they can be printed for an admin wallet instead of submitted.
+type AdminClient struct {
+	cfg      *Config
+	insecure bool
+	log      log15.Logger
+	conn     *connection.Connection
+	bridge   *bridge.Bridge
+}
+
+// NewAdminClient parses an ethereum chain config, Connect must be called before querying or submitting
+func NewAdminClient(chainCfg *core.ChainConfig, log log15.Logger) (*AdminClient, error) {
+	cfg, err := parseChainConfig(chainCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminClient{cfg: cfg, insecure: chainCfg.Insecure, log: log}, nil
+}
+
+// Bridge returns the address of the bridge contract
+func (a *AdminClient) Bridge() common.Address {
+	return a.cfg.bridgeContract
+}
+
+// Erc20Handler returns the address of the configured ERC20 handler
+func (a *AdminClient) Erc20Handler() common.Address {
+	return a.cfg.erc20HandlerContract
+}
+
+// ParseAddress parses an address given as bech32 with the prefix of the chain or as hex
+func (a *AdminClient) ParseAddress(addr string) (common.Address, error) {
+	return bech32.ParseAddress(addr, a.cfg.prefix)
+}
+
+// FormatAddress returns the bech32 encoding of an address, or its hex encoding if no prefix is configured
+func (a *AdminClient) FormatAddress(addr common.Address) string {
+	if a.cfg.prefix == "" {
+		return addr.Hex()
+	}
+	res, err := bech32.FormatAddress(addr, a.cfg.prefix)
+	if err != nil {
+		return addr.Hex()
+	}
+	return res
+}
+
+// fromAddress returns the address of the configured key
+func (a *AdminClient) fromAddress() (common.Address, error) {
+	return a.ParseAddress(a.cfg.from)
+}
+
+// Connect loads the key of the from address and connects to the bridge
+func (a *AdminClient) Connect() error {
+	// set Alaya chainId
+	err := os.Setenv("networkId", a.cfg.networkId)
+	if err != nil {
+		return err
+	}
+
+	from, err := a.fromAddress()
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	s, err := newSigner(a.cfg, from, a.insecure)
+	if err != nil {
+		return err
+	}
+
+	a.conn = connection.NewConnection(a.cfg.endpoint, a.cfg.http, s, a.log, a.cfg.gasLimit, a.cfg.maxGasPrice, a.cfg.gasMultiplier)
+	err = a.conn.Connect()
+	if err != nil {
+		return err
+	}
+	err = a.conn.EnsureHasBytecode(a.cfg.bridgeContract)
+	if err != nil {
+		return err
+	}
+	a.bridge, err = bridge.NewBridge(a.cfg.bridgeContract, a.conn.Client())
+	return err
+}
+
+// Close closes the connection
+func (a *AdminClient) Close() {
+	if a.conn != nil {
+		a.conn.Close()
+	}
+}
+
+// Submit sends the call with the configured key and waits until it is mined. The bridge checks the
+// admin role, so the key must be an admin of the bridge.
+func (a *AdminClient) Submit(c AdminCall) (*ethtypes.Transaction, error) {
+	err := a.conn.LockAndUpdateOpts()
+	if err != nil {
+		return nil, err
+	}
+	raw := &bridge.BridgeRaw{Contract: a.bridge}
+	tx, err := raw.Transact(a.conn.Opts(), c.Method, c.Args...)
+	if err == nil {
+		a.conn.IncrementNonce()
+	}
+	a.conn.UnlockOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TxReceiptTimeout)
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, a.conn.Client(), tx)
+	if err != nil {
+		return tx, err
+	}
+	if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+		return tx, fmt.Errorf("transaction %s reverted", tx.Hash().Hex())
+	}
+	return tx, nil
+}
+
+// roleMembers returns the accounts granted role
+func (a *AdminClient) roleMembers(role [32]byte) ([]common.Address, error) {
+	count, err := a.bridge.GetRoleMemberCount(a.conn.CallOpts(), role)
+	if err != nil {
+		return nil, err
+	}
+	var members []common.Address
+	for i := int64(0); i < count.Int64(); i++ {
+		member, err := a.bridge.GetRoleMember(a.conn.CallOpts(), role, big.NewInt(i))
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// Info queries the configuration of the bridge
+func (a *AdminClient) Info() (*BridgeInfo, error) {
+	opts := a.conn.CallOpts()
+	info := &BridgeInfo{}
+	var err error
+
+	if info.ChainId, err = a.bridge.ChainID(opts); err != nil {
+		return nil, err
+	}
+	adminRole, err := a.bridge.DEFAULTADMINROLE(opts)
+	if err != nil {
+		return nil, err
+	}
+	if info.Admins, err = a.roleMembers(adminRole); err != nil {
+		return nil, err
+	}
+	relayerRole, err := a.bridge.RELAYERROLE(opts)
+	if err != nil {
+		return nil, err
+	}
+	if info.Relayers, err = a.roleMembers(relayerRole); err != nil {
+		return nil, err
+	}
+	if info.Threshold, err = a.bridge.RelayerThreshold(opts); err != nil {
+		return nil, err
+	}
+	if info.TotalRelayers, err = a.bridge.TotalRelayers(opts); err != nil {
+		return nil, err
+	}
+	if info.Fee, err = a.bridge.Fee(opts); err != nil {
+		return nil, err
+	}
+	if info.Expiry, err = a.bridge.Expiry(opts); err != nil {
+		return nil, err
+	}
+	if info.Paused, err = a.bridge.Paused(opts); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DepositCount returns the number of deposits made to the destination chain
+func (a *AdminClient) DepositCount(dest msg.ChainId) (uint64, error) {
+	return a.bridge.DepositCounts(a.conn.CallOpts(), uint8(dest))
+}
+
+// ResourceHandler returns the handler registered for a resource id
+func (a *AdminClient) ResourceHandler(rId msg.ResourceId) (common.Address, error) {
+	return a.bridge.ResourceIDToHandlerAddress(a.conn.CallOpts(), rId)
+}
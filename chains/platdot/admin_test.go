@@ -0,0 +1,62 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/bindings/Bridge"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+func TestAdminCalls(t *testing.T) {
+	bridgeAbi, err := abi.JSON(strings.NewReader(Bridge.BridgeABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relayer := common.HexToAddress("0xff93B45308FD417dF303D6515aB04D9e89a750Ca")
+	handler := common.HexToAddress("0x3167776db165D8eA0f51790CA2bbf44Db5105ADF")
+	token := common.HexToAddress("0x21605f71845f372A9ed84253d2D024B7B10999f4")
+	rId := msg.ResourceIdFromSlice(common.FromHex("0x000000000000000000000000000000c76ebe4a02bbc34786d860b355f5a5ce00"))
+
+	testCases := []struct {
+		call     AdminCall
+		expected []interface{}
+	}{
+		{AddRelayerCall(relayer), []interface{}{relayer}},
+		{RemoveRelayerCall(relayer), []interface{}{relayer}},
+		{ChangeThresholdCall(big.NewInt(3)), []interface{}{big.NewInt(3)}},
+		{ChangeFeeCall(big.NewInt(100)), []interface{}{big.NewInt(100)}},
+		{PauseCall(), []interface{}{}},
+		{UnpauseCall(), []interface{}{}},
+		{SetResourceCall(handler, rId, token), []interface{}{handler, [32]byte(rId), token}},
+		{SetBurnableCall(handler, token), []interface{}{handler, token}},
+		{WithdrawCall(handler, token, relayer, big.NewInt(10)), []interface{}{handler, token, relayer, big.NewInt(10)}},
+	}
+
+	for _, tc := range testCases {
+		data, err := tc.call.Pack()
+		if err != nil {
+			t.Fatalf("%s: %s", tc.call.Method, err)
+		}
+		method := bridgeAbi.Methods[tc.call.Method]
+		if !bytes.Equal(data[:4], method.ID) {
+			t.Fatalf("%s: Got selector: %x Expected: %x", tc.call.Method, data[:4], method.ID)
+		}
+		args, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			t.Fatalf("%s: %s", tc.call.Method, err)
+		}
+		if !reflect.DeepEqual(args, tc.expected) {
+			t.Fatalf("%s: Got: %v Expected: %v", tc.call.Method, args, tc.expected)
+		}
+	}
+}
@@ -16,18 +16,23 @@ The listener polls for each new block and looks for deposit events in the bridge
 
 Writer
 
-The writer recieves the message and creates a proposals on-chain. Once a proposal is made, the writer then watches for a finalization event and will attempt to execute the proposal if a matching event occurs. The writer skips over any proposals it has already seen.
+The writer recieves the message and creates a proposals on-chain. Once a proposal is made, the writer waits for the proposal watcher of the chain to report its finalization event and will attempt to execute the proposal if a matching event occurs. Voted proposals are stored, so their execution resumes after a restart. Stored proposals staying Active past the bridge expiry are cancelled, and the deposit of a cancelled proposal is sent back to its source chain to be refunded. The writer skips over any proposals it has already seen.
+Transactions are sent with a locally managed nonce and their receipts are confirmed. When a multicall contract is configured, queued votes are checked in a single call before they are sent.
 */
 package platdot
 
 import (
+	"fmt"
+
 	"github.com/ChainSafe/log15"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	bridge "github.com/rjman-self/Platdot/bindings/Bridge"
 	erc20Handler "github.com/rjman-self/Platdot/bindings/ERC20Handler"
 	connection "github.com/rjman-self/Platdot/connections/platdot"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/Platdot/shared/screening"
+	"github.com/rjman-self/Platdot/shared/signer"
 	"github.com/rjman-self/platdot-utils/blockstore"
 	"github.com/rjman-self/platdot-utils/core"
 	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
@@ -36,6 +41,7 @@ import (
 	"github.com/rjman-self/platdot-utils/msg"
 	"math/big"
 	"os"
+	"sync"
 )
 
 var _ core.Chain = &Chain{}
@@ -44,12 +50,17 @@ var _ Connection = &connection.Connection{}
 
 type Connection interface {
 	Connect() error
-	Keypair() *secp256k1.Keypair
+	Address() common.Address
 	Opts() *bind.TransactOpts
 	CallOpts() *bind.CallOpts
 	LockAndUpdateOpts() error
+	IncrementNonce()
+	ResetNonce()
 	UnlockOpts()
-	Client() *ethclient.Client
+	Client() utils.ChainClient
+	Backend() bind.ContractBackend
+	Reconnect(endpoint string, http bool) error
+	SetGasOptions(gasLimit, maxGasPrice *big.Int, gasMultiplier *big.Float)
 	EnsureHasBytecode(address common.Address) error
 	LatestBlock() (*big.Int, error)
 	WaitForBlock(block *big.Int, delay *big.Int) error
@@ -57,17 +68,20 @@ type Connection interface {
 }
 
 type Chain struct {
-	cfg      *core.ChainConfig // The config of the chain
-	conn     Connection        // THe chains connection
-	listener *listener         // The listener of this chain
-	writer   *writer           // The writer of the chain
-	stop     chan<- int
+	cfg        *core.ChainConfig // The config of the chain
+	conn       Connection        // THe chains connection
+	listener   *listener         // The listener of this chain
+	writer     *writer           // The writer of the chain
+	stop       chan<- int
+	config     *Config    // Parsed config, replaced by Reload
+	reloadLock sync.Mutex // Guards config and denyStop
+	denyStop   chan int   // Stops watching the current deny-list
 }
 
 // checkBlockstore queries the blockstore for the latest known block. If the latest block is
 // greater than cfg.startBlock, then cfg.startBlock is replaced with the latest known block.
-func setupBlockstore(cfg *Config, kp *secp256k1.Keypair) (*blockstore.Blockstore, error) {
-	bs, err := blockstore.NewBlockstore(cfg.blockstorePath, cfg.id, kp.Address())
+func setupBlockstore(cfg *Config, address common.Address) (*blockstore.Blockstore, error) {
+	bs, err := blockstore.NewBlockstore(cfg.blockstorePath, cfg.id, address.String())
 	if err != nil {
 		return nil, err
 	}
@@ -75,12 +89,44 @@ func setupBlockstore(cfg *Config, kp *secp256k1.Keypair) (*blockstore.Blockstore
 	return bs, nil
 }
 
+// newSigner returns the signer of address. Its key is held by the signer daemon when the signer opt is set,
+// otherwise it is loaded from the keystore.
+func newSigner(cfg *Config, address common.Address, insecure bool) (signer.Ethereum, error) {
+	if cfg.signer != "" {
+		client, err := signer.Dial(cfg.signer)
+		if err != nil {
+			return nil, err
+		}
+		return client.Ethereum(address)
+	}
+	kp, err := keystore.KeypairFromAddress(address.String(), keystore.EthChain, cfg.keystorePath, insecure)
+	if err != nil {
+		return nil, err
+	}
+	return signer.NewEthereum(kp.(*secp256k1.Keypair)), nil
+}
+
 func InitializeChain(chainCfg *core.ChainConfig, logger log15.Logger, sysErr chan<- error, m *metrics.ChainMetrics) (*Chain, error) {
+	return initializeChain(chainCfg, func(cfg *Config, s signer.Ethereum) *connection.Connection {
+		return connection.NewConnection(cfg.endpoint, cfg.http, s, logger, cfg.gasLimit, cfg.maxGasPrice, cfg.gasMultiplier)
+	}, logger, sysErr, m)
+}
+
+// InitializeChainWithClient initializes the chain like InitializeChain, but sends its calls through client
+// instead of dialing the endpoint of the config
+func InitializeChainWithClient(chainCfg *core.ChainConfig, client utils.ChainClient, logger log15.Logger, sysErr chan<- error, m *metrics.ChainMetrics) (*Chain, error) {
+	return initializeChain(chainCfg, func(cfg *Config, s signer.Ethereum) *connection.Connection {
+		return connection.NewConnectionWithClient(client, s, logger, cfg.gasLimit, cfg.maxGasPrice, cfg.gasMultiplier)
+	}, logger, sysErr, m)
+}
+
+func initializeChain(chainCfg *core.ChainConfig, newConnection func(*Config, signer.Ethereum) *connection.Connection, logger log15.Logger, sysErr chan<- error, m *metrics.ChainMetrics) (*Chain, error) {
 	// parse config
 	cfg, err := parseChainConfig(chainCfg)
 	if err != nil {
 		return nil, err
 	}
+	loaded := *cfg
 
 	// set Alaya chainId
 	err = os.Setenv("networkId", cfg.networkId)
@@ -89,23 +135,20 @@ func InitializeChain(chainCfg *core.ChainConfig, logger log15.Logger, sysErr cha
 	}
 
 	// load key
-	ethBytes, _ := common.PlatonToEth(cfg.from)
-	ethAddress := common.BytesToAddress(ethBytes)
-	kpI, err := keystore.KeypairFromAddress(ethAddress.String(), keystore.EthChain, cfg.keystorePath, chainCfg.Insecure)
+	ethAddress := common.HexToAddress(cfg.from)
+	s, err := newSigner(cfg, ethAddress, chainCfg.Insecure)
 	if err != nil {
 		return nil, err
 	}
 
-	kp, _ := kpI.(*secp256k1.Keypair)
-
 	// init block store
-	bs, err := setupBlockstore(cfg, kp)
+	bs, err := setupBlockstore(cfg, ethAddress)
 	if err != nil {
 		return nil, err
 	}
 
 	stop := make(chan int)
-	conn := connection.NewConnection(cfg.endpoint, cfg.http, kp, logger, cfg.gasLimit, cfg.maxGasPrice, cfg.gasMultiplier)
+	conn := newConnection(cfg, s)
 	err = conn.Connect()
 	if err != nil {
 		return nil, err
@@ -116,12 +159,12 @@ func InitializeChain(chainCfg *core.ChainConfig, logger log15.Logger, sysErr cha
 		return nil, err
 	}
 
-	bridgeContract, err := bridge.NewBridge(cfg.bridgeContract, conn.Client())
+	bridgeContract, err := bridge.NewBridge(cfg.bridgeContract, conn.Backend())
 	if err != nil {
 		return nil, err
 	}
 
-	erc20HandlerContract, err := erc20Handler.NewERC20Handler(cfg.erc20HandlerContract, conn.Client())
+	erc20HandlerContract, err := erc20Handler.NewERC20Handler(cfg.erc20HandlerContract, conn.Backend())
 	if err != nil {
 		return nil, err
 	}
@@ -137,21 +180,62 @@ func InitializeChain(chainCfg *core.ChainConfig, logger log15.Logger, sysErr cha
 	listener := NewListener(conn, cfg, logger, bs, stop, sysErr, m)
 	listener.setContracts(bridgeContract, erc20HandlerContract)
 
+	var denyList *screening.DenyList
+	if cfg.denyList != "" {
+		denyList, err = screening.NewDenyList(cfg.denyList, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	writer := NewWriter(conn, cfg, logger, stop, sysErr, m)
 	writer.setContract(bridgeContract)
 
-	return &Chain{
+	store, err := newProposalStore(cfg.blockstorePath, cfg.id, ethAddress.String())
+	if err != nil {
+		return nil, err
+	}
+	writer.setProposalWatcher(newProposalWatcher(conn, bridgeContract, cfg.bridgeContract, logger, stop, sysErr), store)
+	if m != nil {
+		writer.setSweeperMetrics(newSweeperMetrics(chainCfg.Name))
+	}
+
+	if cfg.multicallContract != (common.Address{}) {
+		err = conn.EnsureHasBytecode(cfg.multicallContract)
+		if err != nil {
+			return nil, err
+		}
+		// Anyone can make calls through the multicall contract, so it must not be a relayer
+		isRelayer, err := bridgeContract.IsRelayer(conn.CallOpts(), cfg.multicallContract)
+		if err != nil {
+			return nil, err
+		}
+		if isRelayer {
+			return nil, fmt.Errorf("multicall contract %s is a relayer of the bridge, anyone could vote through it", cfg.multicallContract.Hex())
+		}
+		mc, err := newMulticall(cfg.multicallContract, cfg.bridgeContract, conn.Backend())
+		if err != nil {
+			return nil, err
+		}
+		writer.setMulticall(mc)
+	}
+
+	chain := &Chain{
 		cfg:      chainCfg,
 		conn:     conn,
 		writer:   writer,
 		listener: listener,
 		stop:     stop,
-	}, nil
+		config:   &loaded,
+	}
+	chain.setDenyList(denyList)
+	return chain, nil
 }
 
 func (c *Chain) SetRouter(r *core.Router) {
 	r.Listen(c.cfg.Id, c.writer)
 	c.listener.setRouter(r)
+	c.writer.setRouter(r)
 }
 
 func (c *Chain) Start() error {
@@ -183,6 +267,9 @@ func (c *Chain) LatestBlock() metrics.LatestBlock {
 
 // Stop signals to any running routines to exit
 func (c *Chain) Stop() {
+	c.reloadLock.Lock()
+	c.setDenyList(nil)
+	c.reloadLock.Unlock()
 	close(c.stop)
 	if c.conn != nil {
 		c.conn.Close()
@@ -17,16 +17,16 @@ import (
 )
 
 func TestChain_ListenerShutdownOnFailure(t *testing.T) {
-	client := ethtest.NewClient(t, TestEndpoint, AliceKp)
+	sim := newTestChain(t)
+	client := newTestClient(t, sim)
 	contracts := deployTestContracts(t, client, msg.ChainId(1))
 	cfg := &core.ChainConfig{
 		Id:             msg.ChainId(1),
 		Name:           "alice",
-		Endpoint:       TestEndpoint,
 		From:           keystore.AliceKey,
 		Insecure:       true,
 		KeystorePath:   keystore.AliceKey,
-		BlockstorePath: "",
+		BlockstorePath: t.TempDir(),
 		FreshStart:     true,
 		Opts: map[string]string{
 			"bridge":       contracts.BridgeAddress.Hex(),
@@ -36,7 +36,7 @@ func TestChain_ListenerShutdownOnFailure(t *testing.T) {
 		},
 	}
 	sysErr := make(chan error)
-	chain, err := InitializeChain(cfg, TestLogger, sysErr, nil)
+	chain, err := InitializeChainWithClient(cfg, sim, TestLogger, sysErr, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -52,7 +52,8 @@ func TestChain_ListenerShutdownOnFailure(t *testing.T) {
 	// Pull expected error
 	select {
 	case err := <-sysErr:
-		if err.Error() != ErrFatalPolling.Error() {
+		if err.Error() != ErrFatalPolling.Error() &&
+			err.Error() != ErrFatalQuery.Error() {
 			t.Fatalf("Unexpected error: %s", err)
 		}
 	case <-time.After(time.Second * 30):
@@ -65,7 +66,8 @@ func TestChain_ListenerShutdownOnFailure(t *testing.T) {
 
 func TestChain_WriterShutdownOnFailure(t *testing.T) {
 	// Setup contracts and params for erc20 transfer
-	client := ethtest.NewClient(t, TestEndpoint, AliceKp)
+	sim := newTestChain(t)
+	client := newTestClient(t, sim)
 	contracts := deployTestContracts(t, client, msg.ChainId(1))
 	erc20Contract := ethtest.DeployMintApproveErc20(t, client, contracts.ERC20HandlerAddress, big.NewInt(100))
 	src := msg.ChainId(5) // Not yet used, nonce should be 0
@@ -79,11 +81,10 @@ func TestChain_WriterShutdownOnFailure(t *testing.T) {
 	cfg := &core.ChainConfig{
 		Id:             dst,
 		Name:           "alice",
-		Endpoint:       TestEndpoint,
 		From:           keystore.AliceKey,
 		Insecure:       true,
 		KeystorePath:   keystore.AliceKey,
-		BlockstorePath: "",
+		BlockstorePath: t.TempDir(),
 		FreshStart:     true,
 		Opts: map[string]string{
 			"bridge":       contracts.BridgeAddress.Hex(),
@@ -93,7 +94,7 @@ func TestChain_WriterShutdownOnFailure(t *testing.T) {
 		},
 	}
 	sysErr := make(chan error)
-	chain, err := InitializeChain(cfg, TestLogger, sysErr, nil)
+	chain, err := InitializeChainWithClient(cfg, sim, TestLogger, sysErr, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -107,15 +108,12 @@ func TestChain_WriterShutdownOnFailure(t *testing.T) {
 	}
 
 	// Submit some messages
-	message := msg.NewFungibleTransfer(src, dst, 1, amount, resourceId, recipient.Bytes())
-
-	for i := 0; i < 5; i++ {
+	for i := 1; i <= 5; i++ {
+		message := msg.NewFungibleTransfer(src, dst, msg.Nonce(i), amount, resourceId, []byte(recipient.Hex()))
 		err = chain.listener.router.Send(message)
 		if err != nil {
 			t.Fatal(err)
 		}
-
-		message.DepositNonce++
 	}
 
 	time.Sleep(time.Second)
@@ -10,6 +10,7 @@ import (
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/shared/bech32"
 	utils "github.com/rjman-self/Platdot/shared/platdot"
 	"github.com/rjman-self/platdot-utils/core"
 	"github.com/rjman-self/platdot-utils/msg"
@@ -19,6 +20,7 @@ const DefaultGasLimit = 6721975
 const DefaultGasPrice = 20000000000
 const DefaultBlockConfirmations = 10
 const DefaultGasMultiplier = 1
+const DefaultVoteBatchSize = 20
 
 // Chain specific options
 var (
@@ -34,6 +36,10 @@ var (
 	BlockConfirmationsOpt = "blockConfirmations"
 	PrefixOpt             = "prefix"
 	NetWorkIdOpt          = "networkId"
+	DenyListOpt           = "denyList"
+	MulticallOpt          = "multicall"
+	VoteBatchSizeOpt      = "voteBatchSize"
+	SignerOpt             = "signer"
 )
 
 // Config encapsulates all necessary parameters in ethereum compatible forms
@@ -45,12 +51,16 @@ type Config struct {
 	keystorePath           string      // Location of keyfiles
 	blockstorePath         string
 	prefix                 string
-	networkId              string 	   // Network Id
-	freshStart             bool // Disables loading from blockstore at start
+	networkId              string // Network Id
+	denyList               string // Location of the deny-list file
+	signer                 string // Socket of the signer daemon holding the key, the keystore is used when empty
+	freshStart             bool   // Disables loading from blockstore at start
 	bridgeContract         common.Address
 	erc20HandlerContract   common.Address
 	erc721HandlerContract  common.Address
 	genericHandlerContract common.Address
+	multicallContract      common.Address // Batches the checks of queued votes when set
+	voteBatchSize          int            // Maximum number of votes checked in a multicall
 	gasLimit               *big.Int
 	maxGasPrice            *big.Int
 	gasMultiplier          *big.Float
@@ -59,6 +69,21 @@ type Config struct {
 	blockConfirmations     *big.Int
 }
 
+// parseAddressOpt parses and removes the address of opt, given as bech32 with the prefix of the chain or as hex.
+// The zero address is returned if opt is not set.
+func parseAddressOpt(chainCfg *core.ChainConfig, opt, prefix string) (common.Address, error) {
+	value, ok := chainCfg.Opts[opt]
+	delete(chainCfg.Opts, opt)
+	if !ok || value == "" {
+		return utils.ZeroAddress, nil
+	}
+	addr, err := bech32.ParseAddress(value, prefix)
+	if err != nil {
+		return utils.ZeroAddress, fmt.Errorf("unable to parse %s: %w", opt, err)
+	}
+	return addr, nil
+}
+
 // parseChainConfig uses a core.ChainConfig to construct a corresponding Config
 func parseChainConfig(chainCfg *core.ChainConfig) (*Config, error) {
 	http, _ := strconv.ParseBool(chainCfg.Opts["http"])
@@ -75,31 +100,49 @@ func parseChainConfig(chainCfg *core.ChainConfig) (*Config, error) {
 		erc20HandlerContract:   utils.ZeroAddress,
 		erc721HandlerContract:  utils.ZeroAddress,
 		genericHandlerContract: utils.ZeroAddress,
+		multicallContract:      utils.ZeroAddress,
+		voteBatchSize:          DefaultVoteBatchSize,
 		gasLimit:               big.NewInt(DefaultGasLimit),
 		maxGasPrice:            big.NewInt(DefaultGasPrice),
 		gasMultiplier:          big.NewFloat(DefaultGasMultiplier),
 		http:                   http,
 		prefix:                 chainCfg.Opts[PrefixOpt],
-		networkId: 				chainCfg.Opts[NetWorkIdOpt],
+		networkId:              chainCfg.Opts[NetWorkIdOpt],
 		startBlock:             big.NewInt(0),
 		blockConfirmations:     big.NewInt(0),
 	}
 	//fmt.Printf("load config: http is %v\n prefix is %v\nnetworkId is %v\n id is %v\n", config.http, config.prefix, config.networkId, config.id)
+	// Test keys are loaded by the name of the key path, from may name the key too
+	if config.from != "" && !chainCfg.Insecure {
+		from, err := bech32.ParseAddress(config.from, config.prefix)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse from: %w", err)
+		}
+		config.from = from.Hex()
+	}
+
 	if contract, ok := chainCfg.Opts[BridgeOpt]; ok && contract != "" {
-		config.bridgeContract = common.HexToAddress(contract)
-		delete(chainCfg.Opts, BridgeOpt)
+		addr, err := parseAddressOpt(chainCfg, BridgeOpt, config.prefix)
+		if err != nil {
+			return nil, err
+		}
+		config.bridgeContract = addr
 	} else {
 		return nil, fmt.Errorf("must provide opts.bridge field for ethereum config")
 	}
 
-	config.erc20HandlerContract = common.HexToAddress(chainCfg.Opts[Erc20HandlerOpt])
-	delete(chainCfg.Opts, Erc20HandlerOpt)
-
-	config.erc721HandlerContract = common.HexToAddress(chainCfg.Opts[Erc721HandlerOpt])
-	delete(chainCfg.Opts, Erc721HandlerOpt)
-
-	config.genericHandlerContract = common.HexToAddress(chainCfg.Opts[GenericHandlerOpt])
-	delete(chainCfg.Opts, GenericHandlerOpt)
+	for opt, contract := range map[string]*common.Address{
+		Erc20HandlerOpt:   &config.erc20HandlerContract,
+		Erc721HandlerOpt:  &config.erc721HandlerContract,
+		GenericHandlerOpt: &config.genericHandlerContract,
+		MulticallOpt:      &config.multicallContract,
+	} {
+		addr, err := parseAddressOpt(chainCfg, opt, config.prefix)
+		if err != nil {
+			return nil, err
+		}
+		*contract = addr
+	}
 
 	if gasPrice, ok := chainCfg.Opts[MaxGasPriceOpt]; ok {
 		price := big.NewInt(0)
@@ -177,9 +220,40 @@ func parseChainConfig(chainCfg *core.ChainConfig) (*Config, error) {
 		delete(chainCfg.Opts, NetWorkIdOpt)
 	}
 
+	if denyList, ok := chainCfg.Opts[DenyListOpt]; ok && denyList != "" {
+		config.denyList = denyList
+		delete(chainCfg.Opts, DenyListOpt)
+	}
+
+	if signer, ok := chainCfg.Opts[SignerOpt]; ok && signer != "" {
+		config.signer = signer
+		delete(chainCfg.Opts, SignerOpt)
+	}
+
+	if size, ok := chainCfg.Opts[VoteBatchSizeOpt]; ok && size != "" {
+		val, err := strconv.Atoi(size)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("unable to parse %s", VoteBatchSizeOpt)
+		}
+		config.voteBatchSize = val
+		delete(chainCfg.Opts, VoteBatchSizeOpt)
+	}
+
 	if len(chainCfg.Opts) != 0 {
 		return nil, fmt.Errorf("unknown Opts Encountered: %#v", chainCfg.Opts)
 	}
 
 	return config, nil
 }
+
+// ValidateChainConfig checks the options of a chain config without connecting to the chain
+func ValidateChainConfig(chainCfg *core.ChainConfig) error {
+	// parseChainConfig consumes the opts it knows
+	cfg := *chainCfg
+	cfg.Opts = make(map[string]string, len(chainCfg.Opts))
+	for k, v := range chainCfg.Opts {
+		cfg.Opts[k] = v
+	}
+	_, err := parseChainConfig(&cfg)
+	return err
+}
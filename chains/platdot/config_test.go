@@ -12,6 +12,9 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
+const testBridge = "0x62877dDCd49aD22f5eDfc6ac108e9a4b5D2bD88B"
+const testMulticall = "0x3167776db165D8eA0f51790CA2bbf44Db5105ADF"
+
 //TestParseChainConfig tests parseChainConfig with all handlerContracts provided
 func TestParseChainConfig(t *testing.T) {
 
@@ -19,14 +22,14 @@ func TestParseChainConfig(t *testing.T) {
 		Name:         "chain",
 		Id:           1,
 		Endpoint:     "endpoint",
-		From:         "0x0",
+		From:         AliceKp.Address(),
 		KeystorePath: "./keys",
 		Insecure:     false,
 		Opts: map[string]string{
-			"bridge":             "0x1234",
-			"erc20Handler":       "0x1234",
-			"erc721Handler":      "0x1234",
-			"genericHandler":     "0x1234",
+			"bridge":             testBridge,
+			"erc20Handler":       testBridge,
+			"erc721Handler":      testBridge,
+			"genericHandler":     testBridge,
 			"gasLimit":           "10",
 			"gasMultiplier":      "1",
 			"maxGasPrice":        "20",
@@ -46,18 +49,19 @@ func TestParseChainConfig(t *testing.T) {
 		name:                   "chain",
 		id:                     1,
 		endpoint:               "endpoint",
-		from:                   "0x0",
+		from:                   AliceKp.Address(),
 		keystorePath:           "./keys",
-		bridgeContract:         common.HexToAddress("0x1234"),
-		erc20HandlerContract:   common.HexToAddress("0x1234"),
-		erc721HandlerContract:  common.HexToAddress("0x1234"),
-		genericHandlerContract: common.HexToAddress("0x1234"),
+		bridgeContract:         common.HexToAddress(testBridge),
+		erc20HandlerContract:   common.HexToAddress(testBridge),
+		erc721HandlerContract:  common.HexToAddress(testBridge),
+		genericHandlerContract: common.HexToAddress(testBridge),
 		gasLimit:               big.NewInt(10),
 		maxGasPrice:            big.NewInt(20),
 		gasMultiplier:          big.NewFloat(1),
 		http:                   true,
 		startBlock:             big.NewInt(10),
 		blockConfirmations:     big.NewInt(50),
+		voteBatchSize:          DefaultVoteBatchSize,
 	}
 
 	if !reflect.DeepEqual(&expected, out) {
@@ -72,14 +76,14 @@ func TestParseChainConfigWithNoBlockConfirmations(t *testing.T) {
 		Name:         "chain",
 		Id:           1,
 		Endpoint:     "endpoint",
-		From:         "0x0",
+		From:         AliceKp.Address(),
 		KeystorePath: "./keys",
 		Insecure:     false,
 		Opts: map[string]string{
-			"bridge":         "0x1234",
-			"erc20Handler":   "0x1234",
-			"erc721Handler":  "0x1234",
-			"genericHandler": "0x1234",
+			"bridge":         testBridge,
+			"erc20Handler":   testBridge,
+			"erc721Handler":  testBridge,
+			"genericHandler": testBridge,
 			"gasLimit":       "10",
 			"gasMultiplier":  "1",
 			"maxGasPrice":    "20",
@@ -98,18 +102,19 @@ func TestParseChainConfigWithNoBlockConfirmations(t *testing.T) {
 		name:                   "chain",
 		id:                     1,
 		endpoint:               "endpoint",
-		from:                   "0x0",
+		from:                   AliceKp.Address(),
 		keystorePath:           "./keys",
-		bridgeContract:         common.HexToAddress("0x1234"),
-		erc20HandlerContract:   common.HexToAddress("0x1234"),
-		erc721HandlerContract:  common.HexToAddress("0x1234"),
-		genericHandlerContract: common.HexToAddress("0x1234"),
+		bridgeContract:         common.HexToAddress(testBridge),
+		erc20HandlerContract:   common.HexToAddress(testBridge),
+		erc721HandlerContract:  common.HexToAddress(testBridge),
+		genericHandlerContract: common.HexToAddress(testBridge),
 		gasLimit:               big.NewInt(10),
 		maxGasPrice:            big.NewInt(20),
 		gasMultiplier:          big.NewFloat(1),
 		http:                   true,
 		startBlock:             big.NewInt(10),
 		blockConfirmations:     big.NewInt(DefaultBlockConfirmations),
+		voteBatchSize:          DefaultVoteBatchSize,
 	}
 
 	if !reflect.DeepEqual(&expected, out) {
@@ -124,12 +129,12 @@ func TestChainConfigOneContract(t *testing.T) {
 		Name:         "chain",
 		Id:           1,
 		Endpoint:     "endpoint",
-		From:         "0x0",
+		From:         AliceKp.Address(),
 		KeystorePath: "./keys",
 		Insecure:     false,
 		Opts: map[string]string{
-			"bridge":        "0x1234",
-			"erc20Handler":  "0x1234",
+			"bridge":        testBridge,
+			"erc20Handler":  testBridge,
 			"gasLimit":      "10",
 			"maxGasPrice":   "20",
 			"gasMultiplier": "1",
@@ -148,16 +153,17 @@ func TestChainConfigOneContract(t *testing.T) {
 		name:                 "chain",
 		id:                   1,
 		endpoint:             "endpoint",
-		from:                 "0x0",
+		from:                 AliceKp.Address(),
 		keystorePath:         "./keys",
-		bridgeContract:       common.HexToAddress("0x1234"),
-		erc20HandlerContract: common.HexToAddress("0x1234"),
+		bridgeContract:       common.HexToAddress(testBridge),
+		erc20HandlerContract: common.HexToAddress(testBridge),
 		gasLimit:             big.NewInt(10),
 		maxGasPrice:          big.NewInt(20),
 		gasMultiplier:        big.NewFloat(1),
 		http:                 true,
 		startBlock:           big.NewInt(10),
 		blockConfirmations:   big.NewInt(DefaultBlockConfirmations),
+		voteBatchSize:        DefaultVoteBatchSize,
 	}
 
 	if !reflect.DeepEqual(&expected, out) {
@@ -165,12 +171,39 @@ func TestChainConfigOneContract(t *testing.T) {
 	}
 }
 
+func TestParseVoteBatchingOpts(t *testing.T) {
+	input := core.ChainConfig{
+		Name:     "chain",
+		Id:       1,
+		Endpoint: "endpoint",
+		From:     AliceKp.Address(),
+		Opts: map[string]string{
+			"bridge":        testBridge,
+			"multicall":     testMulticall,
+			"voteBatchSize": "5",
+		},
+	}
+
+	out, err := parseChainConfig(&input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.multicallContract != common.HexToAddress(testMulticall) || out.voteBatchSize != 5 {
+		t.Fatalf("Got: %s %d Expected: 0x5678 5", out.multicallContract.Hex(), out.voteBatchSize)
+	}
+
+	input.Opts = map[string]string{"bridge": testBridge, "voteBatchSize": "0"}
+	if _, err = parseChainConfig(&input); err == nil {
+		t.Fatal("expected an error for an empty vote batch")
+	}
+}
+
 func TestRequiredOpts(t *testing.T) {
 	// No opts provided
 	input := core.ChainConfig{
 		Id:           0,
 		Endpoint:     "endpoint",
-		From:         "0x0",
+		From:         AliceKp.Address(),
 		KeystorePath: "./keys",
 		Insecure:     false,
 		Opts:         map[string]string{},
@@ -186,7 +219,7 @@ func TestRequiredOpts(t *testing.T) {
 	input = core.ChainConfig{
 		Id:           0,
 		Endpoint:     "endpoint",
-		From:         "0x0",
+		From:         AliceKp.Address(),
 		KeystorePath: "./keys",
 		Insecure:     false,
 		Opts:         map[string]string{"bridge": ""},
@@ -205,11 +238,11 @@ func TestExtraOpts(t *testing.T) {
 		Name:         "chain",
 		Id:           1,
 		Endpoint:     "endpoint",
-		From:         "0x0",
+		From:         AliceKp.Address(),
 		KeystorePath: "./keys",
 		Insecure:     false,
 		Opts: map[string]string{
-			"bridge":        "0x1234",
+			"bridge":        testBridge,
 			"gasLimit":      "10",
 			"maxGasPrice":   "20",
 			"gasMultiplier": "1",
@@ -224,3 +257,40 @@ func TestExtraOpts(t *testing.T) {
 		t.Error("Config should not accept incorrect opts.")
 	}
 }
+
+// TestParseChainConfigBech32 tests parseChainConfig with addresses given as bech32
+func TestParseChainConfigBech32(t *testing.T) {
+	input := core.ChainConfig{
+		Name:         "chain",
+		Id:           1,
+		Endpoint:     "endpoint",
+		From:         "atp1l7fmg5cgl4qhmucr6eg44vzdn6y6w5x2q695d9",
+		KeystorePath: "./keys",
+		Opts: map[string]string{
+			"bridge":         "atp1v2rhmhx5ntfz7hklc6kppr56fdwjhkytxgakmg",
+			"erc20Handler":   testBridge,
+			"erc721Handler":  "atp1v2rhmhx5ntfz7hklc6kppr56fdwjhkytxgakmg",
+			"genericHandler": "ATP1V2RHMHX5NTFZ7HKLC6KPPR56FDWJHKYTXGAKMG",
+			"prefix":         "atp",
+		},
+	}
+
+	out, err := parseChainConfig(&input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.from != AliceKp.Address() {
+		t.Fatalf("Got: %s Expected: %s", out.from, AliceKp.Address())
+	}
+	for _, addr := range []common.Address{out.bridgeContract, out.erc20HandlerContract, out.erc721HandlerContract, out.genericHandlerContract} {
+		if addr != common.HexToAddress(testBridge) {
+			t.Fatalf("Got: %s Expected: %s", addr.Hex(), testBridge)
+		}
+	}
+
+	// Invalid checksum
+	input.Opts = map[string]string{"bridge": testBridge, "genericHandler": "atp1v2rhmhx5ntfz7hklc6kppr56fdwjhkytxgakmq", "prefix": "atp"}
+	if _, err = parseChainConfig(&input); err == nil {
+		t.Fatal("Got: nil Expected: invalid genericHandler")
+	}
+}
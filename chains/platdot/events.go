@@ -11,26 +11,33 @@ import (
 func (l *listener) handleErc20DepositedEvent(destId msg.ChainId, nonce msg.Nonce) (msg.Message, error) {
 	l.log.Info("Handling fungible deposit event", "dest", destId, "nonce", nonce)
 
-	record, err := l.erc20HandlerContract.GetDepositRecord(&bind.CallOpts{From: l.conn.Keypair().CommonAddress()}, uint64(nonce), uint8(destId))
+	record, err := l.erc20HandlerContract.GetDepositRecord(&bind.CallOpts{From: l.conn.Address()}, uint64(nonce), uint8(destId))
 	if err != nil {
 		l.log.Error("Error Unpacking ERC20 Deposit Record", "err", err)
 		return msg.Message{}, err
 	}
 
-	return msg.NewFungibleTransfer(
+	m := msg.NewFungibleTransfer(
 		l.cfg.id,
 		destId,
 		nonce,
 		record.Amount,
 		record.ResourceID,
 		record.DestinationRecipientAddress,
-	), nil
+	)
+
+	err = l.screening().Screen(m, record.Depositer.Hex(), string(record.DestinationRecipientAddress), record.Amount)
+	if err != nil {
+		return msg.Message{}, err
+	}
+
+	return m, nil
 }
 
 func (l *listener) handleErc721DepositedEvent(destId msg.ChainId, nonce msg.Nonce) (msg.Message, error) {
 	l.log.Info("Handling nonfungible deposit event")
 
-	record, err := l.erc721HandlerContract.GetDepositRecord(&bind.CallOpts{From: l.conn.Keypair().CommonAddress()}, uint64(nonce), uint8(destId))
+	record, err := l.erc721HandlerContract.GetDepositRecord(&bind.CallOpts{From: l.conn.Address()}, uint64(nonce), uint8(destId))
 	if err != nil {
 		l.log.Error("Error Unpacking ERC721 Deposit Record", "err", err)
 		return msg.Message{}, err
@@ -50,7 +57,7 @@ func (l *listener) handleErc721DepositedEvent(destId msg.ChainId, nonce msg.Nonc
 func (l *listener) handleGenericDepositedEvent(destId msg.ChainId, nonce msg.Nonce) (msg.Message, error) {
 	l.log.Info("Handling generic deposit event")
 
-	record, err := l.genericHandlerContract.GetDepositRecord(&bind.CallOpts{From: l.conn.Keypair().CommonAddress()}, uint64(nonce), uint8(destId))
+	record, err := l.genericHandlerContract.GetDepositRecord(&bind.CallOpts{From: l.conn.Address()}, uint64(nonce), uint8(destId))
 	if err != nil {
 		l.log.Error("Error Unpacking Generic Deposit Record", "err", err)
 		return msg.Message{}, nil
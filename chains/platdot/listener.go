@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/rjman-self/platdot-utils/blockstore"
@@ -23,6 +24,7 @@ import (
 	"github.com/rjman-self/Platdot/bindings/GenericHandler"
 	"github.com/rjman-self/Platdot/chains"
 	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/Platdot/shared/screening"
 )
 
 var BlockRetryInterval = time.Second * 5
@@ -44,6 +46,8 @@ type listener struct {
 	latestBlock            metrics.LatestBlock
 	metrics                *metrics.ChainMetrics
 	blockConfirmations     *big.Int
+	denyList               *screening.DenyList
+	denyLock               sync.RWMutex // Guards denyList, which is replaced on reload
 }
 
 // NewListener creates and returns a listener
@@ -66,6 +70,19 @@ func (l *listener) setContracts(bridge *Bridge.Bridge, erc20Handler *ERC20Handle
 	l.erc20HandlerContract = erc20Handler
 }
 
+func (l *listener) setDenyList(d *screening.DenyList) {
+	l.denyLock.Lock()
+	l.denyList = d
+	l.denyLock.Unlock()
+}
+
+// screening returns the current deny-list, nil when screening is disabled
+func (l *listener) screening() *screening.DenyList {
+	l.denyLock.RLock()
+	defer l.denyLock.RUnlock()
+	return l.denyList
+}
+
 // sets the router
 func (l *listener) setRouter(r chains.Router) {
 	l.router = r
@@ -172,13 +189,13 @@ func (l *listener) getDepositEventsForBlock(latestBlock *big.Int) error {
 		dest := log.Data[:32]
 		destBig := new(big.Int).SetBytes(dest)
 		destId := msg.ChainId(destBig.Uint64())
-		rId := msg.ResourceIdFromSlice(log.Data[33:64])
-		nc := log.Data[65:96]
+		rId := msg.ResourceIdFromSlice(log.Data[32:64])
+		nc := log.Data[64:96]
 		ncBig := new(big.Int).SetBytes(nc)
 		nonce := msg.Nonce(ncBig.Uint64())
 
 		l.log.Info("Parse event successfully.", "DestId", dest, "ResourceId", rId, "Nonce", nonce)
-		addr, err := l.bridgeContract.ResourceIDToHandlerAddress(&bind.CallOpts{From: l.conn.Keypair().CommonAddress()}, rId)
+		addr, err := l.bridgeContract.ResourceIDToHandlerAddress(&bind.CallOpts{From: l.conn.Address()}, rId)
 		if err != nil {
 			return fmt.Errorf("failed to get handler from resource ID %x", rId)
 		}
@@ -194,7 +211,9 @@ func (l *listener) getDepositEventsForBlock(latestBlock *big.Int) error {
 			return nil
 		}
 
-		if err != nil {
+		if errors.Is(err, screening.ErrBlocked) {
+			continue
+		} else if err != nil {
 			return err
 		}
 
@@ -0,0 +1,40 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sweeperMetrics counts the actions taken on stale proposals
+type sweeperMetrics struct {
+	ProposalsExpired   prometheus.Counter
+	ProposalsCancelled prometheus.Counter
+	RefundsRequested   prometheus.Counter
+}
+
+func newSweeperMetrics(chain string) *sweeperMetrics {
+	metrics := &sweeperMetrics{
+		ProposalsExpired: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_proposals_expired", chain),
+			Help: "Number of Active proposals found past the bridge expiry",
+		}),
+		ProposalsCancelled: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_proposals_cancelled", chain),
+			Help: "Number of expired proposals cancelled by the relayer",
+		}),
+		RefundsRequested: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_refunds_requested", chain),
+			Help: "Number of deposits of cancelled proposals sent back to the source chain for a refund",
+		}),
+	}
+
+	prometheus.MustRegister(metrics.ProposalsExpired)
+	prometheus.MustRegister(metrics.ProposalsCancelled)
+	prometheus.MustRegister(metrics.RefundsRequested)
+
+	return metrics
+}
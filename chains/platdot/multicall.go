@@ -0,0 +1,200 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/bindings/Bridge"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+// Time between two batches of queued votes
+var VoteBatchInterval = time.Second * 5
+
+// MulticallABI is the tryAggregate method of the Multicall2 contract
+const MulticallABI = `[{"inputs":[{"internalType":"bool","name":"requireSuccess","type":"bool"},{"components":[{"internalType":"address","name":"target","type":"address"},{"internalType":"bytes","name":"callData","type":"bytes"}],"internalType":"struct Multicall2.Call[]","name":"calls","type":"tuple[]"}],"name":"tryAggregate","outputs":[{"components":[{"internalType":"bool","name":"success","type":"bool"},{"internalType":"bytes","name":"returnData","type":"bytes"}],"internalType":"struct Multicall2.Result[]","name":"returnData","type":"tuple[]"}],"stateMutability":"nonpayable","type":"function"}]`
+
+// multicallCall is a single call of a tryAggregate
+type multicallCall struct {
+	Target   common.Address
+	CallData []byte
+}
+
+// multicallResult is the result of a single call of a tryAggregate
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// multicall batches reads of the bridge through a Multicall2 compatible contract. It is only ever called
+// with eth_call: tryAggregate is public, so a contract registered as relayer would let anyone vote with it.
+// Transactions are always sent from the relayer key.
+type multicall struct {
+	address   common.Address
+	bridge    common.Address
+	contract  *bind.BoundContract
+	bridgeAbi abi.ABI
+}
+
+func newMulticall(address, bridge common.Address, caller bind.ContractCaller) (*multicall, error) {
+	parsed, err := abi.JSON(strings.NewReader(MulticallABI))
+	if err != nil {
+		return nil, err
+	}
+	bridgeAbi, err := abi.JSON(strings.NewReader(Bridge.BridgeABI))
+	if err != nil {
+		return nil, err
+	}
+	return &multicall{
+		address:   address,
+		bridge:    bridge,
+		contract:  bind.NewBoundContract(address, parsed, caller, nil, nil),
+		bridgeAbi: bridgeAbi,
+	}, nil
+}
+
+// bridgeCall encodes a call of a bridge method
+func (m *multicall) bridgeCall(method string, args ...interface{}) (multicallCall, error) {
+	data, err := m.bridgeAbi.Pack(method, args...)
+	if err != nil {
+		return multicallCall{}, err
+	}
+	return multicallCall{Target: m.bridge, CallData: data}, nil
+}
+
+// tryAggregate makes calls with a single eth_call, a failing call fails them all
+func (m *multicall) tryAggregate(opts *bind.CallOpts, calls []multicallCall) ([]multicallResult, error) {
+	var out []interface{}
+	err := m.contract.Call(opts, &out, "tryAggregate", true, calls)
+	if err != nil {
+		return nil, err
+	}
+	results := *abi.ConvertType(out[0], new([]multicallResult)).(*[]multicallResult)
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("multicall returned %d results for %d calls", len(results), len(calls))
+	}
+	return results, nil
+}
+
+// votesNeeded reports for each vote whether voter still has to cast it, that is whether its proposal is
+// neither complete nor voted on by voter. Every proposal and vote is read in a single call.
+func (m *multicall) votesNeeded(opts *bind.CallOpts, voter common.Address, votes []vote) ([]bool, error) {
+	calls := make([]multicallCall, 0, 2*len(votes))
+	for _, v := range votes {
+		prop, err := m.bridgeCall("getProposal", uint8(v.m.Source), uint64(v.m.DepositNonce), v.dataHash)
+		if err != nil {
+			return nil, err
+		}
+		voted, err := m.bridgeCall("_hasVotedOnProposal", utils.IDAndNonce(v.m.Source, v.m.DepositNonce), v.dataHash, voter)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, prop, voted)
+	}
+	results, err := m.tryAggregate(opts, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	needed := make([]bool, len(votes))
+	for i := range votes {
+		out, err := m.bridgeAbi.Unpack("getProposal", results[2*i].ReturnData)
+		if err != nil {
+			return nil, err
+		}
+		prop := *abi.ConvertType(out[0], new(Bridge.BridgeProposal)).(*Bridge.BridgeProposal)
+		out, err = m.bridgeAbi.Unpack("_hasVotedOnProposal", results[2*i+1].ReturnData)
+		if err != nil {
+			return nil, err
+		}
+		voted := *abi.ConvertType(out[0], new(bool)).(*bool)
+		needed[i] = !voted && prop.Status != PassedStatus && prop.Status != TransferredStatus && prop.Status != CancelledStatus
+	}
+	return needed, nil
+}
+
+// vote is a vote proposal waiting for the next batch
+type vote struct {
+	m        msg.Message
+	dataHash [32]byte
+}
+
+// queueVote adds v to the next batch, which is submitted early once full
+func (w *writer) queueVote(v vote) {
+	w.voteLock.Lock()
+	w.votes = append(w.votes, v)
+	full := len(w.votes) >= w.cfg.voteBatchSize
+	w.voteLock.Unlock()
+
+	if full {
+		select {
+		case w.voteReady <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// nextVotes removes and returns up to voteBatchSize queued votes
+func (w *writer) nextVotes() []vote {
+	w.voteLock.Lock()
+	defer w.voteLock.Unlock()
+
+	n := len(w.votes)
+	if n > w.cfg.voteBatchSize {
+		n = w.cfg.voteBatchSize
+	}
+	votes := make([]vote, n)
+	copy(votes, w.votes)
+	w.votes = w.votes[n:]
+	return votes
+}
+
+// batchVotes submits the queued votes every VoteBatchInterval or once a batch is full
+func (w *writer) batchVotes() {
+	ticker := time.NewTicker(VoteBatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		case <-w.voteReady:
+		}
+
+		for votes := w.nextVotes(); len(votes) != 0; votes = w.nextVotes() {
+			w.submitVotes(votes)
+		}
+	}
+}
+
+// submitVotes sends the votes still needed from the relayer key, they are checked with a single multicall
+func (w *writer) submitVotes(votes []vote) {
+	needed, err := w.multicall.votesNeeded(w.conn.CallOpts(), w.conn.Opts().From, votes)
+	if err != nil {
+		w.log.Warn("Failed to check votes with multicall, checking them one by one", "votes", len(votes), "err", err)
+		needed = make([]bool, len(votes))
+		for i, v := range votes {
+			needed[i] = !w.voteCounted(v.m, v.dataHash)
+		}
+	}
+
+	for i, v := range votes {
+		if !needed[i] {
+			w.log.Info("Vote no longer needed, not voting", "src", v.m.Source, "nonce", v.m.DepositNonce)
+			continue
+		}
+		tx, ok := w.sendVote(v.m, v.dataHash)
+		if ok {
+			go w.confirmVote(v.m, v.dataHash, tx)
+		}
+	}
+}
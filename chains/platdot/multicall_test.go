@@ -0,0 +1,138 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/bindings/Bridge"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+// fakeBridgeCaller answers the eth_calls of tryAggregate with the proposals and votes of a bridge
+type fakeBridgeCaller struct {
+	t         *testing.T
+	mc        abi.ABI
+	bridge    abi.ABI
+	proposals map[uint64]uint8 // Status of the proposal of a deposit nonce
+	voted     map[uint64]bool  // Whether the voter voted on the proposal of a deposit nonce
+	voter     common.Address
+}
+
+func (c *fakeBridgeCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (c *fakeBridgeCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	args, err := c.mc.Methods["tryAggregate"].Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	calls := args[1].([]struct {
+		Target   common.Address `json:"target"`
+		CallData []byte         `json:"callData"`
+	})
+
+	var results []multicallResult
+	for _, bc := range calls {
+		method, err := c.bridge.MethodById(bc.CallData[:4])
+		if err != nil {
+			return nil, err
+		}
+		in, err := method.Inputs.Unpack(bc.CallData[4:])
+		if err != nil {
+			return nil, err
+		}
+		var out []byte
+		switch method.Name {
+		case "getProposal":
+			prop := Bridge.BridgeProposal{Status: c.proposals[in[1].(uint64)], YesVotes: big.NewInt(0), ProposedBlock: big.NewInt(0)}
+			out, err = method.Outputs.Pack(prop)
+		case "_hasVotedOnProposal":
+			if in[2].(common.Address) != c.voter {
+				c.t.Errorf("Got: %s Expected: %s", in[2].(common.Address).Hex(), c.voter.Hex())
+			}
+			out, err = method.Outputs.Pack(c.voted[new(big.Int).Rsh(in[0].(*big.Int), 8).Uint64()]) // The chain id is the low byte
+		default:
+			c.t.Errorf("unexpected call of %s", method.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, multicallResult{Success: true, ReturnData: out})
+	}
+	return c.mc.Methods["tryAggregate"].Outputs.Pack(results)
+}
+
+func TestVotesNeeded(t *testing.T) {
+	mcAbi, err := abi.JSON(strings.NewReader(MulticallABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridgeAbi, err := abi.JSON(strings.NewReader(Bridge.BridgeABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	caller := &fakeBridgeCaller{
+		t:         t,
+		mc:        mcAbi,
+		bridge:    bridgeAbi,
+		proposals: map[uint64]uint8{1: 1, 2: PassedStatus, 3: 1, 4: CancelledStatus},
+		voted:     map[uint64]bool{3: true},
+		voter:     AliceKp.CommonAddress(),
+	}
+	m, err := newMulticall(common.HexToAddress("0x5678"), common.HexToAddress("0x1234"), caller)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var votes []vote
+	for nonce := 0; nonce <= 4; nonce++ {
+		votes = append(votes, vote{m: msg.Message{Source: 1, DepositNonce: msg.Nonce(nonce)}, dataHash: [32]byte{byte(nonce)}})
+	}
+	needed, err := m.votesNeeded(&bind.CallOpts{}, AliceKp.CommonAddress(), votes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only inactive and active proposals the relayer has not voted on need a vote
+	expected := []bool{true, true, false, false, false}
+	if !reflect.DeepEqual(needed, expected) {
+		t.Fatalf("Got: %v Expected: %v", needed, expected)
+	}
+}
+
+func TestQueueVotes(t *testing.T) {
+	w := &writer{cfg: Config{voteBatchSize: 2}, log: TestLogger, voteReady: make(chan struct{}, 1)}
+
+	for i := 0; i < 3; i++ {
+		w.queueVote(vote{m: msg.Message{DepositNonce: msg.Nonce(i)}})
+	}
+	select {
+	case <-w.voteReady:
+	default:
+		t.Fatal("full batch not signalled")
+	}
+
+	// Votes are batched in order up to the batch size
+	votes := w.nextVotes()
+	if len(votes) != 2 || votes[0].m.DepositNonce != 0 || votes[1].m.DepositNonce != 1 {
+		t.Fatalf("unexpected votes %v", votes)
+	}
+	if votes = w.nextVotes(); len(votes) != 1 || votes[0].m.DepositNonce != 2 {
+		t.Fatalf("unexpected votes %v", votes)
+	}
+	if votes = w.nextVotes(); len(votes) != 0 {
+		t.Fatalf("Got: %d Expected: %d", len(votes), 0)
+	}
+
+}
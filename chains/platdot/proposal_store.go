@@ -0,0 +1,139 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rjman-self/platdot-utils/blockstore"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+// VotedProposal is a proposal this relayer voted on which has not been finalized yet
+type VotedProposal struct {
+	Source       msg.ChainId    `json:"source"`
+	Destination  msg.ChainId    `json:"destination"`
+	DepositNonce msg.Nonce      `json:"depositNonce"`
+	ResourceId   msg.ResourceId `json:"resourceId"`
+	Data         hexutil.Bytes  `json:"data"`
+	DataHash     [32]byte       `json:"dataHash"`
+}
+
+func newVotedProposal(m msg.Message, data []byte, dataHash [32]byte) VotedProposal {
+	return VotedProposal{
+		Source:       m.Source,
+		Destination:  m.Destination,
+		DepositNonce: m.DepositNonce,
+		ResourceId:   m.ResourceId,
+		Data:         data,
+		DataHash:     dataHash,
+	}
+}
+
+func (p VotedProposal) key() proposalKey {
+	return proposalKey{source: p.Source, nonce: p.DepositNonce, dataHash: p.DataHash}
+}
+
+// message returns the fields of the original message needed to execute the proposal
+func (p VotedProposal) message() msg.Message {
+	return msg.Message{
+		Source:       p.Source,
+		Destination:  p.Destination,
+		DepositNonce: p.DepositNonce,
+		ResourceId:   p.ResourceId,
+	}
+}
+
+// proposalStore persists the proposals voted on, so their execution is resumed after a restart
+type proposalStore struct {
+	path      string
+	proposals []VotedProposal
+	lock      sync.Mutex
+}
+
+// newProposalStore loads the voted proposals for the chain/relayer pair, they are stored next to the blockstore.
+// Passing an empty string for path will cause it to use the home directory.
+func newProposalStore(path string, chain msg.ChainId, relayer string) (*proposalStore, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, blockstore.PathPostfix)
+	}
+
+	s := &proposalStore{path: filepath.Join(path, fmt.Sprintf("%s-%d.proposals", relayer, chain))}
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &s.proposals)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse voted proposals %s: %w", s.path, err)
+	}
+	return s, nil
+}
+
+// add stores a proposal, a proposal already stored is not added again
+func (s *proposalStore) add(p VotedProposal) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, stored := range s.proposals {
+		if stored.key() == p.key() {
+			return nil
+		}
+	}
+	s.proposals = append(s.proposals, p)
+	return s.save()
+}
+
+// list returns a copy of all stored proposals
+func (s *proposalStore) list() []VotedProposal {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	proposals := make([]VotedProposal, len(s.proposals))
+	copy(proposals, s.proposals)
+	return proposals
+}
+
+// remove deletes a finalized proposal
+func (s *proposalStore) remove(key proposalKey) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, stored := range s.proposals {
+		if stored.key() == key {
+			s.proposals = append(s.proposals[:i], s.proposals[i+1:]...)
+			return s.save()
+		}
+	}
+	return nil
+}
+
+func (s *proposalStore) save() error {
+	if _, err := os.Stat(filepath.Dir(s.path)); os.IsNotExist(err) {
+		err = os.MkdirAll(filepath.Dir(s.path), os.ModePerm)
+		if err != nil {
+			return err
+		}
+	}
+
+	raw, err := json.MarshalIndent(s.proposals, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, raw, 0600)
+}
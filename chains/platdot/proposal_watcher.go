@@ -0,0 +1,163 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ChainSafe/log15"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/Platdot/bindings/Bridge"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+// proposalKey identifies a proposal of the bridge
+type proposalKey struct {
+	source   msg.ChainId
+	nonce    msg.Nonce
+	dataHash [32]byte
+}
+
+// proposalWatcher polls the ProposalEvent logs of the bridge and notifies the waiters of a proposal
+// once it leaves the Active status. A single watcher serves all proposals of the chain.
+type proposalWatcher struct {
+	conn     Connection
+	bridge   *Bridge.Bridge
+	contract ethcommon.Address
+	log      log15.Logger
+	stop     <-chan int
+	sysErr   chan<- error
+	waiters  map[proposalKey][]chan uint8
+	lock     sync.Mutex
+}
+
+func newProposalWatcher(conn Connection, bridge *Bridge.Bridge, contract ethcommon.Address, log log15.Logger, stop <-chan int, sysErr chan<- error) *proposalWatcher {
+	return &proposalWatcher{
+		conn:     conn,
+		bridge:   bridge,
+		contract: contract,
+		log:      log,
+		stop:     stop,
+		sysErr:   sysErr,
+		waiters:  make(map[proposalKey][]chan uint8),
+	}
+}
+
+// start watches the proposal events from the latest block
+func (p *proposalWatcher) start() error {
+	latest, err := p.conn.LatestBlock()
+	if err != nil {
+		return err
+	}
+	go p.watch(latest)
+	return nil
+}
+
+// wait registers a waiter for the proposal, the returned channel receives the next status of the proposal
+func (p *proposalWatcher) wait(key proposalKey) <-chan uint8 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	ch := make(chan uint8, 1)
+	p.waiters[key] = append(p.waiters[key], ch)
+	return ch
+}
+
+// cancel removes a waiter of a proposal
+func (p *proposalWatcher) cancel(key proposalKey, ch <-chan uint8) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	waiters := p.waiters[key]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(p.waiters, key)
+	} else {
+		p.waiters[key] = waiters
+	}
+}
+
+// notify sends the status of a proposal to its waiters
+func (p *proposalWatcher) notify(key proposalKey, status uint8) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, ch := range p.waiters[key] {
+		ch <- status
+	}
+	delete(p.waiters, key)
+}
+
+// watch queries the proposal events of every new block, starting at block
+func (p *proposalWatcher) watch(block *big.Int) {
+	var retry = BlockRetryLimit
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+			if retry == 0 {
+				p.log.Error("Watching proposal events failed, retries exceeded")
+				p.sysErr <- ErrFatalQuery
+				return
+			}
+
+			latest, err := p.conn.LatestBlock()
+			if err != nil {
+				p.log.Error("Unable to get latest block", "block", block, "err", err)
+				retry--
+				time.Sleep(BlockRetryInterval)
+				continue
+			}
+			if latest.Cmp(block) < 0 {
+				time.Sleep(BlockRetryInterval)
+				continue
+			}
+
+			query := buildQuery(p.contract, utils.ProposalEvent, block, latest)
+			logs, err := p.conn.Client().FilterLogs(context.Background(), query)
+			if err != nil {
+				p.log.Error("Failed to fetch proposal events", "from", block, "to", latest, "err", err)
+				retry--
+				time.Sleep(BlockRetryInterval)
+				continue
+			}
+			p.handleLogs(logs)
+
+			block = new(big.Int).Add(latest, big.NewInt(1))
+			retry = BlockRetryLimit
+		}
+	}
+}
+
+// handleLogs notifies the waiters of the proposals which are no longer Active
+func (p *proposalWatcher) handleLogs(logs []ethtypes.Log) {
+	for _, l := range logs {
+		evt, err := p.bridge.ParseProposalEvent(l)
+		if err != nil {
+			p.log.Error("Failed to parse proposal event", "tx", l.TxHash, "err", err)
+			continue
+		}
+		p.log.Trace("Proposal event", "src", evt.OriginChainID, "nonce", evt.DepositNonce, "status", evt.Status)
+
+		if utils.IsActive(evt.Status) {
+			continue
+		}
+		p.notify(proposalKey{
+			source:   msg.ChainId(evt.OriginChainID),
+			nonce:    msg.Nonce(evt.DepositNonce),
+			dataHash: evt.DataHash,
+		}, evt.Status)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/Platdot/bindings/Bridge"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+func proposalEventLog(t *testing.T, key proposalKey, status utils.ProposalStatus) ethtypes.Log {
+	parsed, err := abi.JSON(strings.NewReader(Bridge.BridgeABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parsed.Events["ProposalEvent"].Inputs.Pack(uint8(key.source), uint64(key.nonce), uint8(status), key.dataHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ethtypes.Log{Topics: []common.Hash{utils.ProposalEvent.GetTopic()}, Data: data}
+}
+
+func TestProposalWatcher(t *testing.T) {
+	bridge, err := Bridge.NewBridge(common.Address{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProposalWatcher(nil, bridge, common.Address{}, TestLogger, nil, nil)
+
+	key := proposalKey{source: 1, nonce: 10, dataHash: [32]byte{1}}
+	other := proposalKey{source: 1, nonce: 11, dataHash: [32]byte{1}}
+	passed := p.wait(key)
+	cancelled := p.wait(other)
+	p.cancel(other, cancelled)
+
+	p.handleLogs([]ethtypes.Log{
+		proposalEventLog(t, key, utils.Active),
+		proposalEventLog(t, other, utils.Passed),
+		proposalEventLog(t, key, utils.Passed),
+	})
+
+	select {
+	case status := <-passed:
+		if !utils.IsFinalized(status) {
+			t.Fatalf("Got: %d Expected: %d", status, utils.Passed)
+		}
+	default:
+		t.Fatal("waiter not notified")
+	}
+	select {
+	case <-cancelled:
+		t.Fatal("cancelled waiter notified")
+	default:
+	}
+	if len(p.waiters) != 0 {
+		t.Fatalf("Got: %d Expected: %d", len(p.waiters), 0)
+	}
+}
+
+func TestProposalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proposals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newProposalStore(dir, 1, "relayer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := msg.Message{Source: 1, Destination: 0, DepositNonce: 10, ResourceId: msg.ResourceId{1}}
+	p := newVotedProposal(m, []byte{1, 2}, [32]byte{3})
+	for i := 0; i < 2; i++ {
+		if err = s.add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.add(newVotedProposal(m, []byte{4}, [32]byte{4}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Proposals are loaded again after a restart
+	s, err = newProposalStore(dir, 1, "relayer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposals := s.list()
+	if len(proposals) != 2 || proposals[0].message().DepositNonce != m.DepositNonce || proposals[0].ResourceId != m.ResourceId || string(proposals[0].Data) != string([]byte{1, 2}) {
+		t.Fatalf("unexpected proposals %+v", proposals)
+	}
+
+	if err = s.remove(p.key()); err != nil {
+		t.Fatal(err)
+	}
+	if proposals = s.list(); len(proposals) != 1 || proposals[0].DataHash != [32]byte{4} {
+		t.Fatalf("unexpected proposals %+v", proposals)
+	}
+}
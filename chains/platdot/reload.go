@@ -0,0 +1,112 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rjman-self/Platdot/shared/screening"
+	"github.com/rjman-self/platdot-utils/core"
+)
+
+// restartOpts returns the options of next that differ from c and can not be applied by Reload.
+// The endpoint, gas options, vote batch size and deny-list are reloadable.
+func (c *Config) restartOpts(next *Config) []string {
+	var opts []string
+	changed := func(opt string, differs bool) {
+		if differs {
+			opts = append(opts, opt)
+		}
+	}
+	changed("name", c.name != next.name)
+	changed("id", c.id != next.id)
+	changed("from", c.from != next.from)
+	changed(SignerOpt, c.signer != next.signer)
+	changed(PrefixOpt, c.prefix != next.prefix)
+	changed(NetWorkIdOpt, c.networkId != next.networkId)
+	changed(BridgeOpt, c.bridgeContract != next.bridgeContract)
+	changed(Erc20HandlerOpt, c.erc20HandlerContract != next.erc20HandlerContract)
+	changed(Erc721HandlerOpt, c.erc721HandlerContract != next.erc721HandlerContract)
+	changed(GenericHandlerOpt, c.genericHandlerContract != next.genericHandlerContract)
+	changed(MulticallOpt, c.multicallContract != next.multicallContract)
+	changed(StartBlockOpt, c.startBlock.Cmp(next.startBlock) != 0)
+	changed(BlockConfirmationsOpt, c.blockConfirmations.Cmp(next.blockConfirmations) != 0)
+	return opts
+}
+
+// CheckReload checks that Reload can apply chainCfg to the running chain
+func (c *Chain) CheckReload(chainCfg *core.ChainConfig) error {
+	_, err := c.checkReload(chainCfg)
+	return err
+}
+
+func (c *Chain) checkReload(chainCfg *core.ChainConfig) (*Config, error) {
+	// parseChainConfig consumes the opts it knows
+	copied := *chainCfg
+	copied.Opts = make(map[string]string, len(chainCfg.Opts))
+	for k, v := range chainCfg.Opts {
+		copied.Opts[k] = v
+	}
+	next, err := parseChainConfig(&copied)
+	if err != nil {
+		return nil, err
+	}
+
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+	if opts := c.config.restartOpts(next); len(opts) != 0 {
+		return nil, fmt.Errorf("changing %s requires a restart", strings.Join(opts, ", "))
+	}
+	return next, nil
+}
+
+// Reload applies the endpoint, gas options, vote batch size and deny-list of chainCfg to the running
+// chain. Nothing is applied if any of them fails or another option changed.
+func (c *Chain) Reload(chainCfg *core.ChainConfig) error {
+	next, err := c.checkReload(chainCfg)
+	if err != nil {
+		return err
+	}
+
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+	current := c.config
+
+	// Everything that can fail is done before the running chain is changed
+	var denyList *screening.DenyList
+	if next.denyList != "" && next.denyList != current.denyList {
+		denyList, err = screening.NewDenyList(next.denyList, c.writer.log)
+		if err != nil {
+			return err
+		}
+	}
+	if next.endpoint != current.endpoint || next.http != current.http {
+		err = c.conn.Reconnect(next.endpoint, next.http)
+		if err != nil {
+			return fmt.Errorf("unable to connect to %s: %w", next.endpoint, err)
+		}
+	}
+
+	c.conn.SetGasOptions(next.gasLimit, next.maxGasPrice, next.gasMultiplier)
+	c.writer.setVoteBatchSize(next.voteBatchSize)
+	if next.denyList != current.denyList {
+		c.setDenyList(denyList)
+	}
+	c.config = next
+	return nil
+}
+
+// setDenyList replaces the deny-list of the listener and watches the new one, nil disables screening
+func (c *Chain) setDenyList(denyList *screening.DenyList) {
+	if c.denyStop != nil {
+		close(c.denyStop)
+		c.denyStop = nil
+	}
+	if denyList != nil {
+		c.denyStop = make(chan int)
+		go denyList.Watch(c.denyStop)
+	}
+	c.listener.setDenyList(denyList)
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rjman-self/platdot-utils/core"
+)
+
+func reloadChainConfig(opts map[string]string) *core.ChainConfig {
+	chainCfg := &core.ChainConfig{
+		Name:     "chain",
+		Id:       1,
+		Endpoint: "ws://localhost:6790",
+		From:     AliceKp.Address(),
+		Opts: map[string]string{
+			"bridge":       testBridge,
+			"erc20Handler": testBridge,
+		},
+	}
+	for k, v := range opts {
+		chainCfg.Opts[k] = v
+	}
+	return chainCfg
+}
+
+func TestRestartOpts(t *testing.T) {
+	current, err := parseChainConfig(reloadChainConfig(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Gas options, the vote batch and the deny-list are reloadable
+	next, err := parseChainConfig(reloadChainConfig(map[string]string{
+		"maxGasPrice":   "30",
+		"gasLimit":      "100",
+		"voteBatchSize": "5",
+		"denyList":      "denylist.json",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.endpoint = "ws://localhost:6791"
+	if opts := current.restartOpts(next); len(opts) != 0 {
+		t.Fatalf("Got: %v Expected: none", opts)
+	}
+
+	next, err = parseChainConfig(reloadChainConfig(map[string]string{
+		"bridge":     testMulticall,
+		"startBlock": "10",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.from = "0x1"
+	expected := []string{"from", BridgeOpt, StartBlockOpt}
+	if opts := current.restartOpts(next); !reflect.DeepEqual(opts, expected) {
+		t.Fatalf("Got: %v Expected: %v", opts, expected)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/Platdot/chains"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+)
+
+// Time between two sweeps of the voted proposals for expired ones
+var ProposalSweepInterval = time.Minute * 10
+
+// isExpired mirrors the expiry check of the bridge, a proposal expires once more than expiry blocks passed
+func isExpired(proposedBlock, latest, expiry *big.Int) bool {
+	return new(big.Int).Sub(latest, proposedBlock).Cmp(expiry) > 0
+}
+
+// sweepProposals periodically cancels the voted proposals which stayed Active past the bridge expiry.
+// Their deposits are refunded once the watcher reports them Cancelled, relayers which did not vote
+// refund them when they process the deposit.
+func (w *writer) sweepProposals() {
+	expired := make(map[proposalKey]bool)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(ProposalSweepInterval):
+		}
+
+		err := w.sweep(expired)
+		if err != nil {
+			w.log.Error("Failed to sweep stale proposals", "err", err)
+		}
+	}
+}
+
+// sweep cancels the expired proposals of the store, expired records the ones already reported
+func (w *writer) sweep(expired map[proposalKey]bool) error {
+	expiry, err := w.bridgeContract.Expiry(w.conn.CallOpts())
+	if err != nil {
+		return err
+	}
+	latest, err := w.conn.LatestBlock()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range w.store.list() {
+		prop, err := w.bridgeContract.GetProposal(w.conn.CallOpts(), uint8(p.Source), uint64(p.DepositNonce), p.DataHash)
+		if err != nil {
+			w.log.Error("Failed to check stored proposal", "src", p.Source, "nonce", p.DepositNonce, "err", err)
+			continue
+		}
+		if !utils.IsActive(prop.Status) || !isExpired(prop.ProposedBlock, latest, expiry) {
+			continue
+		}
+
+		if !expired[p.key()] {
+			expired[p.key()] = true
+			w.log.Warn("Proposal expired", "src", p.Source, "nonce", p.DepositNonce, "proposedBlock", prop.ProposedBlock, "expiry", expiry)
+			if w.sweeperMetrics != nil {
+				w.sweeperMetrics.ProposalsExpired.Inc()
+			}
+		}
+
+		canCancel, err := w.canCancel()
+		if err != nil {
+			return err
+		}
+		if !canCancel {
+			w.log.Warn("Relayer is not allowed to cancel proposals, leaving expired proposal", "src", p.Source, "nonce", p.DepositNonce)
+			continue
+		}
+		w.cancelProposal(p)
+	}
+	return nil
+}
+
+// canCancel reports whether this relayer may cancel proposals, which the bridge allows to relayers and admins
+func (w *writer) canCancel() (bool, error) {
+	voter := w.conn.Opts().From
+	isRelayer, err := w.bridgeContract.IsRelayer(w.conn.CallOpts(), voter)
+	if err != nil || isRelayer {
+		return isRelayer, err
+	}
+	role, err := w.bridgeContract.DEFAULTADMINROLE(w.conn.CallOpts())
+	if err != nil {
+		return false, err
+	}
+	return w.bridgeContract.HasRole(w.conn.CallOpts(), role, voter)
+}
+
+// cancelProposal cancels an expired proposal and confirms its receipt
+func (w *writer) cancelProposal(p VotedProposal) {
+	tx, err := w.submitTx(func(opts *bind.TransactOpts) (*ethtypes.Transaction, error) {
+		return w.bridgeContract.CancelProposal(opts, uint8(p.Source), uint64(p.DepositNonce), p.DataHash)
+	})
+	if err != nil {
+		w.log.Warn("Cancelling proposal failed", "src", p.Source, "nonce", p.DepositNonce, "err", err)
+		return
+	}
+	w.log.Info("Submitted proposal cancellation", "tx", tx.Hash(), "src", p.Source, "nonce", p.DepositNonce)
+
+	w.waitReceipt(tx)
+	prop, err := w.bridgeContract.GetProposal(w.conn.CallOpts(), uint8(p.Source), uint64(p.DepositNonce), p.DataHash)
+	if err != nil || prop.Status != CancelledStatus {
+		w.log.Warn("Proposal not cancelled, will retry", "tx", tx.Hash(), "src", p.Source, "nonce", p.DepositNonce, "err", err)
+		return
+	}
+	w.log.Info("Cancelled expired proposal", "tx", tx.Hash(), "src", p.Source, "nonce", p.DepositNonce)
+	if w.sweeperMetrics != nil {
+		w.sweeperMetrics.ProposalsCancelled.Inc()
+	}
+}
+
+// refundProposal sends the deposit of a cancelled proposal back to its source chain, which refunds it.
+// Every relayer sends it, the refund is a multisig transfer needing the approval of the relayers.
+func (w *writer) refundProposal(p VotedProposal) {
+	if len(p.Data) < 32 {
+		w.log.Error("Cancelled proposal has no amount to refund", "src", p.Source, "nonce", p.DepositNonce)
+		w.finishProposal(p)
+		return
+	}
+	amount := new(big.Int).SetBytes(p.Data[:32])
+
+	err := w.router.Send(chains.NewRefundTransfer(w.cfg.id, p.Source, p.DepositNonce, p.ResourceId, amount))
+	if err != nil {
+		w.log.Error("Failed to route refund of cancelled proposal", "src", p.Source, "nonce", p.DepositNonce, "err", err)
+		return
+	}
+	w.log.Info("Requested refund of cancelled proposal", "src", p.Source, "nonce", p.DepositNonce, "amount", amount)
+	if w.sweeperMetrics != nil {
+		w.sweeperMetrics.RefundsRequested.Inc()
+	}
+	w.finishProposal(p)
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/rjman-self/Platdot/chains"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	ethtest "github.com/rjman-self/Platdot/shared/platdot/testing"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+type testRouter struct {
+	msgs []msg.Message
+}
+
+func (r *testRouter) Send(m msg.Message) error {
+	r.msgs = append(r.msgs, m)
+	return nil
+}
+
+// chanRouter passes the routed messages to the test, they are routed from the goroutines of the writer
+type chanRouter chan msg.Message
+
+func (r chanRouter) Send(m msg.Message) error {
+	r <- m
+	return nil
+}
+
+func TestIsExpired(t *testing.T) {
+	expiry := big.NewInt(100)
+	if isExpired(big.NewInt(10), big.NewInt(110), expiry) {
+		t.Fatal("proposal expired at the expiry block")
+	}
+	if !isExpired(big.NewInt(10), big.NewInt(111), expiry) {
+		t.Fatal("proposal not expired past the expiry block")
+	}
+}
+
+func TestRefundProposal(t *testing.T) {
+	router := &testRouter{}
+	w := &writer{cfg: Config{id: 2}, log: TestLogger, router: router}
+
+	amount := big.NewInt(1000000000)
+	m := msg.Message{Source: 1, Destination: 2, DepositNonce: 12345, ResourceId: msg.ResourceId{1}}
+	w.refundProposal(newVotedProposal(m, ConstructErc20ProposalData(amount.Bytes(), []byte("recipient")), [32]byte{1}))
+
+	if len(router.msgs) != 1 {
+		t.Fatalf("Got: %d Expected: %d", len(router.msgs), 1)
+	}
+	refund := router.msgs[0]
+	if refund.Type != chains.RefundTransfer || refund.Source != 2 || refund.Destination != 1 || refund.DepositNonce != 12345 {
+		t.Fatalf("unexpected refund %+v", refund)
+	}
+	if got := new(big.Int).SetBytes(refund.Payload[0].([]byte)); got.Cmp(amount) != 0 {
+		t.Fatalf("Got: %s Expected: %s", got, amount)
+	}
+}
+
+func TestRefundCancelledProposal(t *testing.T) {
+	sim := newTestChain(t)
+	client := newTestClient(t, sim)
+	contracts := deployTestContracts(t, client, TestChainId)
+	latestBlock := ethtest.GetLatestBlock(t, client)
+
+	errs := make(chan error)
+	var writers []*writer
+	var routers []chanRouter
+	for _, name := range []string{"bob", "charlie", "dave"} {
+		w, stop := createTestWriter(t, sim, createConfig(name, latestBlock, contracts), errs)
+		defer stop()
+		defer w.conn.Close()
+		router := make(chanRouter, 1)
+		w.setRouter(router)
+		writers = append(writers, w)
+		routers = append(routers, router)
+	}
+
+	erc20Address := ethtest.DeployMintApproveErc20(t, client, contracts.ERC20HandlerAddress, big.NewInt(100))
+	resourceId := msg.ResourceIdFromSlice(append(common.LeftPadBytes(erc20Address.Bytes(), 31), 0))
+	ethtest.RegisterResource(t, client, contracts.BridgeAddress, contracts.ERC20HandlerAddress, resourceId, erc20Address)
+	recipient := ethcrypto.PubkeyToAddress(BobKp.PrivateKey().PublicKey)
+	amount := big.NewInt(10)
+	m := msg.NewFungibleTransfer(1, 0, 0, amount, resourceId, []byte(recipient.Hex()))
+	data := ConstructErc20ProposalData(m.Payload[0].([]byte), recipient.Bytes())
+	dataHash := utils.Hash(append(contracts.ERC20HandlerAddress.Bytes(), data...))
+
+	// Only Bob votes, the proposal stays Active below the threshold of two votes
+	bob := writers[0]
+	if ok := bob.ResolveMessage(copyMessage(m)); !ok {
+		t.Fatal("Bob failed to resolve the message")
+	}
+	for !bob.hasVoted(m.Source, m.DepositNonce, dataHash) {
+		select {
+		case err := <-errs:
+			t.Fatalf("Fatal error: %s", err)
+		case <-time.After(BlockRetryInterval):
+		}
+	}
+
+	// Bob cancels the proposal once it expired
+	sim.Mine(101)
+	bob.cancelProposal(newVotedProposal(m, data, dataHash))
+	if !bob.proposalIsCancelled(m.Source, m.DepositNonce, dataHash) {
+		t.Fatal("proposal not cancelled")
+	}
+
+	// Charlie and Dave only see the cancelled proposal
+	for _, w := range writers[1:] {
+		if ok := w.ResolveMessage(copyMessage(m)); !ok {
+			t.Fatalf("%s failed to resolve the message", w.cfg.name)
+		}
+		if w.hasVoted(m.Source, m.DepositNonce, dataHash) {
+			t.Fatalf("%s voted on a cancelled proposal", w.cfg.name)
+		}
+	}
+
+	for i, router := range routers {
+		select {
+		case refund := <-router:
+			if refund.Type != chains.RefundTransfer || refund.Destination != m.Source || refund.DepositNonce != m.DepositNonce {
+				t.Fatalf("unexpected refund %+v", refund)
+			}
+			if got := new(big.Int).SetBytes(refund.Payload[0].([]byte)); got.Cmp(amount) != 0 {
+				t.Fatalf("Got: %s Expected: %s", got, amount)
+			}
+		case err := <-errs:
+			t.Fatalf("Fatal error: %s", err)
+		case <-time.After(TestTimeout):
+			t.Fatalf("%s did not refund the cancelled proposal", writers[i].cfg.name)
+		}
+	}
+}
@@ -14,12 +14,12 @@ import (
 	"github.com/rjman-self/Platdot/bindings/Bridge"
 	connection "github.com/rjman-self/Platdot/connections/platdot"
 	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/Platdot/shared/platdot/simulated"
+	"github.com/rjman-self/Platdot/shared/signer"
 	"github.com/rjman-self/platdot-utils/keystore"
 	"github.com/rjman-self/platdot-utils/msg"
 )
 
-const TestEndpoint = "ws://localhost:8545"
-
 var TestLogger = newTestLogger("test")
 var TestTimeout = time.Second * 30
 
@@ -35,7 +35,6 @@ func createConfig(name string, startBlock *big.Int, contracts *utils.DeployedCon
 	cfg := &Config{
 		name:                   name,
 		id:                     0,
-		endpoint:               TestEndpoint,
 		from:                   name,
 		keystorePath:           "",
 		blockstorePath:         "",
@@ -66,9 +65,29 @@ func newTestLogger(name string) log15.Logger {
 	return tLog
 }
 
-func newLocalConnection(t *testing.T, cfg *Config) *connection.Connection {
+func init() {
+	// The simulated chain mines a block for every tx, polling is shortened to keep up with it
+	BlockRetryInterval = time.Millisecond * 50
+}
+
+// newTestChain starts a simulated chain with the accounts of the test keyring
+func newTestChain(t *testing.T) *simulated.Backend {
+	sim := simulated.New()
+	t.Cleanup(sim.Close)
+	return sim
+}
+
+func newTestClient(t *testing.T, sim *simulated.Backend) *utils.Client {
+	client, err := utils.NewClientWithChain(sim, AliceKp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func newLocalConnection(t *testing.T, sim *simulated.Backend, cfg *Config) *connection.Connection {
 	kp := keystore.TestKeyRing.EthereumKeys[cfg.from]
-	conn := connection.NewConnection(TestEndpoint, false, kp, TestLogger, big.NewInt(DefaultGasLimit), big.NewInt(DefaultGasPrice), big.NewFloat(DefaultGasMultiplier))
+	conn := connection.NewConnectionWithClient(sim, signer.NewEthereum(kp), TestLogger, big.NewInt(DefaultGasLimit), big.NewInt(DefaultGasPrice), big.NewFloat(DefaultGasMultiplier))
 	err := conn.Connect()
 	if err != nil {
 		t.Fatal(err)
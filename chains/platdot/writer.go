@@ -4,11 +4,14 @@
 package platdot
 
 import (
+	"sync"
+
 	"github.com/rjman-self/platdot-utils/core"
 	metrics "github.com/rjman-self/platdot-utils/metrics/types"
 	"github.com/rjman-self/platdot-utils/msg"
 	"github.com/ChainSafe/log15"
 	"github.com/rjman-self/Platdot/bindings/Bridge"
+	"github.com/rjman-self/Platdot/chains"
 )
 
 var _ core.Writer = &writer{}
@@ -25,22 +28,42 @@ type writer struct {
 	stop           <-chan int
 	sysErr         chan<- error // Reports fatal error to core
 	metrics        *metrics.ChainMetrics
+	multicall      *multicall    // Batches the checks of votes when set
+	votes          []vote        // Votes waiting for the next batch
+	voteLock       sync.Mutex    // Guards votes
+	voteReady      chan struct{} // Signals a full batch of votes
+	proposals      *proposalWatcher
+	store          *proposalStore // Voted proposals, resumed at start
+	router         chains.Router  // Routes refunds of cancelled proposals
+	sweeperMetrics *sweeperMetrics
 }
 
 // NewWriter creates and returns writer
 func NewWriter(conn Connection, cfg *Config, log log15.Logger, stop <-chan int, sysErr chan<- error, m *metrics.ChainMetrics) *writer {
 	return &writer{
-		cfg:     *cfg,
-		conn:    conn,
-		log:     log,
-		stop:    stop,
-		sysErr:  sysErr,
-		metrics: m,
+		cfg:       *cfg,
+		conn:      conn,
+		log:       log,
+		stop:      stop,
+		sysErr:    sysErr,
+		metrics:   m,
+		voteReady: make(chan struct{}, 1),
 	}
 }
 
 func (w *writer) start() error {
 	w.log.Debug("Starting Alaya writer...")
+	err := w.proposals.start()
+	if err != nil {
+		return err
+	}
+	if w.store != nil {
+		go w.resumeProposals()
+		go w.sweepProposals()
+	}
+	if w.multicall != nil {
+		go w.batchVotes()
+	}
 	return nil
 }
 
@@ -49,6 +72,34 @@ func (w *writer) setContract(bridge *Bridge.Bridge) {
 	w.bridgeContract = bridge
 }
 
+// setProposalWatcher adds the proposal event watcher and the store of voted proposals to the writer
+func (w *writer) setProposalWatcher(p *proposalWatcher, s *proposalStore) {
+	w.proposals = p
+	w.store = s
+}
+
+// setRouter sets the router refunds of cancelled proposals are sent with
+func (w *writer) setRouter(r chains.Router) {
+	w.router = r
+}
+
+// setSweeperMetrics adds the metrics of the stale proposal sweeper
+func (w *writer) setSweeperMetrics(m *sweeperMetrics) {
+	w.sweeperMetrics = m
+}
+
+// setMulticall makes the writer batch votes, checking them through the multicall contract
+func (w *writer) setMulticall(m *multicall) {
+	w.multicall = m
+}
+
+// setVoteBatchSize replaces the maximum number of votes in a batch
+func (w *writer) setVoteBatchSize(size int) {
+	w.voteLock.Lock()
+	w.cfg.voteBatchSize = size
+	w.voteLock.Unlock()
+}
+
 // ResolveMessage handles any given message based on type
 // A bool is returned to indicate failure/success, this should be ignored except for within tests.
 func (w *writer) ResolveMessage(m msg.Message) bool {
@@ -6,24 +6,24 @@ package platdot
 import (
 	"context"
 	"errors"
-	"github.com/ethereum/go-ethereum/common"
-	"math/big"
 	"time"
 
-	log "github.com/ChainSafe/log15"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/Platdot/shared/bech32"
 	utils "github.com/rjman-self/Platdot/shared/platdot"
 	"github.com/rjman-self/platdot-utils/msg"
 )
 
-// Number of blocks to wait for an finalization event
-const ExecuteBlockWatchLimit = 100
-
 // Time between retrying a failed tx
 const TxRetryInterval = time.Second * 2
 
 // Maximum number of tx retries before exiting
 const TxRetryLimit = 10
 
+// Time to wait for a submitted tx to be mined
+var TxReceiptTimeout = time.Minute * 5
+
 var ErrNonceTooLow = errors.New("nonce too low")
 var ErrTxUnderpriced = errors.New("replacement transaction underpriced")
 var ErrFatalTx = errors.New("submission of transaction failed")
@@ -52,12 +52,21 @@ func (w *writer) proposalIsFinalized(srcId msg.ChainId, nonce msg.Nonce, dataHas
 }
 
 func (w *writer) proposalIsPassed(srcId msg.ChainId, nonce msg.Nonce, dataHash [32]byte) bool {
+	return w.proposalHasStatus(srcId, nonce, dataHash, PassedStatus)
+}
+
+// proposalIsCancelled returns true if the proposal was cancelled, whether or not this relayer voted on it
+func (w *writer) proposalIsCancelled(srcId msg.ChainId, nonce msg.Nonce, dataHash [32]byte) bool {
+	return w.proposalHasStatus(srcId, nonce, dataHash, CancelledStatus)
+}
+
+func (w *writer) proposalHasStatus(srcId msg.ChainId, nonce msg.Nonce, dataHash [32]byte, status uint8) bool {
 	prop, err := w.bridgeContract.GetProposal(w.conn.CallOpts(), uint8(srcId), uint64(nonce), dataHash)
 	if err != nil {
 		w.log.Error("Failed to check proposal existence", "err", err)
 		return false
 	}
-	return prop.Status == PassedStatus
+	return prop.Status == status
 }
 
 // hasVoted checks if this relayer has already voted
@@ -72,7 +81,7 @@ func (w *writer) hasVoted(srcId msg.ChainId, nonce msg.Nonce, dataHash [32]byte)
 }
 
 func (w *writer) shouldVote(m msg.Message, dataHash [32]byte) bool {
-	// Check if proposal has passed and skip if Passed or Transferred
+	// Check if proposal has passed and skip if Passed, Transferred or Cancelled
 	if w.proposalIsComplete(m.Source, m.DepositNonce, dataHash) {
 		w.log.Info("Proposal complete, not voting", "src", m.Source, "nonce", m.DepositNonce)
 		return false
@@ -92,9 +101,12 @@ func (w *writer) shouldVote(m msg.Message, dataHash [32]byte) bool {
 func (w *writer) createErc20Proposal(m msg.Message) bool {
 	w.log.Info("Creating erc20 proposal", "src", m.Source, "nonce", m.DepositNonce)
 
-	atp := string(m.Payload[1].([]byte))
-	eth, _ := common.PlatonToEth(atp)
-	m.Payload[1] = eth
+	recipient, err := bech32.ParseAddress(string(m.Payload[1].([]byte)), w.cfg.prefix)
+	if err != nil {
+		w.log.Error("Invalid recipient, not creating proposal", "src", m.Source, "nonce", m.DepositNonce, "err", err)
+		return false
+	}
+	m.Payload[1] = recipient.Bytes()
 
 	data := ConstructErc20ProposalData(m.Payload[0].([]byte), m.Payload[1].([]byte))
 	dataHash := utils.Hash(append(w.cfg.erc20HandlerContract.Bytes(), data...))
@@ -104,20 +116,17 @@ func (w *writer) createErc20Proposal(m msg.Message) bool {
 			// Execute if proposal passed
 			w.executeProposal(m, data, dataHash)
 			return true
+		} else if w.proposalIsCancelled(m.Source, m.DepositNonce, dataHash) {
+			// Every relayer approves the refund on the source chain, voters or not
+			w.refundProposal(newVotedProposal(m, data, dataHash))
+			return true
 		} else {
 			return false
 		}
 	}
 
-	// Capture latest block so when know where to watch from
-	latestBlock, err := w.conn.LatestBlock()
-	if err != nil {
-		w.log.Error("Unable to fetch latest block", "err", err)
-		return false
-	}
-
 	// Watch for execution event
-	go w.watchThenExecute(m, data, dataHash, latestBlock)
+	w.watchProposal(m, data, dataHash)
 
 	w.voteProposal(m, dataHash)
 
@@ -142,15 +151,8 @@ func (w *writer) createErc721Proposal(m msg.Message) bool {
 		}
 	}
 
-	// Capture latest block so we know where to watch from
-	latestBlock, err := w.conn.LatestBlock()
-	if err != nil {
-		w.log.Error("Unable to fetch latest block", "err", err)
-		return false
-	}
-
-	// watch for execution event
-	go w.watchThenExecute(m, data, dataHash, latestBlock)
+	// Watch for execution event
+	w.watchProposal(m, data, dataHash)
 
 	w.voteProposal(m, dataHash)
 
@@ -177,108 +179,131 @@ func (w *writer) createGenericDepositProposal(m msg.Message) bool {
 		}
 	}
 
-	// Capture latest block so when know where to watch from
-	latestBlock, err := w.conn.LatestBlock()
-	if err != nil {
-		w.log.Error("Unable to fetch latest block", "err", err)
-		return false
-	}
-
 	// Watch for execution event
-	go w.watchThenExecute(m, data, dataHash, latestBlock)
+	w.watchProposal(m, data, dataHash)
 
 	w.voteProposal(m, dataHash)
 
 	return true
 }
 
-// watchThenExecute watches for the latest block and executes once the matching finalized event is found
-func (w *writer) watchThenExecute(m msg.Message, data []byte, dataHash [32]byte, latestBlock *big.Int) {
+// watchProposal stores the proposal and executes it once the watcher reports it passed.
+// The waiter is registered before voting, so a vote passing the proposal is always seen.
+func (w *writer) watchProposal(m msg.Message, data []byte, dataHash [32]byte) {
+	p := newVotedProposal(m, data, dataHash)
+	if w.store != nil {
+		err := w.store.add(p)
+		if err != nil {
+			w.log.Error("Failed to store voted proposal", "src", m.Source, "nonce", m.DepositNonce, "err", err)
+		}
+	}
+	go w.waitThenExecute(p, w.proposals.wait(p.key()))
+}
+
+// waitThenExecute waits for the status of the proposal, executes it once it passed and refunds it if it is cancelled
+func (w *writer) waitThenExecute(p VotedProposal, status <-chan uint8) {
+	m := p.message()
 	w.log.Info("Watching for finalization event", "src", m.Source, "nonce", m.DepositNonce)
 
-	// Watching for the latest block, querying and matching the finalized event will be retried up to ExecuteBlockWatchLimit times
-	for i := 0; i < ExecuteBlockWatchLimit; i++ {
+	// The proposal may have been finalized before the waiter was registered
+	var s uint8
+	prop, err := w.bridgeContract.GetProposal(w.conn.CallOpts(), uint8(m.Source), uint64(m.DepositNonce), p.DataHash)
+	if err == nil && prop.Status >= PassedStatus {
+		w.proposals.cancel(p.key(), status)
+		s = prop.Status
+	} else {
 		select {
 		case <-w.stop:
+			w.proposals.cancel(p.key(), status)
 			return
-		default:
-			// Watch for the lastest block, retry up to BlockRetryLimit times
-			for waitRetrys := 0; waitRetrys < BlockRetryLimit; waitRetrys++ {
-				err := w.conn.WaitForBlock(latestBlock, big.NewInt(1))
-				if err != nil {
-					w.log.Error("Waiting for block failed", "err", err)
-					// Exit if retries exceeded
-					if waitRetrys+1 == BlockRetryLimit {
-						w.log.Error("Waiting for block retries exceeded, shutting down")
-						w.sysErr <- ErrFatalQuery
-						return
-					}
-				} else {
-					break
-				}
-			}
+		case s = <-status:
+		}
+	}
 
-			// query for logs
-			query := buildQuery(w.cfg.bridgeContract, utils.ProposalEvent, latestBlock, latestBlock)
-			evts, err := w.conn.Client().FilterLogs(context.Background(), query)
-			if err != nil {
-				w.log.Error("Failed to fetch logs", "err", err)
-				return
-			}
+	switch s {
+	case PassedStatus:
+		w.executeProposal(m, p.Data, p.DataHash)
+		if w.proposalIsFinalized(m.Source, m.DepositNonce, p.DataHash) {
+			w.finishProposal(p)
+		}
+	case CancelledStatus:
+		w.log.Warn("Proposal cancelled", "src", m.Source, "nonce", m.DepositNonce)
+		w.refundProposal(p)
+	default:
+		w.log.Info("Proposal executed by another relayer", "src", m.Source, "nonce", m.DepositNonce, "status", s)
+		w.finishProposal(p)
+	}
+}
 
-			// Execute the proposal once we find the matching finalized event
-			for _, evt := range evts {
-				sourceId := common.BytesToHash(evt.Data[:32]).Big().Uint64()
-				depositNonce := common.BytesToHash(evt.Data[33:64]).Big().Uint64()
-				status := common.BytesToHash(evt.Data[65:96]).Big().Uint64()
-				log.Info("Proposal log", "sourceID", sourceId, "depositNonce", depositNonce, "status", status)
-
-				if m.Source == msg.ChainId(sourceId) &&
-					m.DepositNonce.Big().Uint64() == depositNonce &&
-					utils.IsFinalized(uint8(status)) {
-					w.executeProposal(m, data, dataHash)
-					return
-				} else {
-					w.log.Trace("Ignoring event", "src", sourceId, "nonce", depositNonce)
-				}
-			}
-			w.log.Trace("No finalization event found in current block", "block", latestBlock, "src", m.Source, "nonce", m.DepositNonce)
-			latestBlock = latestBlock.Add(latestBlock, big.NewInt(1))
+// finishProposal removes a finalized proposal from the store
+func (w *writer) finishProposal(p VotedProposal) {
+	if w.store == nil {
+		return
+	}
+	err := w.store.remove(p.key())
+	if err != nil {
+		w.log.Error("Failed to remove voted proposal", "src", p.Source, "nonce", p.DepositNonce, "err", err)
+	}
+}
+
+// resumeProposals watches the stored proposals again after a restart, the ones which passed or were
+// cancelled in the meantime are executed or refunded right away
+func (w *writer) resumeProposals() {
+	for _, p := range w.store.list() {
+		prop, err := w.bridgeContract.GetProposal(w.conn.CallOpts(), uint8(p.Source), uint64(p.DepositNonce), p.DataHash)
+		if err != nil {
+			w.log.Error("Failed to check stored proposal", "src", p.Source, "nonce", p.DepositNonce, "err", err)
+			continue
+		}
+
+		if prop.Status == TransferredStatus {
+			w.finishProposal(p)
+		} else {
+			w.log.Info("Resuming proposal", "src", p.Source, "nonce", p.DepositNonce, "status", prop.Status)
+			go w.waitThenExecute(p, w.proposals.wait(p.key()))
 		}
 	}
-	log.Warn("Block watch limit exceeded, skipping execution", "source", m.Source, "dest", m.Destination, "nonce", m.DepositNonce)
 }
 
-// voteProposal submits a vote proposal
-// a vote proposal will try to be submitted up to the TxRetryLimit times
+// voteProposal submits a vote proposal and confirms its receipt
+// votes are batched when a multicall contract is configured, it checks which votes are still needed
 func (w *writer) voteProposal(m msg.Message, dataHash [32]byte) {
+	if w.multicall != nil {
+		w.queueVote(vote{m: m, dataHash: dataHash})
+		return
+	}
+
+	tx, ok := w.sendVote(m, dataHash)
+	if ok {
+		go w.confirmVote(m, dataHash, tx)
+	}
+}
+
+// sendVote submits a vote proposal
+// a vote proposal will try to be submitted up to the TxRetryLimit times
+func (w *writer) sendVote(m msg.Message, dataHash [32]byte) (*ethtypes.Transaction, bool) {
 	for i := 0; i < TxRetryLimit; i++ {
 		select {
 		case <-w.stop:
-			return
+			return nil, false
 		default:
-			err := w.conn.LockAndUpdateOpts()
-			if err != nil {
-				w.log.Error("Failed to update tx opts", "err", err)
-				continue
-			}
-
-			tx, err := w.bridgeContract.VoteProposal(
-				w.conn.Opts(),
-				uint8(m.Source),
-				uint64(m.DepositNonce),
-				m.ResourceId,
-				dataHash,
-			)
-			w.conn.UnlockOpts()
+			tx, err := w.submitTx(func(opts *bind.TransactOpts) (*ethtypes.Transaction, error) {
+				return w.bridgeContract.VoteProposal(
+					opts,
+					uint8(m.Source),
+					uint64(m.DepositNonce),
+					m.ResourceId,
+					dataHash,
+				)
+			})
 
 			if err == nil {
-				w.log.Info("Submitted proposal vote", "tx", tx.Hash(), "src", m.Source, "depositNonce", m.DepositNonce)
+				w.log.Info("Submitted proposal vote", "tx", tx.Hash(), "src", m.Source, "depositNonce", m.DepositNonce, "nonce", tx.Nonce())
 				if w.metrics != nil {
 					w.metrics.VotesSubmitted.Inc()
 				}
-				return
-			} else if err.Error() == ErrNonceTooLow.Error() || err.Error() == ErrTxUnderpriced.Error() {
+				return tx, true
+			} else if isNonceError(err) {
 				w.log.Debug("Nonce too low, will retry")
 				time.Sleep(TxRetryInterval)
 			} else {
@@ -289,41 +314,77 @@ func (w *writer) voteProposal(m msg.Message, dataHash [32]byte) {
 			// Verify proposal is still open for voting, otherwise no need to retry
 			if w.proposalIsComplete(m.Source, m.DepositNonce, dataHash) {
 				w.log.Info("Proposal voting complete on chain", "src", m.Source, "dst", m.Destination, "nonce", m.DepositNonce)
-				return
+				return nil, false
 			}
 		}
 	}
 	w.log.Error("Submission of Vote transaction failed", "source", m.Source, "dest", m.Destination, "depositNonce", m.DepositNonce)
 	w.sysErr <- ErrFatalTx
+	return nil, false
+}
+
+// confirmVote waits for the receipt of a vote and votes again while the vote is not counted
+func (w *writer) confirmVote(m msg.Message, dataHash [32]byte, tx *ethtypes.Transaction) {
+	for i := 0; i < TxRetryLimit; i++ {
+		if w.waitReceipt(tx) || w.voteCounted(m, dataHash) {
+			return
+		}
+
+		w.log.Warn("Vote not counted, voting again", "tx", tx.Hash(), "src", m.Source, "depositNonce", m.DepositNonce)
+		var ok bool
+		if tx, ok = w.sendVote(m, dataHash); !ok {
+			return
+		}
+	}
+	w.log.Error("Vote transactions keep failing", "source", m.Source, "dest", m.Destination, "depositNonce", m.DepositNonce)
+	w.sysErr <- ErrFatalTx
+}
+
+// voteCounted returns true if the vote of this relayer is no longer needed
+func (w *writer) voteCounted(m msg.Message, dataHash [32]byte) bool {
+	return w.proposalIsComplete(m.Source, m.DepositNonce, dataHash) || w.hasVoted(m.Source, m.DepositNonce, dataHash)
 }
 
-// executeProposal executes the proposal
+// executeProposal executes the proposal and confirms its receipt
 func (w *writer) executeProposal(m msg.Message, data []byte, dataHash [32]byte) {
+	for i := 0; i < TxRetryLimit; i++ {
+		tx, ok := w.sendExecution(m, data, dataHash)
+		if !ok {
+			return
+		}
+
+		if w.waitReceipt(tx) || w.proposalIsFinalized(m.Source, m.DepositNonce, dataHash) {
+			return
+		}
+		w.log.Warn("Proposal not executed, executing again", "tx", tx.Hash(), "src", m.Source, "nonce", m.DepositNonce)
+	}
+	w.log.Error("Execute transactions keep failing", "source", m.Source, "dest", m.Destination, "depositNonce", m.DepositNonce)
+	w.sysErr <- ErrFatalTx
+}
+
+// sendExecution submits the execution of a proposal
+// an execution will try to be submitted up to the TxRetryLimit times
+func (w *writer) sendExecution(m msg.Message, data []byte, dataHash [32]byte) (*ethtypes.Transaction, bool) {
 	for i := 0; i < TxRetryLimit; i++ {
 		select {
 		case <-w.stop:
-			return
+			return nil, false
 		default:
-			err := w.conn.LockAndUpdateOpts()
-			if err != nil {
-				w.log.Error("Failed to update nonce", "err", err)
-				return
-			}
-
-			tx, err := w.bridgeContract.ExecuteProposal(
-				w.conn.Opts(),
-				uint8(m.Source),
-				uint64(m.DepositNonce),
-				data,
-				m.ResourceId,
-			)
-			w.conn.UnlockOpts()
+			tx, err := w.submitTx(func(opts *bind.TransactOpts) (*ethtypes.Transaction, error) {
+				return w.bridgeContract.ExecuteProposal(
+					opts,
+					uint8(m.Source),
+					uint64(m.DepositNonce),
+					data,
+					m.ResourceId,
+				)
+			})
 
 			if err == nil {
 				w.log.Info("Submitted proposal execution", "tx", tx.Hash(), "src", m.Source, "dst", m.Destination, "nonce", m.DepositNonce)
 				//TODO: store DepositNonce
-				return
-			} else if err.Error() == ErrNonceTooLow.Error() || err.Error() == ErrTxUnderpriced.Error() {
+				return tx, true
+			} else if isNonceError(err) {
 				w.log.Error("Nonce too low, will retry")
 				time.Sleep(TxRetryInterval)
 			} else {
@@ -335,10 +396,60 @@ func (w *writer) executeProposal(m msg.Message, data []byte, dataHash [32]byte)
 			// but there is no need to retry
 			if w.proposalIsFinalized(m.Source, m.DepositNonce, dataHash) {
 				w.log.Info("Proposal finalized on chain", "src", m.Source, "dst", m.Destination, "nonce", m.DepositNonce)
-				return
+				return nil, false
 			}
 		}
 	}
 	w.log.Error("Submission of Execute transaction failed", "source", m.Source, "dest", m.Destination, "depositNonce", m.DepositNonce)
 	w.sysErr <- ErrFatalTx
+	return nil, false
+}
+
+func isNonceError(err error) bool {
+	return err.Error() == ErrNonceTooLow.Error() || err.Error() == ErrTxUnderpriced.Error()
+}
+
+// submitTx sends the tx built by send with the locally managed nonce of the connection.
+// The nonce is queried from the chain again if the tx is rejected for its nonce.
+func (w *writer) submitTx(send func(*bind.TransactOpts) (*ethtypes.Transaction, error)) (*ethtypes.Transaction, error) {
+	err := w.conn.LockAndUpdateOpts()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := send(w.conn.Opts())
+	if err == nil {
+		w.conn.IncrementNonce()
+	}
+	w.conn.UnlockOpts()
+
+	if err != nil && isNonceError(err) {
+		w.conn.ResetNonce()
+	}
+	return tx, err
+}
+
+// waitReceipt waits until tx is mined and returns true if it succeeded. A tx not mined within
+// TxReceiptTimeout may have been dropped, so the nonce is queried from the chain again.
+func (w *writer) waitReceipt(tx *ethtypes.Transaction) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), TxReceiptTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	receipt, err := bind.WaitMined(ctx, w.conn.Client(), tx)
+	if err != nil {
+		w.log.Warn("Transaction not mined", "tx", tx.Hash(), "err", err)
+		w.conn.ResetNonce()
+		return false
+	}
+	if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+		w.log.Warn("Transaction reverted", "tx", tx.Hash(), "block", receipt.BlockNumber)
+		return false
+	}
+	return true
 }
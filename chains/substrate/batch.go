@@ -0,0 +1,116 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+)
+
+const DefaultMaxBatchSize = 10
+const DefaultBatchWindow = 12 * time.Second // Two rounds
+
+// redemption is a transfer waiting to be paid out through the multisig account
+type redemption struct {
+	dest     Dest
+	done     func(MultiSignTx) // Called once the transfer is executed
+	received time.Time
+	batch    *batch // Multisig paying out the redemption, nil until it is included in one
+}
+
+// batch is a single multisig paying out one or more redemptions. Several transfers are wrapped in a
+// Utility.batch_all, so they are paid out all together or not at all.
+type batch struct {
+	redemptions []*redemption
+	call        types.Call
+	hash        types.Hash
+}
+
+func (b *batch) nonces() []uint64 {
+	nonces := make([]uint64, len(b.redemptions))
+	for i, r := range b.redemptions {
+		nonces[i] = uint64(r.dest.DepositNonce)
+	}
+	return nonces
+}
+
+// newBatchCall creates the call paying out dests, a single transfer is not wrapped in a batch
+func newBatchCall(meta *types.Metadata, dests []Dest) (types.Call, error) {
+	calls := make([]types.Call, 0, len(dests))
+	for _, d := range dests {
+		c, err := newTransferCall(meta, d)
+		if err != nil {
+			return types.Call{}, err
+		}
+		calls = append(calls, c)
+	}
+	if len(calls) == 1 {
+		return calls[0], nil
+	}
+	return types.NewCall(meta, string(utils.UtilityBatchAll), calls)
+}
+
+// sameTransfer reports whether a and b pay the same amount to the same recipient
+func sameTransfer(a, b Dest) bool {
+	return strings.EqualFold(strings.TrimPrefix(a.DestAddress, "0x"), strings.TrimPrefix(b.DestAddress, "0x")) &&
+		a.DestAmount == b.DestAmount
+}
+
+// matchBatch pairs every transfer of a multisig with a distinct redemption not yet in a batch, in the order
+// of the transfers. It returns nil unless all transfers are matched.
+func matchBatch(transfers []Dest, pending []*redemption) []*redemption {
+	if len(transfers) == 0 {
+		return nil
+	}
+	used := make(map[*redemption]bool, len(transfers))
+	matched := make([]*redemption, 0, len(transfers))
+	for _, t := range transfers {
+		var found *redemption
+		for _, r := range pending {
+			if r.batch == nil && !used[r] && sameTransfer(r.dest, t) {
+				found = r
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		used[found] = true
+		matched = append(matched, found)
+	}
+	return matched
+}
+
+// selectBatch returns the redemptions to open a new batch with, by deposit nonce. Redemptions paying the
+// same as a transfer of another open multisig are left out, so a transfer is never part of two multisigs.
+func selectBatch(pending []*redemption, open []Dest, max int) []*redemption {
+	var selected []*redemption
+	for _, r := range pending {
+		if r.batch != nil {
+			continue
+		}
+		inFlight := false
+		for _, d := range open {
+			if sameTransfer(r.dest, d) {
+				inFlight = true
+				break
+			}
+		}
+		if !inFlight {
+			selected = append(selected, r)
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].dest.DepositNonce < selected[j].dest.DepositNonce
+	})
+	if len(selected) > max {
+		selected = selected[:max]
+	}
+	return selected
+}
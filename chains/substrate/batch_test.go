@@ -0,0 +1,127 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/log15"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+func testRedemptions(amounts ...string) []*redemption {
+	var res []*redemption
+	for i, amount := range amounts {
+		res = append(res, &redemption{
+			dest:     Dest{DepositNonce: msg.Nonce(10 - i), DestAddress: testDest.DestAddress, DestAmount: amount},
+			received: time.Now(),
+		})
+	}
+	return res
+}
+
+func TestSelectBatch(t *testing.T) {
+	pending := testRedemptions("1", "2", "3", "4")
+
+	// Redemptions are selected by deposit nonce
+	selected := selectBatch(pending, nil, 3)
+	if len(selected) != 3 || selected[0] != pending[3] || selected[2] != pending[1] {
+		t.Fatalf("unexpected batch: %+v", selected)
+	}
+
+	// A transfer of another open multisig is left out
+	open := []Dest{{DestAddress: strings.ToUpper(testDest.DestAddress[2:]), DestAmount: "4"}}
+	selected = selectBatch(pending, open, 3)
+	if len(selected) != 3 || selected[0] != pending[2] {
+		t.Fatalf("unexpected batch: %+v", selected)
+	}
+
+	// Redemptions already in a batch are left out
+	pending[3].batch = &batch{}
+	pending[2].batch = pending[3].batch
+	if selected = selectBatch(pending, nil, 3); len(selected) != 2 {
+		t.Fatalf("Got: %d Expected: %d", len(selected), 2)
+	}
+}
+
+func TestAdoptBatch(t *testing.T) {
+	meta := testCallMetadata(t)
+	others := []types.AccountID{testOther}
+
+	// Another relayer opens a batch of two transfers with as_multi
+	dests := []Dest{{DestAddress: testDest.DestAddress, DestAmount: "2"}, {DestAddress: testDest.DestAddress, DestAmount: "1"}}
+	c, err := newBatchCall(meta, dests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CallIndex != mustCallIndex(t, meta, utils.UtilityBatchAll) {
+		t.Fatal("several transfers must be wrapped in Utility.batch_all")
+	}
+	asMulti, err := newAsMultiCall(meta, 2, others, nil, c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extrinsic := types.HexEncodeToString(signedExtrinsicBytes(t, testSigner, EncodeCall(asMulti)))
+
+	hash := CallHash(c)
+	events := &utils.Events{}
+	events.Multisig_NewMultisig = []types.EventMultisigNewMultisig{{
+		Phase:    types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 1},
+		Who:      types.AccountID(testSigner),
+		ID:       types.AccountID(testMultiSign),
+		CallHash: hash,
+	}}
+	l := &listener{
+		log:           log15.Root(),
+		conn:          &Connection{meta: *testMetadata(t)},
+		multiSignAddr: testMultiSign,
+		msTxAsMulti:   make(map[types.Hash]MultiSigAsMulti),
+	}
+	l.trackMultisigs(120, events, []string{"0x00", extrinsic})
+
+	ms, ok := l.getMultisig(hash)
+	if !ok || len(ms.Transfers) != 2 || ms.Transfers[0].DestAmount != "2" || !sameTransfer(ms.Transfers[1], dests[1]) {
+		t.Fatalf("unexpected multisig: %+v", ms)
+	}
+
+	// The writer approves the batch once all its transfers are queued
+	w := &writer{listener: l, log: log15.Root()}
+	w.pending = testRedemptions("1")
+	w.adoptBatches(meta, l.multisigs())
+	if len(w.batches) != 0 {
+		t.Fatal("adopted a batch with a transfer not queued")
+	}
+
+	w.pending = testRedemptions("1", "2", "3")
+	w.adoptBatches(meta, l.multisigs())
+	if len(w.batches) != 1 || w.batches[0].hash != hash {
+		t.Fatalf("unexpected batches: %+v", w.batches)
+	}
+	b := w.batches[0]
+	if len(b.redemptions) != 2 || b.redemptions[0] != w.pending[1] || b.redemptions[1] != w.pending[0] || w.pending[2].batch != nil {
+		t.Fatalf("unexpected batch: %+v", b.redemptions)
+	}
+
+	// Once executed the batch is removed with its redemptions
+	var executed []MultiSignTx
+	for _, r := range b.redemptions {
+		r.done = func(tx MultiSignTx) { executed = append(executed, tx) }
+	}
+	w.finishBatch(b, MultiSignTx{BlockNumber: 120, MultiSignTxId: 1})
+	if len(executed) != 2 || len(w.batches) != 0 || len(w.pending) != 1 {
+		t.Fatalf("Got: %d executed %d batches %d pending", len(executed), len(w.batches), len(w.pending))
+	}
+}
+
+func mustCallIndex(t *testing.T, meta *types.Metadata, method utils.Method) types.CallIndex {
+	index, err := meta.FindCallIndex(string(method))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
@@ -0,0 +1,374 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjmand/go-substrate-rpc-client/v2/scale"
+	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+// call is a dispatchable decoded with the runtime metadata. Arguments are keyed by their metadata name,
+// nested calls are stored as *call or []*call.
+type call struct {
+	Index    types.CallIndex
+	Module   string
+	Function string
+	Args     map[string]interface{}
+	Encoded  []byte // Encoding of a call passed as OpaqueCall, as hashed by the multisig pallet
+}
+
+func (c *call) is(module string, functions ...string) bool {
+	if c.Module != module {
+		return false
+	}
+	for _, fn := range functions {
+		if c.Function == fn {
+			return true
+		}
+	}
+	return false
+}
+
+// timepoint identifies an extrinsic by block height and index
+type timepoint struct {
+	Height uint32
+	Index  uint32
+}
+
+// signedExtrinsic is an extrinsic with its signer and decoded call tree
+type signedExtrinsic struct {
+	Index  int
+	Signer types.AccountID
+	Call   *call
+}
+
+// decodeExtrinsic decodes a v4 extrinsic. Unsigned extrinsics are returned with a nil call.
+func decodeExtrinsic(meta *types.Metadata, data []byte) (*signedExtrinsic, error) {
+	decoder := scale.NewDecoder(bytes.NewReader(data))
+
+	var length types.UCompact
+	if err := decoder.Decode(&length); err != nil {
+		return nil, fmt.Errorf("decode extrinsic length: %w", err)
+	}
+	version, err := decoder.ReadOneByte()
+	if err != nil {
+		return nil, fmt.Errorf("decode extrinsic version: %w", err)
+	}
+	if version&0x7f != 4 {
+		return nil, fmt.Errorf("unsupported extrinsic version %d", version&0x7f)
+	}
+	if version&0x80 == 0 {
+		return &signedExtrinsic{}, nil
+	}
+
+	signer, err := decodeAddress(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("decode signer: %w", err)
+	}
+	sigType, err := decoder.ReadOneByte()
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	sig := make([]byte, 64)
+	if sigType == 2 {
+		// Ecdsa signatures are 65 bytes
+		sig = make([]byte, 65)
+	}
+	if err = decoder.Read(sig); err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	var era types.ExtrinsicEra
+	if err = decoder.Decode(&era); err != nil {
+		return nil, fmt.Errorf("decode era: %w", err)
+	}
+	var nonce, tip types.UCompact
+	if err = decoder.Decode(&nonce); err != nil {
+		return nil, fmt.Errorf("decode nonce: %w", err)
+	}
+	if err = decoder.Decode(&tip); err != nil {
+		return nil, fmt.Errorf("decode tip: %w", err)
+	}
+
+	c, err := decodeCall(meta, decoder)
+	if err != nil {
+		return nil, err
+	}
+	return &signedExtrinsic{Signer: signer, Call: c}, nil
+}
+
+var ErrNotMultisig = errors.New("not a multisig call")
+
+// MultisigCall is a call of the Multisig pallet decoded from a signed extrinsic
+type MultisigCall struct {
+	Signer    eventTypes.AccountID
+	Function  string // as_multi, approve_as_multi or cancel_as_multi
+	Threshold uint16
+	Others    []eventTypes.AccountID
+	TimePoint *MultiSignTx // Timepoint of the multisig, nil when the call opens it
+	CallHash  eventTypes.Hash
+	Transfers []Dest // Transfers of the call carried by as_multi
+}
+
+// DecodeMultisigCall decodes the Multisig call of a signed extrinsic, ErrNotMultisig is returned for other calls
+func DecodeMultisigCall(meta *types.Metadata, data []byte) (*MultisigCall, error) {
+	ext, err := decodeExtrinsic(meta, data)
+	if err != nil {
+		return nil, err
+	}
+	c := ext.Call
+	if c == nil || !c.is("Multisig", "as_multi", "approve_as_multi", "cancel_as_multi") {
+		return nil, ErrNotMultisig
+	}
+
+	res := &MultisigCall{Signer: eventTypes.AccountID(ext.Signer), Function: c.Function}
+	res.Threshold, _ = c.Args["threshold"].(uint16)
+	others, _ := c.Args["other_signatories"].([]types.AccountID)
+	for _, o := range others {
+		res.Others = append(res.Others, eventTypes.AccountID(o))
+	}
+	switch tp := c.Args["maybe_timepoint"].(type) {
+	case *timepoint:
+		if tp != nil {
+			res.TimePoint = &MultiSignTx{BlockNumber: BlockNumber(tp.Height), MultiSignTxId: MultiSignTxId(tp.Index)}
+		}
+	}
+	if tp, ok := c.Args["timepoint"].(timepoint); ok {
+		res.TimePoint = &MultiSignTx{BlockNumber: BlockNumber(tp.Height), MultiSignTxId: MultiSignTxId(tp.Index)}
+	}
+	if nested, ok := c.Args["call"].(*call); ok {
+		res.CallHash = blake2b.Sum256(nested.Encoded)
+		res.Transfers = multisigTransfers(c)
+	} else if hash, ok := c.Args["call_hash"].(types.Hash); ok {
+		res.CallHash = eventTypes.Hash(hash)
+	}
+	return res, nil
+}
+
+// decodeCall decodes a call index and its arguments, walking into nested calls
+func decodeCall(meta *types.Metadata, decoder *scale.Decoder) (*call, error) {
+	var index types.CallIndex
+	if err := decoder.Decode(&index); err != nil {
+		return nil, fmt.Errorf("decode call index: %w", err)
+	}
+	module, fn, err := findCall(meta, index)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &call{Index: index, Module: module, Function: string(fn.Name), Args: make(map[string]interface{}, len(fn.Args))}
+	for _, arg := range fn.Args {
+		value, err := decodeArg(meta, decoder, normalizeType(string(arg.Type)))
+		if err != nil {
+			return nil, fmt.Errorf("decode %s.%s argument %s: %w", c.Module, c.Function, arg.Name, err)
+		}
+		c.Args[string(arg.Name)] = value
+	}
+	return c, nil
+}
+
+// findCall looks up the module and function of a call index
+func findCall(meta *types.Metadata, index types.CallIndex) (string, types.FunctionMetadataV4, error) {
+	switch {
+	case meta.IsMetadataV12:
+		for _, mod := range meta.AsMetadataV12.Modules {
+			if mod.HasCalls && mod.Index == index.SectionIndex && int(index.MethodIndex) < len(mod.Calls) {
+				return string(mod.Name), mod.Calls[index.MethodIndex], nil
+			}
+		}
+	case meta.IsMetadataV11:
+		// Before V12 the section index is the position among modules with calls
+		section := uint8(0)
+		for _, mod := range meta.AsMetadataV11.Modules {
+			if !mod.HasCalls {
+				continue
+			}
+			if section == index.SectionIndex && int(index.MethodIndex) < len(mod.Calls) {
+				return string(mod.Name), mod.Calls[index.MethodIndex], nil
+			}
+			section++
+		}
+	default:
+		return "", types.FunctionMetadataV4{}, fmt.Errorf("unsupported metadata version %d", meta.Version)
+	}
+	return "", types.FunctionMetadataV4{}, fmt.Errorf("call index %d.%d not found in metadata", index.SectionIndex, index.MethodIndex)
+}
+
+// normalizeType strips the runtime trait paths of a metadata type name, eg. Box<<T as Config>::Call> becomes Call
+func normalizeType(typ string) string {
+	typ = strings.ReplaceAll(typ, " ", "")
+	typ = strings.ReplaceAll(typ, "<TasTrait>::", "")
+	typ = strings.ReplaceAll(typ, "<TasConfig>::", "")
+	typ = strings.ReplaceAll(typ, "T::", "")
+	typ = strings.ReplaceAll(typ, "<LookupasStaticLookup>::Source", "LookupSource")
+	if strings.HasPrefix(typ, "Box<") && strings.HasSuffix(typ, ">") {
+		typ = typ[len("Box<") : len(typ)-1]
+	}
+	return typ
+}
+
+func decodeArg(meta *types.Metadata, decoder *scale.Decoder, typ string) (interface{}, error) {
+	switch typ {
+	case "Call", "CallOf<T>":
+		return decodeCall(meta, decoder)
+	case "Vec<Call>", "Vec<CallOf<T>>":
+		var n types.UCompact
+		if err := decoder.Decode(&n); err != nil {
+			return nil, err
+		}
+		var calls []*call
+		for i := int64(0); i < (*big.Int)(&n).Int64(); i++ {
+			c, err := decodeCall(meta, decoder)
+			if err != nil {
+				return nil, err
+			}
+			calls = append(calls, c)
+		}
+		return calls, nil
+	case "OpaqueCall", "WrapperKeepOpaque<Call>":
+		var encoded types.Bytes
+		if err := decoder.Decode(&encoded); err != nil {
+			return nil, err
+		}
+		c, err := decodeCall(meta, scale.NewDecoder(bytes.NewReader(encoded)))
+		if err != nil {
+			return nil, err
+		}
+		c.Encoded = encoded
+		return c, nil
+	case "LookupSource", "Address", "MultiAddress":
+		return decodeAddress(decoder)
+	case "AccountId":
+		var v types.AccountID
+		err := decoder.Decode(&v)
+		return v, err
+	case "Vec<AccountId>":
+		var v []types.AccountID
+		err := decoder.Decode(&v)
+		return v, err
+	case "Compact<Balance>", "Compact<BalanceOf<T>>", "Compact<BlockNumber>", "Compact<Moment>", "Compact<u32>", "Compact<u64>", "Compact<u128>":
+		var v types.UCompact
+		err := decoder.Decode(&v)
+		return big.NewInt(0).Set((*big.Int)(&v)), err
+	case "Vec<u8>", "Bytes":
+		var v types.Bytes
+		err := decoder.Decode(&v)
+		return []byte(v), err
+	case "bool":
+		var v bool
+		err := decoder.Decode(&v)
+		return v, err
+	case "u8", "ProxyType":
+		var v uint8
+		err := decoder.Decode(&v)
+		return v, err
+	case "u16":
+		var v uint16
+		err := decoder.Decode(&v)
+		return v, err
+	case "u32", "BlockNumber", "Perbill":
+		var v uint32
+		err := decoder.Decode(&v)
+		return v, err
+	case "u64", "Weight":
+		var v uint64
+		err := decoder.Decode(&v)
+		return v, err
+	case "u128", "Balance", "BalanceOf<T>":
+		var v types.U128
+		err := decoder.Decode(&v)
+		return v.Int, err
+	case "Option<ProxyType>":
+		var v types.OptionU8
+		err := decoder.Decode(&v)
+		return v, err
+	case "Timepoint<BlockNumber>":
+		var v timepoint
+		err := decoder.Decode(&v)
+		return v, err
+	case "Option<Timepoint<BlockNumber>>":
+		var hasValue bool
+		var v timepoint
+		err := decoder.DecodeOption(&hasValue, &v)
+		if !hasValue {
+			return (*timepoint)(nil), err
+		}
+		return &v, err
+	case "[u8;32]", "CallHash", "CallHashOf<T>", "Hash":
+		var v types.Hash
+		err := decoder.Decode(&v)
+		return v, err
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+// decodeAddress decodes a MultiAddress, only account ids can be resolved without querying the chain
+func decodeAddress(decoder *scale.Decoder) (types.AccountID, error) {
+	variant, err := decoder.ReadOneByte()
+	if err != nil {
+		return types.AccountID{}, err
+	}
+	switch variant {
+	case 0:
+		var id types.AccountID
+		err = decoder.Decode(&id)
+		return id, err
+	case 1:
+		var index types.UCompact
+		err = decoder.Decode(&index)
+		return types.AccountID{}, err
+	case 2:
+		var raw types.Bytes
+		err = decoder.Decode(&raw)
+		return types.AccountID{}, err
+	case 3:
+		var raw types.H256
+		err = decoder.Decode(&raw)
+		return types.AccountID{}, err
+	case 4:
+		var raw types.H160
+		err = decoder.Decode(&raw)
+		return types.AccountID{}, err
+	}
+	return types.AccountID{}, fmt.Errorf("unsupported address variant %d", variant)
+}
+
+// MultiAccountID derives the account of a multisig wallet from its signatories and threshold
+func MultiAccountID(signatories []types.AccountID, threshold uint16) (types.AccountID, error) {
+	sorted := make([]types.AccountID, len(signatories))
+	copy(sorted, signatories)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && bytes.Compare(sorted[j][:], sorted[j-1][:]) < 0; j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+
+	who, err := types.EncodeToBytes(sorted)
+	if err != nil {
+		return types.AccountID{}, err
+	}
+	t, err := types.EncodeToBytes(threshold)
+	if err != nil {
+		return types.AccountID{}, err
+	}
+	data := append([]byte("modlpy/utilisuba"), who...)
+	return types.AccountID(blake2b.Sum256(append(data, t...))), nil
+}
+
+// derivativeAccountID derives the account used by Utility.as_derivative
+func derivativeAccountID(origin types.AccountID, index uint16) (types.AccountID, error) {
+	i, err := types.EncodeToBytes(index)
+	if err != nil {
+		return types.AccountID{}, err
+	}
+	data := append([]byte("modlpy/utilisuba"), origin[:]...)
+	return types.AccountID(blake2b.Sum256(append(data, i...))), nil
+}
@@ -0,0 +1,282 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"math/big"
+	"testing"
+
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/rjmand/go-substrate-rpc-client/v2/scale"
+	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+// rawArg is an already encoded call argument
+type rawArg []byte
+
+func (r rawArg) Encode(encoder scale.Encoder) error {
+	return encoder.Write(r)
+}
+
+var (
+	testMultiSign = types.NewAccountID(types.MustHexDecodeString("0x1111111111111111111111111111111111111111111111111111111111111111"))
+	testSigner    = types.NewAccountID(types.MustHexDecodeString("0x2222222222222222222222222222222222222222222222222222222222222222"))
+	testReal      = types.NewAccountID(types.MustHexDecodeString("0x3333333333333333333333333333333333333333333333333333333333333333"))
+)
+
+// testMetadata is the Polkadot example metadata with Utility.batch_all added
+func testMetadata(t *testing.T) *types.Metadata {
+	var meta types.Metadata
+	err := types.DecodeFromHexString(types.ExamplaryMetadataV12PolkadotString, &meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mod := range meta.AsMetadataV12.Modules {
+		if mod.Name == "Utility" {
+			batchAll := mod.Calls[0]
+			batchAll.Name = "batch_all"
+			meta.AsMetadataV12.Modules[i].Calls = append(mod.Calls, batchAll)
+		}
+	}
+	return &meta
+}
+
+func encodeCall(t *testing.T, meta *types.Metadata, name string, args ...interface{}) []byte {
+	c, err := types.NewCall(meta, name, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := types.EncodeToBytes(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func address(id types.AccountID) rawArg {
+	return append([]byte{0}, id[:]...)
+}
+
+func transferCall(t *testing.T, meta *types.Metadata, dest types.AccountID, amount uint64) []byte {
+	return encodeCall(t, meta, "Balances.transfer_keep_alive", address(dest), types.NewUCompactFromUInt(amount))
+}
+
+func remarkCall(t *testing.T, meta *types.Metadata, remark string) []byte {
+	return encodeCall(t, meta, "System.remark", []byte(remark))
+}
+
+func batchCall(t *testing.T, meta *types.Metadata, name string, calls ...[]byte) []byte {
+	arg, err := types.EncodeToBytes(types.NewUCompactFromUInt(uint64(len(calls))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range calls {
+		arg = append(arg, c...)
+	}
+	return encodeCall(t, meta, name, rawArg(arg))
+}
+
+func signedExtrinsicBytes(t *testing.T, signer types.AccountID, c []byte) []byte {
+	body := []byte{0x84}
+	body = append(body, address(signer)...)
+	body = append(body, 1)                   // Sr25519
+	body = append(body, make([]byte, 64)...) // Signature
+	body = append(body, 0, 0, 0)             // Immortal era, nonce, tip
+	body = append(body, c...)
+	length, err := types.EncodeToBytes(types.NewUCompactFromUInt(uint64(len(body))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(length, body...)
+}
+
+func decodeTestDeposits(t *testing.T, meta *types.Metadata, c []byte) []deposit {
+	ext, err := decodeExtrinsic(meta, signedExtrinsicBytes(t, testSigner, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.Signer != testSigner {
+		t.Fatalf("Got signer: %x Expected: %x", ext.Signer, testSigner)
+	}
+	return findDeposits(ext.Call, ext.Signer, testMultiSign)
+}
+
+func TestFindDepositsBatch(t *testing.T) {
+	meta := testMetadata(t)
+
+	deposits := decodeTestDeposits(t, meta, batchCall(t, meta, "Utility.batch",
+		transferCall(t, meta, testMultiSign, 1000),
+		remarkCall(t, meta, "atp1recipient"),
+	))
+	if len(deposits) != 1 {
+		t.Fatalf("Got: %d deposits Expected: %d", len(deposits), 1)
+	}
+	d := deposits[0]
+	if d.Sender != testSigner || d.Amount.Cmp(big.NewInt(1000)) != 0 || !d.HasRemark || d.Remark != "atp1recipient" {
+		t.Fatalf("unexpected deposit: %+v", d)
+	}
+
+	// A transfer to another account is not a deposit
+	deposits = decodeTestDeposits(t, meta, batchCall(t, meta, "Utility.batch_all",
+		transferCall(t, meta, testReal, 1000),
+		remarkCall(t, meta, "atp1recipient"),
+	))
+	if len(deposits) != 0 {
+		t.Fatalf("Got: %d deposits Expected: %d", len(deposits), 0)
+	}
+
+	// A plain transfer has no remark
+	deposits = decodeTestDeposits(t, meta, transferCall(t, meta, testMultiSign, 5))
+	if len(deposits) != 1 || deposits[0].HasRemark {
+		t.Fatalf("unexpected deposits: %+v", deposits)
+	}
+}
+
+func TestFindDepositsNested(t *testing.T) {
+	meta := testMetadata(t)
+
+	inner := batchCall(t, meta, "Utility.batch_all",
+		batchCall(t, meta, "Utility.batch",
+			remarkCall(t, meta, "first"),
+			transferCall(t, meta, testMultiSign, 10),
+		),
+		transferCall(t, meta, testMultiSign, 20),
+		remarkCall(t, meta, "second"),
+	)
+	proxy := encodeCall(t, meta, "Proxy.proxy", testReal, types.NewOptionU8Empty(), rawArg(inner))
+
+	deposits := decodeTestDeposits(t, meta, proxy)
+	if len(deposits) != 2 {
+		t.Fatalf("Got: %d deposits Expected: %d", len(deposits), 2)
+	}
+	expected := []struct {
+		amount int64
+		remark string
+	}{{20, "second"}, {10, "first"}}
+	for i, e := range expected {
+		d := deposits[i]
+		if d.Sender != testReal || d.Amount.Int64() != e.amount || d.Remark != e.remark {
+			t.Fatalf("unexpected deposit %d: %+v", i, d)
+		}
+	}
+}
+
+func TestFindDepositsMultisig(t *testing.T) {
+	meta := testMetadata(t)
+
+	others := []types.AccountID{testReal}
+	c := encodeCall(t, meta, "Multisig.as_multi_threshold_1", others, rawArg(transferCall(t, meta, testMultiSign, 7)))
+	deposits := decodeTestDeposits(t, meta, c)
+	if len(deposits) != 1 {
+		t.Fatalf("Got: %d deposits Expected: %d", len(deposits), 1)
+	}
+
+	wallet, err := MultiAccountID([]types.AccountID{testSigner, testReal}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposits[0].Sender != wallet {
+		t.Fatalf("Got sender: %x Expected: %x", deposits[0].Sender, wallet)
+	}
+
+	// The wallet does not depend on the order of signatories
+	reversed, err := MultiAccountID([]types.AccountID{testReal, testSigner}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reversed != wallet {
+		t.Fatal("multisig account depends on signatory order")
+	}
+}
+
+func TestLinkDeposits(t *testing.T) {
+	meta := testMetadata(t)
+	phase := eventTypes.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 1}
+	transfer := func(amount int64) eventTypes.EventBalancesTransfer {
+		return eventTypes.EventBalancesTransfer{
+			Phase: phase,
+			From:  eventTypes.AccountID(testSigner),
+			To:    eventTypes.AccountID(testMultiSign),
+			Value: eventTypes.NewU128(*big.NewInt(amount)),
+		}
+	}
+
+	// Only the second transfer of the batch has a Transfer event
+	events := &utils.Events{}
+	events.System_ExtrinsicSuccess = []eventTypes.EventSystemExtrinsicSuccess{{Phase: phase}}
+	events.Balances_Transfer = []eventTypes.EventBalancesTransfer{transfer(20)}
+	events.System_Remarked = []utils.EventSystemRemarked{{
+		Phase:  phase,
+		Sender: eventTypes.AccountID(testSigner),
+		Hash:   blake2b.Sum256([]byte("unpaired")),
+	}}
+
+	grouped := groupEvents(events, testMultiSign)
+	if len(grouped) != 1 || grouped[1] == nil {
+		t.Fatalf("unexpected grouped events: %+v", grouped)
+	}
+
+	c := batchCall(t, meta, "Utility.batch",
+		transferCall(t, meta, testMultiSign, 10),
+		remarkCall(t, meta, "first"),
+		transferCall(t, meta, testMultiSign, 20),
+		remarkCall(t, meta, "second"),
+	)
+	ext, err := decodeExtrinsic(meta, signedExtrinsicBytes(t, testSigner, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposits := linkDeposits(grouped[1], ext, testMultiSign)
+	if len(deposits) != 1 || deposits[0].Amount.Int64() != 20 || deposits[0].Remark != "second" {
+		t.Fatalf("unexpected deposits: %+v", deposits)
+	}
+
+	// A transfer without a remark in its batch takes the remark of a Remarked event
+	c = batchCall(t, meta, "Utility.batch",
+		transferCall(t, meta, testMultiSign, 20),
+		batchCall(t, meta, "Utility.batch", remarkCall(t, meta, "unpaired")),
+	)
+	ext, err = decodeExtrinsic(meta, signedExtrinsicBytes(t, testSigner, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposits = linkDeposits(grouped[1], ext, testMultiSign)
+	if len(deposits) != 1 || !deposits[0].HasRemark || deposits[0].Remark != "unpaired" {
+		t.Fatalf("unexpected deposits: %+v", deposits)
+	}
+
+	// Without the extrinsic the transfer is still found, without a remark
+	deposits = linkDeposits(grouped[1], nil, testMultiSign)
+	if len(deposits) != 1 || deposits[0].HasRemark {
+		t.Fatalf("unexpected deposits: %+v", deposits)
+	}
+
+	// Transfers of failed extrinsics are never deposits
+	events.System_ExtrinsicSuccess = nil
+	events.System_ExtrinsicFailed = []eventTypes.EventSystemExtrinsicFailed{{Phase: phase}}
+	if grouped = groupEvents(events, testMultiSign); len(grouped) != 0 {
+		t.Fatalf("Got: %d Expected: %d", len(grouped), 0)
+	}
+}
+
+func TestDepositNonce(t *testing.T) {
+	testCases := []struct {
+		block     BlockNumber
+		extrinsic int
+		expected  msg.Nonce
+	}{
+		{block: 7424375, extrinsic: 2, expected: 74243752},
+		{block: 7424375, extrinsic: 12, expected: 742437512},
+		{block: 1, extrinsic: 0, expected: 10},
+	}
+	for _, tc := range testCases {
+		nonce := DepositNonce(tc.block, tc.extrinsic)
+		if nonce != tc.expected {
+			t.Fatalf("Got: %d Expected: %d", nonce, tc.expected)
+		}
+	}
+}
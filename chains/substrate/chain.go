@@ -26,9 +26,9 @@ package substrate
 import (
 	"fmt"
 	"github.com/ChainSafe/log15"
-	"github.com/JFJun/go-substrate-crypto/ss58"
 	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
-	"github.com/rjman-self/go-polkadot-rpc-client/client"
+	"github.com/rjman-self/Platdot/shared/screening"
+	"github.com/rjman-self/Platdot/shared/signer"
 	"github.com/rjman-self/platdot-utils/blockstore"
 	"github.com/rjman-self/platdot-utils/core"
 	"github.com/rjman-self/platdot-utils/crypto/sr25519"
@@ -36,16 +36,20 @@ import (
 	metrics "github.com/rjman-self/platdot-utils/metrics/types"
 	"github.com/rjman-self/platdot-utils/msg"
 	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+	"sync"
 )
 
 var _ core.Chain = &Chain{}
 
 type Chain struct {
-	cfg      *core.ChainConfig // The config of the chain
-	conn     *Connection       // THe chains connection
-	listener *listener         // The listener of this chain
-	writer   *writer           // The writer of the chain
-	stop     chan<- int
+	cfg        *core.ChainConfig // The config of the chain
+	conn       *Connection       // THe chains connection
+	listener   *listener         // The listener of this chain
+	writer     *writer           // The writer of the chain
+	stop       chan<- int
+	config     *Config    // Parsed config, replaced by Reload
+	reloadLock sync.Mutex // Guards config and denyStop
+	denyStop   chan int   // Stops watching the current deny-list
 }
 
 // checkBlockstore queries the blockStore for the latest known block. If the latest block is
@@ -63,27 +67,58 @@ func checkBlockstore(bs *blockstore.Blockstore, startBlock uint64) (uint64, erro
 	}
 }
 
-func InitializeChain(cfg *core.ChainConfig, logger log15.Logger, sysErr chan<- error, m *metrics.ChainMetrics) (*Chain, error) {
-	/// Load keypair
+// newSigner returns the signer of the from account and the address its blockstore is named after. The key is
+// held by the signer daemon when the signer opt is set, otherwise it is loaded from the keystore.
+func newSigner(cfg *core.ChainConfig, config *Config) (signer.Substrate, string, error) {
+	if config.signer != "" {
+		client, err := signer.Dial(config.signer)
+		if err != nil {
+			return nil, "", err
+		}
+		s, err := client.Substrate(config.account[:])
+		return s, cfg.From, err
+	}
 	kp, err := keystore.KeypairFromAddress(cfg.From, keystore.SubChain, cfg.KeystorePath, cfg.Insecure)
+	if err != nil {
+		return nil, "", err
+	}
+	krp := kp.(*sr25519.Keypair).AsKeyringPair()
+	return signer.NewSubstrate((signature.KeyringPair)(*krp)), kp.Address(), nil
+}
+
+// NewSigner returns the signer of the relayer key of cfg
+func NewSigner(cfg *core.ChainConfig) (signer.Substrate, error) {
+	config, err := parseChainConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
+	s, _, err := newSigner(cfg, config)
+	return s, err
+}
 
-	krp := kp.(*sr25519.Keypair).AsKeyringPair()
+func InitializeChain(cfg *core.ChainConfig, logger log15.Logger, sysErr chan<- error, m *metrics.ChainMetrics) (*Chain, error) {
+	config, err := parseChainConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	startBlock := config.startBlock
 
-	/// Attempt to load latest block
-	bs, err := blockstore.NewBlockstore(cfg.BlockstorePath, cfg.Id, kp.Address())
+	/// Load keypair
+	key, address, err := newSigner(cfg, config)
 	if err != nil {
 		return nil, err
 	}
 
-	startBlock := parseStartBlock(cfg)
+	/// Attempt to load latest block
+	bs, err := blockstore.NewBlockstore(cfg.BlockstorePath, cfg.Id, address)
+	if err != nil {
+		return nil, err
+	}
 
 	stop := make(chan int)
 
 	/// Setup connection
-	conn := NewConnection(cfg.Endpoint, cfg.Name, krp, logger, stop, sysErr)
+	conn := NewConnection(cfg.Endpoint, cfg.Name, key, logger, stop, sysErr)
 
 	err = conn.Connect()
 	if err != nil {
@@ -99,36 +134,42 @@ func InitializeChain(cfg *core.ChainConfig, logger log15.Logger, sysErr chan<- e
 		startBlock = uint64(curr.Number)
 	}
 
-	/// Load listener and writer needed config
-	ue := parseUseExtended(cfg)
-	otherRelayers := parseOtherRelayer(cfg)
-	multiSignAddress := parseMultiSignAddress(cfg)
-	total, currentRelayer, threshold := parseMultiSignConfig(cfg)
-	weight := parseMaxWeight(cfg)
-	url := parseUrl(cfg)
-	dest := parseDestId(cfg)
-	resource := parseResourceId(cfg)
-
-	cli, err := client.New(url)
+	/// Set relayer parameters
+	relayer := NewRelayer(key, config.otherRelayers, config.totalRelayers, config.multiSignThreshold, config.currentRelayer)
+
+	/// Setup listener & writer
+	l := NewListener(conn, cfg.Name, cfg.Id, startBlock, logger, bs, stop, sysErr, m, types.AccountID(config.multiSignAddress), config.ss58Prefix, config.resourceId, config.destId, relayer)
+	w, err := NewWriter(conn, l, logger, sysErr, m, config.useExtendedCall, config.maxWeight, relayer)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	cli.SetPrefix(ss58.PolkadotPrefix)
 
-	/// Set relayer parameters
-	relayer := NewRelayer((signature.KeyringPair)(*krp), otherRelayers, total, threshold, currentRelayer)
+	hold, err := newHoldQueue(cfg.BlockstorePath, cfg.Id, address)
+	if err != nil {
+		return nil, err
+	}
+	l.setHoldQueue(hold, config.recipientPrefix)
+	w.setRefunds(hold, config.refundFee)
+	w.setBatching(config.maxBatchSize, config.batchWindow)
 
-	/// Setup listener & writer
-	l := NewListener(conn, cfg.Name, cfg.Id, startBlock, logger, bs, stop, sysErr, m, types.AccountID(multiSignAddress), cli, resource, dest, relayer)
-	w := NewWriter(conn, l, logger, sysErr, m, ue, weight, relayer)
+	var denyList *screening.DenyList
+	if config.denyList != "" {
+		denyList, err = screening.NewDenyList(config.denyList, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return &Chain{
+	chain := &Chain{
 		cfg:      cfg,
 		conn:     conn,
 		listener: l,
 		writer:   w,
 		stop:     stop,
-	}, nil
+		config:   config,
+	}
+	chain.setDenyList(denyList)
+	return chain, nil
 }
 
 func (c *Chain) Start() error {
@@ -136,6 +177,7 @@ func (c *Chain) Start() error {
 	if err != nil {
 		return err
 	}
+	c.writer.start(c.listener.stop)
 	c.conn.log.Debug("Successfully started chain", "chainId", c.cfg.Id)
 	return nil
 }
@@ -158,5 +200,8 @@ func (c *Chain) Name() string {
 }
 
 func (c *Chain) Stop() {
+	c.reloadLock.Lock()
+	c.setDenyList(nil)
+	c.reloadLock.Unlock()
 	close(c.stop)
 }
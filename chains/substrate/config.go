@@ -4,116 +4,416 @@
 package substrate
 
 import (
-	"github.com/rjman-self/platdot-utils/msg"
-	log "github.com/ChainSafe/log15"
-	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
-	"github.com/ethereum/go-ethereum/common"
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/JFJun/go-substrate-crypto/ss58"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/rjman-self/platdot-utils/core"
+	"github.com/rjman-self/platdot-utils/msg"
+	subtypes "github.com/rjmand/go-substrate-rpc-client/v2/types"
 )
 
-func parseStartBlock(cfg *core.ChainConfig) uint64 {
-	if blk, ok := cfg.Opts["startBlock"]; ok {
-		res, err := strconv.ParseUint(blk, 10, 32)
+// Default bech32 prefix of Alaya recipients in deposit remarks
+const DefaultRecipientPrefix = "atp"
+
+// Default ss58 network prefix of the addresses logged and held for deposit senders
+const DefaultSS58Prefix = 0
+
+// Default weight limit of a multisig call
+const DefaultMaxWeight = 2269800000
+
+// Chain specific options
+var (
+	StartBlockOpt           = "startBlock"
+	UseExtendedCallOpt      = "useExtendedCall"
+	TotalRelayerOpt         = "TotalRelayer"
+	CurrentRelayerNumberOpt = "CurrentRelayerNumber"
+	MultiSignThresholdOpt   = "MultiSignThreshold"
+	MultiSignAddressOpt     = "MultiSignAddress" // Optional with Relayers, derived from the relayers and threshold
+	RelayersOpt             = "Relayers"         // Comma separated ordered list of every relayer, instead of the numbered opts
+	OtherRelayerOpt         = "OtherRelayer"     // Followed by the number of the relayer, from 1 to TotalRelayer-1
+	MaxWeightOpt            = "MaxWeight"
+	DestIdOpt               = "DestId"
+	ResourceIdOpt           = "ResourceId"
+	DenyListOpt             = "DenyList"
+	RecipientPrefixOpt      = "RecipientPrefix"
+	RefundFeeOpt            = "RefundFee"
+	MaxBatchSizeOpt         = "MaxBatchSize"
+	BatchWindowOpt          = "BatchWindow"
+	SignerOpt               = "signer"
+	SS58PrefixOpt           = "SS58Prefix" // Network prefix of sender addresses, from 0 to 63
+)
+
+// ConfigErrors holds every invalid option of a chain config
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid substrate chain config: %s", strings.Join(msgs, "; "))
+}
+
+// Config encapsulates all necessary parameters of a substrate chain
+type Config struct {
+	name               string      // Human-readable chain name
+	id                 msg.ChainId // ChainID
+	endpoint           string      // url for rpc endpoint
+	from               string      // address of key to use
+	account            types.AccountID
+	startBlock         uint64
+	useExtendedCall    bool
+	totalRelayers      uint64
+	currentRelayer     uint64 // Number of this relayer, from 1 to totalRelayers
+	multiSignThreshold uint16
+	multiSignAddress   types.AccountID
+	otherRelayers      []types.AccountID
+	maxWeight          uint64
+	destId             msg.ChainId
+	resourceId         msg.ResourceId
+	denyList           string // Location of the deny-list file
+	signer             string // Socket of the signer daemon holding the key, the keystore is used when empty
+	recipientPrefix    string
+	ss58Prefix         []byte
+	refundFee          *big.Int
+	maxBatchSize       int
+	batchWindow        time.Duration
+}
+
+// parseChainConfig uses a core.ChainConfig to construct a corresponding Config. Unknown options are
+// rejected and every invalid option is reported in the returned ConfigErrors.
+func parseChainConfig(chainCfg *core.ChainConfig) (*Config, error) {
+	config := &Config{
+		name:            chainCfg.Name,
+		id:              chainCfg.Id,
+		endpoint:        chainCfg.Endpoint,
+		from:            chainCfg.From,
+		maxWeight:       DefaultMaxWeight,
+		recipientPrefix: DefaultRecipientPrefix,
+		ss58Prefix:      []byte{DefaultSS58Prefix},
+		refundFee:       big.NewInt(FixedFee),
+		maxBatchSize:    DefaultMaxBatchSize,
+		batchWindow:     DefaultBatchWindow,
+	}
+	var errs ConfigErrors
+	fail := func(opt string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", opt, fmt.Sprintf(format, args...)))
+	}
+	opts := make(map[string]string)
+	for k, v := range chainCfg.Opts {
+		opts[k] = v
+	}
+	// take returns the value of a set option and marks it as known
+	take := func(opt string) (string, bool) {
+		v, ok := opts[opt]
+		delete(opts, opt)
+		return v, ok && v != ""
+	}
+
+	if pub, err := ss58.DecodeToPub(chainCfg.From); err == nil {
+		config.account = types.NewAccountID(pub)
+	} else {
+		fail("from", "invalid ss58 address %q", chainCfg.From)
+	}
+
+	if v, ok := take(StartBlockOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 64)
 		if err != nil {
-			panic(err)
+			fail(StartBlockOpt, "%s", err)
 		}
-		return res
+		config.startBlock = res
 	}
-	return 0
-}
 
-func parseUseExtended(cfg *core.ChainConfig) bool {
-	if b, ok := cfg.Opts["useExtendedCall"]; ok {
-		res, err := strconv.ParseBool(b)
+	if v, ok := take(UseExtendedCallOpt); ok {
+		res, err := strconv.ParseBool(v)
 		if err != nil {
-			panic(err)
+			fail(UseExtendedCallOpt, "%s", err)
 		}
-		return res
+		config.useExtendedCall = res
 	}
-	return false
-}
 
-func parseOtherRelayer(cfg *core.ChainConfig) []types.AccountID {
-	var otherSignatories []types.AccountID
-	if totalRelayer, ok := cfg.Opts["TotalRelayer"]; ok {
-		total, _ := strconv.ParseUint(totalRelayer, 10, 32)
-		for i := uint64(1); i < total; i++ {
-			relayedKey := "OtherRelayer" + string(strconv.Itoa(int(i)))
-			if relayer, ok := cfg.Opts[relayedKey]; ok {
-				address, _ := types.NewAddressFromHexAccountID(relayer)
-				otherSignatories = append(otherSignatories, address.AsAccountID)
-			} else {
-				log.Warn("Please set config 'OtherRelayer' from 1 to ...!")
-				log.Error("Polkadot OtherRelayer Not Found", "OtherRelayerNumber", i)
+	if v, ok := take(RelayersOpt); ok {
+		for k := range opts {
+			if k == TotalRelayerOpt || k == CurrentRelayerNumberOpt || strings.HasPrefix(k, OtherRelayerOpt) {
+				fail(k, "conflicts with %s", RelayersOpt)
+				delete(opts, k)
 			}
 		}
+		config.parseRelayers(v, fail)
 	} else {
-		log.Error("Please set config opts 'TotalRelayer'.")
+		config.parseNumberedRelayers(take, opts, fail)
 	}
-	return otherSignatories
-}
 
-func parseMultiSignConfig(cfg *core.ChainConfig) (uint64, uint64, uint16) {
-	total := uint64(3)
-	current := uint64(1)
-	threshold := uint64(2)
-	if totalRelayer, ok := cfg.Opts["TotalRelayer"]; ok {
-		total, _ = strconv.ParseUint(totalRelayer, 10, 32)
+	if v, ok := take(MultiSignThresholdOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 16)
+		if err != nil || res == 0 || res > config.totalRelayers {
+			fail(MultiSignThresholdOpt, "must be from 1 to the number of relayers, got %q", v)
+		}
+		config.multiSignThreshold = uint16(res)
+	} else {
+		fail(MultiSignThresholdOpt, "required")
 	}
-	if currentRelayerNumber, ok := cfg.Opts["CurrentRelayerNumber"]; ok {
-		current, _ = strconv.ParseUint(currentRelayerNumber, 10, 32)
-		if current == 0 {
-			log.Error("Please set config opts 'CurrentRelayerNumber' from 1 to ...!")
+
+	multiSignSet := false
+	if v, ok := take(MultiSignAddressOpt); ok {
+		pub, err := types.HexDecodeString(v)
+		if err != nil || len(pub) != 32 {
+			fail(MultiSignAddressOpt, "invalid public key %q", v)
 		}
+		config.multiSignAddress = types.NewAccountID(pub)
+		multiSignSet = true
+	} else if _, ok := chainCfg.Opts[RelayersOpt]; !ok {
+		// Only the relayer set can derive the multisig account
+		fail(MultiSignAddressOpt, "required")
 	}
-	if multiSignThreshold, ok := cfg.Opts["MultiSignThreshold"]; ok {
-		threshold, _ = strconv.ParseUint(multiSignThreshold, 10, 32)
+
+	if v, ok := take(MaxWeightOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			fail(MaxWeightOpt, "%s", err)
+		}
+		config.maxWeight = res
 	}
-	return total, current, uint16(threshold)
-}
 
-func parseMultiSignAddress(cfg *core.ChainConfig) types.AccountID {
-	if multisignAddress, ok := cfg.Opts["MultiSignAddress"]; ok {
-		multiSignPk, _ := types.HexDecodeString(multisignAddress)
-		multiSignAccount := types.NewAccountID(multiSignPk)
-		return multiSignAccount
-	} else {
-		log.Error("Polkadot MultiAddress Not Found")
+	if v, ok := take(DestIdOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			fail(DestIdOpt, "%s", err)
+		}
+		config.destId = msg.ChainId(res)
+	}
+
+	if v, ok := take(ResourceIdOpt); ok {
+		rId := common.FromHex(v)
+		if len(rId) != 32 {
+			fail(ResourceIdOpt, "must be 32 bytes of hex, got %q", v)
+		}
+		config.resourceId = msg.ResourceIdFromSlice(rId)
+	}
+
+	if v, ok := take(DenyListOpt); ok {
+		config.denyList = v
+	}
+
+	if v, ok := take(SignerOpt); ok {
+		config.signer = v
+	}
+
+	if v, ok := take(RecipientPrefixOpt); ok {
+		config.recipientPrefix = v
+	}
+
+	if v, ok := take(SS58PrefixOpt); ok {
+		// Prefixes from 64 on are encoded in two bytes, which is not supported
+		res, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || res > 63 {
+			fail(SS58PrefixOpt, "must be from 0 to 63, got %q", v)
+		}
+		config.ss58Prefix = []byte{byte(res)}
+	}
+
+	if v, ok := take(RefundFeeOpt); ok {
+		res, ok := big.NewInt(0).SetString(v, 10)
+		if !ok || res.Sign() < 0 {
+			fail(RefundFeeOpt, "invalid amount %q", v)
+		} else {
+			config.refundFee = res
+		}
+	}
+
+	if v, ok := take(MaxBatchSizeOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || res == 0 {
+			fail(MaxBatchSizeOpt, "must be a positive number, got %q", v)
+		}
+		config.maxBatchSize = int(res)
+	}
+
+	if v, ok := take(BatchWindowOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			fail(BatchWindowOpt, "%s", err)
+		}
+		config.batchWindow = time.Duration(res) * time.Second
+	}
+
+	unknown := make([]string, 0, len(opts))
+	for k := range opts {
+		unknown = append(unknown, k)
 	}
-	return types.AccountID{}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		fail(k, "unknown option")
+	}
+
+	// The multisig account is derived from every signatory and the threshold
+	if len(errs) == 0 {
+		signatories := []subtypes.AccountID{subtypes.AccountID(config.account)}
+		for _, r := range config.otherRelayers {
+			signatories = append(signatories, subtypes.AccountID(r))
+		}
+		expected, err := MultiAccountID(signatories, config.multiSignThreshold)
+		if err != nil {
+			fail(MultiSignAddressOpt, "%s", err)
+		} else if !multiSignSet {
+			config.multiSignAddress = types.AccountID(expected)
+		} else if types.AccountID(expected) != config.multiSignAddress {
+			fail(MultiSignAddressOpt, "%s is not the multisig account of the relayers, expected %s",
+				types.HexEncodeToString(config.multiSignAddress[:]), types.HexEncodeToString(expected[:]))
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return config, nil
 }
 
-func parseUrl(cfg *core.ChainConfig) string {
-	if len(cfg.Endpoint) > 0 {
-		return cfg.Endpoint
+// parseRelayers sets the relayers from the ordered list of the relayer set. The number of this relayer is
+// its position in the list, the other signatories are sorted as the multisig calls require.
+func (c *Config) parseRelayers(list string, fail func(opt string, format string, args ...interface{})) {
+	seen := make(map[types.AccountID]bool)
+	for i, r := range strings.Split(list, ",") {
+		account, err := parseAccount(strings.TrimSpace(r))
+		if err != nil {
+			fail(RelayersOpt, "%s", err)
+			continue
+		}
+		if seen[account] {
+			fail(RelayersOpt, "duplicate relayer %s", r)
+			continue
+		}
+		seen[account] = true
+		if account == c.account {
+			c.currentRelayer = uint64(i + 1)
+		} else {
+			c.otherRelayers = append(c.otherRelayers, account)
+		}
+		c.totalRelayers++
 	}
-	return ""
+	if c.currentRelayer == 0 && c.account != (types.AccountID{}) {
+		fail(RelayersOpt, "%s is not one of the relayers", c.from)
+	}
+	sortAccounts(c.otherRelayers)
 }
 
-func parseMaxWeight(cfg *core.ChainConfig) uint64 {
-	if weight, ok := cfg.Opts["MaxWeight"]; ok {
-		res, _ := strconv.ParseUint(weight, 10, 32)
-		return res
+// parseNumberedRelayers sets the relayers from the TotalRelayer, CurrentRelayerNumber and OtherRelayerN opts,
+// the other signatories are sorted whatever their numbers
+func (c *Config) parseNumberedRelayers(take func(opt string) (string, bool), opts map[string]string,
+	fail func(opt string, format string, args ...interface{})) {
+	if v, ok := take(TotalRelayerOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || res == 0 {
+			fail(TotalRelayerOpt, "must be a positive number, got %q", v)
+		}
+		c.totalRelayers = res
+	} else {
+		fail(TotalRelayerOpt, "required")
+	}
+
+	if v, ok := take(CurrentRelayerNumberOpt); ok {
+		res, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || res == 0 || res > c.totalRelayers {
+			fail(CurrentRelayerNumberOpt, "must be from 1 to %s, got %q", TotalRelayerOpt, v)
+		}
+		c.currentRelayer = res
+	} else {
+		fail(CurrentRelayerNumberOpt, "required")
+	}
+
+	var relayers []int
+	for k := range opts {
+		if strings.HasPrefix(k, OtherRelayerOpt) {
+			n, err := strconv.Atoi(strings.TrimPrefix(k, OtherRelayerOpt))
+			if err != nil || n < 1 {
+				continue // Reported as unknown option
+			}
+			relayers = append(relayers, n)
+		}
+	}
+	sort.Ints(relayers)
+	if c.totalRelayers > 0 && uint64(len(relayers)) != c.totalRelayers-1 {
+		fail(OtherRelayerOpt, "found %d other relayers, %s requires %d", len(relayers), TotalRelayerOpt, c.totalRelayers-1)
 	}
-	return 2269800000
+	for _, n := range relayers {
+		opt := OtherRelayerOpt + strconv.Itoa(n)
+		v, _ := take(opt)
+		if c.totalRelayers == 0 {
+			continue // TotalRelayer is already reported
+		}
+		if uint64(n) >= c.totalRelayers {
+			fail(opt, "must be numbered from 1 to %s-1", TotalRelayerOpt)
+			continue
+		}
+		pub, err := types.HexDecodeString(v)
+		if err != nil || len(pub) != 32 {
+			fail(opt, "invalid public key %q", v)
+			continue
+		}
+		c.otherRelayers = append(c.otherRelayers, types.NewAccountID(pub))
+	}
+	sortAccounts(c.otherRelayers)
 }
 
-func parseDestId(cfg *core.ChainConfig) msg.ChainId {
-	if id, ok := cfg.Opts["DestId"]; ok {
-		res, err := strconv.ParseUint(id, 10, 32)
-		if err != nil {
-			panic(err)
+// parseAccount parses an ss58 address or hex public key
+func parseAccount(addr string) (types.AccountID, error) {
+	if strings.HasPrefix(addr, "0x") {
+		pub, err := types.HexDecodeString(addr)
+		if err != nil || len(pub) != 32 {
+			return types.AccountID{}, fmt.Errorf("invalid public key %q", addr)
 		}
-		return msg.ChainId(res)
+		return types.NewAccountID(pub), nil
+	}
+	pub, err := ss58.DecodeToPub(addr)
+	if err != nil {
+		return types.AccountID{}, fmt.Errorf("invalid address %q", addr)
 	}
-	return 0
+	return types.NewAccountID(pub), nil
+}
+
+// sortAccounts sorts accounts by their public key
+func sortAccounts(accounts []types.AccountID) {
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i][:], accounts[j][:]) < 0
+	})
+}
+
+// ValidateChainConfig checks the options of a chain config without connecting to the chain
+func ValidateChainConfig(chainCfg *core.ChainConfig) error {
+	_, err := parseChainConfig(chainCfg)
+	return err
+}
+
+// Multisig is the multisig account of the relayers of a chain config
+type Multisig struct {
+	Address   types.AccountID
+	Threshold uint16
+	Relayer   types.AccountID   // Relayer of the chain config
+	Number    uint64            // Number of the relayer, from 1
+	Relayers  []types.AccountID // Every relayer, sorted
 }
 
-func parseResourceId(cfg *core.ChainConfig) msg.ResourceId {
-	if resource, ok := cfg.Opts["ResourceId"]; ok {
-		return msg.ResourceIdFromSlice(common.FromHex(resource))
+// ChainMultisig returns the multisig of the relayers of a chain config
+func ChainMultisig(chainCfg *core.ChainConfig) (*Multisig, error) {
+	cfg, err := parseChainConfig(chainCfg)
+	if err != nil {
+		return nil, err
 	}
-	return msg.ResourceIdFromSlice(common.FromHex("0x0000000000000000000000000000000000000000000000000000000000000000"))
+	relayers := append([]types.AccountID{cfg.account}, cfg.otherRelayers...)
+	sortAccounts(relayers)
+	return &Multisig{
+		Address:   cfg.multiSignAddress,
+		Threshold: cfg.multiSignThreshold,
+		Relayer:   cfg.account,
+		Number:    cfg.currentRelayer,
+		Relayers:  relayers,
+	}, nil
 }
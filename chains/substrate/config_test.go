@@ -4,27 +4,236 @@
 package substrate
 
 import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/JFJun/go-substrate-crypto/ss58"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
 	"github.com/rjman-self/platdot-utils/core"
 )
 
+// validChainConfig returns the substrate chain config of a relayer of a 3 of 5 multisig
+func validChainConfig() *core.ChainConfig {
+	return &core.ChainConfig{
+		Name:     "kusama",
+		Id:       1,
+		Endpoint: "ws://localhost:9944",
+		From:     "12pkkkKCovEzByPTjjK1qtKwPi1Pto1AX519UxTU4FJx6iGJ",
+		Opts: map[string]string{
+			"MultiSignAddress":     "0x83b0e4664507e7072dd2b30e9c5f68a708979e741a965048fb1ccbc61bd331f5",
+			"TotalRelayer":         "5",
+			"CurrentRelayerNumber": "2",
+			"MultiSignThreshold":   "3",
+			"OtherRelayer1":        "0x0a19674301c56a1721feb98dbe93cfab911a8c1bed127f598ef93b374bcc6e71",
+			"OtherRelayer2":        "0x923eeef27b93315c97e63e0c1284b7433ffbc413a58da0626a63955a48586075",
+			"OtherRelayer3":        "0xa45a0ddd81da79f65cbcfeefc8e62382b1f56ccbbdd9533f77cdc49172cca33d",
+			"OtherRelayer4":        "0xe6c2b6c4a5d3a770814f3ebe99893d1bb66e8f0d086a2badfcbb481b043ada1a",
+			"ResourceId":           "0x0000000000000000000000000000000000000000000000000000000000000000",
+			"MaxWeight":            "22698000000",
+			"DestId":               "2",
+		},
+	}
+}
+
+func TestParseChainConfig(t *testing.T) {
+	cfg, err := parseChainConfig(validChainConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.maxWeight != 22698000000 {
+		t.Fatalf("Got: %d Expected: %d", cfg.maxWeight, uint64(22698000000))
+	}
+	if cfg.totalRelayers != 5 || cfg.currentRelayer != 2 || cfg.multiSignThreshold != 3 {
+		t.Fatalf("Got: %d %d %d Expected: 5 2 3", cfg.totalRelayers, cfg.currentRelayer, cfg.multiSignThreshold)
+	}
+	if len(cfg.otherRelayers) != 4 {
+		t.Fatalf("Got: %d Expected: %d", len(cfg.otherRelayers), 4)
+	}
+	if cfg.destId != 2 {
+		t.Fatalf("Got: %d Expected: %d", cfg.destId, 2)
+	}
+	if cfg.recipientPrefix != DefaultRecipientPrefix || cfg.maxBatchSize != DefaultMaxBatchSize {
+		t.Fatalf("Got: %s %d Expected: %s %d", cfg.recipientPrefix, cfg.maxBatchSize, DefaultRecipientPrefix, DefaultMaxBatchSize)
+	}
+}
+
 func TestParseStartBlock(t *testing.T) {
 	// Valid option included in config
-	cfg := &core.ChainConfig{Opts: map[string]string{"startBlock": "1000"}}
-
-	blk := parseStartBlock(cfg)
+	chainCfg := validChainConfig()
+	chainCfg.Opts[StartBlockOpt] = "1000"
 
-	if blk != 1000 {
-		t.Fatalf("Got: %d Expected: %d", blk, 1000)
+	cfg, err := parseChainConfig(chainCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.startBlock != 1000 {
+		t.Fatalf("Got: %d Expected: %d", cfg.startBlock, 1000)
 	}
 
 	// Not included in config
-	cfg = &core.ChainConfig{Opts: map[string]string{}}
+	cfg, err = parseChainConfig(validChainConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.startBlock != 0 {
+		t.Fatalf("Got: %d Expected: %d", cfg.startBlock, 0)
+	}
+
+	// Invalid option is an error instead of a panic
+	chainCfg = validChainConfig()
+	chainCfg.Opts[StartBlockOpt] = "latest"
+	if _, err = parseChainConfig(chainCfg); err == nil {
+		t.Fatal("expected an error for an invalid start block")
+	}
+}
+
+func TestParseSS58Prefix(t *testing.T) {
+	cfg, err := parseChainConfig(validChainConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cfg.ss58Prefix, ss58.PolkadotPrefix) {
+		t.Fatalf("Got: %x Expected: %x", cfg.ss58Prefix, ss58.PolkadotPrefix)
+	}
+
+	chainCfg := validChainConfig()
+	chainCfg.Opts[SS58PrefixOpt] = "2"
+	cfg, err = parseChainConfig(chainCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cfg.ss58Prefix, ss58.KsmPrefix) {
+		t.Fatalf("Got: %x Expected: %x", cfg.ss58Prefix, ss58.KsmPrefix)
+	}
 
-	blk = parseStartBlock(cfg)
+	chainCfg.Opts[SS58PrefixOpt] = "64"
+	if _, err = parseChainConfig(chainCfg); err == nil {
+		t.Fatal("expected an error for a two byte ss58 prefix")
+	}
+}
+
+func TestParseChainConfigErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		change   func(opts map[string]string)
+		expected []string
+	}{
+		{
+			name:     "unknown option",
+			change:   func(opts map[string]string) { opts["MaxWieght"] = "1" },
+			expected: []string{"MaxWieght: unknown option"},
+		},
+		{
+			name:     "missing relayer",
+			change:   func(opts map[string]string) { delete(opts, "OtherRelayer4") },
+			expected: []string{"found 3 other relayers"},
+		},
+		{
+			name:     "threshold above total",
+			change:   func(opts map[string]string) { opts["MultiSignThreshold"] = "6" },
+			expected: []string{MultiSignThresholdOpt},
+		},
+		{
+			name:     "wrong multisig address",
+			change:   func(opts map[string]string) { opts["MultiSignThreshold"] = "2" },
+			expected: []string{"is not the multisig account of the relayers"},
+		},
+		{
+			name: "aggregated errors",
+			change: func(opts map[string]string) {
+				opts["DestId"] = "256"
+				opts["CurrentRelayerNumber"] = "0"
+				delete(opts, "MultiSignAddress")
+			},
+			expected: []string{DestIdOpt, CurrentRelayerNumberOpt, "MultiSignAddress: required"},
+		},
+	}
+
+	for _, tc := range testCases {
+		chainCfg := validChainConfig()
+		tc.change(chainCfg.Opts)
+		_, err := parseChainConfig(chainCfg)
+		if err == nil {
+			t.Fatalf("%s: expected an error", tc.name)
+		}
+		for _, e := range tc.expected {
+			if !strings.Contains(err.Error(), e) {
+				t.Fatalf("%s: Got: %s Expected: %s", tc.name, err, e)
+			}
+		}
+	}
+}
+
+func TestParseNumberedRelayersOrder(t *testing.T) {
+	expected, err := parseChainConfig(validChainConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The other relayers numbered in reverse order
+	chainCfg := validChainConfig()
+	for i := 1; i <= 4; i++ {
+		chainCfg.Opts["OtherRelayer"+strconv.Itoa(i)] = validChainConfig().Opts["OtherRelayer"+strconv.Itoa(5-i)]
+	}
+	cfg, err := parseChainConfig(chainCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.otherRelayers, expected.otherRelayers) {
+		t.Fatalf("Got: %v Expected: %v", cfg.otherRelayers, expected.otherRelayers)
+	}
+	for i := 1; i < len(cfg.otherRelayers); i++ {
+		if bytes.Compare(cfg.otherRelayers[i-1][:], cfg.otherRelayers[i][:]) >= 0 {
+			t.Fatalf("other relayers not sorted: %v", cfg.otherRelayers)
+		}
+	}
+}
+
+func TestParseRelayerSet(t *testing.T) {
+	chainCfg := validChainConfig()
+	chainCfg.Opts = map[string]string{
+		RelayersOpt: "5CHwt8bFyDLC3MyzPQugmmxZTGjShBW2kFMWiC2kSL5TuJxd,12pkkkKCovEzByPTjjK1qtKwPi1Pto1AX519UxTU4FJx6iGJ," +
+			"0x923eeef27b93315c97e63e0c1284b7433ffbc413a58da0626a63955a48586075,0xe6c2b6c4a5d3a770814f3ebe99893d1bb66e8f0d086a2badfcbb481b043ada1a," +
+			"0xa45a0ddd81da79f65cbcfeefc8e62382b1f56ccbbdd9533f77cdc49172cca33d",
+		MultiSignThresholdOpt: "3",
+	}
+
+	cfg, err := parseChainConfig(chainCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.totalRelayers != 5 || cfg.currentRelayer != 2 {
+		t.Fatalf("Got: %d %d Expected: 5 2", cfg.totalRelayers, cfg.currentRelayer)
+	}
+	// The multisig account is derived from the relayers
+	expected := "0x83b0e4664507e7072dd2b30e9c5f68a708979e741a965048fb1ccbc61bd331f5"
+	if addr := types.HexEncodeToString(cfg.multiSignAddress[:]); addr != expected {
+		t.Fatalf("Got: %s Expected: %s", addr, expected)
+	}
+	// The other signatories are sorted whatever the order of the relayers
+	legacy, err := parseChainConfig(validChainConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.otherRelayers, legacy.otherRelayers) {
+		t.Fatalf("Got: %v Expected: %v", cfg.otherRelayers, legacy.otherRelayers)
+	}
+
+	// The relayer must be in the set
+	chainCfg.Opts[RelayersOpt] = "5CHwt8bFyDLC3MyzPQugmmxZTGjShBW2kFMWiC2kSL5TuJxd"
+	chainCfg.Opts[MultiSignThresholdOpt] = "1"
+	if _, err = parseChainConfig(chainCfg); err == nil || !strings.Contains(err.Error(), "is not one of the relayers") {
+		t.Fatalf("Got: %v Expected: not one of the relayers", err)
+	}
 
-	if blk != 0 {
-		t.Fatalf("Got: %d Expected: %d", blk, 0)
+	// The numbered relayers conflict with the relayer set
+	chainCfg = validChainConfig()
+	chainCfg.Opts[RelayersOpt] = "12pkkkKCovEzByPTjjK1qtKwPi1Pto1AX519UxTU4FJx6iGJ"
+	if _, err = parseChainConfig(chainCfg); err == nil || !strings.Contains(err.Error(), "conflicts with "+RelayersOpt) {
+		t.Fatalf("Got: %v Expected: conflicts with %s", err, RelayersOpt)
 	}
 }
@@ -8,32 +8,35 @@ import (
 	"sync"
 
 	"github.com/ChainSafe/log15"
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
 	"github.com/rjman-self/platdot-utils/msg"
 	gsrpc "github.com/rjmand/go-substrate-rpc-client/v2"
 
+	"github.com/rjman-self/Platdot/shared/signer"
 	utils "github.com/rjman-self/Platdot/shared/substrate"
 	"github.com/rjmand/go-substrate-rpc-client/v2/rpc/author"
-	"github.com/rjmand/go-substrate-rpc-client/v2/signature"
 	"github.com/rjmand/go-substrate-rpc-client/v2/types"
 )
 
 type Connection struct {
 	api         *gsrpc.SubstrateAPI
+	endpoint    *endpoint // Switchable rpc connection behind api
 	log         log15.Logger
-	url         string                 // API endpoint
-	name        string                 // Chain name
-	meta        types.Metadata         // Latest chain metadata
-	metaLock    sync.RWMutex           // Lock metadata for updates, allows concurrent reads
-	genesisHash types.Hash             // Chain genesis hash
-	key         *signature.KeyringPair // Keyring used for signing
-	nonce       types.U32              // Latest account nonce
-	nonceLock   sync.Mutex             // Locks nonce for updates
-	stop        <-chan int             // Signals system shutdown, should be observed in all selects and loops
-	sysErr      chan<- error           // Propagates fatal errors to core
-	prefix      []byte                 // the prefix of token
-}
-
-func NewConnection(url string, name string, key *signature.KeyringPair, log log15.Logger, stop <-chan int, sysErr chan<- error) *Connection {
+	url         string              // API endpoint
+	name        string              // Chain name
+	meta        types.Metadata      // Latest chain metadata
+	eventMeta   eventTypes.Metadata // Latest chain metadata for the shared event types
+	metaLock    sync.RWMutex        // Lock metadata for updates, allows concurrent reads
+	genesisHash types.Hash          // Chain genesis hash
+	key         signer.Substrate    // Signer of the extrinsics
+	nonce       types.U32           // Latest account nonce
+	nonceLock   sync.Mutex          // Locks nonce for updates
+	stop        <-chan int          // Signals system shutdown, should be observed in all selects and loops
+	sysErr      chan<- error        // Propagates fatal errors to core
+	prefix      []byte              // the prefix of token
+}
+
+func NewConnection(url string, name string, key signer.Substrate, log log15.Logger, stop <-chan int, sysErr chan<- error) *Connection {
 	return &Connection{url: url, name: name, key: key, log: log, stop: stop, sysErr: sysErr}
 }
 
@@ -51,17 +54,63 @@ func (c *Connection) updateMetatdata() error {
 		c.metaLock.Unlock()
 		return err
 	}
-	c.meta = *meta
+	err = c.setMetadata(meta)
 	c.metaLock.Unlock()
+	return err
+}
+
+// setMetadata stores the metadata, along with a copy for decoding the shared event types. Requires metaLock.
+func (c *Connection) setMetadata(meta *types.Metadata) error {
+	encoded, err := types.EncodeToBytes(meta)
+	if err != nil {
+		return err
+	}
+	var eventMeta eventTypes.Metadata
+	err = eventTypes.DecodeFromBytes(encoded, &eventMeta)
+	if err != nil {
+		return err
+	}
+	c.meta = *meta
+	c.eventMeta = eventMeta
 	return nil
 }
 
+// getEvents fetches and decodes the events of a block
+func (c *Connection) getEvents(hash types.Hash) (*utils.Events, error) {
+	meta := c.getMetadata()
+	key, err := types.CreateStorageKey(&meta, "System", "Events", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.api.RPC.State.GetStorageRaw(key, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	c.metaLock.RLock()
+	eventMeta := c.eventMeta
+	c.metaLock.RUnlock()
+
+	events := utils.Events{}
+	err = eventTypes.EventRecordsRaw(*raw).DecodeEventRecords(&eventMeta, &events)
+	if err != nil {
+		return nil, fmt.Errorf("decode events: %w", err)
+	}
+	return &events, nil
+}
+
 func (c *Connection) Connect() error {
 	c.log.Info("Connecting to substrate chain...", "url", c.url)
-	api, err := gsrpc.NewSubstrateAPI(c.url)
+	e, err := dialEndpoint(c.url)
+	if err != nil {
+		return err
+	}
+	api, err := e.api()
 	if err != nil {
+		e.close()
 		return err
 	}
+	c.endpoint = e
 	c.api = api
 
 	// Fetch metadata
@@ -69,7 +118,10 @@ func (c *Connection) Connect() error {
 	if err != nil {
 		return err
 	}
-	c.meta = *meta
+	err = c.setMetadata(meta)
+	if err != nil {
+		return err
+	}
 	c.log.Debug("Fetched substrate metadata")
 
 	// Fetch genesis hash
@@ -85,7 +137,7 @@ func (c *Connection) Connect() error {
 // SubmitTx constructs and submits an extrinsic to call the method with the given arguments.
 // All args are passed directly into GSRPC. GSRPC types are recommended to avoid serialization inconsistencies.
 func (c *Connection) SubmitTx(method utils.Method, args ...interface{}) error {
-	c.log.Debug("Submitting substrate call...", "method", method, "sender", c.key.Address)
+	c.log.Debug("Submitting substrate call...", "method", method, "sender", types.HexEncodeToString(c.key.PublicKey()))
 
 	meta := c.getMetadata()
 
@@ -127,7 +179,7 @@ func (c *Connection) SubmitTx(method utils.Method, args ...interface{}) error {
 		TransactionVersion: 1,
 	}
 
-	err = ext.Sign(*c.key, o)
+	err = c.sign(&ext, o)
 	if err != nil {
 		c.nonceLock.Unlock()
 		return err
@@ -146,6 +198,47 @@ func (c *Connection) SubmitTx(method utils.Method, args ...interface{}) error {
 	return c.watchSubmission(sub)
 }
 
+// sign signs ext like Extrinsic.Sign, with the payload signed by the signer of the connection
+func (c *Connection) sign(ext *types.Extrinsic, o types.SignatureOptions) error {
+	method, err := types.EncodeToBytes(ext.Method)
+	if err != nil {
+		return err
+	}
+	era := o.Era
+	if !o.Era.IsMortalEra {
+		era = types.ExtrinsicEra{IsImmortalEra: true}
+	}
+	payload, err := types.EncodeToBytes(types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{
+			Method:      method,
+			Era:         era,
+			Nonce:       o.Nonce,
+			Tip:         o.Tip,
+			SpecVersion: o.SpecVersion,
+			GenesisHash: o.GenesisHash,
+			BlockHash:   o.BlockHash,
+		},
+		TransactionVersion: o.TransactionVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	sig, err := c.key.Sign(payload)
+	if err != nil {
+		return err
+	}
+	ext.Signature = types.ExtrinsicSignatureV4{
+		Signer:    types.NewAddressFromAccountID(c.key.PublicKey()),
+		Signature: types.MultiSignature{IsSr25519: true, AsSr25519: types.NewSignature(sig)},
+		Era:       era,
+		Nonce:     o.Nonce,
+		Tip:       o.Tip,
+	}
+	ext.Version |= types.ExtrinsicBitSigned
+	return nil
+}
+
 func (c *Connection) watchSubmission(sub *author.ExtrinsicStatusSubscription) error {
 	for {
 		select {
@@ -216,7 +309,7 @@ func (c *Connection) checkChainId(expected msg.ChainId) error {
 
 func (c *Connection) getLatestNonce() (types.U32, error) {
 	var acct types.AccountInfo
-	exists, err := c.queryStorage("System", "Account", c.key.PublicKey, nil, &acct)
+	exists, err := c.queryStorage("System", "Account", c.key.PublicKey(), nil, &acct)
 	if err != nil {
 		return 0, err
 	}
@@ -226,6 +319,19 @@ func (c *Connection) getLatestNonce() (types.U32, error) {
 
 	return acct.Nonce, nil
 }
+
+// Reconnect switches the connection to url, which must serve the same chain
+func (c *Connection) Reconnect(url string) error {
+	err := c.endpoint.switchTo(url, c.genesisHash)
+	if err != nil {
+		return err
+	}
+	c.log.Info("Switched substrate endpoint", "url", url)
+	return nil
+}
+
 func (c *Connection) Close() {
-	// TODO: Anything required to shutdown GRPC?
+	if c.endpoint != nil {
+		c.endpoint.close()
+	}
 }
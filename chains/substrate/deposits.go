@@ -0,0 +1,278 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"math/big"
+	"strconv"
+
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+// deposit is a transfer to the multisig account, paired with the remark naming its Alaya recipient
+type deposit struct {
+	BlockNumber    BlockNumber
+	ExtrinsicIndex int
+	DepositIndex   int // Position among the deposits of the extrinsic
+	Sender         types.AccountID
+	Amount         *big.Int
+	Remark         string
+	HasRemark      bool
+}
+
+// DepositNonce returns the nonce of the deposit of an extrinsic, made of the block number followed by the extrinsic index
+func DepositNonce(block BlockNumber, extrinsic int) msg.Nonce {
+	nonce, _ := strconv.ParseUint(strconv.FormatInt(int64(block), 10)+strconv.Itoa(extrinsic), 10, 64)
+	return msg.Nonce(nonce)
+}
+
+// findDeposits walks the call tree of an extrinsic and returns every transfer to the multisig account.
+// Calls dispatched through Proxy, Multisig and Utility.as_derivative are attributed to the account they
+// are dispatched from. Within a batch the transfers are paired in order with the remarks of the same batch.
+func findDeposits(c *call, origin types.AccountID, multiSign types.AccountID) []deposit {
+	switch {
+	case c.is("Utility", "batch", "batch_all", "force_batch"):
+		calls, _ := c.Args["calls"].([]*call)
+		return findBatchDeposits(calls, origin, multiSign)
+	case c.is("Utility", "as_derivative"):
+		index, _ := c.Args["index"].(uint16)
+		derived, err := derivativeAccountID(origin, index)
+		if err != nil {
+			return nil
+		}
+		return findNested(c, derived, multiSign)
+	case c.is("Proxy", "proxy", "proxy_announced"):
+		real, ok := c.Args["real"].(types.AccountID)
+		if !ok {
+			return nil
+		}
+		return findNested(c, real, multiSign)
+	case c.is("Multisig", "as_multi", "as_multi_threshold_1"):
+		threshold := uint16(1)
+		if t, ok := c.Args["threshold"].(uint16); ok {
+			threshold = t
+		}
+		others, _ := c.Args["other_signatories"].([]types.AccountID)
+		wallet, err := MultiAccountID(append(others, origin), threshold)
+		if err != nil {
+			return nil
+		}
+		return findNested(c, wallet, multiSign)
+	}
+
+	if amount, ok := transferTo(c, multiSign); ok {
+		return []deposit{{Sender: origin, Amount: amount}}
+	}
+	return nil
+}
+
+func findNested(c *call, origin types.AccountID, multiSign types.AccountID) []deposit {
+	nested, ok := c.Args["call"].(*call)
+	if !ok {
+		return nil
+	}
+	return findDeposits(nested, origin, multiSign)
+}
+
+func findBatchDeposits(calls []*call, origin types.AccountID, multiSign types.AccountID) []deposit {
+	var transfers, nested []deposit
+	var remarks []string
+	for _, c := range calls {
+		if amount, ok := transferTo(c, multiSign); ok {
+			transfers = append(transfers, deposit{Sender: origin, Amount: amount})
+			continue
+		}
+		if remark, ok := remarkOf(c); ok {
+			remarks = append(remarks, remark)
+			continue
+		}
+		nested = append(nested, findDeposits(c, origin, multiSign)...)
+	}
+
+	for i := range transfers {
+		if i < len(remarks) {
+			transfers[i].Remark = remarks[i]
+			transfers[i].HasRemark = true
+		}
+	}
+	return append(transfers, nested...)
+}
+
+// transferTo returns the amount of a Balances transfer to the given account
+func transferTo(c *call, dest types.AccountID) (*big.Int, bool) {
+	if !c.is("Balances", "transfer", "transfer_keep_alive", "transfer_allow_death") {
+		return nil, false
+	}
+	to, ok := c.Args["dest"].(types.AccountID)
+	if !ok || to != dest {
+		return nil, false
+	}
+	amount, ok := c.Args["value"].(*big.Int)
+	return amount, ok
+}
+
+func remarkOf(c *call) (string, bool) {
+	if !c.is("System", "remark", "remark_with_event") {
+		return "", false
+	}
+	for _, name := range []string{"remark", "_remark"} {
+		if remark, ok := c.Args[name].([]byte); ok {
+			return string(remark), true
+		}
+	}
+	return "", false
+}
+
+// collectRemarks returns the remarks anywhere in the call tree
+func collectRemarks(c *call) []string {
+	if remark, ok := remarkOf(c); ok {
+		return []string{remark}
+	}
+	var remarks []string
+	for _, arg := range c.Args {
+		switch nested := arg.(type) {
+		case *call:
+			remarks = append(remarks, collectRemarks(nested)...)
+		case []*call:
+			for _, n := range nested {
+				remarks = append(remarks, collectRemarks(n)...)
+			}
+		}
+	}
+	return remarks
+}
+
+// remarked links a System.Remarked event to its sender
+type remarked struct {
+	Sender types.AccountID
+	Hash   [32]byte
+}
+
+// extrinsicEvents are the events of a single extrinsic relevant to its deposits
+type extrinsicEvents struct {
+	success   bool
+	transfers []deposit
+	remarks   []remarked
+}
+
+// groupEvents indexes the successful transfers to the multisig account and the remarks of a block by extrinsic index.
+// Failed extrinsics are left out.
+func groupEvents(events *utils.Events, multiSign types.AccountID) map[int]*extrinsicEvents {
+	grouped := make(map[int]*extrinsicEvents)
+	get := func(phase eventTypes.Phase) *extrinsicEvents {
+		if !phase.IsApplyExtrinsic {
+			return nil
+		}
+		index := int(phase.AsApplyExtrinsic)
+		if grouped[index] == nil {
+			grouped[index] = &extrinsicEvents{}
+		}
+		return grouped[index]
+	}
+
+	for _, evt := range events.System_ExtrinsicSuccess {
+		if e := get(evt.Phase); e != nil {
+			e.success = true
+		}
+	}
+	for _, evt := range events.Balances_Transfer {
+		if types.AccountID(evt.To) != multiSign {
+			continue
+		}
+		if e := get(evt.Phase); e != nil {
+			e.transfers = append(e.transfers, deposit{Sender: types.AccountID(evt.From), Amount: big.NewInt(0).Set(evt.Value.Int)})
+		}
+	}
+	for _, evt := range events.System_Remarked {
+		if e := get(evt.Phase); e != nil {
+			e.remarks = append(e.remarks, remarked{Sender: types.AccountID(evt.Sender), Hash: evt.Hash})
+		}
+	}
+
+	for index, e := range grouped {
+		if !e.success || len(e.transfers) == 0 {
+			delete(grouped, index)
+		}
+	}
+	return grouped
+}
+
+// linkDeposits attaches remarks to the transfers of an extrinsic. A transfer takes the remark paired with it
+// in the call tree, otherwise the remark of a System.Remarked event from the same sender. Without a decoded
+// extrinsic the transfers are returned without remarks, so they are held for a refund.
+func linkDeposits(events *extrinsicEvents, ext *signedExtrinsic, multiSign types.AccountID) []deposit {
+	var candidates []deposit
+	remarks := make(map[[32]byte]string)
+	if ext != nil && ext.Call != nil {
+		candidates = findDeposits(ext.Call, ext.Signer, multiSign)
+		for _, remark := range collectRemarks(ext.Call) {
+			remarks[blake2b.Sum256([]byte(remark))] = remark
+		}
+	}
+
+	usedCandidates := make([]bool, len(candidates))
+	usedRemarks := make([]bool, len(events.remarks))
+	deposits := make([]deposit, 0, len(events.transfers))
+	for _, transfer := range events.transfers {
+		d := deposit{Sender: transfer.Sender, Amount: transfer.Amount}
+		for i, candidate := range candidates {
+			if usedCandidates[i] || !candidate.HasRemark || candidate.Sender != d.Sender || candidate.Amount.Cmp(d.Amount) != 0 {
+				continue
+			}
+			usedCandidates[i] = true
+			d.Remark, d.HasRemark = candidate.Remark, true
+			break
+		}
+		if !d.HasRemark {
+			for i, r := range events.remarks {
+				remark, ok := remarks[r.Hash]
+				if usedRemarks[i] || r.Sender != d.Sender || !ok {
+					continue
+				}
+				usedRemarks[i] = true
+				d.Remark, d.HasRemark = remark, true
+				break
+			}
+		}
+		deposits = append(deposits, d)
+	}
+	return deposits
+}
+
+// multisigTransfers returns the transfers paid out by an as_multi of the relayers, either a single
+// transfer_keep_alive or the transfers of a Utility.batch_all. Any other call returns nil.
+func multisigTransfers(c *call) []Dest {
+	if !c.is("Multisig", "as_multi") {
+		return nil
+	}
+	nested, ok := c.Args["call"].(*call)
+	if !ok {
+		return nil
+	}
+	calls := []*call{nested}
+	if nested.is("Utility", "batch_all") {
+		calls, _ = nested.Args["calls"].([]*call)
+	}
+
+	var transfers []Dest
+	for _, t := range calls {
+		if !t.is("Balances", "transfer_keep_alive") {
+			return nil
+		}
+		to, ok := t.Args["dest"].(types.AccountID)
+		if !ok {
+			return nil
+		}
+		amount, ok := t.Args["value"].(*big.Int)
+		if !ok {
+			return nil
+		}
+		transfers = append(transfers, Dest{DestAddress: types.HexEncodeToString(to[:]), DestAmount: amount.String()})
+	}
+	return transfers
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cfgsrpc "github.com/centrifuge/go-substrate-rpc-client/v2"
+	cfclient "github.com/centrifuge/go-substrate-rpc-client/v2/client"
+	cfgethrpc "github.com/centrifuge/go-substrate-rpc-client/v2/gethrpc"
+	cfrpc "github.com/centrifuge/go-substrate-rpc-client/v2/rpc"
+	gsrpc "github.com/rjmand/go-substrate-rpc-client/v2"
+	"github.com/rjmand/go-substrate-rpc-client/v2/client"
+	gethrpc "github.com/rjmand/go-substrate-rpc-client/v2/gethrpc"
+	"github.com/rjmand/go-substrate-rpc-client/v2/rpc"
+	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+)
+
+// endpoint is the rpc connection shared by the connection, listener and writer of a chain. Both rpc
+// client libraries used by the chain are connected to the same url, which can be switched while the
+// chain runs. Calls in flight on the previous url fail and are retried by their callers.
+type endpoint struct {
+	url  string
+	cl   client.Client
+	cfCl cfclient.Client
+	lock sync.RWMutex
+}
+
+func dialEndpoint(url string) (*endpoint, error) {
+	cl, cfCl, err := dialClients(url)
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint{url: url, cl: cl, cfCl: cfCl}, nil
+}
+
+func dialClients(url string) (client.Client, cfclient.Client, error) {
+	cl, err := client.Connect(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfCl, err := cfclient.Connect(url)
+	if err != nil {
+		closeClient(cl)
+		return nil, nil, err
+	}
+	return cl, cfCl, nil
+}
+
+func closeClient(cl interface{}) {
+	if c, ok := cl.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
+
+// switchTo connects to url and replaces the current clients once the genesis hash of the new url
+// matches expected
+func (e *endpoint) switchTo(url string, expected types.Hash) error {
+	cl, cfCl, err := dialClients(url)
+	if err != nil {
+		return err
+	}
+	var genesis types.Hash
+	err = cl.Call(&genesis, "chain_getBlockHash", 0)
+	if err == nil && genesis != expected {
+		err = fmt.Errorf("endpoint %s serves chain %s, expected %s", url, genesis.Hex(), expected.Hex())
+	}
+	if err != nil {
+		closeClient(cl)
+		closeClient(cfCl)
+		return err
+	}
+
+	e.lock.Lock()
+	previous, cfPrevious := e.cl, e.cfCl
+	e.url, e.cl, e.cfCl = url, cl, cfCl
+	e.lock.Unlock()
+	closeClient(previous)
+	closeClient(cfPrevious)
+	return nil
+}
+
+func (e *endpoint) close() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	closeClient(e.cl)
+	closeClient(e.cfCl)
+}
+
+func (e *endpoint) URL() string {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.url
+}
+
+func (e *endpoint) client() client.Client {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.cl
+}
+
+func (e *endpoint) cfClient() cfclient.Client {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.cfCl
+}
+
+// api returns a SubstrateAPI following the url of the endpoint
+func (e *endpoint) api() (*gsrpc.SubstrateAPI, error) {
+	cl := &switchClient{e}
+	r, err := rpc.NewRPC(cl)
+	if err != nil {
+		return nil, err
+	}
+	return &gsrpc.SubstrateAPI{RPC: r, Client: cl}, nil
+}
+
+// cfAPI returns a SubstrateAPI of the centrifuge library following the url of the endpoint
+func (e *endpoint) cfAPI() (*cfgsrpc.SubstrateAPI, error) {
+	cl := &cfSwitchClient{e}
+	r, err := cfrpc.NewRPC(cl)
+	if err != nil {
+		return nil, err
+	}
+	return &cfgsrpc.SubstrateAPI{RPC: r, Client: cl}, nil
+}
+
+// switchClient sends every call to the current client of the endpoint
+type switchClient struct {
+	e *endpoint
+}
+
+func (s *switchClient) Call(result interface{}, method string, args ...interface{}) error {
+	return s.e.client().Call(result, method, args...)
+}
+
+func (s *switchClient) Subscribe(ctx context.Context, namespace, subscribeMethodSuffix, unsubscribeMethodSuffix,
+	notificationMethodSuffix string, channel interface{}, args ...interface{}) (*gethrpc.ClientSubscription, error) {
+	return s.e.client().Subscribe(ctx, namespace, subscribeMethodSuffix, unsubscribeMethodSuffix, notificationMethodSuffix, channel, args...)
+}
+
+func (s *switchClient) URL() string {
+	return s.e.URL()
+}
+
+// cfSwitchClient sends every call to the current centrifuge client of the endpoint
+type cfSwitchClient struct {
+	e *endpoint
+}
+
+func (s *cfSwitchClient) Call(result interface{}, method string, args ...interface{}) error {
+	return s.e.cfClient().Call(result, method, args...)
+}
+
+func (s *cfSwitchClient) Subscribe(ctx context.Context, namespace, subscribeMethodSuffix, unsubscribeMethodSuffix,
+	notificationMethodSuffix string, channel interface{}, args ...interface{}) (*cfgethrpc.ClientSubscription, error) {
+	return s.e.cfClient().Subscribe(ctx, namespace, subscribeMethodSuffix, unsubscribeMethodSuffix, notificationMethodSuffix, channel, args...)
+}
+
+func (s *cfSwitchClient) URL() string {
+	return s.e.URL()
+}
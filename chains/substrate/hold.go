@@ -0,0 +1,174 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rjman-self/platdot-utils/blockstore"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+type HoldReason string
+
+const (
+	HoldInvalidRecipient HoldReason = "InvalidRecipient"
+	HoldNoRemark         HoldReason = "NoRemark"
+	HoldBelowFee         HoldReason = "BelowFee"
+	HoldMultipleDeposits HoldReason = "MultipleDeposits"
+	HoldCancelled        HoldReason = "ProposalCancelled"
+)
+
+type RefundStatus string
+
+const (
+	RefundPending      RefundStatus = ""
+	Refunded           RefundStatus = "Refunded"
+	RefundUnrefundable RefundStatus = "Unrefundable"
+)
+
+// HeldDeposit is a transfer to the multisig account that was not bridged
+type HeldDeposit struct {
+	BlockNumber  BlockNumber   `json:"blockNumber"`
+	ExtrinsicId  MultiSignTxId `json:"extrinsicIndex"`
+	DepositIndex int           `json:"depositIndex,omitempty"`
+	Sender       string        `json:"sender"`
+	Amount       string        `json:"amount"`
+	Remark       string        `json:"remark"`
+	Reason       HoldReason    `json:"reason"`
+	Detail       string        `json:"detail"`
+	Time         time.Time     `json:"time"`
+	Refund       RefundStatus  `json:"refund,omitempty"`
+	RefundBlock  BlockNumber   `json:"refundBlock,omitempty"`
+}
+
+// same reports whether both refer to the same deposit
+func (d HeldDeposit) same(o HeldDeposit) bool {
+	return d.BlockNumber == o.BlockNumber && d.ExtrinsicId == o.ExtrinsicId && d.DepositIndex == o.DepositIndex
+}
+
+// holdQueue persists deposits which can not be bridged, so they can be refunded instead of getting lost
+type holdQueue struct {
+	path     string
+	deposits []HeldDeposit
+	lock     sync.Mutex
+}
+
+// newHoldQueue loads the hold queue for the chain/relayer pair, it is stored next to the blockstore.
+// Passing an empty string for path will cause it to use the home directory.
+func newHoldQueue(path string, chain msg.ChainId, relayer string) (*holdQueue, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, blockstore.PathPostfix)
+	}
+
+	q := &holdQueue{path: filepath.Join(path, fmt.Sprintf("%s-%d.hold", relayer, chain))}
+
+	data, err := ioutil.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &q.deposits)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse hold queue %s: %w", q.path, err)
+	}
+	return q, nil
+}
+
+// add stores a deposit, a deposit already in the queue is not added again
+func (q *holdQueue) add(d HeldDeposit) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, held := range q.deposits {
+		if held.same(d) {
+			return nil
+		}
+	}
+	if d.Time.IsZero() {
+		d.Time = time.Now().UTC()
+	}
+	q.deposits = append(q.deposits, d)
+	return q.save()
+}
+
+// list returns a copy of all held deposits
+func (q *holdQueue) list() []HeldDeposit {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	deposits := make([]HeldDeposit, len(q.deposits))
+	copy(deposits, q.deposits)
+	return deposits
+}
+
+// pending returns the deposits which have not been refunded yet
+func (q *holdQueue) pending() []HeldDeposit {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var deposits []HeldDeposit
+	for _, held := range q.deposits {
+		if held.Refund == RefundPending {
+			deposits = append(deposits, held)
+		}
+	}
+	return deposits
+}
+
+// setRefund updates the refund status of a held deposit
+func (q *holdQueue) setRefund(d HeldDeposit, status RefundStatus, refundBlock BlockNumber) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for i, held := range q.deposits {
+		if held.same(d) {
+			q.deposits[i].Refund = status
+			q.deposits[i].RefundBlock = refundBlock
+			return q.save()
+		}
+	}
+	return fmt.Errorf("no held deposit in block %d index %d", d.BlockNumber, d.ExtrinsicId)
+}
+
+// remove deletes a held deposit
+func (q *holdQueue) remove(d HeldDeposit) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for i, held := range q.deposits {
+		if held.same(d) {
+			q.deposits = append(q.deposits[:i], q.deposits[i+1:]...)
+			return q.save()
+		}
+	}
+	return nil
+}
+
+func (q *holdQueue) save() error {
+	if _, err := os.Stat(filepath.Dir(q.path)); os.IsNotExist(err) {
+		err = os.MkdirAll(filepath.Dir(q.path), os.ModePerm)
+		if err != nil {
+			return err
+		}
+	}
+
+	raw, err := json.MarshalIndent(q.deposits, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(q.path, raw, 0600)
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/JFJun/go-substrate-crypto/ss58"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+)
+
+func TestHoldQueue(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "hold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newHoldQueue(dir, 1, "relayer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := HeldDeposit{BlockNumber: 10, ExtrinsicId: 2, Amount: "1000", Remark: "atp1invalid", Reason: HoldInvalidRecipient}
+	if err = q.add(d); err != nil {
+		t.Fatal(err)
+	}
+	// Adding the same extrinsic again must not duplicate it
+	if err = q.add(d); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := newHoldQueue(dir, 1, "relayer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	held := loaded.list()
+	if len(held) != 1 {
+		t.Fatalf("Got: %d Expected: %d", len(held), 1)
+	}
+	if held[0].Remark != d.Remark || held[0].Reason != HoldInvalidRecipient {
+		t.Fatalf("unexpected deposit: %+v", held[0])
+	}
+
+	if err = loaded.remove(d); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.list()) != 0 {
+		t.Fatal("expected hold queue to be empty")
+	}
+}
+
+func TestRefundDest(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "hold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newHoldQueue(dir, 1, "relayer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &writer{}
+	w.setRefunds(q, big.NewInt(FixedFee))
+
+	pub := "0x50a80eb26a7fb43ff4f84ead705fc61c1d4074112e53f781a6b03c0c7504f663"
+	sender, err := ss58.Encode(types.MustHexDecodeString(pub), ss58.KsmPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held := HeldDeposit{BlockNumber: 120, ExtrinsicId: 3, Sender: sender, Amount: "1000000000000", Reason: HoldNoRemark}
+	dest, err := w.refundDest(held)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dest.DepositNonce != 1203 {
+		t.Fatalf("Got: %d Expected: %d", dest.DepositNonce, 1203)
+	}
+	if dest.DestAddress != pub {
+		t.Fatalf("Got: %s Expected: %s", dest.DestAddress, pub)
+	}
+	expected := big.NewInt(1000000000000 - FixedFee).String()
+	if dest.DestAmount != expected {
+		t.Fatalf("Got: %s Expected: %s", dest.DestAmount, expected)
+	}
+
+	// A deposit not covering the refund fee can not be refunded
+	held.Amount = big.NewInt(FixedFee).String()
+	if _, err = w.refundDest(held); err == nil {
+		t.Fatal("expected an error for a deposit below the refund fee")
+	}
+
+	if err = q.add(held); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.setRefund(held, RefundUnrefundable, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.pending()) != 0 {
+		t.Fatal("expected no pending refunds")
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/JFJun/go-substrate-crypto/ss58"
-	"github.com/rjman-self/go-polkadot-rpc-client/expand/polkadot"
-	"github.com/rjman-self/go-polkadot-rpc-client/models"
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
 	"strconv"
-
-	"github.com/rjman-self/go-polkadot-rpc-client/client"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/rjmand/go-substrate-rpc-client/v2/types"
 	"math/big"
@@ -19,30 +19,38 @@ import (
 
 	"github.com/ChainSafe/log15"
 	"github.com/rjman-self/Platdot/chains"
+	"github.com/rjman-self/Platdot/shared/bech32"
+	"github.com/rjman-self/Platdot/shared/screening"
+	subutils "github.com/rjman-self/Platdot/shared/substrate"
 	"github.com/rjman-self/platdot-utils/blockstore"
 	metrics "github.com/rjman-self/platdot-utils/metrics/types"
 	"github.com/rjman-self/platdot-utils/msg"
 )
 
 type listener struct {
-	name          string
-	chainId       msg.ChainId
-	startBlock    uint64
-	blockStore    blockstore.Blockstorer
-	conn          *Connection
-	router        chains.Router
-	log           log15.Logger
-	stop          <-chan int
-	sysErr        chan<- error
-	latestBlock   metrics.LatestBlock
-	metrics       *metrics.ChainMetrics
-	client        client.Client
-	multiSignAddr types.AccountID
-	currentTx     MultiSignTx
-	msTxAsMulti   map[MultiSignTx]MultiSigAsMulti
-	resourceId    msg.ResourceId
-	destId        msg.ChainId
-	relayer       Relayer
+	name            string
+	chainId         msg.ChainId
+	startBlock      uint64
+	blockStore      blockstore.Blockstorer
+	conn            *Connection
+	router          chains.Router
+	log             log15.Logger
+	stop            <-chan int
+	sysErr          chan<- error
+	latestBlock     metrics.LatestBlock
+	metrics         *metrics.ChainMetrics
+	prefix          []byte // ss58 network prefix of sender addresses
+	multiSignAddr   types.AccountID
+	msTxAsMulti     map[eventTypes.Hash]MultiSigAsMulti // Multisigs of the multisig account by call hash
+	msLock          sync.RWMutex
+	resourceId      msg.ResourceId
+	destId          msg.ChainId
+	relayer         Relayer
+	denyList        *screening.DenyList
+	denyLock        sync.RWMutex // Guards denyList, which is replaced on reload
+	holdQueue       *holdQueue
+	recipientPrefix string
+	codeUpdated     uint32 // Set when a runtime upgrade was seen, until the writer refreshed its metadata
 }
 
 // Frequency of polling for a new block
@@ -54,8 +62,11 @@ var KSM int64 = 1e12
 var FixedFee = KSM * 3 / 100
 var FeeRate int64 = 1000
 
+// Number of blocks an executed multisig is kept for the writer to claim
+var MultisigRetention BlockNumber = 600
+
 func NewListener(conn *Connection, name string, id msg.ChainId, startBlock uint64, log log15.Logger, bs blockstore.Blockstorer,
-	stop <-chan int, sysErr chan<- error, m *metrics.ChainMetrics, multiSignAddress types.AccountID, cli *client.Client,
+	stop <-chan int, sysErr chan<- error, m *metrics.ChainMetrics, multiSignAddress types.AccountID, prefix []byte,
 	resource msg.ResourceId, dest msg.ChainId, relayer Relayer) *listener {
 	return &listener{
 		name:          name,
@@ -68,9 +79,9 @@ func NewListener(conn *Connection, name string, id msg.ChainId, startBlock uint6
 		sysErr:        sysErr,
 		latestBlock:   metrics.LatestBlock{LastUpdated: time.Now()},
 		metrics:       m,
-		client:        *cli,
+		prefix:        prefix,
 		multiSignAddr: multiSignAddress,
-		msTxAsMulti:   make(map[MultiSignTx]MultiSigAsMulti, InitCapacity),
+		msTxAsMulti:   make(map[eventTypes.Hash]MultiSigAsMulti, InitCapacity),
 		resourceId:    resource,
 		destId:        dest,
 		relayer:       relayer,
@@ -81,10 +92,28 @@ func (l *listener) setRouter(r chains.Router) {
 	l.router = r
 }
 
+func (l *listener) setDenyList(d *screening.DenyList) {
+	l.denyLock.Lock()
+	l.denyList = d
+	l.denyLock.Unlock()
+}
+
+// screening returns the current deny-list, nil when screening is disabled
+func (l *listener) screening() *screening.DenyList {
+	l.denyLock.RLock()
+	defer l.denyLock.RUnlock()
+	return l.denyList
+}
+
+func (l *listener) setHoldQueue(q *holdQueue, recipientPrefix string) {
+	l.holdQueue = q
+	l.recipientPrefix = recipientPrefix
+}
+
 // start creates the initial subscription for all events
 func (l *listener) start() error {
 	// Check whether latest is less than starting block
-	header, err := l.client.Api.RPC.Chain.GetHeaderLatest()
+	header, err := l.conn.api.RPC.Chain.GetHeaderLatest()
 	if err != nil {
 		return err
 	}
@@ -122,7 +151,7 @@ func (l *listener) pollBlocks() error {
 			}
 
 			/// Get finalized block hash
-			finalizedHash, err := l.client.Api.RPC.Chain.GetFinalizedHead()
+			finalizedHash, err := l.conn.api.RPC.Chain.GetFinalizedHead()
 			if err != nil {
 				l.log.Error("Failed to fetch finalized hash", "err", err)
 				retry--
@@ -131,7 +160,7 @@ func (l *listener) pollBlocks() error {
 			}
 
 			// Get finalized block header
-			finalizedHeader, err := l.client.Api.RPC.Chain.GetHeader(finalizedHash)
+			finalizedHeader, err := l.conn.api.RPC.Chain.GetHeader(finalizedHash)
 			if err != nil {
 				l.log.Error("Failed to fetch finalized header", "err", err)
 				retry--
@@ -151,7 +180,7 @@ func (l *listener) pollBlocks() error {
 			}
 
 			/// Get hash for latest block, sleep and retry if not ready
-			hash, err := l.client.Api.RPC.Chain.GetBlockHash(currentBlock)
+			hash, err := l.conn.api.RPC.Chain.GetBlockHash(currentBlock)
 			if err != nil && err.Error() == ErrBlockNotReady.Error() {
 				time.Sleep(BlockRetryInterval)
 				continue
@@ -191,88 +220,260 @@ func (l *listener) pollBlocks() error {
 }
 
 func (l *listener) processBlock(hash types.Hash) error {
-	block, err := l.client.Api.RPC.Chain.GetBlock(hash)
+	header, err := l.conn.api.RPC.Chain.GetHeader(hash)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	currentBlock := int64(block.Block.Header.Number)
+	currentBlock := int64(header.Number)
 
-	resp, err := l.client.GetBlockByNumber(currentBlock)
+	events, err := l.conn.getEvents(hash)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	for _, e := range resp.Extrinsic {
-		// Current Extrinsic { Block, Index }
-		l.currentTx.BlockNumber = BlockNumber(currentBlock)
-		l.currentTx.MultiSignTxId = MultiSignTxId(e.ExtrinsicIndex)
-		msTx := MultiSigAsMulti{
-			DestAddress: e.MultiSigAsMulti.DestAddress,
-			DestAmount:  e.MultiSigAsMulti.DestAmount,
+	// The block is only needed to decode deposits and the calls of new multisigs
+	var extrinsics []string
+	grouped := groupEvents(events, l.multiSignAddr)
+	if len(grouped) > 0 || l.opensMultisig(events) {
+		extrinsics, err = l.getExtrinsics(hash)
+		if err != nil {
+			return err
 		}
+	}
 
-		if e.Type == polkadot.AsMultiNew {
-			l.log.Info("Find a MultiSign New extrinsic", "Block", currentBlock)
-			/// Mark New a MultiSign Transfer
-			l.markNew(e)
+	l.trackMultisigs(BlockNumber(currentBlock), events, extrinsics)
+
+	for _, d := range l.getDeposits(BlockNumber(currentBlock), grouped, extrinsics) {
+		l.log.Info("Find a deposit to the MultiSign account", "Block", currentBlock, "Index", d.ExtrinsicIndex)
+		err = l.bridgeDeposit(d)
+		if err != nil {
+			return err
 		}
-		if e.Type == polkadot.AsMultiApprove {
-			l.log.Info("Find a MultiSign Approve extrinsic", "Block", currentBlock)
-			/// Mark Vote(Approve)
-			l.markVote(msTx, e)
+	}
+
+	// The new runtime applies from the next block on
+	if len(events.System_CodeUpdated) > 0 {
+		l.log.Info("Runtime upgraded, updating metadata", "Block", currentBlock)
+		err = l.conn.updateMetatdata()
+		if err != nil {
+			return err
+		}
+		l.setCodeUpdated()
+	}
+	return nil
+}
+
+func (l *listener) setCodeUpdated() {
+	atomic.StoreUint32(&l.codeUpdated, 1)
+}
+
+// takeCodeUpdated reports whether a runtime upgrade was seen since the last call
+func (l *listener) takeCodeUpdated() bool {
+	return atomic.CompareAndSwapUint32(&l.codeUpdated, 1, 0)
+}
+
+// getDeposits returns the deposits to the multisig account of a block. Deposits are found from the
+// Balances.Transfer events of successful extrinsics, only those extrinsics are decoded to link their remarks.
+func (l *listener) getDeposits(blockNumber BlockNumber, grouped map[int]*extrinsicEvents, extrinsics []string) []deposit {
+	meta := l.conn.getMetadata()
+	var found []deposit
+	for i, raw := range extrinsics {
+		extEvents, ok := grouped[i]
+		if !ok {
+			continue
 		}
 
-		if e.Type == polkadot.AsMultiExecuted {
-			l.log.Info("Find a MultiSign Executed extrinsic", "Block", currentBlock)
-			// Find An existing multi-signed transaction in the record, and marks for executed status
-			l.markVote(msTx, e)
-			l.markExecution(msTx)
+		var ext *signedExtrinsic
+		data, err := types.HexDecodeString(raw)
+		if err == nil {
+			ext, err = decodeExtrinsic(&meta, data)
 		}
-		if e.Type == polkadot.UtilityBatch {
-			l.log.Info("Find a MultiSign Batch Extrinsic", "Block", currentBlock)
-			// Construct parameters of message
-			amount, ok := big.NewInt(0).SetString(e.Amount, 10)
-			if !ok {
-				fmt.Printf("parse transfer amount %v, amount.string %v\n", amount, amount.String())
-			}
-			receiveAmount := amount
-
-			fixedFee := big.NewInt(FixedFee)
-			additionalFee := big.NewInt(0).Div(amount, big.NewInt(FeeRate))
-			fee := big.NewInt(0).Add(fixedFee, additionalFee)
-
-			actualAmount := big.NewInt(0).Sub(amount, fee)
-			sendAmount := big.NewInt(0).Mul(actualAmount, big.NewInt(oneToken))
-
-			recipient := []byte(e.Recipient)
-			depositNonce, _ := strconv.ParseInt(strconv.FormatInt(currentBlock, 10)+strconv.FormatInt(int64(e.ExtrinsicIndex), 10), 10, 64)
-
-			m := msg.NewFungibleTransfer(
-				l.chainId,
-				l.destId,
-				msg.Nonce(depositNonce),
-				sendAmount,
-				l.resourceId,
-				recipient,
-			)
-			/// Validate whether a cross-chain transaction
-			receivePubAddress, _ := ss58.DecodeToPub(e.ToAddress)
-			receiveAddress := types.NewAddressFromAccountID(receivePubAddress)
-			if receiveAddress.AsAccountID == l.multiSignAddr {
-				fmt.Printf("KSM to AKSM, Amount is %v, Fee is %v, Actual_AKSM_Amount = %v\n", receiveAmount, fee, sendAmount)
-				l.log.Info("Ready to send AKSM...", "Amount", actualAmount, "Recipient", recipient)
-				l.submitMessage(m, err)
-				if err != nil {
-					l.log.Error("Submit message to Writer", "Error", err)
-					return err
-				}
-			}
+		if err != nil {
+			l.log.Warn("Unable to decode deposit extrinsic, remarks are not linked", "Block", blockNumber, "Index", i, "err", err)
 		}
+
+		deposits := linkDeposits(extEvents, ext, l.multiSignAddr)
+		for n := range deposits {
+			deposits[n].BlockNumber = blockNumber
+			deposits[n].ExtrinsicIndex = i
+			deposits[n].DepositIndex = n
+		}
+		found = append(found, deposits...)
+	}
+	return found
+}
+
+// bridgeDeposit sends a deposit to the Alaya chain, or holds it for a refund when it can not be bridged
+func (l *listener) bridgeDeposit(d deposit) error {
+	sender, _ := ss58.Encode(d.Sender[:], l.prefix)
+	amount := d.Amount
+	receiveAmount := amount
+
+	fee := depositFee(amount)
+	actualAmount := big.NewInt(0).Sub(amount, fee)
+	sendAmount := big.NewInt(0).Mul(actualAmount, big.NewInt(oneToken))
+
+	// Deposits without a remark or not covering the fee can not be bridged, hold them for a refund
+	if !d.HasRemark || strings.TrimSpace(d.Remark) == "" {
+		l.hold(d, sender, HoldNoRemark, errors.New("no remark"))
+		return nil
+	}
+	if actualAmount.Sign() <= 0 {
+		l.hold(d, sender, HoldBelowFee, fmt.Errorf("amount %s does not cover fee %s", amount, fee))
+		return nil
+	}
+	// The deposit nonce is made of block and extrinsic index, it can only identify one deposit per extrinsic
+	if d.DepositIndex > 0 {
+		l.hold(d, sender, HoldMultipleDeposits, fmt.Errorf("deposit %d of the extrinsic", d.DepositIndex))
+		return nil
+	}
+
+	// Only bridge to a strictly valid Alaya address, hold the deposit otherwise
+	recipientAddress, err := bech32.ParseAddress(d.Remark, l.recipientPrefix)
+	if err != nil {
+		l.hold(d, sender, HoldInvalidRecipient, err)
+		return nil
+	}
+	recipient := []byte(recipientAddress.Hex())
+	depositNonce := DepositNonce(d.BlockNumber, d.ExtrinsicIndex)
+
+	m := msg.NewFungibleTransfer(
+		l.chainId,
+		l.destId,
+		depositNonce,
+		sendAmount,
+		l.resourceId,
+		recipient,
+	)
+	if l.screening().Screen(m, sender, d.Remark, amount) != nil {
+		return nil
+	}
+
+	fmt.Printf("KSM to AKSM, Amount is %v, Fee is %v, Actual_AKSM_Amount = %v\n", receiveAmount, fee, sendAmount)
+	l.log.Info("Ready to send AKSM...", "Amount", actualAmount, "Recipient", recipientAddress.Hex())
+	l.submitMessage(m, err)
+	if err != nil {
+		l.log.Error("Submit message to Writer", "Error", err)
+		return err
 	}
 	return nil
 }
 
+// depositFee returns the fee kept from a deposit of amount bridged to the Alaya chain
+func depositFee(amount *big.Int) *big.Int {
+	additionalFee := big.NewInt(0).Div(amount, big.NewInt(FeeRate))
+	return big.NewInt(0).Add(big.NewInt(FixedFee), additionalFee)
+}
+
+// bridgedAmount returns the amount of the proposal made on the Alaya chain for a deposit of amount
+func bridgedAmount(amount *big.Int) *big.Int {
+	actualAmount := big.NewInt(0).Sub(amount, depositFee(amount))
+	return big.NewInt(0).Mul(actualAmount, big.NewInt(oneToken))
+}
+
+// holdCancelled holds the deposit of a proposal cancelled on the Alaya chain, so it is refunded.
+// The deposit nonce joins block number and extrinsic index, so every split of the nonce is tried
+// and the deposit must match the amount of the proposal.
+func (l *listener) holdCancelled(nonce msg.Nonce, amount *big.Int) error {
+	head, err := l.conn.api.RPC.Chain.GetHeaderLatest()
+	if err != nil {
+		return err
+	}
+
+	id := strconv.FormatUint(uint64(nonce), 10)
+	for i := 1; i < len(id); i++ {
+		// The extrinsic index is formatted without leading zeros
+		if id[i] == '0' && i < len(id)-1 {
+			continue
+		}
+		block, err := strconv.ParseUint(id[:i], 10, 64)
+		if err != nil || block > uint64(head.Number) {
+			continue
+		}
+		index, err := strconv.Atoi(id[i:])
+		if err != nil {
+			continue
+		}
+
+		deposits, err := l.depositsAt(BlockNumber(block))
+		if err != nil {
+			return err
+		}
+		for _, d := range deposits {
+			if d.ExtrinsicIndex == index && d.DepositIndex == 0 && bridgedAmount(d.Amount).Cmp(amount) == 0 {
+				sender, _ := ss58.Encode(d.Sender[:], l.prefix)
+				l.hold(d, sender, HoldCancelled, fmt.Errorf("proposal of nonce %d cancelled", nonce))
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no deposit of nonce %d and amount %s", nonce, amount)
+}
+
+// depositsAt returns the deposits to the multisig account in a block
+func (l *listener) depositsAt(number BlockNumber) ([]deposit, error) {
+	hash, err := l.conn.api.RPC.Chain.GetBlockHash(uint64(number))
+	if err != nil {
+		return nil, err
+	}
+	events, err := l.conn.getEvents(hash)
+	if err != nil {
+		return nil, err
+	}
+	grouped := groupEvents(events, l.multiSignAddr)
+	if len(grouped) == 0 {
+		return nil, nil
+	}
+
+	extrinsics, err := l.getExtrinsics(hash)
+	if err != nil {
+		return nil, err
+	}
+	return l.getDeposits(number, grouped, extrinsics), nil
+}
+
+// signedBlock is the part of a chain_getBlock result the listener uses. Extrinsics are kept as hex, they are
+// decoded with the metadata of the chain when needed.
+type signedBlock struct {
+	Block struct {
+		Extrinsics []string `json:"extrinsics"`
+	} `json:"block"`
+}
+
+// getExtrinsics returns the hex encoded extrinsics of the block with the given hash
+func (l *listener) getExtrinsics(hash types.Hash) ([]string, error) {
+	var block signedBlock
+	err := l.conn.api.Client.Call(&block, "chain_getBlock", hash.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("get block error: %w", err)
+	}
+	return block.Block.Extrinsics, nil
+}
+
+// hold records a deposit to the multisig account which can not be bridged
+func (l *listener) hold(d deposit, sender string, reason HoldReason, detail error) {
+	l.log.Warn("Deposit can not be bridged, holding it", "Block", d.BlockNumber, "Index", d.ExtrinsicIndex,
+		"From", sender, "Amount", d.Amount, "Remark", d.Remark, "Reason", reason, "err", detail)
+	if l.holdQueue == nil {
+		return
+	}
+
+	err := l.holdQueue.add(HeldDeposit{
+		BlockNumber:  d.BlockNumber,
+		ExtrinsicId:  MultiSignTxId(d.ExtrinsicIndex),
+		DepositIndex: d.DepositIndex,
+		Sender:       sender,
+		Amount:       d.Amount.String(),
+		Remark:       d.Remark,
+		Reason:       reason,
+		Detail:       detail.Error(),
+	})
+	if err != nil {
+		l.log.Error("Failed to store held deposit", "Block", d.BlockNumber, "Index", d.ExtrinsicIndex, "err", err)
+	}
+}
+
 // submitMessage inserts the chainId into the msg and sends it to the router
 func (l *listener) submitMessage(m msg.Message, err error) {
 	if err != nil {
@@ -286,40 +487,116 @@ func (l *listener) submitMessage(m msg.Message, err error) {
 	}
 }
 
-func (l *listener) markExecution(msTx MultiSigAsMulti) {
-	for k, ms := range l.msTxAsMulti {
-		if !ms.Executed && ms.DestAddress == msTx.DestAddress && ms.DestAmount == msTx.DestAmount {
-			exeMsTx := l.msTxAsMulti[k]
-			exeMsTx.Executed = true
-			l.msTxAsMulti[k] = exeMsTx
+// opensMultisig reports whether a multisig of the multisig account is opened in the block of events
+func (l *listener) opensMultisig(events *subutils.Events) bool {
+	for _, evt := range events.Multisig_NewMultisig {
+		if evt.ID == eventTypes.AccountID(l.multiSignAddr) {
+			return true
 		}
 	}
+	return false
 }
 
-func (l *listener) markVote(msTx MultiSigAsMulti, e *models.ExtrinsicResponse) {
-	for k, ms := range l.msTxAsMulti {
-		if !ms.Executed && ms.DestAddress == msTx.DestAddress && ms.DestAmount == msTx.DestAmount {
-			//l.log.Info("relayer succeed vote", "Address", e.FromAddress)
-			voteMsTx := l.msTxAsMulti[k]
-			voteMsTx.Others = append(voteMsTx.Others, e.MultiSigAsMulti.OtherSignatories)
-			l.msTxAsMulti[k] = voteMsTx
+// trackMultisigs records the approvals of the multisigs of the multisig account from the Multisig events of a block.
+// The transfers of a new multisig are decoded from its extrinsic, so other relayers can approve the same batch.
+func (l *listener) trackMultisigs(blockNumber BlockNumber, events *subutils.Events, extrinsics []string) {
+	multiSign := eventTypes.AccountID(l.multiSignAddr)
+
+	l.msLock.Lock()
+	defer l.msLock.Unlock()
+
+	for _, evt := range events.Multisig_NewMultisig {
+		if evt.ID != multiSign || !evt.Phase.IsApplyExtrinsic {
+			continue
+		}
+		l.log.Info("Find a MultiSign New extrinsic", "Block", blockNumber, "CallHash", evt.CallHash.Hex())
+		ms := MultiSigAsMulti{
+			OriginMsTx: MultiSignTx{BlockNumber: blockNumber, MultiSignTxId: MultiSignTxId(evt.Phase.AsApplyExtrinsic)},
+			Approvals:  []eventTypes.AccountID{evt.Who},
+		}
+		if index := int(evt.Phase.AsApplyExtrinsic); index < len(extrinsics) {
+			meta := l.conn.getMetadata()
+			data, err := types.HexDecodeString(extrinsics[index])
+			var ext *signedExtrinsic
+			if err == nil {
+				ext, err = decodeExtrinsic(&meta, data)
+			}
+			if err != nil {
+				l.log.Warn("Unable to decode multisig extrinsic", "Block", blockNumber, "Index", index, "err", err)
+			} else if ext.Call != nil {
+				ms.Transfers = multisigTransfers(ext.Call)
+			}
+		}
+		l.msTxAsMulti[evt.CallHash] = ms
+	}
+	for _, evt := range events.Multisig_MultisigApproval {
+		if evt.ID != multiSign {
+			continue
+		}
+		l.log.Info("Find a MultiSign Approve extrinsic", "Block", blockNumber, "CallHash", evt.CallHash.Hex())
+		ms := l.multisigAt(evt.CallHash, evt.TimePoint)
+		ms.Approvals = append(ms.Approvals, evt.Who)
+		l.msTxAsMulti[evt.CallHash] = ms
+	}
+	for _, evt := range events.Multisig_MultisigExecuted {
+		if evt.ID != multiSign {
+			continue
+		}
+		l.log.Info("Find a MultiSign Executed extrinsic", "Block", blockNumber, "CallHash", evt.CallHash.Hex())
+		if !evt.Result.Ok {
+			l.log.Error("MultiSign call failed", "Block", blockNumber, "CallHash", evt.CallHash.Hex(), "err", evt.Result.Error)
+		}
+		ms := l.multisigAt(evt.CallHash, evt.TimePoint)
+		ms.Approvals = append(ms.Approvals, evt.Who)
+		ms.Executed = true
+		ms.ExecutedBlock = blockNumber
+		l.msTxAsMulti[evt.CallHash] = ms
+	}
+	for _, evt := range events.Multisig_MultisigCancelled {
+		if evt.ID != multiSign {
+			continue
+		}
+		l.log.Info("Find a MultiSign Cancelled extrinsic", "Block", blockNumber, "CallHash", evt.CallHash.Hex())
+		delete(l.msTxAsMulti, evt.CallHash)
+	}
+
+	// Executed multisigs no writer claimed are forgotten, a later transfer may have the same call hash
+	for hash, ms := range l.msTxAsMulti {
+		if ms.Executed && blockNumber-ms.ExecutedBlock > MultisigRetention {
+			delete(l.msTxAsMulti, hash)
 		}
 	}
 }
 
-func (l *listener) markNew(e *models.ExtrinsicResponse) {
-	msTx := MultiSigAsMulti{
-		Executed:       false,
-		Threshold:      e.MultiSigAsMulti.Threshold,
-		MaybeTimePoint: e.MultiSigAsMulti.MaybeTimePoint,
-		DestAddress:    e.MultiSigAsMulti.DestAddress,
-		DestAmount:     e.MultiSigAsMulti.DestAmount,
-		Others:         nil,
-		StoreCall:      e.MultiSigAsMulti.StoreCall,
-		MaxWeight:      e.MultiSigAsMulti.MaxWeight,
-		OriginMsTx:     l.currentTx,
-	}
-	/// Mark voted
-	msTx.Others = append(msTx.Others, e.MultiSigAsMulti.OtherSignatories)
-	l.msTxAsMulti[l.currentTx] = msTx
+// multisigAt returns the tracked multisig of a call hash, or a new one opened at tp if it was opened
+// before the listener started
+func (l *listener) multisigAt(hash eventTypes.Hash, tp eventTypes.TimePoint) MultiSigAsMulti {
+	if ms, ok := l.msTxAsMulti[hash]; ok {
+		return ms
+	}
+	return MultiSigAsMulti{OriginMsTx: MultiSignTx{BlockNumber: BlockNumber(tp.Height), MultiSignTxId: MultiSignTxId(tp.Index)}}
+}
+
+func (l *listener) getMultisig(hash eventTypes.Hash) (MultiSigAsMulti, bool) {
+	l.msLock.RLock()
+	defer l.msLock.RUnlock()
+	ms, ok := l.msTxAsMulti[hash]
+	return ms, ok
+}
+
+// multisigs returns a copy of the tracked multisigs
+func (l *listener) multisigs() map[eventTypes.Hash]MultiSigAsMulti {
+	l.msLock.RLock()
+	defer l.msLock.RUnlock()
+	res := make(map[eventTypes.Hash]MultiSigAsMulti, len(l.msTxAsMulti))
+	for hash, ms := range l.msTxAsMulti {
+		res[hash] = ms
+	}
+	return res
+}
+
+func (l *listener) removeMultisig(hash eventTypes.Hash) {
+	l.msLock.Lock()
+	defer l.msLock.Unlock()
+	delete(l.msTxAsMulti, hash)
 }
@@ -0,0 +1,128 @@
+// Copyright 2020 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/shared/substrate/fakenode"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+const testRecipient = "0x8ba1f109551bd432803012645ac136ddd64dba72"
+
+func transferEvent(index uint32, from, to [32]byte, amount int64) fakenode.Event {
+	return fakenode.Event{
+		Phase:  fakenode.ApplyExtrinsic(index),
+		Module: "Balances",
+		Name:   "Transfer",
+		Args:   []interface{}{eventTypes.AccountID(from), eventTypes.AccountID(to), eventTypes.NewU128(*big.NewInt(amount))},
+	}
+}
+
+func TestProcessBlockDeposit(t *testing.T) {
+	ctx := newTestContext(t, 2)
+	meta := ctx.conn.getMetadata()
+	amount := int64(1000000000000)
+
+	deposit := batchCall(t, &meta, "Utility.batch_all", transferCall(t, &meta, testMultiSign, uint64(amount)), remarkCall(t, &meta, testRecipient))
+	noRemark := transferCall(t, &meta, testMultiSign, uint64(amount))
+	ctx.addBlock(t, fakenode.Block{
+		Extrinsics: [][]byte{
+			signedExtrinsicBytes(t, testSigner, remarkCall(t, &meta, "unrelated")),
+			signedExtrinsicBytes(t, testSigner, deposit),
+			signedExtrinsicBytes(t, testSigner, noRemark),
+		},
+		Events: []fakenode.Event{
+			fakenode.ExtrinsicSuccess(0),
+			transferEvent(1, testSigner, testMultiSign, amount),
+			fakenode.ExtrinsicSuccess(1),
+			transferEvent(2, testSigner, testMultiSign, amount),
+			fakenode.ExtrinsicSuccess(2),
+		},
+	})
+
+	expected := msg.NewFungibleTransfer(ThisChain, ForeignChain, DepositNonce(1, 1), bridgedAmount(big.NewInt(amount)),
+		TestResourceId, []byte(common.HexToAddress(testRecipient).Hex()))
+	select {
+	case m := <-ctx.router.msgs:
+		if !reflect.DeepEqual(m, expected) {
+			t.Fatalf("Got: %+v Expected: %+v", m, expected)
+		}
+	case <-time.After(TestTimeout):
+		t.Fatal("no message for the deposit")
+	}
+
+	// The transfer without a remark is held instead of bridged
+	select {
+	case m := <-ctx.router.msgs:
+		t.Fatalf("unexpected message: %+v", m)
+	default:
+	}
+}
+
+func TestProcessBlockFailedDeposit(t *testing.T) {
+	ctx := newTestContext(t, 2)
+	meta := ctx.conn.getMetadata()
+	amount := int64(1000000000000)
+
+	deposit := batchCall(t, &meta, "Utility.batch_all", transferCall(t, &meta, testMultiSign, uint64(amount)), remarkCall(t, &meta, testRecipient))
+	ctx.addBlock(t, fakenode.Block{
+		Extrinsics: [][]byte{signedExtrinsicBytes(t, testSigner, deposit)},
+		Events: []fakenode.Event{
+			transferEvent(0, testSigner, testMultiSign, amount),
+			{Phase: fakenode.ApplyExtrinsic(0), Module: "System", Name: "ExtrinsicFailed", Args: []interface{}{
+				eventTypes.DispatchError{HasModule: true, Module: 6, Error: 2},
+				eventTypes.DispatchInfo{Weight: fakenode.DefaultWeight, Class: eventTypes.DispatchClass{IsNormal: true}, PaysFee: eventTypes.Pays{IsYes: true}},
+			}},
+		},
+	})
+
+	select {
+	case m := <-ctx.router.msgs:
+		t.Fatalf("unexpected message: %+v", m)
+	default:
+	}
+}
+
+func TestProcessBlockMultisig(t *testing.T) {
+	ctx := newTestContext(t, 2)
+	meta := ctx.writer.getMetadata()
+	alice := eventTypes.NewAccountID(ctx.writer.relayer.key.PublicKey())
+
+	// Another relayer opens a batch with as_multi
+	dests := []Dest{{DestAddress: testDest.DestAddress, DestAmount: "2"}, {DestAddress: testDest.DestAddress, DestAmount: "1"}}
+	c, err := newBatchCall(meta, dests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := newAsMultiCall(meta, 2, []eventTypes.AccountID{alice}, nil, c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := CallHash(c)
+	ctx.addBlock(t, fakenode.Block{
+		Extrinsics: [][]byte{signedExtrinsicBytes(t, [32]byte(testOther), EncodeCall(mc))},
+		Events: []fakenode.Event{
+			{Phase: fakenode.ApplyExtrinsic(0), Module: "Multisig", Name: "NewMultisig", Args: []interface{}{testOther, eventTypes.AccountID(testMultiSign), hash}},
+			fakenode.ExtrinsicSuccess(0),
+		},
+	})
+
+	ms, ok := ctx.listener.getMultisig(hash)
+	if !ok || ms.Executed || !ms.approvedBy(testOther) {
+		t.Fatalf("unexpected multisig: %+v", ms)
+	}
+	if ms.OriginMsTx != (MultiSignTx{BlockNumber: 1, MultiSignTxId: 0}) {
+		t.Fatalf("Got: %+v Expected timepoint 1-0", ms.OriginMsTx)
+	}
+	if !reflect.DeepEqual(ms.Transfers, dests) {
+		t.Fatalf("Got: %+v Expected: %+v", ms.Transfers, dests)
+	}
+}
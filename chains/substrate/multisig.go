@@ -0,0 +1,376 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v2"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/shared/signer"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+	"github.com/rjman-self/platdot-utils/core"
+	"golang.org/x/crypto/blake2b"
+)
+
+var ErrMultisigNotFound = errors.New("multisig not found")
+
+// newTransferCall creates the transfer_keep_alive call paying dest.DestAmount to dest.DestAddress
+func newTransferCall(meta *types.Metadata, dest Dest) (types.Call, error) {
+	amount, ok := big.NewInt(0).SetString(dest.DestAmount, 10)
+	if !ok {
+		return types.Call{}, fmt.Errorf("invalid amount %q", dest.DestAmount)
+	}
+	recipient, err := types.NewMultiAddressFromHexAccountID(dest.DestAddress)
+	if err != nil {
+		return types.Call{}, fmt.Errorf("invalid recipient %q: %w", dest.DestAddress, err)
+	}
+	return types.NewCall(meta, string(utils.BalancesTransferKeepAliveMethod), recipient, types.NewUCompact(amount))
+}
+
+// maybeTimePoint encodes an optional timepoint, a nil timepoint opens a new multisig
+func maybeTimePoint(tp *TimePointSafe32) interface{} {
+	if tp == nil {
+		// An empty byte slice encodes as None
+		return []byte{}
+	}
+	return *tp
+}
+
+// newAsMultiCall wraps c in an as_multi of the relayers' multisig account
+func newAsMultiCall(meta *types.Metadata, threshold uint16, others []types.AccountID, tp *TimePointSafe32, c types.Call, maxWeight uint64) (types.Call, error) {
+	return types.NewCall(meta, string(utils.MultisigAsMulti), threshold, others, maybeTimePoint(tp), EncodeCall(c), false, types.Weight(maxWeight))
+}
+
+// newApproveAsMultiCall approves the call with the given hash without providing the call itself
+func newApproveAsMultiCall(meta *types.Metadata, threshold uint16, others []types.AccountID, tp *TimePointSafe32, callHash types.Hash, maxWeight uint64) (types.Call, error) {
+	return types.NewCall(meta, string(utils.MultisigApproveAsMulti), threshold, others, maybeTimePoint(tp), callHash, types.Weight(maxWeight))
+}
+
+// newCancelAsMultiCall cancels the multisig opened at tp, only its depositor can cancel it
+func newCancelAsMultiCall(meta *types.Metadata, threshold uint16, others []types.AccountID, tp timepoint, callHash types.Hash) (types.Call, error) {
+	return types.NewCall(meta, string(utils.MultisigCancelAsMulti), threshold, others, tp, callHash)
+}
+
+// CallHash is the hash the multisig pallet identifies a call by
+func CallHash(c types.Call) types.Hash {
+	return types.Hash(blake2b.Sum256(EncodeCall(c)))
+}
+
+// dispatchInfo is the response of payment_queryInfo
+type dispatchInfo struct {
+	Weight uint64 `json:"weight"`
+}
+
+// queryWeight returns the dispatch weight of c reported by payment_queryInfo
+func queryWeight(api *gsrpc.SubstrateAPI, c types.Call) (uint64, error) {
+	// The weight does not depend on the signature, an unsigned extrinsic is enough
+	ext, err := types.EncodeToHexString(types.NewExtrinsic(c))
+	if err != nil {
+		return 0, err
+	}
+	var info dispatchInfo
+	err = api.Client.Call(&info, "payment_queryInfo", ext)
+	if err != nil {
+		return 0, err
+	}
+	if info.Weight == 0 {
+		return 0, errors.New("payment_queryInfo returned no weight")
+	}
+	return info.Weight, nil
+}
+
+// signCall creates an extrinsic of c signed by s with its next account nonce
+func signCall(api *gsrpc.SubstrateAPI, meta *types.Metadata, s signer.Substrate, c types.Call) (types.Extrinsic, error) {
+	genesisHash, err := api.RPC.Chain.GetBlockHash(0)
+	if err != nil {
+		return types.Extrinsic{}, fmt.Errorf("get genesis hash: %w", err)
+	}
+	rv, err := api.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return types.Extrinsic{}, fmt.Errorf("get runtime version: %w", err)
+	}
+
+	key, err := types.CreateStorageKey(meta, "System", "Account", s.PublicKey(), nil)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
+	var accountInfo types.AccountInfo
+	ok, err := api.RPC.State.GetStorageLatest(key, &accountInfo)
+	if err != nil {
+		return types.Extrinsic{}, fmt.Errorf("get account info: %w", err)
+	}
+	if !ok {
+		return types.Extrinsic{}, fmt.Errorf("account %x not found", s.PublicKey())
+	}
+
+	o := types.SignatureOptions{
+		BlockHash:          genesisHash,
+		Era:                types.ExtrinsicEra{IsMortalEra: false},
+		GenesisHash:        genesisHash,
+		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
+		SpecVersion:        rv.SpecVersion,
+		Tip:                types.NewUCompactFromUInt(0),
+		TransactionVersion: rv.TransactionVersion,
+	}
+
+	ext := types.NewExtrinsic(c)
+	err = multiSign(&ext, s, o)
+	if err != nil {
+		return types.Extrinsic{}, fmt.Errorf("sign extrinsic: %w", err)
+	}
+	return ext, nil
+}
+
+// multiSign signs ext like Extrinsic.MultiSign, with the payload signed by s instead of a keyring pair
+func multiSign(ext *types.Extrinsic, s signer.Substrate, o types.SignatureOptions) error {
+	method, err := types.EncodeToBytes(ext.Method)
+	if err != nil {
+		return err
+	}
+	era := o.Era
+	if !o.Era.IsMortalEra {
+		era = types.ExtrinsicEra{IsImmortalEra: true}
+	}
+	payload, err := types.EncodeToBytes(types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{
+			Method:      method,
+			Era:         era,
+			Nonce:       o.Nonce,
+			Tip:         o.Tip,
+			SpecVersion: o.SpecVersion,
+			GenesisHash: o.GenesisHash,
+			BlockHash:   o.BlockHash,
+		},
+		TransactionVersion: o.TransactionVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	sig, err := s.Sign(payload)
+	if err != nil {
+		return err
+	}
+	ext.Signature = types.ExtrinsicSignatureV4{
+		Signer:    types.NewMultiAddressFromAccountID(s.PublicKey()),
+		Signature: types.MultiSignature{IsSr25519: true, AsSr25519: types.NewSignature(sig)},
+		Era:       era,
+		Nonce:     o.Nonce,
+		Tip:       o.Tip,
+	}
+	ext.Version |= types.ExtrinsicBitSigned
+	return nil
+}
+
+// multisigInfo is the Multisig.Multisigs storage entry of an open multisig
+type multisigInfo struct {
+	When      timepoint
+	Deposit   types.U128
+	Depositor types.AccountID
+	Approvals []types.AccountID
+}
+
+// PendingMultisig is a multisig of the relayers that is not executed yet
+type PendingMultisig struct {
+	CallHash  types.Hash
+	Height    uint32 // Block of the extrinsic opening the multisig
+	Index     uint32 // Index of the extrinsic opening the multisig
+	Deposit   *big.Int
+	Depositor types.AccountID
+	Approvals []types.AccountID
+}
+
+// MultisigClient inspects and resolves multisigs of the relayers outside of a running relayer.
+// It builds its calls the same way as the writer.
+type MultisigClient struct {
+	api       *gsrpc.SubstrateAPI
+	meta      *types.Metadata
+	account   types.AccountID // Account of the configured relayer
+	others    []types.AccountID
+	threshold uint16
+	multiSign types.AccountID
+	maxWeight uint64
+}
+
+// NewMultisigClient connects to the endpoint of a substrate chain config and checks that the runtime
+// supports the multisig calls. The relayer account is taken from cfg.From.
+func NewMultisigClient(cfg *core.ChainConfig) (*MultisigClient, error) {
+	config, err := parseChainConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := gsrpc.NewSubstrateAPI(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	// The target chain does not include the indices pallet
+	types.SetSerDeOptions(types.SerDeOptions{NoPalletIndices: true})
+
+	meta, err := api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, err
+	}
+	if err = checkCalls(meta, writerCalls); err != nil {
+		return nil, err
+	}
+	if err = checkCalls(meta, multisigCalls); err != nil {
+		return nil, err
+	}
+
+	return &MultisigClient{
+		api:       api,
+		meta:      meta,
+		account:   config.account,
+		others:    config.otherRelayers,
+		threshold: config.multiSignThreshold,
+		multiSign: config.multiSignAddress,
+		maxWeight: config.maxWeight,
+	}, nil
+}
+
+// MultiSignAddress returns the multisig account of the relayers
+func (m *MultisigClient) MultiSignAddress() types.AccountID {
+	return m.multiSign
+}
+
+// Pending lists the open multisigs of the relayers' multisig account
+func (m *MultisigClient) Pending() ([]PendingMultisig, error) {
+	entry, err := m.meta.FindStorageEntryMetadata("Multisig", "Multisigs")
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := entry.Hasher2()
+	if err != nil {
+		return nil, err
+	}
+	var zero types.Hash
+	if _, err = hasher.Write(zero[:]); err != nil {
+		return nil, err
+	}
+	key, err := types.CreateStorageKey(m.meta, "Multisig", "Multisigs", m.multiSign[:], zero[:])
+	if err != nil {
+		return nil, err
+	}
+	// Strip the hashed call hash to list every multisig of the account
+	prefix := key[:len(key)-len(hasher.Sum(nil))]
+
+	keys, err := m.api.RPC.State.GetKeysLatest(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []PendingMultisig
+	for _, k := range keys {
+		var info multisigInfo
+		ok, err := m.api.RPC.State.GetStorageLatest(k, &info)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		pending = append(pending, PendingMultisig{
+			CallHash:  types.NewHash(k[len(k)-len(types.Hash{}):]),
+			Height:    info.When.Height,
+			Index:     info.When.Index,
+			Deposit:   info.Deposit.Int,
+			Depositor: info.Depositor,
+			Approvals: info.Approvals,
+		})
+	}
+	return pending, nil
+}
+
+// Find returns the open multisig of the given call hash
+func (m *MultisigClient) Find(hash types.Hash) (PendingMultisig, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return PendingMultisig{}, err
+	}
+	for _, p := range pending {
+		if p.CallHash == hash {
+			return p, nil
+		}
+	}
+	return PendingMultisig{}, fmt.Errorf("%w: call hash %s", ErrMultisigNotFound, hash.Hex())
+}
+
+// ApproveCall approves p with approve_as_multi
+func (m *MultisigClient) ApproveCall(p PendingMultisig) (types.Call, error) {
+	tp := &TimePointSafe32{Height: types.NewOptionU32(types.U32(p.Height)), Index: types.U32(p.Index)}
+	return newApproveAsMultiCall(m.meta, m.threshold, m.others, tp, p.CallHash, m.maxWeight)
+}
+
+// ExecuteTransfersCall approves p with an as_multi carrying the transfers to dests, which executes them once
+// the threshold is reached. Several transfers are rebuilt as the Utility.batch_all the writer opens, in the
+// order of its deposit nonces. The call must match the call hash of p.
+func (m *MultisigClient) ExecuteTransfersCall(p PendingMultisig, dests []Dest) (types.Call, error) {
+	if len(dests) == 0 {
+		return types.Call{}, errors.New("no transfer to execute")
+	}
+	c, err := newBatchCall(m.meta, dests)
+	if err != nil {
+		return types.Call{}, err
+	}
+	if hash := CallHash(c); hash != p.CallHash {
+		return types.Call{}, fmt.Errorf("call hash %s of the %d transfers does not match %s", hash.Hex(), len(dests), p.CallHash.Hex())
+	}
+	weight, err := queryWeight(m.api, c)
+	if err != nil {
+		return types.Call{}, fmt.Errorf("query weight of the transfers: %w", err)
+	}
+	tp := &TimePointSafe32{Height: types.NewOptionU32(types.U32(p.Height)), Index: types.U32(p.Index)}
+	return newAsMultiCall(m.meta, m.threshold, m.others, tp, c, weight)
+}
+
+// CancelCall cancels p. Only its depositor can cancel a multisig, and only after expiry blocks.
+func (m *MultisigClient) CancelCall(p PendingMultisig, expiry uint32) (types.Call, error) {
+	if p.Depositor != m.account {
+		return types.Call{}, fmt.Errorf("multisig was opened by %x, only its depositor can cancel it", p.Depositor)
+	}
+	header, err := m.api.RPC.Chain.GetHeaderLatest()
+	if err != nil {
+		return types.Call{}, err
+	}
+	if uint32(header.Number) < p.Height+expiry {
+		return types.Call{}, fmt.Errorf("multisig opened at block %d has not expired, it expires at block %d", p.Height, p.Height+expiry)
+	}
+	return newCancelAsMultiCall(m.meta, m.threshold, m.others, timepoint{Height: p.Height, Index: p.Index}, p.CallHash)
+}
+
+// Submit signs c with s and waits for it to be included in a block
+func (m *MultisigClient) Submit(s signer.Substrate, c types.Call) (types.Hash, error) {
+	if types.NewAccountID(s.PublicKey()) != m.account {
+		return types.Hash{}, fmt.Errorf("key %#x is not the configured relayer", s.PublicKey())
+	}
+	ext, err := signCall(m.api, m.meta, s, c)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	sub, err := m.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case status := <-sub.Chan():
+			switch {
+			case status.IsInBlock:
+				return status.AsInBlock, nil
+			case status.IsDropped:
+				return types.Hash{}, errors.New("extrinsic dropped from network")
+			case status.IsInvalid:
+				return types.Hash{}, errors.New("extrinsic invalid")
+			}
+		case err := <-sub.Err():
+			return types.Hash{}, err
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"testing"
+
+	"github.com/ChainSafe/log15"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/shared/signer"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+)
+
+var testOther = types.NewAccountID(types.MustHexDecodeString("0x3333333333333333333333333333333333333333333333333333333333333333"))
+
+var testDest = Dest{
+	DestAddress: "0x50a80eb26a7fb43ff4f84ead705fc61c1d4074112e53f781a6b03c0c7504f663",
+	DestAmount:  "1000000000",
+}
+
+// testCallMetadata is the Polkadot example metadata with Utility.batch_all added, as in testMetadata
+func testCallMetadata(t *testing.T) *types.Metadata {
+	var meta types.Metadata
+	err := types.DecodeFromHexString(types.ExamplaryMetadataV12PolkadotString, &meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mod := range meta.AsMetadataV12.Modules {
+		if mod.Name == "Utility" {
+			batchAll := mod.Calls[0]
+			batchAll.Name = "batch_all"
+			meta.AsMetadataV12.Modules[i].Calls = append(mod.Calls, batchAll)
+		}
+	}
+	return &meta
+}
+
+func assertCall(t *testing.T, name string, c types.Call, err error, expected string) {
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded := types.HexEncodeToString(EncodeCall(c)); encoded != expected {
+		t.Fatalf("%s Got: %s Expected: %s", name, encoded, expected)
+	}
+}
+
+func TestMultisigCallVectors(t *testing.T) {
+	meta := testCallMetadata(t)
+	others := []types.AccountID{testOther}
+	tp := &TimePointSafe32{Height: types.NewOptionU32(120), Index: 3}
+
+	transfer, err := newTransferCall(meta, testDest)
+	assertCall(t, "transfer_keep_alive", transfer, err,
+		"0x06030050a80eb26a7fb43ff4f84ead705fc61c1d4074112e53f781a6b03c0c7504f66302286bee")
+
+	hash := CallHash(transfer)
+	if hash.Hex() != "0x6d2f784fb49c9f57f4e8505b9b59498ea42aa1344ca6a9786f5857bd861064a1" {
+		t.Fatalf("Got: %s Expected: %s", hash.Hex(), "0x6d2f784fb49c9f57f4e8505b9b59498ea42aa1344ca6a9786f5857bd861064a1")
+	}
+
+	// The first approval opens the multisig without a timepoint
+	c, err := newApproveAsMultiCall(meta, 2, others, nil, hash, 0)
+	assertCall(t, "approve_as_multi", c, err,
+		"0x1f020200043333333333333333333333333333333333333333333333333333333333333333"+
+			"006d2f784fb49c9f57f4e8505b9b59498ea42aa1344ca6a9786f5857bd861064a10000000000000000")
+
+	c, err = newApproveAsMultiCall(meta, 2, others, tp, hash, 0)
+	assertCall(t, "approve_as_multi", c, err,
+		"0x1f0202000433333333333333333333333333333333333333333333333333333333333333330178000000030000"+
+			"006d2f784fb49c9f57f4e8505b9b59498ea42aa1344ca6a9786f5857bd861064a10000000000000000")
+
+	c, err = newAsMultiCall(meta, 2, others, tp, transfer, 195000000)
+	assertCall(t, "as_multi", c, err,
+		"0x1f0102000433333333333333333333333333333333333333333333333333333333333333330178000000030000"+
+			"009c06030050a80eb26a7fb43ff4f84ead705fc61c1d4074112e53f781a6b03c0c7504f66302286bee00c0769f0b00000000")
+
+	c, err = newCancelAsMultiCall(meta, 2, others, timepoint{Height: 120, Index: 3}, hash)
+	assertCall(t, "cancel_as_multi", c, err,
+		"0x1f03020004333333333333333333333333333333333333333333333333333333333333333378000000030000"+
+			"006d2f784fb49c9f57f4e8505b9b59498ea42aa1344ca6a9786f5857bd861064a1")
+}
+
+func TestDecodeMultisigCall(t *testing.T) {
+	meta := testCallMetadata(t)
+	others := []types.AccountID{testOther}
+	transfer, err := newTransferCall(meta, testDest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := CallHash(transfer)
+
+	// The call hash of as_multi is taken from the carried call
+	c, err := newAsMultiCall(meta, 2, others, nil, transfer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := DecodeMultisigCall(testMetadata(t), signedExtrinsicBytes(t, testSigner, EncodeCall(c)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.Function != "as_multi" || mc.Signer != types.AccountID(testSigner) || mc.Threshold != 2 || mc.TimePoint != nil ||
+		mc.CallHash != hash || len(mc.Transfers) != 1 || !sameTransfer(mc.Transfers[0], testDest) {
+		t.Fatalf("unexpected call: %+v", mc)
+	}
+	if len(mc.Others) != 1 || mc.Others[0] != testOther {
+		t.Fatalf("Got: %x Expected: %x", mc.Others, others)
+	}
+
+	c, err = newApproveAsMultiCall(meta, 2, others, &TimePointSafe32{Height: types.NewOptionU32(120), Index: 3}, hash, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err = DecodeMultisigCall(testMetadata(t), signedExtrinsicBytes(t, testSigner, EncodeCall(c)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := MultiSignTx{BlockNumber: 120, MultiSignTxId: 3}
+	if mc.Function != "approve_as_multi" || mc.TimePoint == nil || *mc.TimePoint != expected || mc.CallHash != hash {
+		t.Fatalf("unexpected call: %+v", mc)
+	}
+
+	_, err = DecodeMultisigCall(testMetadata(t), signedExtrinsicBytes(t, testSigner, EncodeCall(transfer)))
+	if err != ErrNotMultisig {
+		t.Fatalf("Got: %v Expected: %s", err, ErrNotMultisig)
+	}
+}
+
+func TestMultisigClientCalls(t *testing.T) {
+	meta := testCallMetadata(t)
+	m := &MultisigClient{meta: meta, others: []types.AccountID{testOther}, threshold: 2, maxWeight: 1000}
+
+	transfer, err := newTransferCall(meta, testDest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := PendingMultisig{CallHash: CallHash(transfer), Height: 120, Index: 3}
+
+	c, err := m.ApproveCall(p)
+	assertCall(t, "approve_as_multi", c, err,
+		"0x1f0202000433333333333333333333333333333333333333333333333333333333333333330178000000030000"+
+			"006d2f784fb49c9f57f4e8505b9b59498ea42aa1344ca6a9786f5857bd861064a1e803000000000000")
+
+	// A transfer not matching the call hash is refused
+	dest := testDest
+	dest.DestAmount = "1"
+	if _, err = m.ExecuteTransfersCall(p, []Dest{dest}); err == nil {
+		t.Fatal("expected an error for a transfer not matching the call hash")
+	}
+
+	// Only the depositor can cancel
+	p.Depositor = testOther
+	if _, err = m.CancelCall(p, 0); err == nil {
+		t.Fatal("expected an error cancelling a multisig of another depositor")
+	}
+}
+
+func TestMultiSign(t *testing.T) {
+	meta := testCallMetadata(t)
+	transfer, err := newTransferCall(meta, testDest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newAsMultiCall(meta, 2, []types.AccountID{testOther}, nil, transfer, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisHash := types.NewHash(types.MustHexDecodeString("0x91b171bb158e2d3848fa23a9f1c25182fb8e20313b2c1eb49219da7a70ce90c3"))
+	o := types.SignatureOptions{
+		BlockHash:          genesisHash,
+		GenesisHash:        genesisHash,
+		Nonce:              types.NewUCompactFromUInt(7),
+		SpecVersion:        28,
+		Tip:                types.NewUCompactFromUInt(0),
+		TransactionVersion: 6,
+	}
+
+	// The extrinsic signed through a signer only differs by the random sr25519 signature
+	expected := types.NewExtrinsic(c)
+	err = expected.MultiSign(signature.TestKeyringPairAlice, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := types.NewExtrinsic(c)
+	err = multiSign(&ext, signer.NewSubstrate(signature.TestKeyringPairAlice), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := ext.Signature.Signature.AsSr25519
+	ext.Signature.Signature.AsSr25519 = expected.Signature.Signature.AsSr25519
+	encoded, _ := types.EncodeToHexString(ext)
+	encodedExpected, _ := types.EncodeToHexString(expected)
+	if encoded != encodedExpected {
+		t.Fatalf("Got: %s Expected: %s", encoded, encodedExpected)
+	}
+
+	method, _ := types.EncodeToBytes(ext.Method)
+	payload, _ := types.EncodeToBytes(types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{Method: method, Era: types.ExtrinsicEra{IsImmortalEra: true},
+			Nonce: o.Nonce, Tip: o.Tip, SpecVersion: o.SpecVersion, GenesisHash: o.GenesisHash, BlockHash: o.BlockHash},
+		TransactionVersion: o.TransactionVersion,
+	})
+	ok, err := signature.Verify(payload, sig[:], signature.TestKeyringPairAlice.URI)
+	if err != nil || !ok {
+		t.Fatalf("Got: %v %v Expected: a valid signature", ok, err)
+	}
+}
+
+func TestTrackMultisigs(t *testing.T) {
+	multiSign := types.NewAccountID(types.MustHexDecodeString("0x1111111111111111111111111111111111111111111111111111111111111111"))
+	relayer := types.NewAccountID(types.MustHexDecodeString("0x2222222222222222222222222222222222222222222222222222222222222222"))
+	hash := types.NewHash(types.MustHexDecodeString("0x6d2f784fb49c9f57f4e8505b9b59498ea42aa1344ca6a9786f5857bd861064a1"))
+	l := &listener{
+		log:           log15.Root(),
+		multiSignAddr: [32]byte(multiSign),
+		msTxAsMulti:   make(map[types.Hash]MultiSigAsMulti),
+	}
+
+	events := &utils.Events{}
+	events.Multisig_NewMultisig = []types.EventMultisigNewMultisig{{
+		Phase:    types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 3},
+		Who:      relayer,
+		ID:       multiSign,
+		CallHash: hash,
+	}}
+	l.trackMultisigs(120, events, nil)
+
+	ms, ok := l.getMultisig(hash)
+	if !ok || ms.Executed || !ms.approvedBy(relayer) || ms.approvedBy(testOther) {
+		t.Fatalf("unexpected multisig: %+v", ms)
+	}
+	if ms.OriginMsTx != (MultiSignTx{BlockNumber: 120, MultiSignTxId: 3}) {
+		t.Fatalf("Got: %+v Expected timepoint 120-3", ms.OriginMsTx)
+	}
+
+	events = &utils.Events{}
+	events.Multisig_MultisigExecuted = []types.EventMultisigExecuted{{
+		Who:       testOther,
+		TimePoint: types.TimePoint{Height: 120, Index: 3},
+		ID:        multiSign,
+		CallHash:  hash,
+		Result:    types.DispatchResult{Ok: true},
+	}}
+	l.trackMultisigs(121, events, nil)
+
+	ms, _ = l.getMultisig(hash)
+	if !ms.Executed || !ms.approvedBy(testOther) || len(ms.Approvals) != 2 {
+		t.Fatalf("unexpected multisig: %+v", ms)
+	}
+
+	// Multisigs of other accounts are ignored
+	events.Multisig_MultisigExecuted[0].ID = testOther
+	events.Multisig_MultisigExecuted[0].CallHash = types.Hash{}
+	l.trackMultisigs(122, events, nil)
+	if _, ok = l.getMultisig(types.Hash{}); ok {
+		t.Fatal("tracked a multisig of another account")
+	}
+}
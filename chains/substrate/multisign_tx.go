@@ -2,8 +2,6 @@ package substrate
 
 import (
 	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
-	"github.com/rjman-self/go-polkadot-rpc-client/expand"
-	"github.com/rjman-self/platdot-utils/msg"
 )
 
 type MultiSignTxId uint64
@@ -15,16 +13,27 @@ type MultiSignTx struct {
 	MultiSignTxId MultiSignTxId
 }
 
+// MultiSigAsMulti is a multisig of the multisig account, tracked by its call hash from the Multisig events
 type MultiSigAsMulti struct {
-	OriginMsTx       MultiSignTx
-	Executed         bool
-	Threshold        uint16
-	Others  		 []OtherSignatories
-	MaybeTimePoint   expand.TimePointSafe32
-	DestAddress      string
-	DestAmount       string
-	StoreCall        bool
-	MaxWeight        uint64
-	DepositNonce     msg.Nonce
-	YesVote          []types.AccountID
+	OriginMsTx    MultiSignTx // Extrinsic opening the multisig, its timepoint
+	Executed      bool
+	ExecutedBlock BlockNumber
+	Approvals     []types.AccountID
+	Transfers     []Dest // Transfers of the call, when it was opened with as_multi
+}
+
+func (ms MultiSigAsMulti) approvedBy(who types.AccountID) bool {
+	for _, a := range ms.Approvals {
+		if a == who {
+			return true
+		}
+	}
+	return false
+}
+
+func (ms MultiSigAsMulti) timePoint() *TimePointSafe32 {
+	return &TimePointSafe32{
+		Height: types.NewOptionU32(types.U32(ms.OriginMsTx.BlockNumber)),
+		Index:  types.U32(ms.OriginMsTx.MultiSignTxId),
+	}
 }
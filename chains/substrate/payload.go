@@ -0,0 +1,86 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/rjman-self/Platdot/shared/signer"
+	gsrpc "github.com/rjmand/go-substrate-rpc-client/v2"
+	"github.com/rjmand/go-substrate-rpc-client/v2/scale"
+	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+)
+
+// Bytes of a payload after its spec version: the transaction version, the genesis hash and the block hash
+const payloadTrailerLength = 4 + 32 + 32
+
+// NewPayloadDecoder returns the decoder of the extrinsic payloads of the runtime of meta, for the policy of the
+// signer daemon. Payloads of another spec version are rejected, as their calls may be indexed differently.
+func NewPayloadDecoder(meta *types.Metadata, specVersion types.U32) signer.PayloadDecoder {
+	return func(payload []byte) (*signer.PayloadCall, error) {
+		reader := bytes.NewReader(payload)
+		decoder := scale.NewDecoder(reader)
+		c, err := decodeCall(meta, decoder)
+		if err != nil {
+			return nil, err
+		}
+
+		var era types.ExtrinsicEra
+		var nonce, tip types.UCompact
+		var spec types.U32
+		for _, v := range []interface{}{&era, &nonce, &tip, &spec} {
+			if err = decoder.Decode(v); err != nil {
+				return nil, fmt.Errorf("decode payload: %w", err)
+			}
+		}
+		if spec != specVersion {
+			return nil, fmt.Errorf("payload of spec version %d, expected %d", spec, specVersion)
+		}
+		if reader.Len() != payloadTrailerLength {
+			return nil, fmt.Errorf("payload has %d bytes after its spec version, expected %d", reader.Len(), payloadTrailerLength)
+		}
+
+		res := &signer.PayloadCall{}
+		addPayloadCall(res, c)
+		return res, nil
+	}
+}
+
+// addPayloadCall adds the index and the transfer of c and of every call nested in it to res
+func addPayloadCall(res *signer.PayloadCall, c *call) {
+	res.Calls = append(res.Calls, [2]byte{c.Index.SectionIndex, c.Index.MethodIndex})
+	if to, ok := c.Args["dest"].(types.AccountID); ok && c.Module == "Balances" {
+		res.Transfers = append(res.Transfers, to)
+	}
+	for _, arg := range c.Args {
+		switch nested := arg.(type) {
+		case *call:
+			addPayloadCall(res, nested)
+		case []*call:
+			for _, n := range nested {
+				addPayloadCall(res, n)
+			}
+		}
+	}
+}
+
+// DialPayloadDecoder returns the decoder of the extrinsic payloads of the current runtime of the chain at url
+func DialPayloadDecoder(url string) (signer.PayloadDecoder, error) {
+	api, err := gsrpc.NewSubstrateAPI(url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeClient(api.Client)
+
+	meta, err := api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, fmt.Errorf("get metadata: %w", err)
+	}
+	rv, err := api.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return nil, fmt.Errorf("get runtime version: %w", err)
+	}
+	return NewPayloadDecoder(meta, rv.SpecVersion), nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/shared/signer"
+)
+
+const testSpecVersion = 28
+
+// testPayload returns the payload of an extrinsic making c, as signed by the relayer
+func testPayload(t *testing.T, c types.Call, specVersion types.U32) []byte {
+	method, err := types.EncodeToBytes(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := types.EncodeToBytes(types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{
+			Method:      method,
+			Era:         types.ExtrinsicEra{IsImmortalEra: true},
+			Nonce:       types.NewUCompactFromUInt(7),
+			Tip:         types.NewUCompactFromUInt(0),
+			SpecVersion: specVersion,
+		},
+		TransactionVersion: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// testSignerPolicy allows the multisig calls of the relayers paying with transfer_keep_alive
+func testSignerPolicy(t *testing.T, meta *types.Metadata) *signer.Policy {
+	policy := &signer.Policy{
+		DeniedAccounts: [][32]byte{testSigner, testMultiSign},
+		Decoder:        NewPayloadDecoder(testMetadata(t), testSpecVersion),
+	}
+	for _, name := range []string{"Multisig.as_multi", "Multisig.approve_as_multi", "Multisig.cancel_as_multi",
+		"Balances.transfer_keep_alive", "Utility.batch_all"} {
+		index, err := meta.FindCallIndex(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy.Calls = append(policy.Calls, [2]byte{index.SectionIndex, index.MethodIndex})
+	}
+	return policy
+}
+
+func TestPolicyCheckPayload(t *testing.T) {
+	meta := testCallMetadata(t)
+	policy := testSignerPolicy(t, meta)
+	others := []types.AccountID{testOther}
+
+	asMulti := func(c types.Call) types.Call {
+		res, err := newAsMultiCall(meta, 2, others, nil, c, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	transferTo := func(to types.AccountID) types.Call {
+		c, err := newTransferCall(meta, Dest{DestAddress: types.HexEncodeToString(to[:]), DestAmount: "1000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	transfer := transferTo(types.AccountID(testOther))
+	approve, err := newApproveAsMultiCall(meta, 2, others, nil, CallHash(transfer), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]types.Call{"as_multi": asMulti(transfer), "approve_as_multi": approve} {
+		if err = policy.CheckPayload(testPayload(t, c, testSpecVersion)); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	// An allowed as_multi wrapping a call the policy does not allow
+	recipient, err := types.NewMultiAddressFromHexAccountID(types.HexEncodeToString(testOther[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	malicious, err := types.NewCall(meta, "Balances.transfer", recipient, types.NewUCompact(big.NewInt(1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	denied := map[string][]byte{
+		"inner call":         testPayload(t, asMulti(malicious), testSpecVersion),
+		"inner call alone":   testPayload(t, malicious, testSpecVersion),
+		"pays the relayer":   testPayload(t, asMulti(transferTo(types.AccountID(testSigner))), testSpecVersion),
+		"pays the multisig":  testPayload(t, asMulti(transferTo(types.AccountID(testMultiSign))), testSpecVersion),
+		"other spec version": testPayload(t, asMulti(transfer), testSpecVersion+1),
+		"trailing bytes":     append(testPayload(t, asMulti(transfer), testSpecVersion), 0),
+	}
+	for name, payload := range denied {
+		if err = policy.CheckPayload(payload); !errors.Is(err, signer.ErrDenied) {
+			t.Fatalf("%s Got: %v Expected: %s", name, err, signer.ErrDenied)
+		}
+	}
+}
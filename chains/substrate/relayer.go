@@ -1,22 +1,22 @@
 package substrate
 
 import (
-	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/shared/signer"
 )
 
 type Relayer struct {
-	kr                 signature.KeyringPair
+	key                signer.Substrate
 	otherSignatories   []types.AccountID
 	totalRelayers      uint64
 	multiSignThreshold uint16
 	currentRelayer     uint64
 }
 
-func NewRelayer(kr signature.KeyringPair, otherSignatories []types.AccountID, totalRelayers uint64,
+func NewRelayer(key signer.Substrate, otherSignatories []types.AccountID, totalRelayers uint64,
 	multiSignThreshold uint16, currentRelayer uint64) Relayer {
 	return Relayer{
-		kr:                 kr,
+		key:                key,
 		otherSignatories:   otherSignatories,
 		totalRelayers:      totalRelayers,
 		multiSignThreshold: multiSignThreshold,
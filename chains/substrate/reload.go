@@ -0,0 +1,110 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rjman-self/Platdot/shared/screening"
+	"github.com/rjman-self/platdot-utils/core"
+)
+
+// restartOpts returns the options of next that differ from c and can not be applied by Reload.
+// The endpoint, MaxWeight, RefundFee, the batching options and the deny-list are reloadable.
+func (c *Config) restartOpts(next *Config) []string {
+	var opts []string
+	changed := func(opt string, differs bool) {
+		if differs {
+			opts = append(opts, opt)
+		}
+	}
+	changed("name", c.name != next.name)
+	changed("id", c.id != next.id)
+	changed("from", c.from != next.from)
+	changed(SignerOpt, c.signer != next.signer)
+	changed(StartBlockOpt, c.startBlock != next.startBlock)
+	changed(UseExtendedCallOpt, c.useExtendedCall != next.useExtendedCall)
+	changed(RelayersOpt, c.totalRelayers != next.totalRelayers || c.currentRelayer != next.currentRelayer ||
+		!reflect.DeepEqual(c.otherRelayers, next.otherRelayers))
+	changed(MultiSignThresholdOpt, c.multiSignThreshold != next.multiSignThreshold)
+	changed(MultiSignAddressOpt, c.multiSignAddress != next.multiSignAddress)
+	changed(DestIdOpt, c.destId != next.destId)
+	changed(ResourceIdOpt, c.resourceId != next.resourceId)
+	changed(RecipientPrefixOpt, c.recipientPrefix != next.recipientPrefix)
+	changed(SS58PrefixOpt, !bytes.Equal(c.ss58Prefix, next.ss58Prefix))
+	return opts
+}
+
+// CheckReload checks that Reload can apply chainCfg to the running chain
+func (c *Chain) CheckReload(chainCfg *core.ChainConfig) error {
+	_, err := c.checkReload(chainCfg)
+	return err
+}
+
+func (c *Chain) checkReload(chainCfg *core.ChainConfig) (*Config, error) {
+	next, err := parseChainConfig(chainCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+	if opts := c.config.restartOpts(next); len(opts) != 0 {
+		return nil, fmt.Errorf("changing %s requires a restart", strings.Join(opts, ", "))
+	}
+	return next, nil
+}
+
+// Reload applies the endpoint, MaxWeight, RefundFee, batching options and deny-list of chainCfg to the
+// running chain. Nothing is applied if any of them fails or another option changed.
+func (c *Chain) Reload(chainCfg *core.ChainConfig) error {
+	next, err := c.checkReload(chainCfg)
+	if err != nil {
+		return err
+	}
+
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+	current := c.config
+
+	// Everything that can fail is done before the running chain is changed
+	var denyList *screening.DenyList
+	if next.denyList != "" && next.denyList != current.denyList {
+		denyList, err = screening.NewDenyList(next.denyList, c.writer.log)
+		if err != nil {
+			return err
+		}
+	}
+	if next.endpoint != current.endpoint {
+		err = c.conn.Reconnect(next.endpoint)
+		if err != nil {
+			return fmt.Errorf("unable to connect to %s: %w", next.endpoint, err)
+		}
+	}
+
+	c.writer.setMaxWeight(next.maxWeight)
+	c.writer.setRefundFee(next.refundFee)
+	c.writer.setBatching(next.maxBatchSize, next.batchWindow)
+	if next.denyList != current.denyList {
+		c.setDenyList(denyList)
+	}
+	c.config = next
+	return nil
+}
+
+// setDenyList replaces the deny-list of the listener and watches the new one, nil disables screening
+func (c *Chain) setDenyList(denyList *screening.DenyList) {
+	if c.denyStop != nil {
+		close(c.denyStop)
+		c.denyStop = nil
+	}
+	if denyList != nil {
+		c.denyStop = make(chan int)
+		go denyList.Watch(c.denyStop)
+	}
+	c.listener.setDenyList(denyList)
+}
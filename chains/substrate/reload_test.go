@@ -0,0 +1,44 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRestartOpts(t *testing.T) {
+	current, err := parseChainConfig(validChainConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fees, limits, batching, the deny-list and the endpoint are reloadable
+	chainCfg := validChainConfig()
+	chainCfg.Endpoint = "ws://localhost:9945"
+	chainCfg.Opts[MaxWeightOpt] = "1000"
+	chainCfg.Opts[RefundFeeOpt] = "1"
+	chainCfg.Opts[MaxBatchSizeOpt] = "3"
+	chainCfg.Opts[DenyListOpt] = "denylist.json"
+	next, err := parseChainConfig(chainCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := current.restartOpts(next); len(opts) != 0 {
+		t.Fatalf("Got: %v Expected: none", opts)
+	}
+
+	// The relayer set and the chains of the bridge require a restart
+	chainCfg = validChainConfig()
+	chainCfg.Opts[CurrentRelayerNumberOpt] = "3"
+	chainCfg.Opts[DestIdOpt] = "3"
+	next, err = parseChainConfig(chainCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{RelayersOpt, DestIdOpt}
+	if opts := current.restartOpts(next); !reflect.DeepEqual(opts, expected) {
+		t.Fatalf("Got: %v Expected: %v", opts, expected)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+)
+
+var ErrIncompatibleRuntime = errors.New("incompatible runtime")
+
+// writerCalls are the calls the writer constructs, with the argument names it encodes in order
+var writerCalls = map[utils.Method][]string{
+	utils.BalancesTransferKeepAliveMethod: {"dest", "value"},
+	utils.MultisigAsMulti:                 {"threshold", "other_signatories", "maybe_timepoint", "call", "store_call", "max_weight"},
+	utils.MultisigApproveAsMulti:          {"threshold", "other_signatories", "maybe_timepoint", "call_hash", "max_weight"},
+	utils.UtilityBatchAll:                 {"calls"},
+}
+
+// multisigCalls are the calls the multisig tool constructs in addition to the writer calls
+var multisigCalls = map[utils.Method][]string{
+	utils.MultisigCancelAsMulti: {"threshold", "other_signatories", "timepoint", "call_hash"},
+}
+
+// checkCalls verifies that the given calls still match the runtime metadata
+func checkCalls(meta *types.Metadata, calls map[utils.Method][]string) error {
+	if !meta.IsMetadataV12 {
+		return fmt.Errorf("%w: unsupported metadata version %d", ErrIncompatibleRuntime, meta.Version)
+	}
+
+	for method, expected := range calls {
+		parts := strings.Split(string(method), ".")
+		fn, err := findFunction(meta, parts[0], parts[1])
+		if err != nil {
+			return fmt.Errorf("%w: missing call %s: %s", ErrIncompatibleRuntime, method, err)
+		}
+
+		var args []string
+		for _, arg := range fn.Args {
+			args = append(args, string(arg.Name))
+		}
+		if strings.Join(args, ",") != strings.Join(expected, ",") {
+			return fmt.Errorf("%w: %s takes (%s), expected (%s)", ErrIncompatibleRuntime, method,
+				strings.Join(args, ", "), strings.Join(expected, ", "))
+		}
+	}
+	return nil
+}
+
+func findFunction(meta *types.Metadata, module, function string) (types.FunctionMetadataV4, error) {
+	for _, mod := range meta.AsMetadataV12.Modules {
+		if string(mod.Name) != module {
+			continue
+		}
+		for _, fn := range mod.Calls {
+			if string(fn.Name) == function {
+				return fn, nil
+			}
+		}
+		return types.FunctionMetadataV4{}, fmt.Errorf("call %s.%s not found", module, function)
+	}
+	return types.FunctionMetadataV4{}, fmt.Errorf("module %s not found", module)
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+)
+
+func TestCheckCalls(t *testing.T) {
+	meta := *testCallMetadata(t)
+	err := checkCalls(&meta, writerCalls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = checkCalls(&meta, multisigCalls); err != nil {
+		t.Fatal(err)
+	}
+
+	// A runtime adding an argument to as_multi is incompatible
+	for i, mod := range meta.AsMetadataV12.Modules {
+		if mod.Name != "Multisig" {
+			continue
+		}
+		for j, fn := range mod.Calls {
+			if fn.Name == "as_multi" {
+				args := append(fn.Args, types.FunctionArgumentMetadata{Name: "extra", Type: "u8"})
+				meta.AsMetadataV12.Modules[i].Calls[j].Args = args
+			}
+		}
+	}
+	err = checkCalls(&meta, writerCalls)
+	if !errors.Is(err, ErrIncompatibleRuntime) {
+		t.Fatalf("expected ErrIncompatibleRuntime, got %v", err)
+	}
+
+	// A runtime without approve_as_multi names the missing call
+	meta = *testCallMetadata(t)
+	for i, mod := range meta.AsMetadataV12.Modules {
+		if mod.Name != "Multisig" {
+			continue
+		}
+		var calls []types.FunctionMetadataV4
+		for _, fn := range mod.Calls {
+			if fn.Name != "approve_as_multi" {
+				calls = append(calls, fn)
+			}
+		}
+		meta.AsMetadataV12.Modules[i].Calls = calls
+	}
+	err = checkCalls(&meta, writerCalls)
+	if !errors.Is(err, ErrIncompatibleRuntime) || !strings.Contains(err.Error(), "Multisig.approve_as_multi") {
+		t.Fatalf("Got: %v Expected: ErrIncompatibleRuntime naming Multisig.approve_as_multi", err)
+	}
+}
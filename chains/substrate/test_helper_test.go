@@ -1,159 +1,117 @@
-//// Copyright 2020 ChainSafe Systems
-//// SPDX-License-Identifier: LGPL-3.0-only
-//
+// Copyright 2020 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
 package substrate
 
-//
-//import (
-//	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
-//	"os"
-//	"testing"
-//
-//	"github.com/ChainSafe/platdot-utils/keystore"
-//	"github.com/ChainSafe/platdot-utils/msg"
-//	"github.com/ChainSafe/log15"
-//	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
-//	"github.com/ethereum/go-ethereum/common/hexutil"
-//	utils "github.com/rjman-self/Platdot/shared/substrate"
-//)
-//
-//const TestEndpoint = "ws://127.0.0.1:9944"
-//
-//const TestRelayerThreshold = 2
-//const TestChainId = 1
-//
-//var AliceKey = keystore.TestKeyRing.SubstrateKeys[keystore.AliceKey].AsKeyringPair()
-//var BobKey = keystore.TestKeyRing.SubstrateKeys[keystore.BobKey].AsKeyringPair()
-//
-//var TestLogLevel = log15.LvlTrace
-//var AliceTestLogger = newTestLogger("Alice")
-//var BobTestLogger = newTestLogger("Bob")
-//
-//var ThisChain msg.ChainId = 1
-//var ForeignChain msg.ChainId = 2
-//
-//var relayers = []types.AccountID{
-//	types.NewAccountID(AliceKey.PublicKey),
-//	types.NewAccountID(BobKey.PublicKey),
-//}
-//
-//var resources = map[msg.ResourceId]utils.Method{
-//	// These are taken from the Polkadot JS UI (Chain State -> Constants)
-//	msg.ResourceIdFromSlice(hexutil.MustDecode("0x000000000000000000000000000000c76ebe4a02bbc34786d860b355f5a5ce00")): utils.ExampleTransferMethod,
-//	msg.ResourceIdFromSlice(hexutil.MustDecode("0x000000000000000000000000000000e389d61c11e5fe32ec1735b3cd38c69501")): utils.ExampleMintErc721Method,
-//	msg.ResourceIdFromSlice(hexutil.MustDecode("0x000000000000000000000000000000f44be64d2de895454c3467021928e55e01")): utils.ExampleRemarkMethod,
-//}
-//
-//const relayerThreshold = 2
-//
-//type testContext struct {
-//	client         *utils.Client
-//	listener       *listener
-//	router         *mockRouter
-//	writerAlice    *writer
-//	writerBob      *writer
-//	latestOutNonce msg.Nonce
-//	latestInNonce  msg.Nonce
-//	lSysErr        chan error
-//	wSysErr        chan error
-//}
-//
-//var context testContext
-//
-//func TestMain(m *testing.M) {
-//	client, err := utils.CreateClient((*signature.KeyringPair)(AliceKey), TestEndpoint)
-//	if err != nil {
-//		panic(err)
-//	}
-//
-//	var nativeTokenId, hashId, nftTokenId []byte
-//
-//	err = utils.QueryConst(client, "Example", "NativeTokenId", &nativeTokenId)
-//	if err != nil {
-//		panic(err)
-//	}
-//
-//	err = utils.QueryConst(client, "Example", "HashId", &hashId)
-//	if err != nil {
-//		panic(err)
-//	}
-//	err = utils.QueryConst(client, "Example", "Erc721Id", &nftTokenId)
-//	if err != nil {
-//		panic(err)
-//	}
-//
-//	err = utils.InitializeChain(client, relayers, []msg.ChainId{ForeignChain}, resources, relayerThreshold)
-//	if err != nil {
-//		panic(err)
-//	}
-//
-//	aliceConn, bobConn, wSysErr, err := createAliceAndBobConnections()
-//	if err != nil {
-//		panic(err)
-//	}
-//	l, lSysErr, r, err := newTestListener(client, aliceConn)
-//	if err != nil {
-//		panic(err)
-//	}
-//	alice := NewWriter(aliceConn, AliceTestLogger, wSysErr, nil, true)
-//	bob := NewWriter(bobConn, BobTestLogger, wSysErr, nil, true)
-//	context = testContext{
-//		client:         client,
-//		listener:       l,
-//		router:         r,
-//		writerAlice:    alice,
-//		writerBob:      bob,
-//		latestInNonce:  0,
-//		latestOutNonce: 0,
-//		lSysErr:        lSysErr,
-//		wSysErr:        wSysErr,
-//	}
-//
-//	os.Exit(m.Run())
-//}
-//
-//func newTestLogger(name string) log15.Logger {
-//	tLog := log15.Root().New("chain", name)
-//	tLog.SetHandler(log15.LvlFilterHandler(TestLogLevel, tLog.GetHandler()))
-//	return tLog
-//}
-//
-//// createAliceConnection creates and starts a connection with the Alice keypair
-//func createAliceConnection() (*Connection, chan error, error) {
-//	sysErr := make(chan error)
-//	alice := NewConnection(TestEndpoint, "Alice", (*signature.KeyringPair)(AliceKey), AliceTestLogger, make(chan int), sysErr)
-//	err := alice.Connect()
-//	if err != nil {
-//		return nil, nil, err
-//	}
-//	return alice, sysErr, err
-//}
-//
-//// createAliceAndBobConnections creates and calls `Connect()` on two Connections using the Alice and Bob keypairs
-//func createAliceAndBobConnections() (*Connection, *Connection, chan error, error) {
-//	alice, sysErr, err := createAliceConnection()
-//	if err != nil {
-//		return nil, nil, nil, err
-//	}
-//
-//	bob := NewConnection(TestEndpoint, "Bob", (*signature.KeyringPair)(BobKey), AliceTestLogger, make(chan int), sysErr)
-//	err = bob.Connect()
-//	if err != nil {
-//		return nil, nil, nil, err
-//	}
-//
-//	return alice, bob, sysErr, nil
-//}
-//
-//// getFreeBalance queries the balance for an account, storing the result in `res`
-//func getFreeBalance(c *Connection, res *types.U128) {
-//	var acct types.AccountInfo
-//
-//	ok, err := c.queryStorage("System", "Account", c.key.PublicKey, nil, &acct)
-//	if err != nil {
-//		panic(err)
-//	} else if !ok {
-//		panic("no account data")
-//	}
-//	*res = acct.Data.Free
-//}
+import (
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/log15"
+	"github.com/JFJun/go-substrate-crypto/ss58"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/shared/signer"
+	"github.com/rjman-self/Platdot/shared/substrate/fakenode"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+)
+
+const TestTimeout = time.Second * 10
+
+var ThisChain msg.ChainId = 1
+var ForeignChain msg.ChainId = 2
+
+var TestResourceId = msg.ResourceIdFromSlice([]byte("KSM"))
+
+var TestLogger = newTestLogger("test")
+
+func newTestLogger(name string) log15.Logger {
+	tLog := log15.Root().New("chain", name)
+	tLog.SetHandler(log15.LvlFilterHandler(log15.LvlError, tLog.GetHandler()))
+	return tLog
+}
+
+// testRouter collects the messages sent by a listener
+type testRouter struct {
+	msgs chan msg.Message
+}
+
+func newTestRouter() *testRouter {
+	return &testRouter{msgs: make(chan msg.Message, 10)}
+}
+
+func (r *testRouter) Send(m msg.Message) error {
+	r.msgs <- m
+	return nil
+}
+
+// testContext is a listener and writer of a relayer connected to a fake node
+type testContext struct {
+	node     *fakenode.Node
+	conn     *Connection
+	router   *testRouter
+	listener *listener
+	writer   *writer
+	stop     chan int
+	sysErr   chan error
+}
+
+// newTestContext starts a fake node and connects a relayer of Alice to it. The multisig account of
+// the relayer is testMultiSign, with testOther as the other signatory.
+func newTestContext(t *testing.T, threshold uint16) *testContext {
+	meta, err := fakenode.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := fakenode.New(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(node.Close)
+
+	alice := signature.TestKeyringPairAlice
+	err = node.SetAccount(eventTypes.NewAccountID(alice.PublicKey), eventTypes.AccountInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &testContext{
+		node:   node,
+		router: newTestRouter(),
+		stop:   make(chan int),
+		sysErr: make(chan error, 1),
+	}
+	t.Cleanup(func() { close(ctx.stop) })
+
+	key := signer.NewSubstrate(alice)
+	ctx.conn = NewConnection(node.URL(), "fake", key, TestLogger, ctx.stop, ctx.sysErr)
+	err = ctx.conn.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ctx.conn.Close)
+
+	relayer := NewRelayer(key, []eventTypes.AccountID{testOther}, 1, threshold, 0)
+	ctx.listener = NewListener(ctx.conn, "fake", ThisChain, 0, TestLogger, nil, ctx.stop, ctx.sysErr, nil,
+		testMultiSign, ss58.PolkadotPrefix, TestResourceId, ForeignChain, relayer)
+	ctx.listener.setRouter(ctx.router)
+	ctx.writer, err = NewWriter(ctx.conn, ctx.listener, TestLogger, ctx.sysErr, nil, false, fakenode.DefaultWeight, relayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+// addBlock adds a block to the fake node and processes it with the listener
+func (ctx *testContext) addBlock(t *testing.T, b fakenode.Block) types.Hash {
+	hash, err := ctx.node.AddBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ctx.listener.processBlock(types.Hash(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Hash(hash)
+}
@@ -7,15 +7,18 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ChainSafe/log15"
+	"github.com/JFJun/go-substrate-crypto/ss58"
 	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v2"
 	"github.com/centrifuge/go-substrate-rpc-client/v2/rpc/author"
 	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
-	utils "github.com/rjman-self/Platdot/shared/substrate"
+	"github.com/rjman-self/Platdot/chains"
 	"github.com/rjman-self/platdot-utils/core"
 	metrics "github.com/rjman-self/platdot-utils/metrics/types"
 	"github.com/rjman-self/platdot-utils/msg"
 	"math/big"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,149 +26,220 @@ var _ core.Writer = &writer{}
 
 var TerminatedError = errors.New("terminated")
 
-const RoundInterval = time.Second * 6
-const oneToken = 1000000
-
-var NotExecuted = MultiSignTx{
-	BlockNumber:   -1,
-	MultiSignTxId: 0,
-}
+// Frequency of the multisig rounds of the relayer
+var RoundInterval = time.Second * 6
 
-var YesVoted = MultiSignTx{
-	BlockNumber:   -1,
-	MultiSignTxId: 1,
-}
+const oneToken = 1000000
 
 type writer struct {
-	meta       *types.Metadata
-	conn       *Connection
-	listener   *listener
-	log        log15.Logger
-	sysErr     chan<- error
-	metrics    *metrics.ChainMetrics
-	extendCall bool // Extend extrinsic calls to substrate with ResourceID.Used for backward compatibility with example pallet.
-	msApi      *gsrpc.SubstrateAPI
-	relayer    Relayer
-	maxWeight  uint64
-	messages   map[Dest]bool
+	meta         *types.Metadata
+	conn         *Connection
+	listener     *listener
+	log          log15.Logger
+	sysErr       chan<- error
+	metrics      *metrics.ChainMetrics
+	extendCall   bool // Extend extrinsic calls to substrate with ResourceID.Used for backward compatibility with example pallet.
+	msApi        *gsrpc.SubstrateAPI
+	relayer      Relayer
+	maxWeight    uint64
+	pending      []*redemption // Queued transfers, guarded by msgLock
+	batches      []*batch      // Multisigs approved by the relayer, guarded by msgLock
+	msgLock      sync.Mutex
+	maxBatchSize int
+	batchWindow  time.Duration
+	holdQueue    *holdQueue
+	refundFee    *big.Int
+	settingsLock sync.RWMutex // Guards maxWeight, maxBatchSize, batchWindow and refundFee, which are replaced on reload
+	metaLock     sync.RWMutex
+	specVersion  types.U32 // Spec version of meta
+	halted       uint32    // Set once the runtime is incompatible with the writer
+	haltOnce     sync.Once
 }
 
 func NewWriter(conn *Connection, listener *listener, log log15.Logger, sysErr chan<- error,
-	m *metrics.ChainMetrics, extendCall bool, weight uint64, relayer Relayer) *writer {
+	m *metrics.ChainMetrics, extendCall bool, weight uint64, relayer Relayer) (*writer, error) {
 
-	msApi, err := gsrpc.NewSubstrateAPI(conn.url)
+	msApi, err := conn.endpoint.cfAPI()
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("connect writer: %w", err)
 	}
 
+	// The target chain does not include the indices pallet
+	types.SetSerDeOptions(types.SerDeOptions{NoPalletIndices: true})
+
+	rv, err := msApi.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return nil, fmt.Errorf("get runtime version: %w", err)
+	}
 	meta, err := msApi.RPC.State.GetMetadataLatest()
 	if err != nil {
-		fmt.Printf("GetMetadataLatest err\n")
-		panic(err)
+		return nil, fmt.Errorf("get metadata: %w", err)
+	}
+	err = checkCalls(meta, writerCalls)
+	if err != nil {
+		return nil, err
 	}
 
 	return &writer{
-		meta:       meta,
-		conn:       conn,
-		listener:   listener,
-		log:        log,
-		sysErr:     sysErr,
-		metrics:    m,
-		extendCall: extendCall,
-		msApi:      msApi,
-		relayer:    relayer,
-		maxWeight:  weight,
-		messages:   make(map[Dest]bool, InitCapacity),
-	}
+		meta:         meta,
+		specVersion:  rv.SpecVersion,
+		conn:         conn,
+		listener:     listener,
+		log:          log,
+		sysErr:       sysErr,
+		metrics:      m,
+		extendCall:   extendCall,
+		msApi:        msApi,
+		relayer:      relayer,
+		maxWeight:    weight,
+		maxBatchSize: DefaultMaxBatchSize,
+		batchWindow:  DefaultBatchWindow,
+	}, nil
 }
-func (w *writer) ResolveMessage(m msg.Message) bool {
-	w.checkRepeat(m)
-	w.log.Info("Start a redeemTx...", "DepositNonce", m.DepositNonce)
 
-	/// Mark isProcessing
-	destMessage := Dest{
-		DepositNonce: m.DepositNonce,
-		DestAddress:  string(m.Payload[1].([]byte)),
-		DestAmount:   string(m.Payload[0].([]byte)),
-	}
-	w.messages[destMessage] = true
-
-	go func() {
-		// calculate spend time
-		start := time.Now()
-		defer func() {
-			cost := time.Since(start)
-			fmt.Printf("Relayer #%v finish depositNonce %v cost %v\n", w.relayer.currentRelayer, m.DepositNonce, cost)
-		}()
-
-		for {
-			isFinished, currentTx := w.redeemTx(m)
-			if isFinished {
-				var mutex sync.Mutex
-				mutex.Lock()
-
-				/// If currentTx is Vote
-				if currentTx == YesVoted {
-					//fmt.Printf("I have Vote, wait executing\n")
-					time.Sleep(RoundInterval * time.Duration(w.relayer.totalRelayers) / 2)
-				}
+func (w *writer) setRefunds(q *holdQueue, fee *big.Int) {
+	w.holdQueue = q
+	w.setRefundFee(fee)
+}
 
-				if currentTx != YesVoted && currentTx != NotExecuted {
-					w.log.Info("MultiSig extrinsic executed!", "DepositNonce", m.DepositNonce, "OriginBlock", currentTx.BlockNumber)
-					/// Delete Listener msTx
-					delete(w.listener.msTxAsMulti, currentTx)
-
-					var mutex sync.Mutex
-					mutex.Lock()
-					/// Delete Message
-					dm := Dest{
-						DepositNonce: m.DepositNonce,
-						DestAddress:  string(m.Payload[1].([]byte)),
-						DestAmount:   string(m.Payload[0].([]byte)),
-					}
-					delete(w.messages, dm)
-					mutex.Unlock()
-
-					w.log.Info("finish a redeemTx", "DepositNonce", m.DepositNonce)
-					break
-				}
-			}
-		}
-	}()
-	return true
+// setRefundFee replaces the fee kept from refunded deposits
+func (w *writer) setRefundFee(fee *big.Int) {
+	w.settingsLock.Lock()
+	w.refundFee = fee
+	w.settingsLock.Unlock()
 }
 
-func (w *writer) checkRepeat(m msg.Message) bool {
+func (w *writer) getRefundFee() *big.Int {
+	w.settingsLock.RLock()
+	defer w.settingsLock.RUnlock()
+	return w.refundFee
+}
+
+// setBatching sets the maximum number of transfers of a batch and how long the oldest queued transfer waits for others
+func (w *writer) setBatching(size int, window time.Duration) {
+	w.settingsLock.Lock()
+	w.maxBatchSize = size
+	w.batchWindow = window
+	w.settingsLock.Unlock()
+}
+
+func (w *writer) getBatching() (int, time.Duration) {
+	w.settingsLock.RLock()
+	defer w.settingsLock.RUnlock()
+	return w.maxBatchSize, w.batchWindow
+}
+
+// setMaxWeight replaces the weight of an executing approval when the call weight can not be queried
+func (w *writer) setMaxWeight(weight uint64) {
+	w.settingsLock.Lock()
+	w.maxWeight = weight
+	w.settingsLock.Unlock()
+}
+
+func (w *writer) getMaxWeight() uint64 {
+	w.settingsLock.RLock()
+	defer w.settingsLock.RUnlock()
+	return w.maxWeight
+}
+
+// start launches the payout of queued transfers and the refunding of held deposits,
+// a writer without a hold queue does not refund
+func (w *writer) start(stop <-chan int) {
+	go w.processRedemptions(stop)
+	if w.holdQueue == nil {
+		return
+	}
+	go w.refundHeld(stop)
+}
+
+// refundHeld periodically sends every pending held deposit back to its sender, minus the refund fee.
+// Refunds use the same multisig approval flow as redemptions.
+func (w *writer) refundHeld(stop <-chan int) {
+	var lock sync.Mutex
+	refunding := make(map[HeldDeposit]bool)
+
 	for {
-		isRepeat := false
-		/// Lock
-		var mutex sync.Mutex
-		mutex.Lock()
-		for dest := range w.messages {
-			if dest.DepositNonce != m.DepositNonce && dest.DestAmount == string(m.Payload[0].([]byte)) && dest.DestAddress == string(m.Payload[1].([]byte)) {
-				isRepeat = true
+		for _, held := range w.holdQueue.pending() {
+			id := HeldDeposit{BlockNumber: held.BlockNumber, ExtrinsicId: held.ExtrinsicId, DepositIndex: held.DepositIndex}
+			lock.Lock()
+			inProgress := refunding[id]
+			lock.Unlock()
+			if inProgress {
+				continue
+			}
+
+			dest, err := w.refundDest(held)
+			if err != nil {
+				w.log.Warn("Held deposit can not be refunded", "Block", held.BlockNumber, "Index", held.ExtrinsicId, "Sender", held.Sender, "err", err)
+				err = w.holdQueue.setRefund(held, RefundUnrefundable, 0)
+				if err != nil {
+					w.log.Error("Failed to update held deposit", "Block", held.BlockNumber, "Index", held.ExtrinsicId, "err", err)
+				}
+				continue
 			}
+
+			lock.Lock()
+			refunding[id] = true
+			lock.Unlock()
+
+			w.log.Info("Start a refund...", "Block", held.BlockNumber, "Index", held.ExtrinsicId, "Sender", held.Sender, "Amount", dest.DestAmount)
+			w.resolveTransfer(dest, func(executed MultiSignTx) {
+				err := w.holdQueue.setRefund(held, Refunded, executed.BlockNumber)
+				if err != nil {
+					w.log.Error("Failed to update held deposit", "Block", held.BlockNumber, "Index", held.ExtrinsicId, "err", err)
+				}
+				lock.Lock()
+				delete(refunding, id)
+				lock.Unlock()
+			})
 		}
-		mutex.Unlock()
-
-		/// Check Repeat
-		if isRepeat {
-			repeatTime := RoundInterval
-			w.log.Info("Meet a Repeat Transaction", "DepositNonce", m.DepositNonce, "Waiting", repeatTime)
-			time.Sleep(repeatTime)
-		} else {
-			break
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(RoundInterval):
 		}
 	}
-	return true
 }
 
-func (w *writer) redeemTx(m msg.Message) (bool, MultiSignTx) {
-	w.UpdateMetadate()
-	types.SetSerDeOptions(types.SerDeOptions{NoPalletIndices: true})
+// refundDest builds the transfer returning a held deposit to its sender
+func (w *writer) refundDest(held HeldDeposit) (Dest, error) {
+	amount, ok := big.NewInt(0).SetString(held.Amount, 10)
+	if !ok {
+		return Dest{}, fmt.Errorf("invalid amount %q", held.Amount)
+	}
+	fee := w.getRefundFee()
+	refundAmount := big.NewInt(0).Sub(amount, fee)
+	if refundAmount.Sign() <= 0 {
+		return Dest{}, fmt.Errorf("amount %s does not cover refund fee %s", amount, fee)
+	}
+
+	sender, err := ss58.DecodeToPub(held.Sender)
+	if err != nil {
+		return Dest{}, fmt.Errorf("invalid sender %q: %w", held.Sender, err)
+	}
+
+	// The refund nonce is made the same way as the deposit nonce of a bridged extrinsic
+	id := strconv.FormatInt(int64(held.BlockNumber), 10) + strconv.FormatInt(int64(held.ExtrinsicId), 10)
+	if held.DepositIndex > 0 {
+		id += strconv.Itoa(held.DepositIndex)
+	}
+	nonce, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return Dest{}, err
+	}
+
+	return Dest{
+		DepositNonce: msg.Nonce(nonce),
+		DestAddress:  types.HexEncodeToString(sender),
+		DestAmount:   refundAmount.String(),
+	}, nil
+}
 
-	// BEGIN: Create a call of transfer
-	method := string(utils.BalancesTransferKeepAliveMethod)
+func (w *writer) ResolveMessage(m msg.Message) bool {
+	if m.Type == chains.RefundTransfer {
+		return w.resolveRefund(m)
+	}
 
 	// Convert AKSM amount to KSM amount
 	amount := big.NewInt(0).SetBytes(m.Payload[0].([]byte))
@@ -176,175 +250,314 @@ func (w *writer) redeemTx(m msg.Message) (bool, MultiSignTx) {
 	additionalFee := big.NewInt(0).Div(receiveAmount, big.NewInt(FeeRate))
 	fee := big.NewInt(0).Add(fixedFee, additionalFee)
 	actualAmount := big.NewInt(0).Sub(receiveAmount, fee)
-	sendAmount := types.NewUCompact(actualAmount)
 	fmt.Printf("AKSM to KSM, Amount is %v, Fee is %v, Actual_KSM_Amount = %v\n", receiveAmount, fee, actualAmount)
 
-	// Get recipient of Polkadot
-	recipient, _ := types.NewMultiAddressFromHexAccountID(string(m.Payload[1].([]byte)))
-
-	// Create a transfer_keep_alive call
-	c, err := types.NewCall(
-		w.meta,
-		method,
-		recipient,
-		sendAmount,
-	)
+	w.log.Info("Start a redeemTx...", "DepositNonce", m.DepositNonce)
+	w.resolveTransfer(Dest{
+		DepositNonce: m.DepositNonce,
+		DestAddress:  string(m.Payload[1].([]byte)),
+		DestAmount:   actualAmount.String(),
+	}, nil)
+	return true
+}
 
+// resolveRefund holds the deposit of a proposal cancelled on the Alaya chain, it is then refunded
+// with the held deposits
+func (w *writer) resolveRefund(m msg.Message) bool {
+	amount := big.NewInt(0).SetBytes(m.Payload[0].([]byte))
+	w.log.Info("Refund the deposit of a cancelled proposal", "DepositNonce", m.DepositNonce, "Amount", amount)
+	err := w.listener.holdCancelled(m.DepositNonce, amount)
 	if err != nil {
-		fmt.Printf("NewCall err\n")
-		panic(err)
+		w.log.Error("Unable to refund the deposit of a cancelled proposal", "DepositNonce", m.DepositNonce, "err", err)
+		return false
 	}
+	return true
+}
 
-	// BEGIN: Create a call of MultiSignTransfer
-	mulMethod := string(utils.MultisigAsMulti)
-	var threshold = w.relayer.multiSignThreshold
-
-	// Get parameters of multiSignature
-	destAddress := string(m.Payload[1].([]byte))
-
-	defer func() {
-		/// Single thread send one time each round
-		time.Sleep(RoundInterval)
-	}()
+// resolveTransfer queues a multisig transfer of dest.DestAmount to dest.DestAddress until it is executed.
+// done is called once the multisig extrinsic has been executed.
+func (w *writer) resolveTransfer(dest Dest, done func(MultiSignTx)) {
+	w.msgLock.Lock()
+	defer w.msgLock.Unlock()
+	w.pending = append(w.pending, &redemption{dest: dest, done: done, received: time.Now()})
+}
 
+// processRedemptions pays out the queued transfers once per round. Transfers received together are
+// batched into a single multisig, other relayers approve the batch opened first.
+func (w *writer) processRedemptions(stop <-chan int) {
+	relayer := types.NewAccountID(w.relayer.key.PublicKey())
 	for {
-		processRound := (w.relayer.currentRelayer + uint64(m.DepositNonce)) % w.relayer.totalRelayers
-		round := w.getRound()
-		if round.blockRound.Uint64() == processRound {
-			//fmt.Printf("process the message in block #%v, round #%v, depositnonce is %v\n", round.blockHeight, processRound, m.DepositNonce)
-			// Try to find a exist MultiSignTx
-			var maybeTimePoint interface{}
-			maxWeight := types.Weight(0)
-
-			// Traverse all of matched Tx, included New、Approve、Executed
-			for _, ms := range w.listener.msTxAsMulti {
-				// Validate parameter
-				if ms.DestAddress == destAddress[2:] && ms.DestAmount == actualAmount.String() {
-					/// Once MultiSign Extrinsic is executed, stop sending Extrinsic to Polkadot
-					finished, executed := w.isFinish(ms)
-					if finished {
-						return finished, executed
-					}
-
-					/// Match the correct TimePoint
-					height := types.U32(ms.OriginMsTx.BlockNumber)
-					maybeTimePoint = TimePointSafe32{
-						Height: types.NewOptionU32(height),
-						Index:  types.U32(ms.OriginMsTx.MultiSignTxId),
-					}
-					maxWeight = types.Weight(w.maxWeight)
-					break
-				} else {
-					maybeTimePoint = []byte{}
-				}
-			}
+		select {
+		case <-stop:
+			return
+		case <-time.After(RoundInterval):
+		}
 
-			if len(w.listener.msTxAsMulti) == 0 {
-				maybeTimePoint = []byte{}
-			}
+		if w.isHalted() {
+			w.log.Error("Writer halted, dropping pending transfers")
+			return
+		}
+		w.redeemRound(relayer)
+	}
+}
 
-			if maxWeight == 0 {
-				w.log.Info("Try to make a New MultiSign Tx!", "depositNonce", m.DepositNonce)
-			} else {
-				_, height := maybeTimePoint.(TimePointSafe32).Height.Unwrap()
-				w.log.Info("Try to Approve a MultiSignTx!", "Block", height, "Index", maybeTimePoint.(TimePointSafe32).Index, "depositNonce", m.DepositNonce)
-			}
+// redeemRound batches the queued transfers and sends the approvals of the relayer for the current round
+func (w *writer) redeemRound(relayer types.AccountID) {
+	err := w.ensureMetadata()
+	if err != nil {
+		w.log.Error("Failed to update metadata", "err", err)
+		return
+	}
+	meta := w.getMetadata()
+	round, err := w.getRound()
+	if err != nil {
+		w.log.Error("Failed to get the round, skipping it", "err", err)
+		return
+	}
+	multisigs := w.listener.multisigs()
 
-			mc, err := types.NewCall(w.meta, mulMethod, threshold, w.relayer.otherSignatories, maybeTimePoint, EncodeCall(c), false, maxWeight)
-			if err != nil {
-				fmt.Printf("New MultiCall err\n")
-				panic(err)
-			}
-			///END: Create a call of MultiSignTransfer
-
-			///BEGIN: Submit a MultiSignExtrinsic to Polkadot
-			w.submitTx(mc)
-			return false, NotExecuted
-			///END: Submit a MultiSignExtrinsic to Polkadot
-		} else {
-			///Round over, wait a RoundInterval
-			time.Sleep(RoundInterval)
-		}
+	w.msgLock.Lock()
+	w.adoptBatches(meta, multisigs)
+	w.openBatch(meta, round, multisigs)
+	batches := make([]*batch, len(w.batches))
+	copy(batches, w.batches)
+	w.msgLock.Unlock()
+
+	for _, b := range batches {
+		w.approveBatch(meta, b, round, relayer)
 	}
 }
 
-func (w *writer) submitTx(c types.Call) {
-	// BEGIN: Get the essential information first
-	w.UpdateMetadate()
-	retryTimes := BlockRetryLimit
-	for {
-		// No more retries, stop submitting Tx
-		if retryTimes == 0 {
-			fmt.Printf("submit Tx failed, check it\n")
+// adoptBatches takes over the multisigs opened by other relayers whose transfers are all queued
+func (w *writer) adoptBatches(meta *types.Metadata, multisigs map[types.Hash]MultiSigAsMulti) {
+	for hash, ms := range multisigs {
+		if w.hasBatch(hash) {
+			continue
 		}
-		genesisHash, err := w.msApi.RPC.Chain.GetBlockHash(0)
-		if err != nil {
-			fmt.Printf("GetBlockHash err\n")
-			retryTimes--
+		matched := matchBatch(ms.Transfers, w.pending)
+		if matched == nil {
 			continue
 		}
-		rv, err := w.msApi.RPC.State.GetRuntimeVersionLatest()
-		if err != nil {
-			fmt.Printf("GetRuntimeVersionLatest err\n")
-			retryTimes--
+		dests := make([]Dest, len(matched))
+		for i, r := range matched {
+			dests[i] = r.dest
+		}
+		c, err := newBatchCall(meta, dests)
+		if err != nil || CallHash(c) != hash {
 			continue
 		}
 
-		key, err := types.CreateStorageKey(w.meta, "System", "Account", w.relayer.kr.PublicKey, nil)
-		if err != nil {
-			fmt.Printf("CreateStorageKey err\n")
-			retryTimes--
-			continue
+		b := &batch{redemptions: matched, call: c, hash: hash}
+		for _, r := range matched {
+			r.batch = b
 		}
-		// END: Get the essential information
-
-		// Validate account and get account information
-		var accountInfo types.AccountInfo
-		ok, err := w.msApi.RPC.State.GetStorageLatest(key, &accountInfo)
-		if err != nil || !ok {
-			fmt.Printf("GetStorageLatest err\n")
-			retryTimes--
-			continue
+		w.batches = append(w.batches, b)
+		w.log.Info("Approve a MultiSign batch of another relayer", "CallHash", hash.Hex(), "DepositNonces", b.nonces())
+	}
+}
+
+// openBatch starts a new batch in the round of the relayer, once the batch is full or the oldest queued
+// transfer waited for the batch window
+func (w *writer) openBatch(meta *types.Metadata, round Round, multisigs map[types.Hash]MultiSigAsMulti) {
+	var open []Dest
+	for _, ms := range multisigs {
+		if !ms.Executed {
+			open = append(open, ms.Transfers...)
 		}
-		// Extrinsic nonce
-		nonce := uint32(accountInfo.Nonce)
-
-		// Construct signature option
-		o := types.SignatureOptions{
-			BlockHash:          genesisHash,
-			Era:                types.ExtrinsicEra{IsMortalEra: false},
-			GenesisHash:        genesisHash,
-			Nonce:              types.NewUCompactFromUInt(uint64(nonce)),
-			SpecVersion:        rv.SpecVersion,
-			Tip:                types.NewUCompactFromUInt(0),
-			TransactionVersion: rv.TransactionVersion,
+	}
+	for _, b := range w.batches {
+		for _, r := range b.redemptions {
+			open = append(open, r.dest)
 		}
+	}
 
-		// Create and Sign the MultiSign
-		ext := types.NewExtrinsic(c)
-		err = ext.MultiSign(w.relayer.kr, o)
-		if err != nil {
-			fmt.Printf("MultiTx Sign err\n")
-			panic(err)
+	maxBatchSize, batchWindow := w.getBatching()
+	selected := selectBatch(w.pending, open, maxBatchSize)
+	if len(selected) == 0 {
+		return
+	}
+	oldest := selected[0].received
+	for _, r := range selected {
+		if r.received.Before(oldest) {
+			oldest = r.received
 		}
+	}
+	if len(selected) < maxBatchSize && time.Since(oldest) < batchWindow {
+		return
+	}
+	if !w.isRound(round, selected[0].dest.DepositNonce) {
+		return
+	}
 
-		// Do the transfer and track the actual status
-		_, _ = w.msApi.RPC.Author.SubmitAndWatchExtrinsic(ext)
-		//fmt.Printf("submit Tx, Relayer nonce is %v\n", nonce)
-		break
+	dests := make([]Dest, len(selected))
+	for i, r := range selected {
+		dests[i] = r.dest
 	}
+	c, err := newBatchCall(meta, dests)
+	if err != nil {
+		w.log.Error("Failed to create the MultiSign batch, dropping it", "DepositNonce", selected[0].dest.DepositNonce, "err", err)
+		w.drop(selected)
+		return
+	}
+	hash := CallHash(c)
+	if _, ok := multisigs[hash]; ok {
+		// Wait until the multisig of an identical batch is claimed
+		return
+	}
+
+	b := &batch{redemptions: selected, call: c, hash: hash}
+	for _, r := range selected {
+		r.batch = b
+	}
+	w.batches = append(w.batches, b)
+	w.log.Info("Open a MultiSign batch", "CallHash", hash.Hex(), "DepositNonces", b.nonces())
 }
 
-func (w *writer) getRound() Round {
-	finalizedHash, err := w.listener.client.Api.RPC.Chain.GetFinalizedHead()
+// approveBatch sends the approval of the relayer for a batch in its round. Relayers approve the call hash
+// with approve_as_multi, except the first approval which carries the call so that the other relayers can
+// approve the same batch, and the approval reaching the threshold which executes it.
+func (w *writer) approveBatch(meta *types.Metadata, b *batch, round Round, relayer types.AccountID) {
+	ms, ok := w.listener.getMultisig(b.hash)
+	if ok && ms.Executed {
+		w.listener.removeMultisig(b.hash)
+		w.finishBatch(b, ms.OriginMsTx)
+		return
+	}
+	/// If already voted, avoid sending duplicated Tx until being executed
+	if ok && ms.approvedBy(relayer) {
+		return
+	}
+	if !w.isRound(round, b.redemptions[0].dest.DepositNonce) {
+		return
+	}
+
+	var mc types.Call
+	var err error
+	threshold := w.relayer.multiSignThreshold
+	switch {
+	case ok && len(ms.Approvals)+1 >= int(threshold), !ok && threshold <= 1:
+		// The final approval executes the call, it must carry enough weight
+		weight, werr := queryWeight(w.msApi, b.call)
+		if werr != nil {
+			weight = w.getMaxWeight()
+			w.log.Warn("Failed to query call weight, using MaxWeight", "MaxWeight", weight, "err", werr)
+		}
+		w.log.Info("Try to Execute a MultiSign batch!", "CallHash", b.hash.Hex(), "DepositNonces", b.nonces())
+		var tp *TimePointSafe32
+		if ok {
+			tp = ms.timePoint()
+		}
+		mc, err = newAsMultiCall(meta, threshold, w.relayer.otherSignatories, tp, b.call, weight)
+	case !ok:
+		w.log.Info("Try to make a New MultiSign Tx!", "CallHash", b.hash.Hex(), "DepositNonces", b.nonces())
+		mc, err = newAsMultiCall(meta, threshold, w.relayer.otherSignatories, nil, b.call, 0)
+	default:
+		w.log.Info("Try to Approve a MultiSignTx!", "Block", ms.OriginMsTx.BlockNumber, "Index", ms.OriginMsTx.MultiSignTxId, "DepositNonces", b.nonces())
+		mc, err = newApproveAsMultiCall(meta, threshold, w.relayer.otherSignatories, ms.timePoint(), b.hash, 0)
+	}
 	if err != nil {
-		w.listener.log.Error("Writer Failed to fetch finalized hash", "err", err)
+		w.log.Error("Failed to create MultiSign call", "CallHash", b.hash.Hex(), "err", err)
+		return
+	}
+	err = w.submitTx(mc)
+	if err != nil {
+		w.log.Error("Failed to submit MultiSign call", "CallHash", b.hash.Hex(), "DepositNonces", b.nonces(), "err", err)
+		return
+	}
+	w.log.Info("Submitted MultiSign call", "CallHash", b.hash.Hex(), "DepositNonces", b.nonces())
+}
+
+// finishBatch removes an executed batch and reports its transfers as executed
+func (w *writer) finishBatch(b *batch, executed MultiSignTx) {
+	w.msgLock.Lock()
+	for i, other := range w.batches {
+		if other == b {
+			w.batches = append(w.batches[:i], w.batches[i+1:]...)
+			break
+		}
+	}
+	w.drop(b.redemptions)
+	w.msgLock.Unlock()
+
+	w.log.Info("MultiSig extrinsic executed!", "CallHash", b.hash.Hex(), "OriginBlock", executed.BlockNumber)
+	for _, r := range b.redemptions {
+		fmt.Printf("Relayer #%v finish depositNonce %v cost %v\n", w.relayer.currentRelayer, r.dest.DepositNonce, time.Since(r.received))
+		if r.done != nil {
+			r.done(executed)
+		}
+		w.log.Info("finish a redeemTx", "DepositNonce", r.dest.DepositNonce)
+	}
+}
+
+// drop removes redemptions from the queue, the caller must hold msgLock
+func (w *writer) drop(redemptions []*redemption) {
+	remove := make(map[*redemption]bool, len(redemptions))
+	for _, r := range redemptions {
+		remove[r] = true
+	}
+	pending := w.pending[:0]
+	for _, r := range w.pending {
+		if !remove[r] {
+			pending = append(pending, r)
+		}
+	}
+	w.pending = pending
+}
+
+func (w *writer) hasBatch(hash types.Hash) bool {
+	for _, b := range w.batches {
+		if b.hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// isRound reports whether the relayer sends the multisig transactions for a deposit nonce in this round
+func (w *writer) isRound(round Round, nonce msg.Nonce) bool {
+	processRound := (w.relayer.currentRelayer + uint64(nonce)) % w.relayer.totalRelayers
+	return round.blockRound.Uint64() == processRound
+}
+
+func (w *writer) submitTx(c types.Call) error {
+	meta := w.getMetadata()
+	var ext types.Extrinsic
+	for retryTimes := BlockRetryLimit; ; retryTimes-- {
+		// No more retries, stop submitting Tx
+		if retryTimes == 0 {
+			return errors.New("sign extrinsic: no retries left")
+		}
+
+		// Create and Sign the MultiSign, the signer may be a daemon that is briefly unavailable
+		var err error
+		ext, err = signCall(w.msApi, meta, w.relayer.key, c)
+		if err == nil {
+			break
+		}
+		w.log.Warn("Failed to sign Tx, retrying", "err", err)
+		time.Sleep(BlockRetryInterval)
+	}
+
+	// Do the transfer, its execution is seen by the listener
+	sub, err := w.msApi.RPC.Author.SubmitAndWatchExtrinsic(ext)
+	if err != nil {
+		return fmt.Errorf("submit extrinsic: %w", err)
+	}
+	defer sub.Unsubscribe()
+	return nil
+}
+
+// getRound returns the round of the latest finalized block
+func (w *writer) getRound() (Round, error) {
+	finalizedHash, err := w.conn.api.RPC.Chain.GetFinalizedHead()
+	if err != nil {
+		return Round{}, fmt.Errorf("fetch finalized hash: %w", err)
 	}
 
 	// Get finalized block header
-	finalizedHeader, err := w.listener.client.Api.RPC.Chain.GetHeader(finalizedHash)
+	finalizedHeader, err := w.conn.api.RPC.Chain.GetHeader(finalizedHash)
 	if err != nil {
-		w.listener.log.Error("Failed to fetch finalized header", "err", err)
+		return Round{}, fmt.Errorf("fetch finalized header: %w", err)
 	}
 
 	blockHeight := big.NewInt(int64(finalizedHeader.Number))
@@ -356,34 +569,7 @@ func (w *writer) getRound() Round {
 		blockRound:  blockRound,
 	}
 
-	return round
-}
-
-func (w *writer) isFinish(ms MultiSigAsMulti) (bool, MultiSignTx) {
-	/// Check isExecuted
-	if ms.Executed {
-		return true, ms.OriginMsTx
-	}
-
-	/// Check isVoted
-	/// if already voted, avoid sending duplicated Tx until being executed
-	for _, others := range ms.Others {
-		var isVote = true
-		for _, signatory := range others {
-			voter, _ := types.NewAddressFromHexAccountID(signatory)
-			relayer := types.NewAddressFromAccountID(w.relayer.kr.PublicKey)
-			if voter == relayer {
-				isVote = false
-			}
-		}
-
-		if isVote {
-			w.log.Info("relayer has vote, wait others!", "Relayer", w.relayer.currentRelayer, "Block", ms.OriginMsTx.BlockNumber, "Index", ms.OriginMsTx.MultiSignTxId)
-			return true, YesVoted
-		}
-	}
-
-	return false, NotExecuted
+	return round, nil
 }
 
 func (w *writer) watchSubmission(sub *author.ExtrinsicStatusSubscription) error {
@@ -408,9 +594,55 @@ func (w *writer) watchSubmission(sub *author.ExtrinsicStatusSubscription) error
 	}
 }
 
-func (w *writer) UpdateMetadate() {
-	meta, _ := w.msApi.RPC.State.GetMetadataLatest()
-	if meta != nil {
-		w.meta = meta
+func (w *writer) getMetadata() *types.Metadata {
+	w.metaLock.RLock()
+	defer w.metaLock.RUnlock()
+	return w.meta
+}
+
+// ensureMetadata refreshes the cached metadata when the spec version changed or the listener saw a
+// System.CodeUpdated event. The writer is halted if its calls no longer match the new metadata.
+func (w *writer) ensureMetadata() error {
+	rv, err := w.msApi.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return err
+	}
+
+	w.metaLock.Lock()
+	defer w.metaLock.Unlock()
+
+	codeUpdated := w.listener.takeCodeUpdated()
+	if rv.SpecVersion == w.specVersion && !codeUpdated {
+		return nil
+	}
+
+	w.log.Info("Runtime upgraded, updating metadata", "specVersion", rv.SpecVersion, "previous", w.specVersion)
+	meta, err := w.msApi.RPC.State.GetMetadataLatest()
+	if err != nil {
+		if codeUpdated {
+			w.listener.setCodeUpdated()
+		}
+		return err
 	}
+	err = checkCalls(meta, writerCalls)
+	if err != nil {
+		w.halt(fmt.Errorf("spec version %d: %w", rv.SpecVersion, err))
+		return err
+	}
+	w.meta = meta
+	w.specVersion = rv.SpecVersion
+	return nil
+}
+
+// halt stops the writer from sending further transactions and reports the error to core
+func (w *writer) halt(err error) {
+	w.haltOnce.Do(func() {
+		atomic.StoreUint32(&w.halted, 1)
+		w.log.Crit("Halting writer", "err", err)
+		w.sysErr <- err
+	})
+}
+
+func (w *writer) isHalted() bool {
+	return atomic.LoadUint32(&w.halted) == 1
 }
@@ -0,0 +1,171 @@
+// Copyright 2020 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	eventTypes "github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/shared/substrate/fakenode"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/rjmand/go-substrate-rpc-client/v2/types"
+)
+
+// redeemMessage is the message of a proposal executed on the Alaya chain paying 1 KSM to testDest
+func redeemMessage(nonce msg.Nonce) msg.Message {
+	amount := big.NewInt(0).Mul(big.NewInt(1000000000000), big.NewInt(oneToken))
+	return msg.NewFungibleTransfer(ForeignChain, ThisChain, nonce, amount, TestResourceId, []byte(testDest.DestAddress))
+}
+
+// submittedCall decodes the call of the extrinsic submitted at index, it must be signed by the relayer
+func submittedCall(t *testing.T, ctx *testContext, index int) *call {
+	submitted, err := ctx.node.WaitForSubmitted(index+1, TestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ctx.conn.getMetadata()
+	ext, err := decodeExtrinsic(&meta, submitted[index])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.Signer != types.NewAccountID(ctx.conn.key.PublicKey()) {
+		t.Fatalf("Got signer: %x Expected: %x", ext.Signer, ctx.conn.key.PublicKey())
+	}
+	return ext.Call
+}
+
+func TestRedeem(t *testing.T) {
+	ctx := newTestContext(t, 2)
+	ctx.writer.setBatching(DefaultMaxBatchSize, 0)
+	alice := eventTypes.NewAccountID(ctx.writer.relayer.key.PublicKey())
+
+	if !ctx.writer.ResolveMessage(redeemMessage(7)) {
+		t.Fatal("message not resolved")
+	}
+	ctx.writer.redeemRound(alice)
+
+	// The relayer opens the multisig with the call
+	c := submittedCall(t, ctx, 0)
+	if !c.is("Multisig", "as_multi") {
+		t.Fatalf("Got: %s.%s Expected: Multisig.as_multi", c.Module, c.Function)
+	}
+	expected := []Dest{{DestAddress: testDest.DestAddress, DestAmount: "969000000000"}}
+	if transfers := multisigTransfers(c); !reflect.DeepEqual(transfers, expected) {
+		t.Fatalf("Got: %+v Expected: %+v", transfers, expected)
+	}
+	batches := ctx.writer.batches
+	if len(batches) != 1 {
+		t.Fatalf("Got: %d batches Expected: 1", len(batches))
+	}
+	hash := batches[0].hash
+
+	// Once its approval is included the relayer waits for the others
+	submitted := ctx.node.Submitted()
+	ctx.addBlock(t, fakenode.Block{
+		Extrinsics: submitted,
+		Events: []fakenode.Event{
+			{Phase: fakenode.ApplyExtrinsic(0), Module: "Multisig", Name: "NewMultisig", Args: []interface{}{alice, eventTypes.AccountID(testMultiSign), hash}},
+			fakenode.ExtrinsicSuccess(0),
+		},
+	})
+	ctx.writer.redeemRound(alice)
+	if n := len(ctx.node.Submitted()); n != 1 {
+		t.Fatalf("Got: %d extrinsics Expected: 1", n)
+	}
+
+	// The other relayer executes the multisig
+	ctx.addBlock(t, fakenode.Block{
+		Extrinsics: [][]byte{{0x04, 0x00}},
+		Events: []fakenode.Event{
+			{Phase: fakenode.ApplyExtrinsic(0), Module: "Multisig", Name: "MultisigExecuted", Args: []interface{}{
+				testOther, eventTypes.TimePoint{Height: 1, Index: 0}, eventTypes.AccountID(testMultiSign), hash, eventTypes.DispatchResult{Ok: true},
+			}},
+			fakenode.ExtrinsicSuccess(0),
+		},
+	})
+	ctx.writer.redeemRound(alice)
+	if len(ctx.writer.pending) != 0 || len(ctx.writer.batches) != 0 {
+		t.Fatalf("transfer not finished: %d pending, %d batches", len(ctx.writer.pending), len(ctx.writer.batches))
+	}
+	if _, ok := ctx.listener.getMultisig(hash); ok {
+		t.Fatal("executed multisig still tracked")
+	}
+}
+
+func TestRedeemExecute(t *testing.T) {
+	ctx := newTestContext(t, 1)
+	ctx.writer.setBatching(DefaultMaxBatchSize, 0)
+	alice := eventTypes.NewAccountID(ctx.writer.relayer.key.PublicKey())
+	ctx.node.SetWeight(fakenode.DefaultWeight / 2)
+
+	ctx.writer.ResolveMessage(redeemMessage(7))
+	ctx.writer.ResolveMessage(redeemMessage(8))
+	ctx.writer.redeemRound(alice)
+
+	// With a threshold of 1 the first approval executes the batch of both transfers with the queried weight
+	c := submittedCall(t, ctx, 0)
+	if !c.is("Multisig", "as_multi") || len(multisigTransfers(c)) != 2 {
+		t.Fatalf("unexpected call: %+v", c)
+	}
+	if weight, _ := c.Args["max_weight"].(uint64); weight != fakenode.DefaultWeight/2 {
+		t.Fatalf("Got: %d Expected: %d", weight, fakenode.DefaultWeight/2)
+	}
+
+	// MaxWeight is used when the weight can not be queried
+	ctx.node.SetWeight(0)
+	ctx.writer.setMaxWeight(42)
+	round, err := ctx.writer.getRound()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.writer.approveBatch(ctx.writer.getMetadata(), ctx.writer.batches[0], round, alice)
+	c = submittedCall(t, ctx, 1)
+	if weight, _ := c.Args["max_weight"].(uint64); weight != 42 {
+		t.Fatalf("Got: %d Expected: %d", weight, 42)
+	}
+
+	select {
+	case err := <-ctx.sysErr:
+		t.Fatal(err)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestExecuteBatchTransfers(t *testing.T) {
+	ctx := newTestContext(t, 2)
+	ctx.writer.setBatching(DefaultMaxBatchSize, 0)
+	alice := eventTypes.NewAccountID(ctx.writer.relayer.key.PublicKey())
+
+	ctx.writer.ResolveMessage(redeemMessage(7))
+	ctx.writer.ResolveMessage(redeemMessage(8))
+	ctx.writer.redeemRound(alice)
+	if len(ctx.writer.batches) != 1 {
+		t.Fatalf("Got: %d batches Expected: 1", len(ctx.writer.batches))
+	}
+	p := PendingMultisig{CallHash: ctx.writer.batches[0].hash, Height: 1}
+
+	// The operator rebuilds the batch the writer opened from its transfers
+	m := &MultisigClient{api: ctx.writer.msApi, meta: ctx.writer.getMetadata(), others: []eventTypes.AccountID{testOther}, threshold: 2}
+	dest := Dest{DestAddress: testDest.DestAddress, DestAmount: "969000000000"}
+	c, err := m.ExecuteTransfersCall(p, []Dest{dest, dest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ctx.conn.getMetadata()
+	mc, err := DecodeMultisigCall(&meta, signedExtrinsicBytes(t, testSigner, EncodeCall(c)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.Function != "as_multi" || mc.CallHash != p.CallHash || len(mc.Transfers) != 2 {
+		t.Fatalf("unexpected call: %+v", mc)
+	}
+
+	// A single transfer of the batch does not match its call hash
+	if _, err = m.ExecuteTransfersCall(p, []Dest{dest}); err == nil {
+		t.Fatal("expected an error for transfers not matching the call hash")
+	}
+}
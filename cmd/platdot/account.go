@@ -4,15 +4,23 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	log "github.com/ChainSafe/log15"
+	"github.com/JFJun/go-substrate-crypto/ss58"
 	gokeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/Platdot/shared/bech32"
 	"github.com/rjman-self/platdot-utils/crypto"
 	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
 	"github.com/rjman-self/platdot-utils/crypto/sr25519"
@@ -433,3 +441,292 @@ func keystoreDir(keyPath string) (keystorepath string, err error) {
 
 	return keystorepath, nil
 }
+
+// keyField is a labelled public value of a key, eg. its address on a network
+type keyField struct {
+	name  string
+	value string
+}
+
+// confirmInput is read to confirm destructive commands
+var confirmInput io.Reader = os.Stdin
+
+// handlePasswordCmd encrypts a keystore file with a new password
+func handlePasswordCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	path, err := keyFilePath(ctx.Args().First(), dHandler.datadir)
+	if err != nil {
+		return err
+	}
+
+	var password, newPassword []byte
+	if pwdflag := ctx.String(config.PasswordFlag.Name); pwdflag != "" {
+		password = []byte(pwdflag)
+	}
+	if pwdflag := ctx.String(config.NewPasswordFlag.Name); pwdflag != "" {
+		newPassword = []byte(pwdflag)
+	}
+
+	err = changePassword(path, password, newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	return nil
+}
+
+// handleExportCmd prints the public key of a keystore file and the addresses it has on each network
+func handleExportCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	path, err := keyFilePath(ctx.Args().First(), dHandler.datadir)
+	if err != nil {
+		return err
+	}
+
+	fields, err := exportKey(path)
+	if err != nil {
+		return fmt.Errorf("failed to export key: %w", err)
+	}
+	printKeyFields(fields)
+	return nil
+}
+
+// handleInspectCmd prints the type of a key file and checks that its public key matches its address
+func handleInspectCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	path, err := keyFilePath(ctx.Args().First(), dHandler.datadir)
+	if err != nil {
+		return err
+	}
+
+	fields, err := inspectKey(path)
+	if err != nil {
+		return fmt.Errorf("failed to inspect key: %w", err)
+	}
+	printKeyFields(fields)
+	return nil
+}
+
+// handleDeleteCmd removes a keystore file once the user confirmed it
+func handleDeleteCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	path, err := keyFilePath(ctx.Args().First(), dHandler.datadir)
+	if err != nil {
+		return err
+	}
+
+	_, err = deleteKey(path)
+	if err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+	return nil
+}
+
+// keyFilePath returns the path of the key file given either as a path or as the address it is stored under
+// in the keystore
+func keyFilePath(key, datadir string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("Must provide a key file or address.")
+	}
+	if info, err := os.Stat(key); err == nil && !info.IsDir() {
+		return filepath.Abs(key)
+	}
+
+	keystorepath, err := keystoreDir(datadir)
+	if err != nil {
+		return "", fmt.Errorf("could not get keystore directory: %w", err)
+	}
+	fp := filepath.Join(keystorepath, strings.TrimSuffix(key, ".key")+".key")
+	if _, err = os.Stat(fp); err != nil {
+		return "", fmt.Errorf("key file not found: %s", key)
+	}
+	return fp, nil
+}
+
+// readKeystore reads the unencrypted fields of a keystore file
+func readKeystore(path string) (*keystore.EncryptedKeystore, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+
+	ks := new(keystore.EncryptedKeystore)
+	err = json.Unmarshal(data, ks)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file contents: %w", err)
+	}
+	if ks.Type != crypto.Secp256k1Type && ks.Type != crypto.Sr25519Type {
+		return nil, fmt.Errorf("%s is not a bridge keystore file", path)
+	}
+	return ks, nil
+}
+
+// changePassword decrypts the keystore file with password and writes it encrypted with newPassword.
+// The passwords are prompted for when nil. The file is replaced only once the new one is written.
+func changePassword(path string, password, newPassword []byte) error {
+	ks, err := readKeystore(path)
+	if err != nil {
+		return err
+	}
+
+	if password == nil {
+		password = keystore.GetPassword("Enter password to decrypt keystore file:")
+	}
+	kp, err := keystore.DecryptKeypair(ks.PublicKey, ks.Ciphertext, password, ks.Type)
+	if err != nil {
+		return fmt.Errorf("could not decrypt key: %w", err)
+	}
+
+	if newPassword == nil {
+		newPassword = keystore.GetPassword("Enter new password to encrypt keystore file:")
+		repeated := keystore.GetPassword("Repeat new password:")
+		if !bytes.Equal(newPassword, repeated) {
+			return fmt.Errorf("passwords do not match")
+		}
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".password-*.key")
+	if err != nil {
+		return fmt.Errorf("could not create key file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	err = keystore.EncryptAndWriteToFile(file, kp, newPassword)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("could not write key to file: %w", err)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("could not write key to file: %w", err)
+	}
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf("could not replace key file: %w", err)
+	}
+
+	log.Info("password changed", "address", ks.Address, "file", path)
+	return nil
+}
+
+// exportKey returns the public key of a keystore file in hex and the addresses of the key. Sr25519 keys
+// are encoded as SS58 for Polkadot, Kusama and Westend, secp256k1 keys as 0x hex and bech32 for Alaya
+// and PlatON. The key is not decrypted.
+func exportKey(path string) ([]keyField, error) {
+	ks, err := readKeystore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := hexutil.Decode(ks.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", ks.PublicKey, err)
+	}
+	fields := []keyField{{"type", ks.Type}, {"public key", hexutil.Encode(pub)}}
+
+	if ks.Type == crypto.Sr25519Type {
+		networks := []struct {
+			name   string
+			prefix []byte
+		}{
+			{"polkadot", ss58.PolkadotPrefix},
+			{"kusama", ss58.KsmPrefix},
+			{"westend", ss58.SubstratePrefix},
+		}
+		for _, n := range networks {
+			addr, err := ss58.Encode(pub, n.prefix)
+			if err != nil {
+				return nil, fmt.Errorf("invalid public key %q: %w", ks.PublicKey, err)
+			}
+			fields = append(fields, keyField{n.name, addr})
+		}
+		return fields, nil
+	}
+
+	key, err := ethcrypto.DecompressPubkey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", ks.PublicKey, err)
+	}
+	addr := ethcrypto.PubkeyToAddress(*key)
+	fields = append(fields, keyField{"0x", addr.Hex()})
+	for _, prefix := range []string{"atp", "lat"} {
+		bech, err := bech32.FormatAddress(addr, prefix)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, keyField{prefix, bech})
+	}
+	return fields, nil
+}
+
+// inspectKey returns the type of a key file, and whether the address it is stored under is the one of its
+// public key. Ethereum keystore files, which must be imported with --ethereum, are recognized.
+func inspectKey(path string) ([]keyField, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+
+	var geth struct {
+		Address string          `json:"address"`
+		Crypto  json.RawMessage `json:"crypto"`
+		Version int             `json:"version"`
+	}
+	if json.Unmarshal(data, &geth) == nil && len(geth.Crypto) != 0 {
+		return []keyField{
+			{"file", path},
+			{"type", fmt.Sprintf("ethereum keystore v%d, import it with --ethereum", geth.Version)},
+			{"address", "0x" + geth.Address},
+		}, nil
+	}
+
+	ks, err := readKeystore(path)
+	if err != nil {
+		return nil, err
+	}
+	fields := []keyField{{"file", path}, {"type", ks.Type}, {"address", ks.Address}, {"public key", ks.PublicKey}}
+
+	exported, err := exportKey(path)
+	if err != nil {
+		return nil, err
+	}
+	var match bool
+	if ks.Type == crypto.Sr25519Type {
+		// The address may be encoded for any network
+		pub, err := ss58.DecodeToPub(ks.Address)
+		match = err == nil && hexutil.Encode(pub) == exported[1].value
+	} else {
+		match = ks.Address == exported[2].value
+	}
+	if !match {
+		return nil, fmt.Errorf("address %s is not the address of public key %s, the file may be corrupt or have been tampered with", ks.Address, ks.PublicKey)
+	}
+	return fields, nil
+}
+
+// deleteKey removes a keystore file if the user confirms it. It returns whether the file was removed.
+func deleteKey(path string) (bool, error) {
+	ks, err := readKeystore(path)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Delete key %s (%s) stored in %s? It cannot be recovered without a backup. [y/N]\n> ", ks.Address, ks.Type, path)
+	answer, err := bufio.NewReader(confirmInput).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		log.Info("key not deleted", "address", ks.Address)
+		return false, nil
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return false, err
+	}
+	log.Info("key deleted", "address", ks.Address, "file", path)
+	return true, nil
+}
+
+func printKeyFields(fields []keyField) {
+	for _, f := range fields {
+		fmt.Printf("%-12s %s\n", f.name+":", f.value)
+	}
+}
@@ -374,3 +374,163 @@ func TestListKeys(t *testing.T) {
 		}
 	}
 }
+
+// importTestKey imports the private key of Alice of the test keyring for keytype into the test keystore
+func importTestKey(t *testing.T, keytype string) string {
+	key := "000000000000000000000000000000000000000000000000000000616c696365"
+	if keytype == crypto.Sr25519Type {
+		key = "//Alice"
+	}
+	ctx, err := newTestContext("import", []string{"network"}, []interface{}{"0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyfile, err := importPrivKey(ctx, keytype, testKeystoreDir, key, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyfile
+}
+
+func TestChangePassword(t *testing.T) {
+	defer os.RemoveAll(testKeystoreDir)
+	keyfile := importTestKey(t, crypto.Secp256k1Type)
+
+	err := changePassword(keyfile, []byte("wrong"), []byte("new"))
+	if err == nil {
+		t.Fatal("Got: nil Expected: incorrect password")
+	}
+
+	err = changePassword(keyfile, testPassword, []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kp, err := keystore.ReadFromFileAndDecrypt(keyfile, []byte("new"), crypto.Secp256k1Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kp.Address() != keystore.TestKeyRing.EthereumKeys[keystore.AliceKey].Address() {
+		t.Fatalf("Got: %s Expected: %s", kp.Address(), keystore.TestKeyRing.EthereumKeys[keystore.AliceKey].Address())
+	}
+	if _, err = keystore.ReadFromFileAndDecrypt(keyfile, testPassword, crypto.Secp256k1Type); err == nil {
+		t.Fatal("Got: nil Expected: old password is rejected")
+	}
+
+	keys, err := getKeyFiles(testKeystoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("Got: %v Expected: only %s", keys, filepath.Base(keyfile))
+	}
+}
+
+func TestExportKey(t *testing.T) {
+	defer os.RemoveAll(testKeystoreDir)
+
+	testcases := []struct {
+		keytype  string
+		expected []keyField
+	}{
+		{crypto.Secp256k1Type, []keyField{
+			{"type", "secp256k1"},
+			{"public key", keystore.TestKeyRing.EthereumKeys[keystore.AliceKey].PublicKey()},
+			{"0x", "0xff93B45308FD417dF303D6515aB04D9e89a750Ca"},
+			{"atp", "atp1l7fmg5cgl4qhmucr6eg44vzdn6y6w5x2q695d9"},
+			{"lat", "lat1l7fmg5cgl4qhmucr6eg44vzdn6y6w5x2evnvj2"},
+		}},
+		{crypto.Sr25519Type, []keyField{
+			{"type", "sr25519"},
+			{"public key", "0xd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d"},
+			{"polkadot", "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"},
+			{"kusama", "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F"},
+			{"westend", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"},
+		}},
+	}
+	for _, c := range testcases {
+		keyfile := importTestKey(t, c.keytype)
+		fields, err := exportKey(keyfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.Equal(t, c.expected, fields)
+
+		// Keys are also found by the address they are listed under
+		path, err := keyFilePath(strings.TrimSuffix(filepath.Base(keyfile), ".key"), testKeystoreDir)
+		if err != nil || path != keyfile {
+			t.Fatalf("Got: %s %v Expected: %s", path, err, keyfile)
+		}
+	}
+}
+
+func TestInspectKey(t *testing.T) {
+	defer os.RemoveAll(testKeystoreDir)
+	keyfile := importTestKey(t, crypto.Sr25519Type)
+
+	fields, err := inspectKey(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields[1].value != crypto.Sr25519Type {
+		t.Fatalf("Got: %s Expected: %s", fields[1].value, crypto.Sr25519Type)
+	}
+
+	// A key file stored under the address of another key is rejected
+	ks, err := readKeystore(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Address = "5FHneW46xGXgs5mUiveU4sbTyGBzmstUspZC92UhjJM694ty"
+	data, err := json.Marshal(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyfile, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = inspectKey(keyfile); err == nil {
+		t.Fatal("Got: nil Expected: address mismatch")
+	}
+
+	gethJSON, err := json.Marshal(createTestGethKeystore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gethFile := filepath.Join(testKeystoreDir, "geth.json")
+	err = ioutil.WriteFile(gethFile, gethJSON, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err = inspectKey(gethFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(fields[1].value, "ethereum keystore") {
+		t.Fatalf("Got: %s Expected: ethereum keystore", fields[1].value)
+	}
+}
+
+func TestDeleteKey(t *testing.T) {
+	defer os.RemoveAll(testKeystoreDir)
+	defer func() { confirmInput = os.Stdin }()
+	keyfile := importTestKey(t, crypto.Secp256k1Type)
+
+	confirmInput = strings.NewReader("n\n")
+	deleted, err := deleteKey(keyfile)
+	if err != nil || deleted {
+		t.Fatalf("Got: %v %v Expected: not deleted", deleted, err)
+	}
+	if _, err = os.Stat(keyfile); err != nil {
+		t.Fatal(err)
+	}
+
+	confirmInput = strings.NewReader("yes\n")
+	deleted, err = deleteKey(keyfile)
+	if err != nil || !deleted {
+		t.Fatalf("Got: %v %v Expected: deleted", deleted, err)
+	}
+	if _, err = os.Stat(keyfile); !os.IsNotExist(err) {
+		t.Fatalf("Got: %v Expected: %s removed", err, keyfile)
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	log "github.com/ChainSafe/log15"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rjman-self/Platdot/chains/platdot"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/urfave/cli/v2"
+)
+
+var adminFlags = []cli.Flag{
+	config.ConfigFileFlag,
+	config.KeystorePathFlag,
+	config.ChainIdFlag,
+	config.DryRunFlag,
+}
+
+var adminHandlerFlags = append([]cli.Flag{config.HandlerFlag}, adminFlags...)
+
+var adminInfoFlags = []cli.Flag{
+	config.ConfigFileFlag,
+	config.KeystorePathFlag,
+	config.ChainIdFlag,
+}
+
+var adminCommand = cli.Command{
+	Name:  "admin",
+	Usage: "manage the bridge contract",
+	Description: "The admin command calls the admin methods of the bridge of the ethereum chain config with the key of its from address.\n" +
+		"\tAddresses are given as bech32 with the prefix of the chain or as hex.\n" +
+		"\tTo show the bridge config: platdot admin info\n" +
+		"\tTo add a relayer: platdot admin addRelayer atp1...\n" +
+		"\tTo register a resource: platdot admin registerResource 0xresourceid atp1token...\n" +
+		"\tUse --dryRun to print the target and calldata instead of submitting it, eg. for a multisig admin wallet.",
+	Subcommands: []*cli.Command{
+		{
+			Action:      wrapHandler(handleAdminInfoCmd),
+			Name:        "info",
+			Usage:       "show the bridge config",
+			Flags:       adminInfoFlags,
+			Description: "The info subcommand prints the admins, relayers, threshold, fee, expiry and deposit counts of the bridge.\n",
+		},
+		{
+			Action:      wrapHandler(handleAdminAddRelayerCmd),
+			Name:        "addRelayer",
+			Usage:       "add a relayer",
+			Flags:       adminFlags,
+			Description: "The addRelayer subcommand grants the relayer role to the address given as argument.\n",
+		},
+		{
+			Action:      wrapHandler(handleAdminRemoveRelayerCmd),
+			Name:        "removeRelayer",
+			Usage:       "remove a relayer",
+			Flags:       adminFlags,
+			Description: "The removeRelayer subcommand revokes the relayer role of the address given as argument.\n",
+		},
+		{
+			Action:      wrapHandler(handleAdminSetThresholdCmd),
+			Name:        "setThreshold",
+			Usage:       "set the relayer threshold",
+			Flags:       adminFlags,
+			Description: "The setThreshold subcommand sets the number of votes needed to pass a proposal.\n",
+		},
+		{
+			Action:      wrapHandler(handleAdminSetFeeCmd),
+			Name:        "setFee",
+			Usage:       "set the deposit fee",
+			Flags:       adminFlags,
+			Description: "The setFee subcommand sets the fee paid with a deposit, in the smallest unit of the native token.\n",
+		},
+		{
+			Action:      wrapHandler(handleAdminPauseCmd),
+			Name:        "pause",
+			Usage:       "pause deposits and proposals",
+			Flags:       adminFlags,
+			Description: "The pause subcommand pauses the deposits and proposals of the bridge.\n",
+		},
+		{
+			Action:      wrapHandler(handleAdminUnpauseCmd),
+			Name:        "unpause",
+			Usage:       "unpause deposits and proposals",
+			Flags:       adminFlags,
+			Description: "The unpause subcommand unpauses the deposits and proposals of the bridge.\n",
+		},
+		{
+			Action: wrapHandler(handleAdminRegisterResourceCmd),
+			Name:   "registerResource",
+			Usage:  "register a resource id",
+			Flags:  adminHandlerFlags,
+			Description: "The registerResource subcommand maps the resource id to the token contract given as arguments.\n" +
+				"\tThe resource is registered with the erc20Handler of the chain config unless --handler is set.",
+		},
+		{
+			Action: wrapHandler(handleAdminSetBurnableCmd),
+			Name:   "setBurnable",
+			Usage:  "set a token as burnable",
+			Flags:  adminHandlerFlags,
+			Description: "The setBurnable subcommand makes the handler burn deposits and mint proposals of the token given as argument.\n" +
+				"\tThe handler must be granted the minter role of the token.",
+		},
+		{
+			Action: wrapHandler(handleAdminWithdrawCmd),
+			Name:   "withdraw",
+			Usage:  "withdraw tokens from a handler",
+			Flags:  adminHandlerFlags,
+			Description: "The withdraw subcommand sends tokens held by the handler to a recipient.\n" +
+				"\tUsage: platdot admin withdraw token recipient amount",
+		},
+	},
+}
+
+// newAdminClient returns an admin client for the ethereum chain selected with --chain
+func newAdminClient(ctx *cli.Context) (*platdot.AdminClient, error) {
+	cfg, err := selectChainConfig(ctx, "ethereum")
+	if err != nil {
+		return nil, err
+	}
+	return platdot.NewAdminClient(cfg, log.Root())
+}
+
+// adminHandler returns the handler set with --handler, or the erc20Handler of the chain config
+func adminHandler(ctx *cli.Context, client *platdot.AdminClient) (common.Address, error) {
+	if handler := ctx.String(config.HandlerFlag.Name); handler != "" {
+		return client.ParseAddress(handler)
+	}
+	if client.Erc20Handler() == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("no erc20Handler in chain config, set one with --%s", config.HandlerFlag.Name)
+	}
+	return client.Erc20Handler(), nil
+}
+
+// parseAmount parses a positive integer given in base 10
+func parseAmount(s string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+// submitAdminCall prints the target and calldata of c with --dryRun, otherwise submits it with the admin key
+func submitAdminCall(ctx *cli.Context, client *platdot.AdminClient, c platdot.AdminCall) error {
+	if ctx.Bool(config.DryRunFlag.Name) {
+		data, err := c.Pack()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("to: %s (%s)\n", client.FormatAddress(client.Bridge()), client.Bridge().Hex())
+		fmt.Printf("data: %s\n", hexutil.Encode(data))
+		return nil
+	}
+
+	err := client.Connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	tx, err := client.Submit(c)
+	if err != nil {
+		return fmt.Errorf("failed to submit %s: %w", c.Method, err)
+	}
+	log.Info("transaction mined", "method", c.Method, "tx", tx.Hash().Hex())
+	return nil
+}
+
+// submitAddressCall submits the call built from the address given as argument
+func submitAddressCall(ctx *cli.Context, build func(common.Address) platdot.AdminCall) error {
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	addr, err := client.ParseAddress(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	return submitAdminCall(ctx, client, build(addr))
+}
+
+// submitAmountCall submits the call built from the amount given as argument
+func submitAmountCall(ctx *cli.Context, build func(*big.Int) platdot.AdminCall) error {
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	return submitAdminCall(ctx, client, build(amount))
+}
+
+// handleAdminInfoCmd prints the config of the bridge
+func handleAdminInfoCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	cfg, err := selectChainConfig(ctx, "ethereum")
+	if err != nil {
+		return err
+	}
+	others, err := config.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := platdot.NewAdminClient(cfg, log.Root())
+	if err != nil {
+		return err
+	}
+	err = client.Connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	info, err := client.Info()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("=== Bridge %s ===\n", client.FormatAddress(client.Bridge()))
+	fmt.Printf("chain id: %d\n", info.ChainId)
+	fmt.Printf("paused: %t\n", info.Paused)
+	fmt.Printf("relayer threshold: %d of %s\n", info.Threshold, info.TotalRelayers)
+	fmt.Printf("fee: %s\n", info.Fee)
+	fmt.Printf("expiry: %s blocks\n", info.Expiry)
+	for _, a := range info.Admins {
+		fmt.Printf("admin: %s\n", client.FormatAddress(a))
+	}
+	for _, r := range info.Relayers {
+		fmt.Printf("relayer: %s\n", client.FormatAddress(r))
+	}
+	for _, chain := range others.Chains {
+		if chain.Id == strconv.Itoa(int(cfg.Id)) {
+			continue
+		}
+		id, err := strconv.Atoi(chain.Id)
+		if err != nil {
+			return err
+		}
+		count, err := client.DepositCount(msg.ChainId(id))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deposits to %s (%s): %d\n", chain.Name, chain.Id, count)
+		if rId, ok := chain.Opts["ResourceId"]; ok {
+			handler, err := client.ResourceHandler(msg.ResourceIdFromSlice(common.FromHex(rId)))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("handler of %s: %s\n", rId, client.FormatAddress(handler))
+		}
+	}
+	return nil
+}
+
+func handleAdminAddRelayerCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	return submitAddressCall(ctx, platdot.AddRelayerCall)
+}
+
+func handleAdminRemoveRelayerCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	return submitAddressCall(ctx, platdot.RemoveRelayerCall)
+}
+
+func handleAdminSetThresholdCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	return submitAmountCall(ctx, platdot.ChangeThresholdCall)
+}
+
+func handleAdminSetFeeCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	return submitAmountCall(ctx, platdot.ChangeFeeCall)
+}
+
+func handleAdminPauseCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	return submitAdminCall(ctx, client, platdot.PauseCall())
+}
+
+func handleAdminUnpauseCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	return submitAdminCall(ctx, client, platdot.UnpauseCall())
+}
+
+// handleAdminRegisterResourceCmd registers the resource id and token contract given as arguments
+func handleAdminRegisterResourceCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	handler, err := adminHandler(ctx, client)
+	if err != nil {
+		return err
+	}
+	rId, err := hexutil.Decode(ctx.Args().Get(0))
+	if err != nil || len(rId) != 32 {
+		return fmt.Errorf("must provide a 32 byte hex resource id")
+	}
+	token, err := client.ParseAddress(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	return submitAdminCall(ctx, client, platdot.SetResourceCall(handler, msg.ResourceIdFromSlice(rId), token))
+}
+
+// handleAdminSetBurnableCmd sets the token contract given as argument as burnable
+func handleAdminSetBurnableCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	handler, err := adminHandler(ctx, client)
+	if err != nil {
+		return err
+	}
+	token, err := client.ParseAddress(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	return submitAdminCall(ctx, client, platdot.SetBurnableCall(handler, token))
+}
+
+// handleAdminWithdrawCmd withdraws an amount of a token held by the handler to a recipient
+func handleAdminWithdrawCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	handler, err := adminHandler(ctx, client)
+	if err != nil {
+		return err
+	}
+	token, err := client.ParseAddress(ctx.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+	recipient, err := client.ParseAddress(ctx.Args().Get(1))
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	amount, err := parseAmount(ctx.Args().Get(2))
+	if err != nil {
+		return err
+	}
+	return submitAdminCall(ctx, client, platdot.WithdrawCall(handler, token, recipient, amount))
+}
@@ -0,0 +1,211 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/chains/platdot"
+	"github.com/rjman-self/Platdot/chains/substrate"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/platdot-utils/core"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/urfave/cli/v2"
+)
+
+var configFlags = []cli.Flag{
+	config.ConfigFileFlag,
+}
+
+var configCommand = cli.Command{
+	Name:  "config",
+	Usage: "inspect the bridge config",
+	Description: "The config command is used to check a config file before starting the relayer.\n" +
+		"\tConfig files can be .json, .toml or .yaml, PLATDOT_<CHAIN>_<OPT> environment variables override the file.\n" +
+		"\tTo validate a config: platdot config validate --config config.toml\n" +
+		"\tTo check that the configs of the relayers agree: platdot config diff relayers/*.json",
+	Subcommands: []*cli.Command{
+		{
+			Action: wrapHandler(handleConfigValidateCmd),
+			Name:   "validate",
+			Usage:  "validate the config of every chain",
+			Flags:  configFlags,
+			Description: "The validate subcommand parses the opts of every chain like the relayer does, without connecting to the chains.\n" +
+				"\tIt prints every invalid chain and fails if there is any.",
+		},
+		{
+			Action:    wrapHandler(handleConfigDiffCmd),
+			Name:      "diff",
+			Usage:     "check that the configs of several relayers agree",
+			ArgsUsage: "config...",
+			Description: "The diff subcommand compares the config files of the relayers given as arguments.\n" +
+				"\tThe configs must have the same chains, bridge contracts and relayer set, and each relayer must have its own number.",
+		},
+	},
+}
+
+// coreChainConfig returns the core.ChainConfig of a chain of the config, without the keystore and blockstore
+func coreChainConfig(chain config.RawChainConfig) (*core.ChainConfig, error) {
+	chainId, err := strconv.Atoi(chain.Id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %q", chain.Id)
+	}
+	return &core.ChainConfig{
+		Name:     chain.Name,
+		Id:       msg.ChainId(chainId),
+		Endpoint: chain.Endpoint,
+		From:     chain.From,
+		Opts:     chain.Opts,
+	}, nil
+}
+
+// validateChain runs the parser of the chain type on a chain of the config
+func validateChain(chain config.RawChainConfig) error {
+	chainConfig, err := coreChainConfig(chain)
+	if err != nil {
+		return err
+	}
+
+	switch chain.Type {
+	case "ethereum":
+		return platdot.ValidateChainConfig(chainConfig)
+	case "substrate":
+		return substrate.ValidateChainConfig(chainConfig)
+	default:
+		return fmt.Errorf("unrecognized chain type %q", chain.Type)
+	}
+}
+
+func handleConfigValidateCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	cfg, err := config.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	invalid := 0
+	for _, chain := range cfg.Chains {
+		if err := validateChain(chain); err != nil {
+			fmt.Printf("%s (%s): %s\n", chain.Name, chain.Id, err)
+			invalid++
+			continue
+		}
+		fmt.Printf("%s (%s): ok\n", chain.Name, chain.Id)
+	}
+	if invalid != 0 {
+		return fmt.Errorf("%d of %d chains are invalid", invalid, len(cfg.Chains))
+	}
+	return nil
+}
+
+// relayerConfig is the part of a relayer config that must agree with the other relayers
+type relayerConfig struct {
+	path     string
+	chains   map[string]config.RawChainConfig // By chain id
+	order    []string                         // Relayer set, if the config has one
+	multisig *substrate.Multisig
+}
+
+func readRelayerConfig(path string) (*relayerConfig, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rc := &relayerConfig{path: path, chains: make(map[string]config.RawChainConfig)}
+	if cfg.RelayerSet != nil {
+		rc.order = cfg.RelayerSet.Relayers
+	}
+	for _, chain := range cfg.Chains {
+		rc.chains[chain.Id] = chain
+		if chain.Type != "substrate" {
+			continue
+		}
+		chainConfig, err := coreChainConfig(chain)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if rc.multisig, err = substrate.ChainMultisig(chainConfig); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return rc, nil
+}
+
+// diffRelayerConfigs returns every disagreement of the configs with the first one
+func diffRelayerConfigs(configs []*relayerConfig) []string {
+	var diffs []string
+	report := func(path string, format string, args ...interface{}) {
+		diffs = append(diffs, fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	base := configs[0]
+	numbers := make(map[uint64]string)
+	for _, rc := range configs {
+		if len(rc.chains) != len(base.chains) {
+			report(rc.path, "has %d chains, %s has %d", len(rc.chains), base.path, len(base.chains))
+		}
+		for id, chain := range rc.chains {
+			other, ok := base.chains[id]
+			if !ok {
+				report(rc.path, "chain %s is not in %s", id, base.path)
+				continue
+			}
+			if chain.Name != other.Name || chain.Type != other.Type {
+				report(rc.path, "chain %s is %s %s, %s has %s %s", id, chain.Type, chain.Name, base.path, other.Type, other.Name)
+			}
+			for _, opt := range []string{platdot.BridgeOpt, platdot.Erc20HandlerOpt} {
+				if chain.Type == "ethereum" && chain.Opts[opt] != other.Opts[opt] {
+					report(rc.path, "chain %s has %s %s, %s has %s", id, opt, chain.Opts[opt], base.path, other.Opts[opt])
+				}
+			}
+		}
+
+		if rc.order != nil && base.order != nil && strings.Join(rc.order, ",") != strings.Join(base.order, ",") {
+			report(rc.path, "relayerSet is not in the order of %s", base.path)
+		}
+		if (rc.multisig == nil) != (base.multisig == nil) {
+			report(rc.path, "substrate chain differs from %s", base.path)
+			continue
+		}
+		if rc.multisig == nil {
+			continue
+		}
+		if rc.multisig.Address != base.multisig.Address || rc.multisig.Threshold != base.multisig.Threshold {
+			report(rc.path, "multisig is %s of threshold %d, %s has %s of threshold %d",
+				types.HexEncodeToString(rc.multisig.Address[:]), rc.multisig.Threshold, base.path,
+				types.HexEncodeToString(base.multisig.Address[:]), base.multisig.Threshold)
+		}
+		if path, ok := numbers[rc.multisig.Number]; ok {
+			report(rc.path, "relayer number %d is also used by %s", rc.multisig.Number, path)
+		}
+		numbers[rc.multisig.Number] = rc.path
+	}
+	return diffs
+}
+
+func handleConfigDiffCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	if ctx.NArg() < 2 {
+		return fmt.Errorf("at least two config files are required")
+	}
+	var configs []*relayerConfig
+	for _, path := range ctx.Args().Slice() {
+		rc, err := readRelayerConfig(path)
+		if err != nil {
+			return err
+		}
+		configs = append(configs, rc)
+	}
+
+	diffs := diffRelayerConfigs(configs)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) != 0 {
+		return fmt.Errorf("%d configs disagree", len(configs))
+	}
+	fmt.Printf("%d configs agree\n", len(configs))
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+
+	log "github.com/ChainSafe/log15"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/Platdot/shared/screening"
+	"github.com/urfave/cli/v2"
+)
+
+var denyListFlags = []cli.Flag{
+	config.DenyListFlag,
+}
+
+var denyListAddFlags = []cli.Flag{
+	config.DenyListFlag,
+	config.ReasonFlag,
+}
+
+var denyListCommand = cli.Command{
+	Name:  "denylist",
+	Usage: "manage the transfer deny-list",
+	Description: "The denylist command is used to manage the addresses the relayer refuses to bridge.\n" +
+		"\tSet the same file as the 'denyList' opt of the Alaya chain and the 'DenyList' opt of the substrate chain, running relayers reload it on change.\n" +
+		"\tTo deny an address: platdot denylist add --reason \"reason\" address\n" +
+		"\tTo allow an address again: platdot denylist remove address\n" +
+		"\tTo list denied addresses: platdot denylist list\n" +
+		"\tTo list transfers refused so far: platdot denylist blocked",
+	Subcommands: []*cli.Command{
+		{
+			Action:      wrapHandler(handleDenyListAddCmd),
+			Name:        "add",
+			Usage:       "add an address to the deny-list",
+			Flags:       denyListAddFlags,
+			Description: "The add subcommand denies an address given as 0x hex, bech32 or SS58.\n",
+		},
+		{
+			Action:      wrapHandler(handleDenyListRemoveCmd),
+			Name:        "remove",
+			Usage:       "remove an address from the deny-list",
+			Flags:       denyListFlags,
+			Description: "The remove subcommand allows a previously denied address again.\n",
+		},
+		{
+			Action:      wrapHandler(handleDenyListListCmd),
+			Name:        "list",
+			Usage:       "list denied addresses",
+			Flags:       denyListFlags,
+			Description: "The list subcommand prints every entry of the deny-list.\n",
+		},
+		{
+			Action:      wrapHandler(handleDenyListBlockedCmd),
+			Name:        "blocked",
+			Usage:       "list refused transfers",
+			Flags:       denyListFlags,
+			Description: "The blocked subcommand prints every transfer the relayer refused to bridge.\n",
+		},
+	},
+}
+
+func loadDenyList(ctx *cli.Context) (*screening.DenyList, error) {
+	return screening.NewDenyList(ctx.String(config.DenyListFlag.Name), log.Root())
+}
+
+// handleDenyListAddCmd adds the address given as argument to the deny-list
+func handleDenyListAddCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	address := ctx.Args().First()
+	if address == "" {
+		return fmt.Errorf("must provide an address to deny")
+	}
+
+	d, err := loadDenyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = d.Add(address, ctx.String(config.ReasonFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to update deny-list: %w", err)
+	}
+
+	log.Info("address denied", "address", address, "normalized", screening.Normalize(address), "file", d.Path())
+	return nil
+}
+
+// handleDenyListRemoveCmd removes the address given as argument from the deny-list
+func handleDenyListRemoveCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	address := ctx.Args().First()
+	if address == "" {
+		return fmt.Errorf("must provide an address to remove")
+	}
+
+	d, err := loadDenyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = d.Remove(address)
+	if err != nil {
+		return fmt.Errorf("failed to update deny-list: %w", err)
+	}
+
+	log.Info("address removed from deny-list", "address", address, "file", d.Path())
+	return nil
+}
+
+// handleDenyListListCmd prints all denied addresses
+func handleDenyListListCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	d, err := loadDenyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries := d.Entries()
+	fmt.Printf("=== Found %d denied addresses ===\n", len(entries))
+	for i, e := range entries {
+		fmt.Printf("[%d] %s %s\n", i, e.Address, e.Reason)
+	}
+	return nil
+}
+
+// handleDenyListBlockedCmd prints all transfers refused by the deny-list
+func handleDenyListBlockedCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	d, err := loadDenyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, err := d.Records()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("=== Found %d blocked transfers ===\n", len(records))
+	for i, r := range records {
+		fmt.Printf("[%d] %s src=%d dst=%d nonce=%d sender=%s recipient=%s amount=%s reason=%s\n",
+			i, r.Time.Format("2006-01-02 15:04:05"), r.Source, r.Destination, r.DepositNonce, r.Sender, r.Recipient, r.Amount, r.Reason)
+	}
+	return nil
+}
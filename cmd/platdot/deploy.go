@@ -0,0 +1,208 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/ChainSafe/log15"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/Platdot/shared/bech32"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
+	"github.com/rjman-self/platdot-utils/keystore"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/urfave/cli/v2"
+)
+
+// Number of receipt queries per deployment tx, contract creation may take several blocks
+const DeployTxWaitRetries = 60
+
+var deployFlags = []cli.Flag{
+	config.KeystorePathFlag,
+	config.UrlFlag,
+	config.FromFlag,
+	config.BridgeChainIdFlag,
+	config.RelayersFlag,
+	config.RelayerThresholdFlag,
+	config.FeeFlag,
+	config.ProposalExpiryFlag,
+	config.TokenNameFlag,
+	config.TokenSymbolFlag,
+	config.ResourceIdFlag,
+	config.PrefixFlag,
+	config.ChainNameFlag,
+}
+
+var deployCommand = cli.Command{
+	Action: wrapHandler(handleDeployCmd),
+	Name:   "deploy",
+	Usage:  "deploy the bridge contracts",
+	Flags:  deployFlags,
+	Description: "The deploy command deploys a Bridge with its ERC20Handler and ERC721Handler and a wrapped ERC20PresetMinterPauser token.\n" +
+		"\tThe token is registered as burnable resource of the ERC20Handler, which is granted the minter role of the token.\n" +
+		"\tThe deployer key is the admin of the bridge and the token. Once deployed, the chain block of config.json is printed.\n" +
+		"\tTo deploy: platdot deploy --url http://localhost:6789 --from atp1... --chainId 2",
+}
+
+// deployKeypair loads the deployer key from the keystore, or the test key set with --testkey
+func deployKeypair(ctx *cli.Context, prefix string) (*secp256k1.Keypair, error) {
+	var kpI interface{}
+	var err error
+	if key := ctx.String(config.TestKeyFlag.Name); key != "" {
+		kpI, err = keystore.KeypairFromAddress("", keystore.EthChain, key, true)
+	} else {
+		from, perr := bech32.ParseAddress(ctx.String(config.FromFlag.Name), prefix)
+		if perr != nil {
+			return nil, fmt.Errorf("must provide the deployer address with --%s: %w", config.FromFlag.Name, perr)
+		}
+		kpI, err = keystore.KeypairFromAddress(from.Hex(), keystore.EthChain, ctx.String(config.KeystorePathFlag.Name), false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kp, ok := kpI.(*secp256k1.Keypair)
+	if !ok {
+		return nil, fmt.Errorf("deployer key is not a secp256k1 key")
+	}
+	return kp, nil
+}
+
+// parseDeployment builds the deployment parameters from the flags
+func parseDeployment(ctx *cli.Context, deployer common.Address, prefix string) (utils.BridgeDeployment, error) {
+	chainId := ctx.Uint(config.BridgeChainIdFlag.Name)
+	if chainId > 255 {
+		return utils.BridgeDeployment{}, fmt.Errorf("chain id %d does not fit in a uint8", chainId)
+	}
+
+	relayers := []common.Address{deployer}
+	if raw := ctx.StringSlice(config.RelayersFlag.Name); len(raw) != 0 {
+		relayers = nil
+		for _, r := range raw {
+			relayer, err := bech32.ParseAddress(r, prefix)
+			if err != nil {
+				return utils.BridgeDeployment{}, fmt.Errorf("invalid relayer: %w", err)
+			}
+			relayers = append(relayers, relayer)
+		}
+	}
+	threshold := ctx.Uint64(config.RelayerThresholdFlag.Name)
+	if threshold == 0 || threshold > uint64(len(relayers)) {
+		return utils.BridgeDeployment{}, fmt.Errorf("threshold must be between 1 and the number of relayers (%d)", len(relayers))
+	}
+
+	fee, ok := new(big.Int).SetString(ctx.String(config.FeeFlag.Name), 10)
+	if !ok || fee.Sign() < 0 {
+		return utils.BridgeDeployment{}, fmt.Errorf("invalid fee %q", ctx.String(config.FeeFlag.Name))
+	}
+
+	var rId msg.ResourceId
+	if raw := ctx.String(config.ResourceIdFlag.Name); raw != "" {
+		res, err := hexutil.Decode(raw)
+		if err != nil || len(res) != 32 {
+			return utils.BridgeDeployment{}, fmt.Errorf("resource id must be 32 bytes of hex")
+		}
+		rId = msg.ResourceIdFromSlice(res)
+	}
+
+	return utils.BridgeDeployment{
+		ChainID:          uint8(chainId),
+		Relayers:         relayers,
+		RelayerThreshold: new(big.Int).SetUint64(threshold),
+		Fee:              fee,
+		Expiry:           new(big.Int).SetUint64(ctx.Uint64(config.ProposalExpiryFlag.Name)),
+		TokenName:        ctx.String(config.TokenNameFlag.Name),
+		TokenSymbol:      ctx.String(config.TokenSymbolFlag.Name),
+		ResourceId:       rId,
+	}, nil
+}
+
+// deployedChainConfig returns the config.json chain block of a deployed bridge, with bech32 addresses
+func deployedChainConfig(ctx *cli.Context, deployer common.Address, networkId *big.Int, deployed *utils.DeployedBridge, prefix string) (*config.RawChainConfig, error) {
+	format := func(addr common.Address) (string, error) {
+		return bech32.FormatAddress(addr, prefix)
+	}
+	from, err := format(deployer)
+	if err != nil {
+		return nil, err
+	}
+	bridge, err := format(deployed.BridgeAddress)
+	if err != nil {
+		return nil, err
+	}
+	erc20Handler, err := format(deployed.ERC20HandlerAddress)
+	if err != nil {
+		return nil, err
+	}
+	erc721Handler, err := format(deployed.ERC721HandlerAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	url := ctx.String(config.UrlFlag.Name)
+	return &config.RawChainConfig{
+		Name:     ctx.String(config.ChainNameFlag.Name),
+		Type:     "ethereum",
+		Id:       strconv.FormatUint(uint64(ctx.Uint(config.BridgeChainIdFlag.Name)), 10),
+		Endpoint: url,
+		From:     from,
+		Opts: map[string]string{
+			"bridge":        bridge,
+			"erc20Handler":  erc20Handler,
+			"erc721Handler": erc721Handler,
+			"http":          strconv.FormatBool(strings.HasPrefix(url, "http")),
+			"prefix":        prefix,
+			"networkId":     networkId.String(),
+		},
+	}, nil
+}
+
+// handleDeployCmd deploys the bridge contracts and prints the chain config
+func handleDeployCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	prefix := ctx.String(config.PrefixFlag.Name)
+	kp, err := deployKeypair(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	deployment, err := parseDeployment(ctx, kp.CommonAddress(), prefix)
+	if err != nil {
+		return err
+	}
+
+	client, err := utils.NewClient(ctx.String(config.UrlFlag.Name), kp)
+	if err != nil {
+		return err
+	}
+	networkId, err := client.Client.ChainID(context.Background())
+	if err != nil {
+		return err
+	}
+
+	utils.TxWaitRetries = DeployTxWaitRetries
+	log.Info("Deploying bridge contracts", "deployer", kp.CommonAddress().Hex(), "chainId", deployment.ChainID, "relayers", len(deployment.Relayers))
+	deployed, err := utils.DeployBridgeContracts(client, deployment)
+	if err != nil {
+		return err
+	}
+	log.Info("Deployed bridge contracts", "token", deployed.TokenAddress.Hex(), "resourceId", deployed.ResourceId.Hex())
+
+	chain, err := deployedChainConfig(ctx, kp.CommonAddress(), networkId, deployed, prefix)
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(chain, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", raw)
+	return nil
+}
@@ -21,7 +21,6 @@ import (
 	"github.com/rjman-self/platdot-utils/core"
 	"github.com/rjman-self/platdot-utils/metrics/health"
 	metrics "github.com/rjman-self/platdot-utils/metrics/types"
-	"github.com/rjman-self/platdot-utils/msg"
 	"github.com/urfave/cli/v2"
 )
 
@@ -34,6 +33,7 @@ var cliFlags = []cli.Flag{
 	config.BlockstorePathFlag,
 	config.FreshStartFlag,
 	config.LatestBlockFlag,
+	config.WatchConfigFlag,
 	config.MetricsFlag,
 	config.MetricsPort,
 }
@@ -45,6 +45,11 @@ var generateFlags = []cli.Flag{
 	config.SubkeyNetworkFlag,
 }
 
+var passwordFlags = []cli.Flag{
+	config.PasswordFlag,
+	config.NewPasswordFlag,
+}
+
 var devFlags = []cli.Flag{
 	config.TestKeyFlag,
 }
@@ -66,7 +71,11 @@ var accountCommand = cli.Command{
 		"\tTo import a keystore file: platdot accounts import path/to/file\n" +
 		"\tTo import a geth keystore file: platdot accounts import --ethereum path/to/file\n" +
 		"\tTo import a private key file: platdot accounts import --privateKey private_key\n" +
-		"\tTo list keys: platdot accounts list",
+		"\tTo list keys: platdot accounts list\n" +
+		"\tTo change the password of a key: platdot accounts password address\n" +
+		"\tTo print the public key and addresses of a key: platdot accounts export address\n" +
+		"\tTo check the type of a key file: platdot accounts inspect path/to/file\n" +
+		"\tTo delete a key: platdot accounts delete address",
 	Subcommands: []*cli.Command{
 		{
 			Action: wrapHandler(handleGenerateCmd),
@@ -92,6 +101,36 @@ var accountCommand = cli.Command{
 			Usage:       "list bridge keystore",
 			Description: "The list subcommand is used to list all of the bridge keystore.\n",
 		},
+		{
+			Action: wrapHandler(handlePasswordCmd),
+			Name:   "password",
+			Usage:  "change the password of a bridge keystore",
+			Flags:  passwordFlags,
+			Description: "The password subcommand encrypts a keystore with a new password.\n" +
+				"\tThe key is given as a path or as the address it is listed under.\n" +
+				"\tThe passwords are prompted for unless --password and --newPassword are set.",
+		},
+		{
+			Action: wrapHandler(handleExportCmd),
+			Name:   "export",
+			Usage:  "print the public key and addresses of a bridge keystore",
+			Description: "The export subcommand prints the public key of a keystore and its addresses, without decrypting it.\n" +
+				"\tsr25519 keys are printed as SS58 for Polkadot, Kusama and Westend, secp256k1 keys as 0x hex and atp/lat bech32.\n" +
+				"\tUse them as the 'from' and 'OtherRelayerN' opts of the chains.",
+		},
+		{
+			Action: wrapHandler(handleInspectCmd),
+			Name:   "inspect",
+			Usage:  "print the key type of a key file",
+			Description: "The inspect subcommand prints the type and address of a key file and checks the address is the one of its public key.\n" +
+				"\tEthereum keystore files, eg. from geth, are recognized.",
+		},
+		{
+			Action:      wrapHandler(handleDeleteCmd),
+			Name:        "delete",
+			Usage:       "delete a bridge keystore",
+			Description: "The delete subcommand removes a keystore once confirmed.\n",
+		},
 	},
 }
 
@@ -110,6 +149,13 @@ func init() {
 	app.EnableBashCompletion = true
 	app.Commands = []*cli.Command{
 		&accountCommand,
+		&denyListCommand,
+		&multisigCommand,
+		&adminCommand,
+		&deployCommand,
+		&transferCommand,
+		&configCommand,
+		&signerCommand,
 	}
 	app.Flags = append(app.Flags, cliFlags...)
 	app.Flags = append(app.Flags, devFlags...)
@@ -122,21 +168,26 @@ func main() {
 	}
 }
 
-func startLogger(ctx *cli.Context) error {
-	logger := log.Root()
-	handler := logger.GetHandler()
-	var lvl log.Lvl
+// logHandler is the root handler without the level filter, kept to change the level on reload
+var logHandler log.Handler
 
-	if lvlToInt, err := strconv.Atoi(ctx.String(config.VerbosityFlag.Name)); err == nil {
-		lvl = log.Lvl(lvlToInt)
-	} else if lvl, err = log.LvlFromString(ctx.String(config.VerbosityFlag.Name)); err != nil {
+func startLogger(ctx *cli.Context) error {
+	lvl, err := config.ParseVerbosity(ctx.String(config.VerbosityFlag.Name))
+	if err != nil {
 		return err
 	}
-	log.Root().SetHandler(log.LvlFilterHandler(lvl, handler))
+	if logHandler == nil {
+		logHandler = log.Root().GetHandler()
+	}
+	setLogLevel(lvl)
 
 	return nil
 }
 
+func setLogLevel(lvl log.Lvl) {
+	log.Root().SetHandler(log.LvlFilterHandler(lvl, logHandler))
+}
+
 func run(ctx *cli.Context) error {
 	err := startLogger(ctx)
 	if err != nil {
@@ -149,38 +200,21 @@ func run(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-
-	// Check for test key flag
-	var ks string
-	var insecure bool
-	if key := ctx.String(config.TestKeyFlag.Name); key != "" {
-		ks = key
-		insecure = true
-	} else {
-		ks = cfg.KeystorePath
+	err = applyVerbosity(ctx, cfg)
+	if err != nil {
+		return err
 	}
 
 	// Used to signal core shutdown due to fatal error
 	sysErr := make(chan error)
 	c := core.NewCore(sysErr)
+	r := newReloader(ctx, cfg)
 
 	for _, chain := range cfg.Chains {
-		chainId, err := strconv.Atoi(chain.Id)
+		chainConfig, err := runChainConfig(ctx, cfg, chain)
 		if err != nil {
 			return err
 		}
-		chainConfig := &core.ChainConfig{
-			Name:           chain.Name,
-			Id:             msg.ChainId(chainId),
-			Endpoint:       chain.Endpoint,
-			From:           chain.From,
-			KeystorePath:   ks,
-			Insecure:       insecure,
-			BlockstorePath: ctx.String(config.BlockstorePathFlag.Name),
-			FreshStart:     ctx.Bool(config.FreshStartFlag.Name),
-			LatestBlock:    ctx.Bool(config.LatestBlockFlag.Name),
-			Opts:           chain.Opts,
-		}
 		var newChain core.Chain
 		var m *metrics.ChainMetrics
 
@@ -201,6 +235,7 @@ func run(ctx *cli.Context) error {
 			return err
 		}
 		c.AddChain(newChain)
+		r.addChain(chain, newChain)
 	}
 
 	// Start prometheus and health server
@@ -228,7 +263,30 @@ func run(ctx *cli.Context) error {
 		}()
 	}
 
+	stop := make(chan struct{})
+	r.start(stop)
 	c.Start()
+	close(stop)
 
 	return nil
 }
+
+// runChainConfig returns the core.ChainConfig the relayer runs a chain of the config with
+func runChainConfig(ctx *cli.Context, cfg *config.Config, chain config.RawChainConfig) (*core.ChainConfig, error) {
+	chainConfig, err := coreChainConfig(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for test key flag
+	if key := ctx.String(config.TestKeyFlag.Name); key != "" {
+		chainConfig.KeystorePath = key
+		chainConfig.Insecure = true
+	} else {
+		chainConfig.KeystorePath = cfg.KeystorePath
+	}
+	chainConfig.BlockstorePath = ctx.String(config.BlockstorePathFlag.Name)
+	chainConfig.FreshStart = ctx.Bool(config.FreshStartFlag.Name)
+	chainConfig.LatestBlock = ctx.Bool(config.LatestBlockFlag.Name)
+	return chainConfig, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	log "github.com/ChainSafe/log15"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/chains/substrate"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/platdot-utils/core"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/urfave/cli/v2"
+)
+
+var multisigFlags = []cli.Flag{
+	config.ConfigFileFlag,
+	config.ChainIdFlag,
+}
+
+var multisigApproveFlags = []cli.Flag{
+	config.ConfigFileFlag,
+	config.KeystorePathFlag,
+	config.ChainIdFlag,
+	config.DryRunFlag,
+	config.RecipientFlag,
+	config.AmountFlag,
+}
+
+var multisigCancelFlags = []cli.Flag{
+	config.ConfigFileFlag,
+	config.KeystorePathFlag,
+	config.ChainIdFlag,
+	config.DryRunFlag,
+	config.ExpiryFlag,
+}
+
+var multisigCommand = cli.Command{
+	Name:  "multisig",
+	Usage: "manage multisigs of the relayers",
+	Description: "The multisig command is used to finish or cancel a stuck multisig of the substrate chain by hand.\n" +
+		"\tThe multisig account, threshold and other relayers are read from the opts of the substrate chain config.\n" +
+		"\tTo list open multisigs: platdot multisig pending\n" +
+		"\tTo approve a call hash: platdot multisig approve 0xcallhash\n" +
+		"\tTo execute a transfer: platdot multisig approve --recipient 0xpubkey --amount planck 0xcallhash\n" +
+		"\tTo execute a batch: platdot multisig approve --recipient 0xpubkey1 --amount planck1 --recipient 0xpubkey2 --amount planck2 0xcallhash\n" +
+		"\tTo cancel an expired multisig opened by this relayer: platdot multisig cancel 0xcallhash\n" +
+		"\tUse --dryRun to print the SCALE encoded call instead of submitting it.",
+	Subcommands: []*cli.Command{
+		{
+			Action:      wrapHandler(handleMultisigPendingCmd),
+			Name:        "pending",
+			Usage:       "list open multisigs",
+			Flags:       multisigFlags,
+			Description: "The pending subcommand prints every open multisig of the MultiSignAddress with its call hash.\n",
+		},
+		{
+			Action: wrapHandler(handleMultisigApproveCmd),
+			Name:   "approve",
+			Usage:  "approve a multisig",
+			Flags:  multisigApproveFlags,
+			Description: "The approve subcommand approves the multisig of a call hash with the relayer key.\n" +
+				"\tWith --recipient and --amount the transfer call is sent with as_multi, which executes it once the threshold is reached.\n" +
+				"\tSeveral transfers are sent as the Utility.batch_all of the relayers, list them in the order of their deposit nonces.",
+		},
+		{
+			Action: wrapHandler(handleMultisigCancelCmd),
+			Name:   "cancel",
+			Usage:  "cancel an expired multisig",
+			Flags:  multisigCancelFlags,
+			Description: "The cancel subcommand cancels the multisig of a call hash with cancel_as_multi.\n" +
+				"\tOnly the relayer that opened the multisig can cancel it, once it is older than --expiry blocks.",
+		},
+	},
+}
+
+// selectChainConfig returns the config of the chain of chainType selected with --chain
+func selectChainConfig(ctx *cli.Context, chainType string) (*core.ChainConfig, error) {
+	cfg, err := config.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for test key flag
+	ks, insecure := cfg.KeystorePath, false
+	if key := ctx.String(config.TestKeyFlag.Name); key != "" {
+		ks, insecure = key, true
+	}
+
+	id := ctx.String(config.ChainIdFlag.Name)
+	var found *config.RawChainConfig
+	for i, chain := range cfg.Chains {
+		if chain.Type != chainType || (id != "" && chain.Id != id) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("config has several %s chains, select one with --%s", chainType, config.ChainIdFlag.Name)
+		}
+		found = &cfg.Chains[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s chain not found in config", chainType)
+	}
+
+	chainId, err := strconv.Atoi(found.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &core.ChainConfig{
+		Name:         found.Name,
+		Id:           msg.ChainId(chainId),
+		Endpoint:     found.Endpoint,
+		From:         found.From,
+		KeystorePath: ks,
+		Insecure:     insecure,
+		Opts:         found.Opts,
+	}, nil
+}
+
+// findMultisig connects to the substrate chain and looks up the open multisig of the call hash given as argument
+func findMultisig(ctx *cli.Context) (*core.ChainConfig, *substrate.MultisigClient, substrate.PendingMultisig, error) {
+	hash, err := types.NewHashFromHexString(ctx.Args().First())
+	if err != nil {
+		return nil, nil, substrate.PendingMultisig{}, fmt.Errorf("must provide the call hash of a multisig: %w", err)
+	}
+
+	cfg, err := selectChainConfig(ctx, "substrate")
+	if err != nil {
+		return nil, nil, substrate.PendingMultisig{}, err
+	}
+	client, err := substrate.NewMultisigClient(cfg)
+	if err != nil {
+		return nil, nil, substrate.PendingMultisig{}, err
+	}
+	p, err := client.Find(hash)
+	if err != nil {
+		return nil, nil, substrate.PendingMultisig{}, err
+	}
+	return cfg, client, p, nil
+}
+
+// submitMultisigCall prints c with --dryRun, otherwise signs it with the relayer key and submits it
+func submitMultisigCall(ctx *cli.Context, cfg *core.ChainConfig, client *substrate.MultisigClient, c types.Call) error {
+	if ctx.Bool(config.DryRunFlag.Name) {
+		fmt.Printf("call: %s\n", types.HexEncodeToString(substrate.EncodeCall(c)))
+		fmt.Printf("call hash: %s\n", substrate.CallHash(c).Hex())
+		return nil
+	}
+
+	key, err := substrate.NewSigner(cfg)
+	if err != nil {
+		return err
+	}
+
+	block, err := client.Submit(key, c)
+	if err != nil {
+		return fmt.Errorf("failed to submit call: %w", err)
+	}
+	log.Info("call included", "block", block.Hex())
+	return nil
+}
+
+// handleMultisigPendingCmd prints the open multisigs of the relayers
+func handleMultisigPendingCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	cfg, err := selectChainConfig(ctx, "substrate")
+	if err != nil {
+		return err
+	}
+	client, err := substrate.NewMultisigClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	pending, err := client.Pending()
+	if err != nil {
+		return err
+	}
+
+	multiSign := client.MultiSignAddress()
+	fmt.Printf("=== Found %d open multisigs of %s ===\n", len(pending), types.HexEncodeToString(multiSign[:]))
+	for i, p := range pending {
+		fmt.Printf("[%d] callHash=%s timepoint=%d-%d depositor=%s deposit=%s approvals=%d\n",
+			i, p.CallHash.Hex(), p.Height, p.Index, types.HexEncodeToString(p.Depositor[:]), p.Deposit, len(p.Approvals))
+		for _, a := range p.Approvals {
+			fmt.Printf("\tapproved by %s\n", types.HexEncodeToString(a[:]))
+		}
+	}
+	return nil
+}
+
+// handleMultisigApproveCmd approves the multisig of the call hash given as argument
+func handleMultisigApproveCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	cfg, client, p, err := findMultisig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var c types.Call
+	if recipients := ctx.StringSlice(config.RecipientFlag.Name); len(recipients) != 0 {
+		amounts := ctx.StringSlice(config.AmountFlag.Name)
+		if len(amounts) != len(recipients) {
+			return fmt.Errorf("got %d --%s for %d --%s", len(amounts), config.AmountFlag.Name, len(recipients), config.RecipientFlag.Name)
+		}
+		dests := make([]substrate.Dest, len(recipients))
+		for i, recipient := range recipients {
+			dests[i] = substrate.Dest{DestAddress: recipient, DestAmount: amounts[i]}
+		}
+		c, err = client.ExecuteTransfersCall(p, dests)
+	} else {
+		c, err = client.ApproveCall(p)
+	}
+	if err != nil {
+		return err
+	}
+	return submitMultisigCall(ctx, cfg, client, c)
+}
+
+// handleMultisigCancelCmd cancels the expired multisig of the call hash given as argument
+func handleMultisigCancelCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	cfg, client, p, err := findMultisig(ctx)
+	if err != nil {
+		return err
+	}
+
+	c, err := client.CancelCall(p, uint32(ctx.Uint64(config.ExpiryFlag.Name)))
+	if err != nil {
+		return err
+	}
+	return submitMultisigCall(ctx, cfg, client, c)
+}
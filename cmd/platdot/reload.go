@@ -0,0 +1,195 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/ChainSafe/log15"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/platdot-utils/core"
+	"github.com/urfave/cli/v2"
+)
+
+// reloadableChain is a chain whose non-critical options can be changed while it runs
+type reloadableChain interface {
+	// CheckReload returns an error if the chain can not apply the config without a restart
+	CheckReload(chainCfg *core.ChainConfig) error
+	// Reload applies the config to the running chain, nothing is applied on error
+	Reload(chainCfg *core.ChainConfig) error
+}
+
+// reloader re-reads the config file on SIGHUP, or when it changes with --watchConfig, and applies
+// it to the running chains
+type reloader struct {
+	ctx    *cli.Context
+	cfg    *config.Config
+	chains map[string]reloadableChain // By chain id
+	log    log.Logger
+}
+
+func newReloader(ctx *cli.Context, cfg *config.Config) *reloader {
+	return &reloader{
+		ctx:    ctx,
+		cfg:    cfg,
+		chains: make(map[string]reloadableChain),
+		log:    log.Root().New("module", "reload"),
+	}
+}
+
+func (r *reloader) addChain(chain config.RawChainConfig, c core.Chain) {
+	if rc, ok := c.(reloadableChain); ok {
+		r.chains[chain.Id] = rc
+	}
+}
+
+// start reloads the config on SIGHUP and, with --watchConfig, when the config file changes, until stop is closed
+func (r *reloader) start(stop <-chan struct{}) {
+	// Without a handler SIGHUP terminates the relayer
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var changed <-chan struct{}
+	if r.ctx.Bool(config.WatchConfigFlag.Name) {
+		changed = watchFile(config.ConfigPath(r.ctx), config.DefaultWatchInterval, stop)
+	}
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hup:
+				r.log.Info("Received SIGHUP, reloading config")
+			case <-changed:
+				r.log.Info("Config file changed, reloading config")
+			}
+			if err := r.reload(); err != nil {
+				r.log.Error("Config not reloaded", "err", err)
+			}
+		}
+	}()
+}
+
+// watchFile signals when the modification time or size of the file at path changes
+func watchFile(path string, interval time.Duration, stop <-chan struct{}) <-chan struct{} {
+	changed := make(chan struct{})
+	stat := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+
+	go func() {
+		modTime, size := stat()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			t, s := stat()
+			if s < 0 || (t.Equal(modTime) && s == size) {
+				continue
+			}
+			modTime, size = t, s
+			select {
+			case changed <- struct{}{}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return changed
+}
+
+// reload applies the config file to the running chains. Every chain is checked before any is changed,
+// so a config with an option that requires a restart is not applied at all.
+func (r *reloader) reload() error {
+	cfg, err := config.GetConfig(r.ctx)
+	if err != nil {
+		return err
+	}
+	if err = checkReloadConfig(r.cfg, cfg); err != nil {
+		return err
+	}
+
+	chainConfigs := make(map[string]*core.ChainConfig)
+	for _, chain := range cfg.Chains {
+		chainConfig, err := runChainConfig(r.ctx, cfg, chain)
+		if err != nil {
+			return err
+		}
+		if rc, ok := r.chains[chain.Id]; ok {
+			if err = rc.CheckReload(chainConfig); err != nil {
+				return fmt.Errorf("chain %s: %w", chain.Name, err)
+			}
+		}
+		chainConfigs[chain.Id] = chainConfig
+	}
+
+	failed := 0
+	for id, rc := range r.chains {
+		if err = rc.Reload(chainConfigs[id]); err != nil {
+			r.log.Error("Failed to reload chain, keeping its previous config", "chain", chainConfigs[id].Name, "err", err)
+			failed++
+		}
+	}
+	if err = applyVerbosity(r.ctx, cfg); err != nil {
+		return err
+	}
+	r.cfg = cfg
+	if failed != 0 {
+		return fmt.Errorf("%d of %d chains failed to reload", failed, len(r.chains))
+	}
+	r.log.Info("Reloaded config", "chains", len(r.chains))
+	return nil
+}
+
+// checkReloadConfig returns an error if next changes a part of the config that requires a restart:
+// the chains themselves and the keystore
+func checkReloadConfig(current, next *config.Config) error {
+	if current.KeystorePath != next.KeystorePath {
+		return fmt.Errorf("changing keystorePath requires a restart")
+	}
+	if len(current.Chains) != len(next.Chains) {
+		return fmt.Errorf("adding or removing chains requires a restart")
+	}
+	chains := make(map[string]config.RawChainConfig)
+	for _, chain := range current.Chains {
+		chains[chain.Id] = chain
+	}
+	for _, chain := range next.Chains {
+		running, ok := chains[chain.Id]
+		if !ok {
+			return fmt.Errorf("adding or removing chains requires a restart")
+		}
+		if chain.Type != running.Type {
+			return fmt.Errorf("changing the type of chain %s requires a restart", chain.Id)
+		}
+	}
+	return nil
+}
+
+// applyVerbosity sets the log level to the verbosity of the config, unless the verbosity flag is set
+func applyVerbosity(ctx *cli.Context, cfg *config.Config) error {
+	if cfg.Verbosity == "" || ctx.IsSet(config.VerbosityFlag.Name) {
+		return nil
+	}
+	lvl, err := config.ParseVerbosity(cfg.Verbosity)
+	if err != nil {
+		return err
+	}
+	setLogLevel(lvl)
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjman-self/Platdot/config"
+)
+
+func reloadConfig() *config.Config {
+	return &config.Config{
+		KeystorePath: "./keys",
+		Chains: []config.RawChainConfig{
+			{Name: "alaya", Type: "ethereum", Id: "1"},
+			{Name: "kusama", Type: "substrate", Id: "2"},
+		},
+	}
+}
+
+func TestCheckReloadConfig(t *testing.T) {
+	// The chains may be listed in another order
+	next := reloadConfig()
+	next.Chains[0], next.Chains[1] = next.Chains[1], next.Chains[0]
+	if err := checkReloadConfig(reloadConfig(), next); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		change   func(cfg *config.Config)
+		expected string
+	}{
+		{"keystore", func(cfg *config.Config) { cfg.KeystorePath = "./other" }, "keystorePath"},
+		{"removed chain", func(cfg *config.Config) { cfg.Chains = cfg.Chains[:1] }, "adding or removing chains"},
+		{"replaced chain", func(cfg *config.Config) { cfg.Chains[1].Id = "3" }, "adding or removing chains"},
+		{"chain type", func(cfg *config.Config) { cfg.Chains[1].Type = "ethereum" }, "type of chain 2"},
+	}
+	for _, tc := range testCases {
+		next := reloadConfig()
+		tc.change(next)
+		err := checkReloadConfig(reloadConfig(), next)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Fatalf("%s: Got: %v Expected: %s", tc.name, err, tc.expected)
+		}
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "platdot-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	if err = ioutil.WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	changed := watchFile(path, 10*time.Millisecond, stop)
+
+	select {
+	case <-changed:
+		t.Fatal("unchanged file reported as changed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err = ioutil.WriteFile(path, []byte(`{"chains": []}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("change not reported")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/ChainSafe/log15"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/chains/substrate"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/Platdot/shared/signer"
+	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
+	"github.com/rjman-self/platdot-utils/crypto/sr25519"
+	"github.com/rjman-self/platdot-utils/keystore"
+	"github.com/urfave/cli/v2"
+)
+
+var signerFlags = []cli.Flag{
+	config.SignerSocketFlag,
+	config.SignerPolicyFlag,
+	config.SignerEndpointFlag,
+}
+
+var signerCommand = cli.Command{
+	Action: wrapHandler(handleSignerCmd),
+	Name:   "signer",
+	Usage:  "sign for the relayers of this host",
+	Flags:  signerFlags,
+	Description: "The signer command holds relayer keys in a separate process and signs for the relayers over a Unix socket.\n" +
+		"\tThe keys are loaded from the keystore, given as 0x hex addresses for secp256k1 keys and SS58 addresses for sr25519 keys.\n" +
+		"\tRequests are checked against the --policy file, anything it does not allow is refused.\n" +
+		"\tExtrinsics are decoded with the runtime metadata of the --endpoint chain, their transfers may not pay the keys served.\n" +
+		"\tSet the socket as the 'signer' opt of the chains, the relayer then loads no key from its keystore.\n" +
+		"\tTo serve keys: platdot signer --policy policy.json --endpoint ws://localhost:9944 0x1234... 5GrwvaEF...",
+}
+
+// handleSignerCmd serves the keys of the addresses given as arguments until interrupted
+func handleSignerCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	if ctx.NArg() == 0 {
+		return fmt.Errorf("must provide the addresses of the keys to serve")
+	}
+	path := ctx.String(config.SignerPolicyFlag.Name)
+	if path == "" {
+		return fmt.Errorf("must provide a --%s file", config.SignerPolicyFlag.Name)
+	}
+	policy, err := signer.LoadPolicy(path)
+	if err != nil {
+		return err
+	}
+
+	server := signer.NewServer(policy, log.Root())
+	substrateKeys := 0
+	for _, addr := range ctx.Args().Slice() {
+		if common.IsHexAddress(addr) {
+			kp, err := keystore.KeypairFromAddress(common.HexToAddress(addr).Hex(), keystore.EthChain, dHandler.datadir, false)
+			if err != nil {
+				return err
+			}
+			server.AddEthereum(signer.NewEthereum(kp.(*secp256k1.Keypair)))
+		} else {
+			kp, err := keystore.KeypairFromAddress(addr, keystore.SubChain, dHandler.datadir, false)
+			if err != nil {
+				return err
+			}
+			krp := kp.(*sr25519.Keypair).AsKeyringPair()
+			server.AddSubstrate(signer.NewSubstrate((signature.KeyringPair)(*krp)))
+
+			var pub [32]byte
+			copy(pub[:], krp.PublicKey)
+			policy.DeniedAccounts = append(policy.DeniedAccounts, pub)
+			substrateKeys++
+		}
+	}
+
+	// Extrinsic payloads are only signed once their calls can be decoded
+	if endpoint := ctx.String(config.SignerEndpointFlag.Name); endpoint != "" {
+		policy.Decoder, err = substrate.DialPayloadDecoder(endpoint)
+		if err != nil {
+			return fmt.Errorf("fetch runtime metadata of %s: %w", endpoint, err)
+		}
+	} else if substrateKeys != 0 {
+		return fmt.Errorf("must provide a --%s to sign extrinsics", config.SignerEndpointFlag.Name)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	go func() {
+		<-sigc
+		log.Info("Interrupt received, shutting down the signer")
+		server.Close()
+	}()
+
+	socket := ctx.String(config.SignerSocketFlag.Name)
+	log.Info("Starting signer", "socket", socket, "keys", ctx.NArg())
+	return server.ListenAndServe(socket)
+}
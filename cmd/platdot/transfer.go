@@ -0,0 +1,247 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	log "github.com/ChainSafe/log15"
+	"github.com/JFJun/go-substrate-crypto/ss58"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/chains/platdot"
+	"github.com/rjman-self/Platdot/chains/substrate"
+	"github.com/rjman-self/Platdot/config"
+	"github.com/rjman-self/Platdot/shared/bech32"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	subutils "github.com/rjman-self/Platdot/shared/substrate"
+	"github.com/rjman-self/platdot-utils/core"
+	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
+	"github.com/rjman-self/platdot-utils/crypto/sr25519"
+	"github.com/rjman-self/platdot-utils/keystore"
+	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/urfave/cli/v2"
+)
+
+var transferFlags = []cli.Flag{
+	config.ConfigFileFlag,
+	config.KeystorePathFlag,
+	config.FromFlag,
+	config.TransferRecipientFlag,
+	config.TransferAmountFlag,
+	config.TransferTimeoutFlag,
+}
+
+var transferCommand = cli.Command{
+	Name:  "transfer",
+	Usage: "send a transfer through the bridge",
+	Description: "The transfer command sends a transfer like a bridge user would and follows it until it completes on the other chain.\n" +
+		"\tThe substrate and ethereum chains of the config are used, the key is the from address of the source chain unless --from is set.\n" +
+		"\tTo deposit KSM: platdot transfer deposit --recipient atp1... --amount planck\n" +
+		"\tTo redeem KSM: platdot transfer redeem --recipient 0xpubkey --amount amount",
+	Subcommands: []*cli.Command{
+		{
+			Action: wrapHandler(handleTransferDepositCmd),
+			Name:   "deposit",
+			Usage:  "deposit KSM to the Alaya chain",
+			Flags:  transferFlags,
+			Description: "The deposit subcommand sends a Utility.batch of a transfer to the MultiSignAddress and a remark with the Alaya recipient.\n" +
+				"\tIt then waits for the proposal of the deposit to be executed on the Alaya bridge.",
+		},
+		{
+			Action: wrapHandler(handleTransferRedeemCmd),
+			Name:   "redeem",
+			Usage:  "redeem wrapped KSM from the Alaya chain",
+			Flags:  transferFlags,
+			Description: "The redeem subcommand approves the erc20Handler and deposits the token of the ResourceId on the Alaya bridge.\n" +
+				"\tThe recipient is an ss58 address or hex public key. It then waits for the balance of the recipient to change.",
+		},
+	},
+}
+
+// transferChainConfigs returns the substrate and ethereum chain configs
+func transferChainConfigs(ctx *cli.Context) (*core.ChainConfig, *core.ChainConfig, error) {
+	sub, err := selectChainConfig(ctx, "substrate")
+	if err != nil {
+		return nil, nil, err
+	}
+	eth, err := selectChainConfig(ctx, "ethereum")
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub, eth, nil
+}
+
+// substratePublicKey parses an ss58 address or hex public key
+func substratePublicKey(addr string) ([]byte, error) {
+	if strings.HasPrefix(addr, "0x") {
+		pub, err := types.HexDecodeString(addr)
+		if err != nil || len(pub) != 32 {
+			return nil, fmt.Errorf("invalid public key %q", addr)
+		}
+		return pub, nil
+	}
+	pub, err := ss58.DecodeToPub(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	return pub, nil
+}
+
+// handleTransferDepositCmd deposits KSM for an Alaya recipient and waits for the proposal to be executed
+func handleTransferDepositCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	sub, eth, err := transferChainConfigs(ctx)
+	if err != nil {
+		return err
+	}
+	prefix := eth.Opts[platdot.PrefixOpt]
+
+	amount, err := parseAmount(ctx.String(config.TransferAmountFlag.Name))
+	if err != nil {
+		return err
+	}
+	// The relayers hold deposits with an invalid remark, so only send to a valid recipient
+	recipient := ctx.String(config.TransferRecipientFlag.Name)
+	if _, err = bech32.ParseAddress(recipient, prefix); err != nil {
+		return err
+	}
+	bridge, err := bech32.ParseAddress(eth.Opts[platdot.BridgeOpt], prefix)
+	if err != nil {
+		return fmt.Errorf("invalid bridge: %w", err)
+	}
+	multisig, err := substrate.ChainMultisig(sub)
+	if err != nil {
+		return err
+	}
+
+	from := sub.From
+	if f := ctx.String(config.FromFlag.Name); f != "" {
+		from = f
+	}
+	kp, err := keystore.KeypairFromAddress(from, keystore.SubChain, sub.KeystorePath, sub.Insecure)
+	if err != nil {
+		return err
+	}
+	krp := kp.(*sr25519.Keypair).AsKeyringPair()
+
+	// The target chain does not include the indices pallet
+	types.SetSerDeOptions(types.SerDeOptions{NoPalletIndices: true})
+	client, err := subutils.CreateClient((*signature.KeyringPair)(krp), sub.Endpoint)
+	if err != nil {
+		return err
+	}
+	alaya, err := utils.Dial(eth.Endpoint)
+	if err != nil {
+		return err
+	}
+	start, err := alaya.BlockNumber(context.Background())
+	if err != nil {
+		return err
+	}
+
+	c, err := client.NewDepositCall(multisig.Address, amount, recipient)
+	if err != nil {
+		return err
+	}
+	block, index, err := subutils.SubmitCall(client, c)
+	if err != nil {
+		return fmt.Errorf("failed to submit deposit: %w", err)
+	}
+	nonce := substrate.DepositNonce(substrate.BlockNumber(block), index)
+	log.Info("Deposit included, waiting for the proposal", "block", block, "extrinsic", index, "nonce", nonce)
+
+	status, err := utils.WaitForProposal(alaya, bridge, sub.Id, nonce, start, ctx.Duration(config.TransferTimeoutFlag.Name))
+	if err != nil {
+		return err
+	}
+	if status == utils.Cancelled {
+		return fmt.Errorf("proposal of deposit %d was cancelled, the deposit is refunded", nonce)
+	}
+	log.Info("Transfer completed", "nonce", nonce, "recipient", recipient)
+	return nil
+}
+
+// handleTransferRedeemCmd deposits the wrapped token on the Alaya bridge for a substrate recipient and waits
+// for the recipient to be paid
+func handleTransferRedeemCmd(ctx *cli.Context, dHandler *dataHandler) error {
+	sub, eth, err := transferChainConfigs(ctx)
+	if err != nil {
+		return err
+	}
+	prefix := eth.Opts[platdot.PrefixOpt]
+
+	amount, err := parseAmount(ctx.String(config.TransferAmountFlag.Name))
+	if err != nil {
+		return err
+	}
+	recipient, err := substratePublicKey(ctx.String(config.TransferRecipientFlag.Name))
+	if err != nil {
+		return err
+	}
+	bridge, err := bech32.ParseAddress(eth.Opts[platdot.BridgeOpt], prefix)
+	if err != nil {
+		return fmt.Errorf("invalid bridge: %w", err)
+	}
+	handler, err := bech32.ParseAddress(eth.Opts[platdot.Erc20HandlerOpt], prefix)
+	if err != nil {
+		return fmt.Errorf("invalid erc20Handler: %w", err)
+	}
+	rId := msg.ResourceIdFromSlice(common.FromHex(sub.Opts[substrate.ResourceIdOpt]))
+
+	from := eth.From
+	if f := ctx.String(config.FromFlag.Name); f != "" {
+		from = f
+	}
+	fromAddress, err := bech32.ParseAddress(from, prefix)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	kpI, err := keystore.KeypairFromAddress(fromAddress.Hex(), keystore.EthChain, eth.KeystorePath, eth.Insecure)
+	if err != nil {
+		return err
+	}
+	client, err := utils.NewClient(eth.Endpoint, kpI.(*secp256k1.Keypair))
+	if err != nil {
+		return err
+	}
+
+	// The target chain does not include the indices pallet
+	types.SetSerDeOptions(types.SerDeOptions{NoPalletIndices: true})
+	subClient, err := subutils.CreateClient(nil, sub.Endpoint)
+	if err != nil {
+		return err
+	}
+	balance, err := subutils.BalanceOf(subClient, recipient)
+	if err != nil {
+		return err
+	}
+
+	token, err := utils.Erc20GetResourceId(client, handler, rId)
+	if err != nil {
+		return err
+	}
+	if token == utils.ZeroAddress {
+		return fmt.Errorf("resource %s is not registered with the erc20Handler", rId.Hex())
+	}
+	err = utils.Erc20Approve(client, token, handler, amount)
+	if err != nil {
+		return fmt.Errorf("failed to approve the erc20Handler: %w", err)
+	}
+	nonce, err := utils.DepositErc20(client, bridge, sub.Id, rId, []byte(types.HexEncodeToString(recipient)), amount)
+	if err != nil {
+		return fmt.Errorf("failed to deposit: %w", err)
+	}
+	log.Info("Deposit mined, waiting for the redemption", "nonce", nonce)
+
+	paid, err := subutils.WaitForBalanceChange(subClient, recipient, balance, ctx.Duration(config.TransferTimeoutFlag.Name))
+	if err != nil {
+		return err
+	}
+	log.Info("Transfer completed", "nonce", nonce, "received", new(big.Int).Sub(paid, balance))
+	return nil
+}
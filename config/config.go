@@ -6,9 +6,16 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	ethcommon "github.com/ethereum/go-ethereum/common"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	log15 "github.com/ChainSafe/log15"
+	"github.com/rjman-self/Platdot/shared/bech32"
+	"gopkg.in/yaml.v2"
 
 	//ethcommon "github.com/ethereum/go-ethereum/common"
 
@@ -18,21 +25,44 @@ import (
 
 const DefaultConfigPath = "./config.json"
 const DefaultKeystorePath = "./keystore"
+const DefaultDenyListPath = "./denylist.json"
+const DefaultSignerSocketPath = "./signer.sock"
 const DefaultBlockTimeout = int64(180) // 3 minutes
+const DefaultMultisigExpiry = 14400    // 1 day of 6 second blocks
+const DefaultProposalExpiry = 100
+const DefaultDeployUrl = "http://localhost:6789"
+const DefaultTokenName = "Wrapped KSM"
+const DefaultTokenSymbol = "WKSM"
+const DefaultAddressPrefix = "atp"
+const DefaultChainName = "alaya"
+const DefaultTransferTimeout = 10 * time.Minute
+const DefaultWatchInterval = 5 * time.Second
+
+// Prefix of the environment variables overriding the config file, see applyEnv
+const EnvPrefix = "PLATDOT"
 
 type Config struct {
-	Chains       []RawChainConfig `json:"chains"`
-	KeystorePath string           `json:"keystorePath,omitempty"`
+	Chains       []RawChainConfig `json:"chains" toml:"chains" yaml:"chains"`
+	KeystorePath string           `json:"keystorePath,omitempty" toml:"keystorePath,omitempty" yaml:"keystorePath,omitempty"`
+	RelayerSet   *RelayerSet      `json:"relayerSet,omitempty" toml:"relayerSet,omitempty" yaml:"relayerSet,omitempty"`
+	Verbosity    string           `json:"verbosity,omitempty" toml:"verbosity,omitempty" yaml:"verbosity,omitempty"` // Log level, unless the verbosity flag is set
+}
+
+// RelayerSet is the multisig of the relayers on the substrate chain, shared by the config of every relayer.
+// It replaces the TotalRelayer, CurrentRelayerNumber, OtherRelayerN and MultiSignAddress substrate opts.
+type RelayerSet struct {
+	Relayers  []string `json:"relayers" toml:"relayers" yaml:"relayers"` // SS58 addresses or hex public keys, in relayer order
+	Threshold uint16   `json:"threshold" toml:"threshold" yaml:"threshold"`
 }
 
 // RawChainConfig is parsed directly from the config file and should be using to construct the core.ChainConfig
 type RawChainConfig struct {
-	Name     string            `json:"name"`
-	Type     string            `json:"type"`
-	Id       string            `json:"id"`       // ChainID
-	Endpoint string            `json:"endpoint"` // url for rpc endpoint
-	From     string            `json:"from"`     // address of key to use
-	Opts     map[string]string `json:"opts"`
+	Name     string            `json:"name" toml:"name" yaml:"name"`
+	Type     string            `json:"type" toml:"type" yaml:"type"`
+	Id       string            `json:"id" toml:"id" yaml:"id"`                   // ChainID
+	Endpoint string            `json:"endpoint" toml:"endpoint" yaml:"endpoint"` // url for rpc endpoint
+	From     string            `json:"from" toml:"from" yaml:"from"`             // address of key to use
+	Opts     map[string]string `json:"opts" toml:"opts" yaml:"opts"`
 }
 
 func NewConfig() *Config {
@@ -69,7 +99,16 @@ func (c *Config) ToJSON(file string) *os.File {
 }
 
 func (c *Config) validate() error {
-	for _, chain := range c.Chains {
+	if err := c.applyRelayerSet(); err != nil {
+		return err
+	}
+	if c.Verbosity != "" {
+		if _, err := ParseVerbosity(c.Verbosity); err != nil {
+			return fmt.Errorf("invalid verbosity %q", c.Verbosity)
+		}
+	}
+	for i := range c.Chains {
+		chain := &c.Chains[i]
 		if chain.Type == "" {
 			return fmt.Errorf("required field chain.Type empty for chain %s", chain.Id)
 		}
@@ -85,39 +124,108 @@ func (c *Config) validate() error {
 		if chain.From == "" {
 			return fmt.Errorf("required field chain.From empty for chain %s", chain.Id)
 		}
+		if chain.Type == "ethereum" {
+			if err := chain.normalizeAddresses(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Opts of ethereum chains holding a contract address
+var addressOpts = []string{"bridge", "erc20Handler", "erc721Handler", "genericHandler", "multicall"}
+
+// normalizeAddresses checks the from address and the contract address opts of an ethereum chain, given as bech32
+// with the prefix of the chain or as hex, and rewrites them as hex
+func (chain *RawChainConfig) normalizeAddresses() error {
+	prefix := chain.Opts["prefix"]
+	addr, err := bech32.ParseAddress(chain.From, prefix)
+	if err != nil {
+		return fmt.Errorf("invalid chain.From for chain %s: %w", chain.Id, err)
+	}
+	chain.From = addr.Hex()
+
+	for _, opt := range addressOpts {
+		if chain.Opts[opt] == "" {
+			continue
+		}
+		addr, err := bech32.ParseAddress(chain.Opts[opt], prefix)
+		if err != nil {
+			return fmt.Errorf("invalid opts.%s for chain %s: %w", opt, chain.Id, err)
+		}
+		chain.Opts[opt] = addr.Hex()
+	}
+	return nil
+}
 
-		if len(chain.From) > 3 && chain.From[:3] == chain.Opts["prefix"] {
-			addr, _ := ethcommon.PlatonToEth(chain.From)
-			chain.From = string(addr)
+// applyRelayerSet sets the Relayers and MultiSignThreshold opts of the substrate chains from the relayer set
+func (c *Config) applyRelayerSet() error {
+	if c.RelayerSet == nil {
+		return nil
+	}
+	if len(c.RelayerSet.Relayers) == 0 {
+		return fmt.Errorf("relayerSet has no relayers")
+	}
+	if c.RelayerSet.Threshold == 0 || int(c.RelayerSet.Threshold) > len(c.RelayerSet.Relayers) {
+		return fmt.Errorf("relayerSet threshold must be from 1 to %d", len(c.RelayerSet.Relayers))
+	}
+	for i := range c.Chains {
+		chain := &c.Chains[i]
+		if chain.Type != "substrate" {
+			continue
 		}
-		if len(chain.Opts["bridge"]) > 3 && chain.Opts["bridge"][:3] == chain.Opts["prefix"] {
-			addr, _ := ethcommon.PlatonToEth(chain.Opts["bridge"])
-			address := ethcommon.BytesToAddress(addr)
-			chain.Opts["bridge"] = address.String()
+		for opt := range chain.Opts {
+			if opt == "TotalRelayer" || opt == "CurrentRelayerNumber" || opt == "MultiSignThreshold" ||
+				strings.HasPrefix(opt, "OtherRelayer") {
+				return fmt.Errorf("opts.%s of chain %s conflicts with relayerSet", opt, chain.Id)
+			}
 		}
-		if len(chain.Opts["erc20Handler"]) > 3 && chain.Opts["erc20Handler"][:3] == chain.Opts["prefix"] {
-			addr, _ := ethcommon.PlatonToEth(chain.Opts["erc20Handler"])
-			address := ethcommon.BytesToAddress(addr)
-			chain.Opts["erc20Handler"] = address.String()
+		if chain.Opts == nil {
+			chain.Opts = make(map[string]string)
 		}
+		chain.Opts["Relayers"] = strings.Join(c.RelayerSet.Relayers, ",")
+		chain.Opts["MultiSignThreshold"] = strconv.Itoa(int(c.RelayerSet.Threshold))
 	}
 	return nil
 }
 
 func GetConfig(ctx *cli.Context) (*Config, error) {
-	var fig Config
-	path := DefaultConfigPath
+	fig, err := ReadConfig(ConfigPath(ctx))
+	if err != nil {
+		return fig, err
+	}
+	if ksPath := ctx.String(KeystorePathFlag.Name); ksPath != "" {
+		fig.KeystorePath = ksPath
+	}
+	return fig, nil
+}
+
+// ConfigPath returns the path of the config file given by the config flag
+func ConfigPath(ctx *cli.Context) string {
 	if file := ctx.String(ConfigFileFlag.Name); file != "" {
-		path = file
+		return file
+	}
+	return DefaultConfigPath
+}
+
+// ParseVerbosity parses a log level given by name or number
+func ParseVerbosity(verbosity string) (log15.Lvl, error) {
+	if lvl, err := strconv.Atoi(verbosity); err == nil {
+		return log15.Lvl(lvl), nil
 	}
+	return log15.LvlFromString(verbosity)
+}
+
+// ReadConfig loads and validates a config file with the environment overrides
+func ReadConfig(path string) (*Config, error) {
+	var fig Config
 	err := loadConfig(path, &fig)
 	if err != nil {
-		log.Warn("err loading json file", "err", err.Error())
+		log.Warn("err loading config file", "err", err.Error())
 		return &fig, err
 	}
-	if ksPath := ctx.String(KeystorePathFlag.Name); ksPath != "" {
-		fig.KeystorePath = ksPath
-	}
+	fig.applyEnv(os.Environ())
 	log.Debug("Loaded config", "path", path)
 	err = fig.validate()
 	if err != nil {
@@ -140,13 +248,81 @@ func loadConfig(file string, config *Config) error {
 		return err
 	}
 
-	if ext == ".json" {
-		if err = json.NewDecoder(f).Decode(&config); err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("unrecognized extention: %s", ext)
+	switch ext {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&config)
+	case ".toml":
+		_, err = toml.DecodeReader(f, config)
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(f).Decode(config)
+	default:
+		err = fmt.Errorf("unrecognized extention: %s", ext)
+	}
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
+
+// applyEnv overrides the chains with the PLATDOT_<CHAIN>_<OPT> variables of environ, where CHAIN is the
+// upper case chain name. OPT is ENDPOINT or FROM for these fields, otherwise the name of an opt as written
+// in the config file, so secrets and endpoints can be kept out of the file. A variable applies to the chain
+// of the longest matching prefix, so PLATDOT_ALAYA_TEST_ENDPOINT is not an opt of a chain named alaya.
+func (c *Config) applyEnv(environ []string) {
+	prefixes := make([]string, len(c.Chains))
+	for i := range c.Chains {
+		prefixes[i] = envChainPrefix(c.Chains[i].Name)
+	}
+
+	for _, kv := range environ {
+		longest := 0
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(kv, prefix) && len(prefix) > longest {
+				longest = len(prefix)
+			}
+		}
+		if longest == 0 {
+			continue
+		}
+		sep := strings.Index(kv[longest:], "=")
+		if sep < 1 {
+			continue
+		}
+		key, value := kv[longest:longest+sep], kv[longest+sep+1:]
+
+		for i := range c.Chains {
+			chain := &c.Chains[i]
+			if len(prefixes[i]) != longest || !strings.HasPrefix(kv, prefixes[i]) {
+				continue
+			}
+			switch key {
+			case "ENDPOINT":
+				chain.Endpoint = value
+			case "FROM":
+				chain.From = value
+			default:
+				if chain.Opts == nil {
+					chain.Opts = make(map[string]string)
+				}
+				chain.Opts[key] = value
+			}
+			log.Debug("Config overridden by environment", "chain", chain.Name, "key", key)
+		}
+	}
+}
+
+// envChainPrefix returns the environment variable prefix of a chain, chain names are upper cased and
+// characters other than letters and digits become underscores
+func envChainPrefix(name string) string {
+	upper := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	return EnvPrefix + "_" + upper + "_"
+}
@@ -9,11 +9,14 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/urfave/cli/v2"
 )
 
+const testFrom = "0xff93B45308FD417dF303D6515aB04D9e89a750Ca"
+
 func createTempConfigFile() (*os.File, *Config) {
 	testConfig := NewConfig()
 	ethCfg := RawChainConfig{
@@ -21,7 +24,7 @@ func createTempConfigFile() (*os.File, *Config) {
 		Type:     "ethereum",
 		Id:       "1",
 		Endpoint: "endpoint",
-		From:     "0x0",
+		From:     testFrom,
 		Opts:     map[string]string{"key": "value"},
 	}
 	testConfig.Chains = []RawChainConfig{ethCfg}
@@ -77,7 +80,7 @@ func TestValdiateConfig(t *testing.T) {
 		Type:     "ethereum",
 		Id:       "1",
 		Endpoint: "endpoint",
-		From:     "0x0",
+		From:     testFrom,
 		Opts:     nil,
 	}
 
@@ -86,7 +89,7 @@ func TestValdiateConfig(t *testing.T) {
 		Type:     "",
 		Id:       "1",
 		Endpoint: "endpoint",
-		From:     "0x0",
+		From:     testFrom,
 		Opts:     nil,
 	}
 
@@ -95,7 +98,7 @@ func TestValdiateConfig(t *testing.T) {
 		Type:     "ethereum",
 		Id:       "1",
 		Endpoint: "",
-		From:     "0x0",
+		From:     testFrom,
 		Opts:     nil,
 	}
 
@@ -104,7 +107,7 @@ func TestValdiateConfig(t *testing.T) {
 		Type:     "ethereum",
 		Id:       "1",
 		Endpoint: "endpoint",
-		From:     "0x0",
+		From:     testFrom,
 		Opts:     nil,
 	}
 
@@ -147,4 +150,215 @@ func TestValdiateConfig(t *testing.T) {
 	if err == nil {
 		t.Fatal("must require name field")
 	}
+
+	cfg = Config{
+		Chains:    []RawChainConfig{valid},
+		Verbosity: "dbug",
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Verbosity = "loud"
+	err = cfg.validate()
+	if err == nil {
+		t.Fatal("must reject an unknown verbosity")
+	}
+}
+
+func TestLoadTOMLConfig(t *testing.T) {
+	ctx, err := createCliContext("", []string{"config"}, []interface{}{"../scripts/configs/config1.toml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := GetConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Chains) != 2 {
+		t.Fatalf("Got: %d Expected: %d", len(res.Chains), 2)
+	}
+	chain := res.Chains[1]
+	if chain.Name != "ETC" || chain.Id != "1" || chain.Endpoint != "ws://localhost:8546" {
+		t.Fatalf("unexpected chain: %+v", chain)
+	}
+	if chain.Opts["chainID"] != "1337" {
+		t.Fatalf("Got: %s Expected: %s", chain.Opts["chainID"], "1337")
+	}
+}
+
+func TestLoadYAMLConfig(t *testing.T) {
+	_, cfg := createTempConfigFile()
+	raw := `chains:
+  - name: chain
+    type: ethereum
+    id: 1
+    endpoint: endpoint
+    from: "0xff93B45308FD417dF303D6515aB04D9e89a750Ca"
+    opts:
+      key: value
+`
+	tmpFile, err := ioutil.TempFile(os.TempDir(), "*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.WriteString(raw); err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+
+	ctx, err := createCliContext("", []string{"config"}, []interface{}{tmpFile.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := GetConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res, cfg) {
+		t.Errorf("did not match\ngot: %+v\nexpected: %+v", res.Chains[0], cfg.Chains[0])
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg := &Config{Chains: []RawChainConfig{
+		{Name: "kusama", Endpoint: "ws://localhost:9944", Opts: map[string]string{"MaxWeight": "1"}},
+		{Name: "alaya-dev", Endpoint: "http://localhost:6789"},
+	}}
+
+	cfg.applyEnv([]string{
+		"PLATDOT_KUSAMA_ENDPOINT=wss://kusama.example",
+		"PLATDOT_KUSAMA_MaxWeight=22698000000",
+		"PLATDOT_ALAYA_DEV_FROM=atp1",
+		"PLATDOT_ALAYA_DEV_networkId=201030",
+		"PLATDOT_OTHER_ENDPOINT=ws://other",
+		"HOME=/root",
+	})
+
+	kusama, alaya := cfg.Chains[0], cfg.Chains[1]
+	if kusama.Endpoint != "wss://kusama.example" {
+		t.Fatalf("Got: %s Expected: %s", kusama.Endpoint, "wss://kusama.example")
+	}
+	if kusama.Opts["MaxWeight"] != "22698000000" {
+		t.Fatalf("Got: %s Expected: %s", kusama.Opts["MaxWeight"], "22698000000")
+	}
+	if alaya.From != "atp1" || alaya.Endpoint != "http://localhost:6789" {
+		t.Fatalf("unexpected chain: %+v", alaya)
+	}
+	if alaya.Opts["networkId"] != "201030" {
+		t.Fatalf("Got: %s Expected: %s", alaya.Opts["networkId"], "201030")
+	}
+}
+
+func TestApplyEnvOverlappingChains(t *testing.T) {
+	cfg := &Config{Chains: []RawChainConfig{
+		{Name: "alaya", Endpoint: "http://localhost:6789"},
+		{Name: "alaya_test", Endpoint: "http://localhost:6790"},
+	}}
+
+	cfg.applyEnv([]string{
+		"PLATDOT_ALAYA_TEST_ENDPOINT=http://test.example",
+		"PLATDOT_ALAYA_FROM=atp1",
+	})
+
+	alaya, test := cfg.Chains[0], cfg.Chains[1]
+	if alaya.Endpoint != "http://localhost:6789" || alaya.From != "atp1" || len(alaya.Opts) != 0 {
+		t.Fatalf("unexpected chain: %+v", alaya)
+	}
+	if test.Endpoint != "http://test.example" || test.From != "" {
+		t.Fatalf("unexpected chain: %+v", test)
+	}
+}
+
+func TestApplyRelayerSet(t *testing.T) {
+	cfg := &Config{
+		Chains: []RawChainConfig{
+			{Name: "alaya", Type: "ethereum", Id: "2", Opts: map[string]string{"prefix": "atp"}},
+			{Name: "kusama", Type: "substrate", Id: "1"},
+		},
+		RelayerSet: &RelayerSet{Relayers: []string{"0x01", "0x02", "0x03"}, Threshold: 2},
+	}
+
+	if err := cfg.applyRelayerSet(); err != nil {
+		t.Fatal(err)
+	}
+	opts := cfg.Chains[1].Opts
+	if opts["Relayers"] != "0x01,0x02,0x03" || opts["MultiSignThreshold"] != "2" {
+		t.Fatalf("unexpected opts: %v", opts)
+	}
+	if _, ok := cfg.Chains[0].Opts["Relayers"]; ok {
+		t.Fatal("relayer set must only apply to substrate chains")
+	}
+
+	// The numbered relayers conflict with the relayer set
+	cfg.Chains[1].Opts = map[string]string{"OtherRelayer1": "0x01"}
+	if err := cfg.applyRelayerSet(); err == nil {
+		t.Fatal("must reject OtherRelayer opts with a relayer set")
+	}
+
+	cfg.Chains[1].Opts = nil
+	cfg.RelayerSet.Threshold = 4
+	if err := cfg.applyRelayerSet(); err == nil {
+		t.Fatal("must reject a threshold above the number of relayers")
+	}
+}
+
+func TestNormalizeAddresses(t *testing.T) {
+	// 0xff93B45308FD417dF303D6515aB04D9e89a750Ca and 0x62877dDCd49aD22f5eDfc6ac108e9a4b5D2bD88B
+	from := "atp1l7fmg5cgl4qhmucr6eg44vzdn6y6w5x2q695d9"
+	bridge := "atp1v2rhmhx5ntfz7hklc6kppr56fdwjhkytxgakmg"
+	chain := RawChainConfig{
+		Name:     "alaya",
+		Type:     "ethereum",
+		Id:       "2",
+		Endpoint: "endpoint",
+		From:     from,
+		Opts: map[string]string{
+			"prefix":         "atp",
+			"bridge":         bridge,
+			"erc20Handler":   "0x62877ddcd49ad22f5edfc6ac108e9a4b5d2bd88b",
+			"erc721Handler":  bridge,
+			"genericHandler": strings.ToUpper(bridge),
+		},
+	}
+	cfg := Config{Chains: []RawChainConfig{chain}}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	res := cfg.Chains[0]
+	if res.From != testFrom {
+		t.Fatalf("Got: %s Expected: %s", res.From, testFrom)
+	}
+	for _, opt := range []string{"bridge", "erc20Handler", "erc721Handler", "genericHandler"} {
+		if res.Opts[opt] != "0x62877dDCd49aD22f5eDfc6ac108e9a4b5D2bD88B" {
+			t.Fatalf("opts.%s Got: %s Expected: %s", opt, res.Opts[opt], "0x62877dDCd49aD22f5eDfc6ac108e9a4b5D2bD88B")
+		}
+	}
+
+	invalid := map[string]string{
+		"bridge":        bridge[:len(bridge)-1] + "q",                 // Invalid checksum
+		"erc721Handler": "lat1v2rhmhx5ntfz7hklc6kppr56fdwjhkytl7twy8", // Other prefix
+		"multicall":     "0x0",
+	}
+	for opt, addr := range invalid {
+		chain.From = from
+		chain.Opts = map[string]string{"prefix": "atp", opt: addr}
+		cfg = Config{Chains: []RawChainConfig{chain}}
+		if err := cfg.validate(); err == nil {
+			t.Fatalf("opts.%s %s Got: nil Expected: invalid address", opt, addr)
+		}
+	}
+
+	// Bech32 needs the prefix of the chain
+	chain.Opts = map[string]string{}
+	cfg = Config{Chains: []RawChainConfig{chain}}
+	if err := cfg.validate(); err == nil {
+		t.Fatal("Got: nil Expected: bech32 from rejected without prefix")
+	}
 }
@@ -46,6 +46,11 @@ var (
 		Name:  "latest",
 		Usage: "Overrides blockstore and start block, starts from latest block",
 	}
+
+	WatchConfigFlag = &cli.BoolFlag{
+		Name:  "watchConfig",
+		Usage: "Reloads the config file when it changes, as on SIGHUP",
+	}
 )
 
 // Metrics flags
@@ -68,6 +73,10 @@ var (
 		Name:  "password",
 		Usage: "Password used to encrypt the keystore. Used with --generate, --import, or --unlock",
 	}
+	NewPasswordFlag = &cli.StringFlag{
+		Name:  "newPassword",
+		Usage: "Password to encrypt the keystore with. Used with password",
+	}
 	Sr25519Flag = &cli.BoolFlag{
 		Name:  "sr25519",
 		Usage: "Specify account/key type as sr25519.",
@@ -101,3 +110,147 @@ var (
 		Usage: "Applies a predetermined test keystore to the chains.",
 	}
 )
+
+// Deny-list subcommand flags
+var (
+	DenyListFlag = &cli.StringFlag{
+		Name:  "denylist",
+		Usage: "Path to the deny-list file",
+		Value: DefaultDenyListPath,
+	}
+	ReasonFlag = &cli.StringFlag{
+		Name:  "reason",
+		Usage: "Reason the address is denied, stored alongside the entry",
+	}
+)
+
+// Multisig subcommand flags
+var (
+	ChainIdFlag = &cli.StringFlag{
+		Name:  "chain",
+		Usage: "Id of the chain in the config file, defaults to the only chain of the type the command manages",
+	}
+	DryRunFlag = &cli.BoolFlag{
+		Name:    "dryRun",
+		Aliases: []string{"dry-run"},
+		Usage:   "Print the encoded call instead of submitting it",
+	}
+	RecipientFlag = &cli.StringSliceFlag{
+		Name:  "recipient",
+		Usage: "Hex public key of a transfer recipient, sends the call with as_multi to execute it. Repeat it for every transfer of a batch",
+	}
+	AmountFlag = &cli.StringSliceFlag{
+		Name:  "amount",
+		Usage: "Amount of a transfer in planck, given once per --recipient in the same order",
+	}
+	ExpiryFlag = &cli.Uint64Flag{
+		Name:  "expiry",
+		Usage: "Number of blocks after which a multisig may be cancelled",
+		Value: DefaultMultisigExpiry,
+	}
+)
+
+// Deploy subcommand flags
+var (
+	UrlFlag = &cli.StringFlag{
+		Name:  "url",
+		Usage: "Endpoint of the chain to deploy to, http(s) or ws(s)",
+		Value: DefaultDeployUrl,
+	}
+	FromFlag = &cli.StringFlag{
+		Name:  "from",
+		Usage: "Address of the key in the keystore to send from",
+	}
+	BridgeChainIdFlag = &cli.UintFlag{
+		Name:     "chainId",
+		Usage:    "Id of the chain in the bridge, as used by the chain config",
+		Required: true,
+	}
+	RelayersFlag = &cli.StringSliceFlag{
+		Name:  "relayers",
+		Usage: "Initial relayers of the bridge, defaults to the deployer",
+	}
+	RelayerThresholdFlag = &cli.Uint64Flag{
+		Name:  "threshold",
+		Usage: "Number of relayer votes needed to pass a proposal",
+		Value: 1,
+	}
+	FeeFlag = &cli.StringFlag{
+		Name:  "fee",
+		Usage: "Fee paid with a deposit, in the smallest unit of the native token",
+		Value: "0",
+	}
+	ProposalExpiryFlag = &cli.Uint64Flag{
+		Name:  "proposalExpiry",
+		Usage: "Number of blocks after which a proposal may be cancelled",
+		Value: DefaultProposalExpiry,
+	}
+	TokenNameFlag = &cli.StringFlag{
+		Name:  "tokenName",
+		Usage: "Name of the wrapped token",
+		Value: DefaultTokenName,
+	}
+	TokenSymbolFlag = &cli.StringFlag{
+		Name:  "tokenSymbol",
+		Usage: "Symbol of the wrapped token",
+		Value: DefaultTokenSymbol,
+	}
+	ResourceIdFlag = &cli.StringFlag{
+		Name:  "resourceId",
+		Usage: "Hex resource id of the wrapped token, derived from the token address and chain id if unset",
+	}
+	PrefixFlag = &cli.StringFlag{
+		Name:  "prefix",
+		Usage: "Bech32 prefix of the chain addresses",
+		Value: DefaultAddressPrefix,
+	}
+	ChainNameFlag = &cli.StringFlag{
+		Name:  "name",
+		Usage: "Name of the chain in the printed config",
+		Value: DefaultChainName,
+	}
+)
+
+// Transfer subcommand flags
+var (
+	TransferRecipientFlag = &cli.StringFlag{
+		Name:     "recipient",
+		Usage:    "Recipient of the transfer on the destination chain",
+		Required: true,
+	}
+	TransferAmountFlag = &cli.StringFlag{
+		Name:     "amount",
+		Usage:    "Amount to transfer in the smallest unit of the source chain token",
+		Required: true,
+	}
+	TransferTimeoutFlag = &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time to wait for the transfer to complete on the destination chain",
+		Value: DefaultTransferTimeout,
+	}
+)
+
+// Admin subcommand flags
+var (
+	HandlerFlag = &cli.StringFlag{
+		Name:  "handler",
+		Usage: "Address of the handler contract, defaults to the erc20Handler of the chain config",
+	}
+)
+
+// Signer subcommand flags
+var (
+	SignerSocketFlag = &cli.StringFlag{
+		Name:  "socket",
+		Usage: "Path of the Unix socket the signer listens on, set it as the 'signer' opt of the chains",
+		Value: DefaultSignerSocketPath,
+	}
+	SignerPolicyFlag = &cli.StringFlag{
+		Name:  "policy",
+		Usage: "JSON file of the policy signing requests are checked against",
+	}
+	SignerEndpointFlag = &cli.StringFlag{
+		Name:  "endpoint",
+		Usage: "Substrate endpoint the runtime metadata is fetched from, required to check the extrinsics of sr25519 keys",
+	}
+)
@@ -0,0 +1,63 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package platdot
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var _ bind.ContractBackend = &backend{}
+
+// backend sends the calls of contract bindings to the current client of the connection, so bindings
+// keep working after Reconnect
+type backend struct {
+	conn *Connection
+}
+
+// Backend returns a contract backend following the endpoint of the connection
+func (c *Connection) Backend() bind.ContractBackend {
+	return &backend{conn: c}
+}
+
+func (b *backend) CodeAt(ctx context.Context, contract ethcommon.Address, blockNumber *big.Int) ([]byte, error) {
+	return b.conn.Client().CodeAt(ctx, contract, blockNumber)
+}
+
+func (b *backend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return b.conn.Client().CallContract(ctx, call, blockNumber)
+}
+
+func (b *backend) PendingCodeAt(ctx context.Context, account ethcommon.Address) ([]byte, error) {
+	return b.conn.Client().PendingCodeAt(ctx, account)
+}
+
+func (b *backend) PendingNonceAt(ctx context.Context, account ethcommon.Address) (uint64, error) {
+	return b.conn.Client().PendingNonceAt(ctx, account)
+}
+
+func (b *backend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return b.conn.Client().SuggestGasPrice(ctx)
+}
+
+func (b *backend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return b.conn.Client().EstimateGas(ctx, call)
+}
+
+func (b *backend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return b.conn.Client().SendTransaction(ctx, tx)
+}
+
+func (b *backend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return b.conn.Client().FilterLogs(ctx, query)
+}
+
+func (b *backend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return b.conn.Client().SubscribeFilterLogs(ctx, query, ch)
+}
@@ -7,13 +7,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
 	"github.com/ChainSafe/log15"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ethcommon "github.com/ethereum/go-ethereum/common"
-	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/Platdot/shared/signer"
 	"math/big"
 	"os"
 	"strconv"
@@ -26,25 +27,28 @@ var BlockRetryInterval = time.Second * 5
 type Connection struct {
 	endpoint      string
 	http          bool
-	kp            *secp256k1.Keypair
+	signer        signer.Ethereum
 	gasLimit      *big.Int
 	maxGasPrice   *big.Int
 	gasMultiplier *big.Float
-	conn          *ethclient.Client
+	conn          utils.ChainClient
+	clientLock    sync.RWMutex // Guards conn, which is replaced by Reconnect
 	opts          *bind.TransactOpts
 	callOpts      *bind.CallOpts
-	nonce         uint64
+	nonce         uint64 // Next nonce of the account, managed locally
+	nonceSynced   bool   // Unset when the nonce must be queried from the chain again
 	optsLock      sync.Mutex
 	log           log15.Logger
 	stop          chan int // All routines should exit when this channel is closed
 }
 
-// NewConnection returns an uninitialized connection, must call Connection.Connect() before using.
-func NewConnection(endpoint string, http bool, kp *secp256k1.Keypair, log log15.Logger, gasLimit, gasPrice *big.Int, gasMultiplier *big.Float) *Connection {
+// NewConnection returns an uninitialized connection signing its transactions with s, must call
+// Connection.Connect() before using.
+func NewConnection(endpoint string, http bool, s signer.Ethereum, log log15.Logger, gasLimit, gasPrice *big.Int, gasMultiplier *big.Float) *Connection {
 	return &Connection{
 		endpoint:      endpoint,
 		http:          http,
-		kp:            kp,
+		signer:        s,
 		gasLimit:      gasLimit,
 		maxGasPrice:   gasPrice,
 		gasMultiplier: gasMultiplier,
@@ -53,52 +57,120 @@ func NewConnection(endpoint string, http bool, kp *secp256k1.Keypair, log log15.
 	}
 }
 
-// Connect starts the ethereum WS connection
+// NewConnectionWithClient returns an uninitialized connection using an already connected client instead
+// of dialing an endpoint, must call Connection.Connect() before using.
+func NewConnectionWithClient(client utils.ChainClient, s signer.Ethereum, log log15.Logger, gasLimit, gasPrice *big.Int, gasMultiplier *big.Float) *Connection {
+	conn := NewConnection("", false, s, log, gasLimit, gasPrice, gasMultiplier)
+	conn.conn = client
+	return conn
+}
+
+// Connect starts the ethereum WS connection, unless the connection was given a client
 func (c *Connection) Connect() error {
-	c.log.Info("Connecting to Alaya test chain...", "url", c.endpoint)
+	if c.conn == nil {
+		c.log.Info("Connecting to Alaya test chain...", "url", c.endpoint)
+		client, err := dial(c.endpoint, c.http)
+		if err != nil {
+			return err
+		}
+		c.conn = client
+	}
+
+	// Construct tx opts, call opts, and nonce mechanism
+	opts, nonce, err := c.newTransactOpts(big.NewInt(0), c.gasLimit, c.maxGasPrice)
+	if err != nil {
+		return err
+	}
+	c.opts = opts
+	c.nonce = nonce
+	c.nonceSynced = true
+	c.callOpts = &bind.CallOpts{From: c.signer.CommonAddress()}
+	return nil
+}
+
+// dial starts an http or ws client
+func dial(endpoint string, http bool) (*ethclient.Client, error) {
 	var rpcClient *rpc.Client
 	var err error
-
-	// Start http or ws client
-	if c.http {
-		rpcClient, err = rpc.DialHTTP(c.endpoint)
+	if http {
+		rpcClient, err = rpc.DialHTTP(endpoint)
 	} else {
-		rpcClient, err = rpc.DialWebsocket(context.Background(), c.endpoint, "/ws")
+		rpcClient, err = rpc.DialWebsocket(context.Background(), endpoint, "/ws")
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	c.conn = ethclient.NewClient(rpcClient)
+	return ethclient.NewClient(rpcClient), nil
+}
 
-	// Construct tx opts, call opts, and nonce mechanism
-	opts, _, err := c.newTransactOpts(big.NewInt(0), c.gasLimit, c.maxGasPrice)
+// Reconnect switches the connection to another endpoint of the same chain. Calls in flight on the
+// previous endpoint may fail, the new endpoint is used by every call made after Reconnect returns.
+func (c *Connection) Reconnect(endpoint string, http bool) error {
+	client, err := dial(endpoint, http)
 	if err != nil {
 		return err
 	}
-	c.opts = opts
-	c.nonce = 0
-	c.callOpts = &bind.CallOpts{From: c.kp.CommonAddress()}
+	expected, err := c.Client().ChainID(context.Background())
+	if err != nil {
+		client.Close()
+		return err
+	}
+	id, err := client.ChainID(context.Background())
+	if err != nil {
+		client.Close()
+		return err
+	}
+	if id.Cmp(expected) != 0 {
+		client.Close()
+		return fmt.Errorf("endpoint %s serves chain %s, expected %s", endpoint, id, expected)
+	}
+
+	c.clientLock.Lock()
+	previous := c.conn
+	c.conn = client
+	c.endpoint = endpoint
+	c.http = http
+	c.clientLock.Unlock()
+	previous.Close()
+	c.log.Info("Reconnected to Alaya chain", "url", endpoint)
 	return nil
 }
 
-// newTransactOpts builds the TransactOpts for the connection's keypair.
+// SetGasOptions replaces the gas limit and gas price caps used by the next transactions
+func (c *Connection) SetGasOptions(gasLimit, maxGasPrice *big.Int, gasMultiplier *big.Float) {
+	c.optsLock.Lock()
+	defer c.optsLock.Unlock()
+	c.gasLimit = gasLimit
+	c.maxGasPrice = maxGasPrice
+	c.gasMultiplier = gasMultiplier
+	if c.opts != nil {
+		c.opts.GasLimit = uint64(gasLimit.Int64())
+	}
+}
+
+// newTransactOpts builds the TransactOpts for the connection's signer.
 func (c *Connection) newTransactOpts(value, gasLimit, gasPrice *big.Int) (*bind.TransactOpts, uint64, error) {
-	privateKey := c.kp.PrivateKey()
-	address := ethcrypto.PubkeyToAddress(privateKey.PublicKey)
+	address := c.signer.CommonAddress()
 
-	nonce, err := c.conn.PendingNonceAt(context.Background(), address)
+	nonce, err := c.Client().PendingNonceAt(context.Background(), address)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	id := os.Getenv("networkId")
-	chainId, _ := strconv.Atoi(id)
-
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(int64(chainId)))
+	chainId, err := c.chainID()
 	if err != nil {
 		return nil, 0, err
 	}
 
+	auth := &bind.TransactOpts{
+		From: address,
+		Signer: func(from ethcommon.Address, tx *ethtypes.Transaction) (*ethtypes.Transaction, error) {
+			if from != address {
+				return nil, bind.ErrNotAuthorized
+			}
+			return c.signer.SignTx(tx, chainId)
+		},
+	}
 	auth.Nonce = big.NewInt(int64(nonce))
 	auth.Value = value
 	auth.GasLimit = uint64(gasLimit.Int64())
@@ -108,11 +180,23 @@ func (c *Connection) newTransactOpts(value, gasLimit, gasPrice *big.Int) (*bind.
 	return auth, nonce, nil
 }
 
-func (c *Connection) Keypair() *secp256k1.Keypair {
-	return c.kp
+// chainID returns the chain id set by the networkId environment variable, or the one served by the client
+func (c *Connection) chainID() (*big.Int, error) {
+	if id := os.Getenv("networkId"); id != "" {
+		chainId, _ := strconv.Atoi(id)
+		return big.NewInt(int64(chainId)), nil
+	}
+	return c.Client().ChainID(context.Background())
+}
+
+// Address returns the account the transactions of the connection are sent from
+func (c *Connection) Address() ethcommon.Address {
+	return c.signer.CommonAddress()
 }
 
-func (c *Connection) Client() *ethclient.Client {
+func (c *Connection) Client() utils.ChainClient {
+	c.clientLock.RLock()
+	defer c.clientLock.RUnlock()
 	return c.conn
 }
 
@@ -126,7 +210,7 @@ func (c *Connection) CallOpts() *bind.CallOpts {
 
 func (c *Connection) SafeEstimateGas(ctx context.Context) (*big.Int, error) {
 
-	suggestedGasPrice, err := c.conn.SuggestGasPrice(context.TODO())
+	suggestedGasPrice, err := c.Client().SuggestGasPrice(context.TODO())
 
 	if err != nil {
 		return nil, err
@@ -156,32 +240,52 @@ func multiplyGasPrice(gasEstimate *big.Int, gasMultiplier *big.Float) *big.Int {
 }
 
 // LockAndUpdateOpts acquires a lock on the opts before updating the nonce
-// and gas price.
+// and gas price. The nonce is tracked locally, the pending nonce of the account
+// is only queried again after ResetNonce. Once a tx is submitted with the opts,
+// IncrementNonce must be called before UnlockOpts.
 func (c *Connection) LockAndUpdateOpts() error {
 	c.optsLock.Lock()
 
 	gasPrice, err := c.SafeEstimateGas(context.TODO())
 	if err != nil {
+		c.optsLock.Unlock()
 		return err
 	}
 	c.opts.GasPrice = gasPrice
 
-	nonce, err := c.conn.PendingNonceAt(context.Background(), c.opts.From)
-	if err != nil {
-		c.optsLock.Unlock()
-		return err
+	if !c.nonceSynced {
+		nonce, err := c.Client().PendingNonceAt(context.Background(), c.opts.From)
+		if err != nil {
+			c.optsLock.Unlock()
+			return err
+		}
+		c.nonce = nonce
+		c.nonceSynced = true
 	}
-	c.opts.Nonce.SetUint64(nonce)
+	c.opts.Nonce.SetUint64(c.nonce)
 	return nil
 }
 
+// IncrementNonce moves the local nonce past a submitted tx, the opts must be locked
+func (c *Connection) IncrementNonce() {
+	c.nonce++
+}
+
+// ResetNonce makes the next LockAndUpdateOpts query the pending nonce of the account,
+// used when a tx was rejected for its nonce or dropped from the pool
+func (c *Connection) ResetNonce() {
+	c.optsLock.Lock()
+	c.nonceSynced = false
+	c.optsLock.Unlock()
+}
+
 func (c *Connection) UnlockOpts() {
 	c.optsLock.Unlock()
 }
 
 // LatestBlock returns the latest block from the current chain
 func (c *Connection) LatestBlock() (*big.Int, error) {
-	header, err := c.conn.HeaderByNumber(context.Background(), nil)
+	header, err := c.Client().HeaderByNumber(context.Background(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -190,7 +294,7 @@ func (c *Connection) LatestBlock() (*big.Int, error) {
 
 // EnsureHasBytecode asserts if contract code exists at the specified address
 func (c *Connection) EnsureHasBytecode(addr ethcommon.Address) error {
-	code, err := c.conn.CodeAt(context.Background(), addr, nil)
+	code, err := c.Client().CodeAt(context.Background(), addr, nil)
 	if err != nil {
 		return err
 	}
@@ -231,8 +335,8 @@ func (c *Connection) WaitForBlock(targetBlock *big.Int, delay *big.Int) error {
 
 // Close terminates the client connection and stops any running routines
 func (c *Connection) Close() {
-	if c.conn != nil {
-		c.conn.Close()
+	if client := c.Client(); client != nil {
+		client.Close()
 	}
 	close(c.stop)
 }
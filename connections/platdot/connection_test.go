@@ -12,18 +12,25 @@ import (
 	"github.com/ChainSafe/log15"
 	ethcmn "github.com/ethereum/go-ethereum/common"
 	ethutils "github.com/rjman-self/Platdot/shared/platdot"
-	ethtest "github.com/rjman-self/Platdot/shared/platdot/testing"
+	"github.com/rjman-self/Platdot/shared/platdot/simulated"
+	"github.com/rjman-self/Platdot/shared/signer"
 )
 
-var TestEndpoint = "ws://localhost:8545"
 var AliceKp = keystore.TestKeyRing.EthereumKeys[keystore.AliceKey]
 var GasLimit = big.NewInt(ethutils.DefaultGasLimit)
 var MaxGasPrice = big.NewInt(ethutils.DefaultMaxGasPrice)
 
 var GasMultipler = big.NewFloat(ethutils.DefaultGasMultiplier)
 
+// newTestChain starts a simulated chain with the accounts of the test keyring
+func newTestChain(t *testing.T) *simulated.Backend {
+	sim := simulated.New()
+	t.Cleanup(sim.Close)
+	return sim
+}
+
 func TestConnect(t *testing.T) {
-	conn := NewConnection(TestEndpoint, false, AliceKp, log15.Root(), GasLimit, MaxGasPrice, GasMultipler)
+	conn := NewConnectionWithClient(newTestChain(t), signer.NewEthereum(AliceKp), log15.Root(), GasLimit, MaxGasPrice, GasMultipler)
 	err := conn.Connect()
 	if err != nil {
 		t.Fatal(err)
@@ -34,13 +41,17 @@ func TestConnect(t *testing.T) {
 // TestContractCode is used to make sure the contracts are deployed correctly.
 // This is probably the least intrusive way to check if the contracts exists
 func TestContractCode(t *testing.T) {
-	client := ethtest.NewClient(t, TestEndpoint, AliceKp)
+	sim := newTestChain(t)
+	client, err := ethutils.NewClientWithChain(sim, AliceKp)
+	if err != nil {
+		t.Fatal(err)
+	}
 	contracts, err := ethutils.DeployContracts(client, 0, big.NewInt(0))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	conn := NewConnection(TestEndpoint, false, AliceKp, log15.Root(), GasLimit, MaxGasPrice, GasMultipler)
+	conn := NewConnectionWithClient(sim, signer.NewEthereum(AliceKp), log15.Root(), GasLimit, MaxGasPrice, GasMultipler)
 	err = conn.Connect()
 	if err != nil {
 		t.Fatal(err)
@@ -62,7 +73,7 @@ func TestContractCode(t *testing.T) {
 
 func TestConnection_SafeEstimateGas(t *testing.T) {
 	// MaxGasPrice is the constant price on the dev network, so we increase it here by 1 to ensure it adjusts
-	conn := NewConnection(TestEndpoint, false, AliceKp, log15.Root(), GasLimit, MaxGasPrice.Add(MaxGasPrice, big.NewInt(1)), GasMultipler)
+	conn := NewConnectionWithClient(newTestChain(t), signer.NewEthereum(AliceKp), log15.Root(), GasLimit, MaxGasPrice.Add(MaxGasPrice, big.NewInt(1)), GasMultipler)
 	err := conn.Connect()
 	if err != nil {
 		t.Fatal(err)
@@ -81,7 +92,7 @@ func TestConnection_SafeEstimateGas(t *testing.T) {
 
 func TestConnection_SafeEstimateGasMax(t *testing.T) {
 	maxPrice := big.NewInt(1)
-	conn := NewConnection(TestEndpoint, false, AliceKp, log15.Root(), GasLimit, maxPrice, GasMultipler)
+	conn := NewConnectionWithClient(newTestChain(t), signer.NewEthereum(AliceKp), log15.Root(), GasLimit, maxPrice, GasMultipler)
 	err := conn.Connect()
 	if err != nil {
 		t.Fatal(err)
@@ -97,3 +108,41 @@ func TestConnection_SafeEstimateGasMax(t *testing.T) {
 		t.Fatalf("Gas price should equal max. Suggested: %s Max: %s", price.String(), maxPrice.String())
 	}
 }
+
+func TestConnection_LocalNonce(t *testing.T) {
+	conn := NewConnectionWithClient(newTestChain(t), signer.NewEthereum(AliceKp), log15.Root(), GasLimit, MaxGasPrice, GasMultipler)
+	err := conn.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pending, err := conn.Client().PendingNonceAt(context.Background(), conn.Opts().From)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Submitted txs move the nonce without querying the chain
+	for i := uint64(0); i < 3; i++ {
+		err = conn.LockAndUpdateOpts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nonce := conn.Opts().Nonce.Uint64(); nonce != pending+i {
+			t.Fatalf("Got: %d Expected: %d", nonce, pending+i)
+		}
+		conn.IncrementNonce()
+		conn.UnlockOpts()
+	}
+
+	// After a reset the pending nonce is used again
+	conn.ResetNonce()
+	err = conn.LockAndUpdateOpts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.UnlockOpts()
+	if nonce := conn.Opts().Nonce.Uint64(); nonce != pending {
+		t.Fatalf("Got: %d Expected: %d", nonce, pending)
+	}
+}
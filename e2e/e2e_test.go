@@ -0,0 +1,181 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package e2e
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ChainSafe/log15"
+)
+
+var oneKSM = big.NewInt(1000000000000)
+var oneAKSM = new(big.Int).Mul(oneKSM, big.NewInt(1000000))
+
+var errUnavailable = errors.New("unavailable")
+
+func init() {
+	log15.Root().SetHandler(log15.LvlFilterHandler(log15.LvlError, log15.StdoutHandler))
+	SetPollInterval(DefaultConfig.BlockTime)
+}
+
+func TestKSMToAKSM(t *testing.T) {
+	h := New(t, DefaultConfig)
+	recipient := h.User.CommonAddress()
+
+	h.DepositKSM(KusamaAccount(1), oneKSM, recipient)
+	h.WaitForAKSM(recipient, BridgedKSM(oneKSM))
+}
+
+func TestAKSMToKSM(t *testing.T) {
+	h := New(t, DefaultConfig)
+	recipient := KusamaAccount(2)
+
+	h.MintAKSM(oneAKSM)
+	h.DepositAKSM(oneAKSM, recipient)
+	h.WaitForKSM(recipient, RedeemedAKSM(oneAKSM))
+	if h.Executions() != 1 {
+		t.Fatalf("Got: %d Expected: 1 multisig executed", h.Executions())
+	}
+}
+
+func TestRelayerCrash(t *testing.T) {
+	h := New(t, DefaultConfig)
+	aksmRecipient := h.User.CommonAddress()
+	ksmRecipient := KusamaAccount(2)
+
+	// The other relayers reach the threshold without the crashed one
+	h.Relayers[0].Crash()
+	h.MintAKSM(oneAKSM)
+	h.DepositKSM(KusamaAccount(1), oneKSM, aksmRecipient)
+	h.DepositAKSM(oneAKSM, ksmRecipient)
+	h.WaitForAKSM(aksmRecipient, BridgedKSM(oneKSM))
+	h.WaitForKSM(ksmRecipient, RedeemedAKSM(oneAKSM))
+
+	// The restarted relayer goes over the bridged deposits again without bridging them twice
+	h.Relayers[0].Start()
+	h.WaitForBlocks(30)
+	if balance := h.AKSMBalance(aksmRecipient); balance.Cmp(BridgedKSM(oneKSM)) != 0 {
+		t.Fatalf("Got: %s Expected: %s", balance, BridgedKSM(oneKSM))
+	}
+	if paid := h.PaidKSM(ksmRecipient); paid.Cmp(RedeemedAKSM(oneAKSM)) != 0 {
+		t.Fatalf("Got: %s Expected: %s", paid, RedeemedAKSM(oneAKSM))
+	}
+}
+
+func TestRPCFailures(t *testing.T) {
+	h := New(t, DefaultConfig)
+	aksmRecipient := h.User.CommonAddress()
+	ksmRecipient := KusamaAccount(2)
+
+	for _, method := range []string{"chain_getFinalizedHead", "chain_getHeader", "chain_getBlockHash", "state_getStorage"} {
+		for i := 0; i < 3; i++ {
+			h.Node.FailNext(method, errUnavailable)
+		}
+	}
+	for _, r := range h.Relayers {
+		r.FailNextAlaya("SendTransaction", errUnavailable)
+		r.FailNextAlaya("FilterLogs", errUnavailable)
+		r.FailNextAlaya("HeaderByNumber", errUnavailable)
+	}
+
+	h.MintAKSM(oneAKSM)
+	h.DepositKSM(KusamaAccount(1), oneKSM, aksmRecipient)
+	h.DepositAKSM(oneAKSM, ksmRecipient)
+	h.WaitForAKSM(aksmRecipient, BridgedKSM(oneKSM))
+	h.WaitForKSM(ksmRecipient, RedeemedAKSM(oneAKSM))
+}
+
+func TestKusamaReorg(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.FinalityDepth = 5
+	h := New(t, cfg)
+	recipient := h.User.CommonAddress()
+
+	// The block of the deposit is replaced before it is finalized, the deposit is included by another block
+	h.DepositKSM(KusamaAccount(1), oneKSM, recipient)
+	h.WaitForDeposits()
+	if n := h.ReorgKusama(); n != 1 {
+		t.Fatalf("Got: %d Expected: 1 deposit reorganized", n)
+	}
+	h.WaitForAKSM(recipient, BridgedKSM(oneKSM))
+
+	h.WaitForBlocks(10)
+	if balance := h.AKSMBalance(recipient); balance.Cmp(BridgedKSM(oneKSM)) != 0 {
+		t.Fatalf("Got: %s Expected: %s", balance, BridgedKSM(oneKSM))
+	}
+}
+
+func TestDuplicateMessages(t *testing.T) {
+	h := New(t, DefaultConfig)
+	aksmRecipient := h.User.CommonAddress()
+	ksmRecipient := KusamaAccount(2)
+
+	h.MintAKSM(oneAKSM)
+	h.DepositKSM(KusamaAccount(1), oneKSM, aksmRecipient)
+	h.DepositAKSM(oneAKSM, ksmRecipient)
+	h.WaitForAKSM(aksmRecipient, BridgedKSM(oneKSM))
+	h.WaitForKSM(ksmRecipient, RedeemedAKSM(oneAKSM))
+
+	// The restarted relayer delivers every deposit again, the extrinsics of the relayers are included twice
+	h.DuplicateExtrinsics(true)
+	h.Relayers[0].Restart()
+	h.WaitForBlocks(30)
+	if balance := h.AKSMBalance(aksmRecipient); balance.Cmp(BridgedKSM(oneKSM)) != 0 {
+		t.Fatalf("Got: %s Expected: %s", balance, BridgedKSM(oneKSM))
+	}
+	if paid := h.PaidKSM(ksmRecipient); paid.Cmp(RedeemedAKSM(oneAKSM)) != 0 {
+		t.Fatalf("Got: %s Expected: %s", paid, RedeemedAKSM(oneAKSM))
+	}
+
+	// New deposits are bridged once
+	h.MintAKSM(oneAKSM)
+	h.DepositKSM(KusamaAccount(1), oneKSM, aksmRecipient)
+	h.DepositAKSM(oneAKSM, ksmRecipient)
+	h.WaitForAKSM(aksmRecipient, new(big.Int).Mul(BridgedKSM(oneKSM), big.NewInt(2)))
+	h.WaitForKSM(ksmRecipient, new(big.Int).Mul(RedeemedAKSM(oneAKSM), big.NewInt(2)))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	h := New(t, DefaultConfig)
+	aksmRecipient := h.User.CommonAddress()
+	ksmRecipient := KusamaAccount(2)
+
+	// The third relayer is down, the threshold is only reached with the keys of the daemon
+	socket := h.StartSigner()
+	h.Relayers[2].Crash()
+	for _, r := range h.Relayers[:2] {
+		r.Crash()
+		r.UseSigner(socket)
+		r.Start()
+	}
+
+	h.MintAKSM(oneAKSM)
+	h.DepositKSM(KusamaAccount(1), oneKSM, aksmRecipient)
+	h.DepositAKSM(oneAKSM, ksmRecipient)
+	h.WaitForAKSM(aksmRecipient, BridgedKSM(oneKSM))
+	h.WaitForKSM(ksmRecipient, RedeemedAKSM(oneAKSM))
+}
+
+func TestRestartAllRelayers(t *testing.T) {
+	// The relayers start from startBlock instead of their blockstore and the writer only knows the multisigs
+	// its listener has seen, so a redemption delivered again by every relayer at once is paid twice
+	t.Skip("redemptions are paid again when every relayer restarts")
+
+	h := New(t, DefaultConfig)
+	recipient := KusamaAccount(2)
+
+	h.MintAKSM(oneAKSM)
+	h.DepositAKSM(oneAKSM, recipient)
+	h.WaitForKSM(recipient, RedeemedAKSM(oneAKSM))
+
+	for _, r := range h.Relayers {
+		r.Restart()
+	}
+	h.WaitForBlocks(30)
+	if paid := h.PaidKSM(recipient); paid.Cmp(RedeemedAKSM(oneAKSM)) != 0 {
+		t.Fatalf("Got: %s Expected: %s", paid, RedeemedAKSM(oneAKSM))
+	}
+}
@@ -0,0 +1,569 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+/*
+The e2e package runs several relayers in process between a simulated Alaya chain and a fake Kusama node.
+
+A Harness starts both chains, deploys the bridge on the Alaya chain and connects Config.Relayers relayers of the
+test keyring to them, each relayer being a core.Core with a substrate and an Alaya chain like the platdot
+command. The Kusama blocks are produced every Config.BlockTime by the harness, which applies the multisig calls
+of the relayers as the Multisig pallet does; the Alaya chain mines a block at the same pace.
+
+Faults are injected while the relayers run: relayers are crashed and restarted, RPC calls of either chain are
+failed, the unfinalized Kusama blocks are replaced by a reorg and relayer extrinsics are included twice. The
+SimulatedBackend of go-ethereum can not fork, so the Alaya chain is never reorganized.
+*/
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/log15"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/Platdot/chains/platdot"
+	"github.com/rjman-self/Platdot/chains/substrate"
+	"github.com/rjman-self/Platdot/shared/bech32"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/Platdot/shared/platdot/simulated"
+	"github.com/rjman-self/Platdot/shared/signer"
+	subutils "github.com/rjman-self/Platdot/shared/substrate"
+	"github.com/rjman-self/Platdot/shared/substrate/fakenode"
+	"github.com/rjman-self/platdot-utils/core"
+	"github.com/rjman-self/platdot-utils/crypto"
+	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
+	"github.com/rjman-self/platdot-utils/keystore"
+	"github.com/rjman-self/platdot-utils/msg"
+	subtypes "github.com/rjmand/go-substrate-rpc-client/v2/types"
+)
+
+var KusamaId = msg.ChainId(1)
+var AlayaId = msg.ChainId(2)
+
+// Keys of the test keyring used by the relayers, in order
+var relayerKeys = []string{keystore.AliceKey, keystore.BobKey, keystore.CharlieKey, keystore.DaveKey, keystore.EveKey}
+
+// Password of the keystores of the relayers
+const keystorePassword = "e2e"
+
+var ErrCrashed = errors.New("relayer crashed")
+
+// Config of the chains and relayers of a harness
+type Config struct {
+	Relayers      int           // Number of relayers, at most 5
+	Threshold     int           // Approvals required on both chains
+	BlockTime     time.Duration // Time between the blocks of both chains
+	FinalityDepth uint32        // Number of Kusama blocks above the finalized block
+	Confirmations int64         // Block confirmations of the Alaya listeners
+	Timeout       time.Duration // Time waited for a transfer to be bridged
+}
+
+// DefaultConfig has 3 relayers with a threshold of 2
+var DefaultConfig = Config{
+	Relayers:      3,
+	Threshold:     2,
+	BlockTime:     100 * time.Millisecond,
+	FinalityDepth: 2,
+	Confirmations: 2,
+	Timeout:       60 * time.Second,
+}
+
+// SetPollInterval sets the intervals the relayers poll the chains at, for the block time of the harnesses.
+// The intervals are package variables of the chains, they must be set before the first harness starts.
+func SetPollInterval(blockTime time.Duration) {
+	substrate.RoundInterval = blockTime
+	substrate.BlockRetryInterval = blockTime / 2
+	platdot.BlockRetryInterval = blockTime / 2
+}
+
+// Harness runs the chains and relayers of a test
+type Harness struct {
+	t   *testing.T
+	cfg Config
+
+	Node     *fakenode.Node
+	Sim      *simulated.Backend
+	kusama   *substrateChain
+	Relayers []*Relayer
+
+	MultiSign types.AccountID // Multisig account of the relayers on Kusama
+	Bridge    *utils.DeployedBridge
+	User      *secp256k1.Keypair // Alaya account depositing AKSM and receiving KSM deposits
+	user      *utils.Client
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// New starts the chains, deploys the bridge and starts cfg.Relayers relayers. Everything is stopped by
+// the cleanup of t.
+func New(t *testing.T, cfg Config) *Harness {
+	if cfg.Relayers < 1 || cfg.Relayers > len(relayerKeys) {
+		t.Fatalf("Relayers must be from 1 to %d, got %d", len(relayerKeys), cfg.Relayers)
+	}
+	h := &Harness{t: t, cfg: cfg, stop: make(chan struct{})}
+	setKeystorePassword(t)
+
+	meta, err := fakenode.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Node, err = fakenode.New(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Node.Close)
+
+	var signatories []subtypes.AccountID
+	for _, r := range relayerKeys[:cfg.Relayers] {
+		account := relayerAccount(r)
+		signatories = append(signatories, subtypes.AccountID(account))
+		err = h.Node.SetAccount(account, types.AccountInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	multiSign, err := substrate.MultiAccountID(signatories, uint16(cfg.Threshold))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.MultiSign = types.AccountID(multiSign)
+	h.kusama, err = newSubstrateChain(h.Node, meta, h.MultiSign, cfg.FinalityDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.User, err = secp256k1.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Sim = simulated.New(h.User.CommonAddress())
+	t.Cleanup(h.Sim.Close)
+	h.user, err = utils.NewClientWithChain(h.Sim.Client(), h.User)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.deployBridge()
+
+	h.stopped.Add(1)
+	go h.produceBlocks()
+	t.Cleanup(h.stopBlocks)
+
+	for _, r := range relayerKeys[:cfg.Relayers] {
+		relayer := &Relayer{Name: r, h: h, keystore: t.TempDir(), blockstore: t.TempDir()}
+		relayer.writeKeys()
+		h.Relayers = append(h.Relayers, relayer)
+		relayer.Start()
+		t.Cleanup(relayer.Crash)
+	}
+	return h
+}
+
+// StartSigner starts a signer daemon holding the keys of the relayers, its policy allows the calls of the
+// bridge contract and the multisig transfers on Kusama that do not pay a relayer or the multisig account. It
+// returns the socket of the daemon, stopped by the cleanup of the test.
+func (h *Harness) StartSigner() string {
+	genesis, _ := h.Node.BlockHash(0)
+	decoder, err := substrate.DialPayloadDecoder(h.Node.URL())
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	policy := &signer.Policy{
+		ChainID:        simulated.ChainID,
+		Contracts:      []common.Address{h.Bridge.BridgeAddress},
+		GenesisHash:    genesis[:],
+		DeniedAccounts: [][32]byte{h.MultiSign},
+		Decoder:        decoder,
+	}
+	for _, method := range []subutils.Method{subutils.MultisigAsMulti, subutils.MultisigApproveAsMulti, subutils.MultisigCancelAsMulti,
+		subutils.BalancesTransferKeepAliveMethod, subutils.UtilityBatchAll} {
+		index, err := h.kusama.meta.FindCallIndex(string(method))
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		policy.Calls = append(policy.Calls, [2]byte{index.SectionIndex, index.MethodIndex})
+	}
+
+	server := signer.NewServer(policy, log15.Root().New("signer", "e2e"))
+	for _, r := range relayerKeys[:h.cfg.Relayers] {
+		server.AddEthereum(signer.NewEthereum(keystore.TestKeyRing.EthereumKeys[r]))
+		krp := keystore.TestKeyRing.SubstrateKeys[r].AsKeyringPair()
+		server.AddSubstrate(signer.NewSubstrate((signature.KeyringPair)(*krp)))
+
+		var pub [32]byte
+		copy(pub[:], krp.PublicKey)
+		policy.DeniedAccounts = append(policy.DeniedAccounts, pub)
+	}
+	socket := filepath.Join(h.t.TempDir(), "signer.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	go func() { _ = server.Serve(l) }()
+	h.t.Cleanup(server.Close)
+	return socket
+}
+
+// deployBridge deploys the bridge of the AKSM token with the relayers, the token is minted by its handler
+func (h *Harness) deployBridge() {
+	var relayers []common.Address
+	for _, r := range relayerKeys[:h.cfg.Relayers] {
+		relayers = append(relayers, keystore.TestKeyRing.EthereumKeys[r].CommonAddress())
+	}
+	var err error
+	h.Bridge, err = utils.DeployBridgeContracts(h.user, utils.BridgeDeployment{
+		ChainID:          uint8(AlayaId),
+		Relayers:         relayers,
+		RelayerThreshold: big.NewInt(int64(h.cfg.Threshold)),
+		Fee:              big.NewInt(0),
+		Expiry:           big.NewInt(100000),
+		TokenName:        "AKSM",
+		TokenSymbol:      "AKSM",
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// produceBlocks adds a block to both chains every BlockTime until the harness is stopped
+func (h *Harness) produceBlocks() {
+	defer h.stopped.Done()
+	ticker := time.NewTicker(h.cfg.BlockTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+		h.Sim.Mine(1)
+		if err := h.kusama.produce(); err != nil {
+			h.t.Errorf("Failed to produce a Kusama block: %s", err)
+			return
+		}
+	}
+}
+
+func (h *Harness) stopBlocks() {
+	close(h.stop)
+	h.stopped.Wait()
+}
+
+// DepositKSM transfers amount to the multisig account in a batch with the remark of recipient, as users
+// deposit KSM. The deposit is included by the next block.
+func (h *Harness) DepositKSM(sender types.AccountID, amount *big.Int, recipient common.Address) {
+	ext, err := depositExtrinsic(h.kusama.meta, sender, h.MultiSign, amount, recipient.Hex())
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.kusama.submit(extrinsic{
+		data: ext,
+		events: []fakenode.Event{
+			{Module: "Balances", Name: "Transfer", Args: []interface{}{sender, h.MultiSign, types.NewU128(*amount)}},
+			fakenode.ExtrinsicSuccess(0),
+		},
+	})
+}
+
+// DepositAKSM deposits amount of AKSM of the user to the Kusama account recipient
+func (h *Harness) DepositAKSM(amount *big.Int, recipient types.AccountID) msg.Nonce {
+	err := utils.Erc20Approve(h.user, h.Bridge.TokenAddress, h.Bridge.ERC20HandlerAddress, amount)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	nonce, err := utils.DepositErc20(h.user, h.Bridge.BridgeAddress, KusamaId, h.Bridge.ResourceId,
+		[]byte(types.HexEncodeToString(recipient[:])), amount)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return nonce
+}
+
+// MintAKSM mints amount of AKSM to the user
+func (h *Harness) MintAKSM(amount *big.Int) {
+	err := utils.Erc20Mint(h.user, h.Bridge.TokenAddress, h.User.CommonAddress(), amount)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// AKSMBalance returns the AKSM balance of an Alaya account
+func (h *Harness) AKSMBalance(account common.Address) *big.Int {
+	balance, err := utils.Erc20GetBalance(h.user, h.Bridge.TokenAddress, account)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return balance
+}
+
+// PaidKSM returns the amount the multisig account paid to recipient in the finalized Kusama blocks
+func (h *Harness) PaidKSM(recipient types.AccountID) *big.Int {
+	return h.kusama.paid(recipient)
+}
+
+// Executions returns the number of multisigs executed in the finalized Kusama blocks
+func (h *Harness) Executions() int {
+	return h.kusama.executions()
+}
+
+// WaitForAKSM waits until account holds expected AKSM
+func (h *Harness) WaitForAKSM(account common.Address, expected *big.Int) {
+	h.waitFor(fmt.Sprintf("AKSM balance %s of %s", expected, account.Hex()), func() bool {
+		return h.AKSMBalance(account).Cmp(expected) == 0
+	})
+}
+
+// WaitForKSM waits until the multisig account paid expected KSM to recipient
+func (h *Harness) WaitForKSM(recipient types.AccountID, expected *big.Int) {
+	h.waitFor(fmt.Sprintf("KSM payment %s to %s", expected, types.HexEncodeToString(recipient[:])), func() bool {
+		return h.PaidKSM(recipient).Cmp(expected) == 0
+	})
+}
+
+// WaitForBlocks waits until n more Kusama blocks are finalized
+func (h *Harness) WaitForBlocks(n int) {
+	target := h.finalizedBlock() + n
+	h.waitFor(fmt.Sprintf("Kusama block %d", target), func() bool {
+		return h.finalizedBlock() >= target
+	})
+}
+
+func (h *Harness) finalizedBlock() int {
+	h.kusama.lock.Lock()
+	defer h.kusama.lock.Unlock()
+	return h.kusama.finalized()
+}
+
+func (h *Harness) waitFor(what string, cond func() bool) {
+	deadline := time.Now().Add(h.cfg.Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(h.cfg.BlockTime / 4)
+	}
+}
+
+// ReorgKusama replaces the unfinalized Kusama blocks, their extrinsics are included again by the next
+// blocks. Returns the number of deposits put back in the queue.
+func (h *Harness) ReorgKusama() int {
+	n, err := h.kusama.rewind()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return n
+}
+
+// WaitForDeposits waits until the deposits are included in a Kusama block
+func (h *Harness) WaitForDeposits() {
+	h.waitFor("the deposits to be included", func() bool { return h.kusama.queued() == 0 })
+}
+
+// DuplicateExtrinsics makes the following Kusama blocks include every relayer extrinsic twice
+func (h *Harness) DuplicateExtrinsics(duplicate bool) {
+	h.kusama.setDuplicate(duplicate)
+}
+
+// Relayer is a relayer of the harness, running a substrate and an Alaya chain
+type Relayer struct {
+	Name       string
+	h          *Harness
+	keystore   string
+	blockstore string
+	signer     string // Socket of the signer daemon the relayer signs through, its keystore is used when empty
+
+	lock   sync.Mutex
+	client *simulated.Backend // Client of the Alaya chain of the running relayer
+	sysErr chan error
+	done   chan struct{} // Closed once the core of the running relayer stopped
+}
+
+// Start starts the relayer with new chains. Nothing is kept in memory from a previous run, the chains
+// resume from their blockstore like the relayer process does.
+func (r *Relayer) Start() {
+	h := r.h
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.done != nil {
+		h.t.Fatalf("Relayer %s is already running", r.Name)
+	}
+
+	logger := log15.Root().New("relayer", r.Name)
+	sysErr := make(chan error, 1)
+	client := h.Sim.Client()
+
+	var relayers []string
+	for _, k := range relayerKeys[:h.cfg.Relayers] {
+		account := relayerAccount(k)
+		relayers = append(relayers, types.HexEncodeToString(account[:]))
+	}
+	kusamaOpts := map[string]string{
+		substrate.RelayersOpt:           strings.Join(relayers, ","),
+		substrate.MultiSignThresholdOpt: fmt.Sprint(h.cfg.Threshold),
+		substrate.DestIdOpt:             fmt.Sprint(AlayaId),
+		substrate.ResourceIdOpt:         types.HexEncodeToString(h.Bridge.ResourceId[:]),
+		substrate.BatchWindowOpt:        "0",
+	}
+	alayaOpts := map[string]string{
+		platdot.BridgeOpt:             h.Bridge.BridgeAddress.Hex(),
+		platdot.Erc20HandlerOpt:       h.Bridge.ERC20HandlerAddress.Hex(),
+		platdot.GasLimitOpt:           fmt.Sprint(platdot.DefaultGasLimit),
+		platdot.MaxGasPriceOpt:        fmt.Sprint(platdot.DefaultGasPrice),
+		platdot.BlockConfirmationsOpt: fmt.Sprint(h.cfg.Confirmations),
+		platdot.PrefixOpt:             "atp",
+	}
+	if r.signer != "" {
+		kusamaOpts[substrate.SignerOpt] = r.signer
+		alayaOpts[platdot.SignerOpt] = r.signer
+	}
+
+	kusama, err := substrate.InitializeChain(&core.ChainConfig{
+		Name:           "kusama",
+		Id:             KusamaId,
+		Endpoint:       h.Node.URL(),
+		From:           keystore.TestKeyRing.SubstrateKeys[r.Name].Address(),
+		KeystorePath:   r.keystore,
+		BlockstorePath: r.blockstore,
+		Opts:           kusamaOpts,
+	}, logger.New("chain", "kusama"), sysErr, nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	from, err := bech32.FormatAddress(keystore.TestKeyRing.EthereumKeys[r.Name].CommonAddress(), "atp")
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	alaya, err := platdot.InitializeChainWithClient(&core.ChainConfig{
+		Name:           "alaya",
+		Id:             AlayaId,
+		From:           from,
+		KeystorePath:   r.keystore,
+		BlockstorePath: r.blockstore,
+		Opts:           alayaOpts,
+	}, client, logger.New("chain", "alaya"), sysErr, nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	c := core.NewCore(sysErr)
+	c.AddChain(kusama)
+	c.AddChain(alaya)
+	done := make(chan struct{})
+	go func() {
+		c.Start()
+		close(done)
+	}()
+	r.client, r.sysErr, r.done = client, sysErr, done
+}
+
+// writeKeys writes the keys of the relayer to its keystore, as the accounts import command does
+func (r *Relayer) writeKeys() {
+	sub := keystore.TestKeyRing.SubstrateKeys[r.Name]
+	eth := keystore.TestKeyRing.EthereumKeys[r.Name]
+	for name, kp := range map[string]crypto.Keypair{sub.Address(): sub, eth.CommonAddress().String(): eth} {
+		f, err := os.Create(filepath.Join(r.keystore, name+".key"))
+		if err != nil {
+			r.h.t.Fatal(err)
+		}
+		err = keystore.EncryptAndWriteToFile(f, kp, []byte(keystorePassword))
+		f.Close()
+		if err != nil {
+			r.h.t.Fatal(err)
+		}
+	}
+}
+
+// Crash stops the relayer as a fatal error does, the relayer loses everything it kept in memory
+func (r *Relayer) Crash() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.done == nil {
+		return
+	}
+	select {
+	case r.sysErr <- ErrCrashed:
+	case <-r.done:
+	}
+	<-r.done
+	r.client, r.sysErr, r.done = nil, nil, nil
+}
+
+// Restart crashes and starts the relayer
+func (r *Relayer) Restart() {
+	r.Crash()
+	r.Start()
+}
+
+// UseSigner makes the relayer sign through the signer daemon listening on socket from its next start, with
+// an empty keystore
+func (r *Relayer) UseSigner(socket string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.signer = socket
+	r.keystore = r.h.t.TempDir()
+}
+
+// FailNextAlaya makes the next call of method to the Alaya chain by the running relayer fail with err
+func (r *Relayer) FailNextAlaya(method string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.client == nil {
+		r.h.t.Fatalf("Relayer %s is not running", r.Name)
+	}
+	r.client.FailNext(method, err)
+}
+
+// setKeystorePassword sets the password the relayers read their keystore with until the end of the test
+func setKeystorePassword(t *testing.T) {
+	previous, set := os.LookupEnv(keystore.EnvPassword)
+	err := os.Setenv(keystore.EnvPassword, keystorePassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if set {
+			_ = os.Setenv(keystore.EnvPassword, previous)
+		} else {
+			_ = os.Unsetenv(keystore.EnvPassword)
+		}
+	})
+}
+
+// relayerAccount returns the Kusama account of a relayer of the test keyring
+func relayerAccount(name string) types.AccountID {
+	return types.NewAccountID(keystore.TestKeyRing.SubstrateKeys[name].AsKeyringPair().PublicKey)
+}
+
+// KusamaAccount returns a Kusama account of the test, other than the relayers
+func KusamaAccount(seed byte) types.AccountID {
+	var account types.AccountID
+	for i := range account {
+		account[i] = seed
+	}
+	return account
+}
+
+// BridgedKSM returns the AKSM minted for a deposit of amount KSM
+func BridgedKSM(amount *big.Int) *big.Int {
+	fee := new(big.Int).Add(big.NewInt(substrate.FixedFee), new(big.Int).Div(amount, big.NewInt(substrate.FeeRate)))
+	res := new(big.Int).Sub(amount, fee)
+	return res.Mul(res, big.NewInt(1000000))
+}
+
+// RedeemedAKSM returns the KSM paid for a deposit of amount AKSM
+func RedeemedAKSM(amount *big.Int) *big.Int {
+	res := new(big.Int).Div(amount, big.NewInt(1000000))
+	fee := new(big.Int).Add(big.NewInt(substrate.FixedFee), new(big.Int).Div(res, big.NewInt(substrate.FeeRate)))
+	return res.Sub(res, fee)
+}
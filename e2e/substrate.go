@@ -0,0 +1,393 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/rjman-self/Platdot/chains/substrate"
+	"github.com/rjman-self/Platdot/shared/substrate/fakenode"
+	subtypes "github.com/rjmand/go-substrate-rpc-client/v2/types"
+)
+
+// Errors of the multisig pallet, by their index in the pallet
+const (
+	errAlreadyApproved     = 1
+	errNotFound            = 7
+	errNotOwner            = 8
+	errNoTimepoint         = 9
+	errWrongTimepoint      = 10
+	errUnexpectedTimepoint = 11
+)
+
+// extrinsic is an extrinsic waiting for a block. Scripted extrinsics come with their events, the
+// extrinsics of the relayers are applied by the multisig pallet of the chain.
+type extrinsic struct {
+	data     []byte
+	events   []fakenode.Event // Phase is set to the index of the extrinsic in its block
+	scripted bool
+}
+
+// multisig is an open multisig of the pallet
+type multisig struct {
+	when      types.TimePoint
+	depositor types.AccountID
+	approvals []types.AccountID
+}
+
+// multisigKey identifies a multisig by its account and call hash, as the Multisigs storage of the pallet
+type multisigKey struct {
+	account  types.AccountID
+	callHash types.Hash
+}
+
+// palletState is the state of the multisig pallet and of the transfers it executed after a block
+type palletState struct {
+	multisigs  map[multisigKey]multisig
+	paid       map[string]*big.Int // Amount paid out by the multisig account, by hex recipient
+	executions int
+	extrinsics []extrinsic // Extrinsics of the block, put back in the queue when it is rewound
+}
+
+func (s *palletState) copy() *palletState {
+	res := &palletState{
+		multisigs:  make(map[multisigKey]multisig, len(s.multisigs)),
+		paid:       make(map[string]*big.Int, len(s.paid)),
+		executions: s.executions,
+	}
+	for k, ms := range s.multisigs {
+		ms.approvals = append([]types.AccountID{}, ms.approvals...)
+		res.multisigs[k] = ms
+	}
+	for k, amount := range s.paid {
+		res.paid[k] = new(big.Int).Set(amount)
+	}
+	return res
+}
+
+// substrateChain produces the blocks of the fake node. It includes the extrinsics submitted by the
+// relayers and emits the events the multisig pallet would, the finalized block follows the head by
+// finalityDepth blocks.
+type substrateChain struct {
+	node          *fakenode.Node
+	meta          *types.Metadata    // Metadata of the node
+	callMeta      *subtypes.Metadata // Metadata decoding the calls of the relayers
+	multisigIndex uint8
+	finalityDepth uint32
+
+	lock      sync.Mutex
+	submitted int // Number of submitted extrinsics already queued
+	queue     []extrinsic
+	states    []*palletState // State after each block, by block number
+	duplicate bool           // Include every submitted extrinsic twice
+	multiSign types.AccountID
+}
+
+func newSubstrateChain(node *fakenode.Node, meta *types.Metadata, multiSign types.AccountID, finalityDepth uint32) (*substrateChain, error) {
+	// Calls are decoded with the types of the relayer, the metadata is decoded again as such
+	encoded, err := types.EncodeToBytes(meta)
+	if err != nil {
+		return nil, err
+	}
+	var callMeta subtypes.Metadata
+	err = subtypes.DecodeFromBytes(encoded, &callMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &substrateChain{
+		node:          node,
+		meta:          meta,
+		callMeta:      &callMeta,
+		finalityDepth: finalityDepth,
+		multiSign:     multiSign,
+		states:        []*palletState{{multisigs: make(map[multisigKey]multisig), paid: make(map[string]*big.Int)}},
+	}
+	for _, mod := range meta.AsMetadataV12.Modules {
+		if mod.Name == "Multisig" {
+			c.multisigIndex = uint8(mod.Index)
+		}
+	}
+	return c, nil
+}
+
+// submit queues a scripted extrinsic for the next block
+func (c *substrateChain) submit(ext extrinsic) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ext.scripted = true
+	c.queue = append(c.queue, ext)
+}
+
+// produce adds a block with the queued extrinsics and the extrinsics submitted since the last block
+func (c *substrateChain) produce() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	submitted := c.node.Submitted()
+	for _, data := range submitted[c.submitted:] {
+		c.queue = append(c.queue, extrinsic{data: data})
+		if c.duplicate {
+			c.queue = append(c.queue, extrinsic{data: data})
+		}
+	}
+	c.submitted = len(submitted)
+
+	number := uint32(len(c.states))
+	state := c.states[len(c.states)-1].copy()
+	block := fakenode.Block{}
+	for i, ext := range c.queue {
+		index := uint32(i)
+		block.Extrinsics = append(block.Extrinsics, ext.data)
+		var events []fakenode.Event
+		if ext.scripted {
+			events = ext.events
+		} else {
+			events = c.apply(state, number, index, ext.data)
+		}
+		for _, evt := range events {
+			evt.Phase = fakenode.ApplyExtrinsic(index)
+			block.Events = append(block.Events, evt)
+		}
+	}
+	state.extrinsics = c.queue
+	c.queue = nil
+
+	if _, err := c.node.AddBlock(block); err != nil {
+		return err
+	}
+	c.states = append(c.states, state)
+	c.node.SetFinalized(c.finalized())
+	return nil
+}
+
+// rewind removes the blocks above the finalized block, as a reorg replacing them. Their extrinsics are
+// included again by the next blocks. Returns the number of scripted extrinsics put back in the queue.
+func (c *substrateChain) rewind() (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	number := c.finalized()
+	err := c.node.Rewind(uint32(number))
+	if err != nil {
+		return 0, err
+	}
+	var requeue []extrinsic
+	scripted := 0
+	for _, s := range c.states[number+1:] {
+		for _, ext := range s.extrinsics {
+			if ext.scripted {
+				scripted++
+			}
+		}
+		requeue = append(requeue, s.extrinsics...)
+	}
+	c.queue = append(requeue, c.queue...)
+	c.states = c.states[:number+1]
+	return scripted, nil
+}
+
+// queued returns the number of scripted extrinsics waiting for a block
+func (c *substrateChain) queued() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	n := 0
+	for _, ext := range c.queue {
+		if ext.scripted {
+			n++
+		}
+	}
+	return n
+}
+
+// finalized returns the number of the finalized block, the caller must hold lock
+func (c *substrateChain) finalized() int {
+	number := len(c.states) - 1 - int(c.finalityDepth)
+	if number < 0 {
+		return 0
+	}
+	return number
+}
+
+// apply runs a Multisig call of a relayer and returns its events
+func (c *substrateChain) apply(state *palletState, number, index uint32, data []byte) []fakenode.Event {
+	call, err := substrate.DecodeMultisigCall(c.callMeta, data)
+	if errors.Is(err, substrate.ErrNotMultisig) {
+		return []fakenode.Event{fakenode.ExtrinsicSuccess(0)}
+	} else if err != nil {
+		return []fakenode.Event{c.failed(errNotFound)}
+	}
+
+	account, err := substrate.MultiAccountID(toCallAccounts(append(call.Others, call.Signer)), call.Threshold)
+	if err != nil {
+		return []fakenode.Event{c.failed(errNotFound)}
+	}
+	id := types.AccountID(account)
+	key := multisigKey{account: id, callHash: call.CallHash}
+	ms, open := state.multisigs[key]
+
+	if call.Function == "cancel_as_multi" {
+		switch {
+		case !open:
+			return []fakenode.Event{c.failed(errNotFound)}
+		case ms.depositor != call.Signer:
+			return []fakenode.Event{c.failed(errNotOwner)}
+		case call.TimePoint == nil || !sameTimePoint(ms.when, *call.TimePoint):
+			return []fakenode.Event{c.failed(errWrongTimepoint)}
+		}
+		delete(state.multisigs, key)
+		return []fakenode.Event{
+			{Module: "Multisig", Name: "MultisigCancelled", Args: []interface{}{call.Signer, ms.when, id, call.CallHash}},
+			fakenode.ExtrinsicSuccess(0),
+		}
+	}
+
+	switch {
+	case open && call.TimePoint == nil:
+		return []fakenode.Event{c.failed(errNoTimepoint)}
+	case open && !sameTimePoint(ms.when, *call.TimePoint):
+		return []fakenode.Event{c.failed(errWrongTimepoint)}
+	case !open && call.TimePoint != nil:
+		return []fakenode.Event{c.failed(errUnexpectedTimepoint)}
+	}
+	for _, a := range ms.approvals {
+		if a == call.Signer {
+			return []fakenode.Event{c.failed(errAlreadyApproved)}
+		}
+	}
+
+	when := types.TimePoint{Height: types.U32(number), Index: types.U32(index)}
+	if open {
+		when = ms.when
+	}
+	// The approval reaching the threshold executes the call, when it carries it
+	if len(ms.approvals)+1 >= int(call.Threshold) && call.Function == "as_multi" {
+		delete(state.multisigs, key)
+		state.executions++
+		events := []fakenode.Event{}
+		for _, t := range call.Transfers {
+			amount, _ := new(big.Int).SetString(t.DestAmount, 10)
+			paid, ok := state.paid[t.DestAddress]
+			if !ok {
+				paid = big.NewInt(0)
+				state.paid[t.DestAddress] = paid
+			}
+			paid.Add(paid, amount)
+			to, _ := types.HexDecodeString(t.DestAddress)
+			events = append(events, fakenode.Event{Module: "Balances", Name: "Transfer",
+				Args: []interface{}{id, types.NewAccountID(to), types.NewU128(*amount)}})
+		}
+		return append(events,
+			fakenode.Event{Module: "Multisig", Name: "MultisigExecuted", Args: []interface{}{
+				call.Signer, when, id, call.CallHash, types.DispatchResult{Ok: true},
+			}},
+			fakenode.ExtrinsicSuccess(0),
+		)
+	}
+
+	if !open {
+		state.multisigs[key] = multisig{when: when, depositor: call.Signer, approvals: []types.AccountID{call.Signer}}
+		return []fakenode.Event{
+			{Module: "Multisig", Name: "NewMultisig", Args: []interface{}{call.Signer, id, call.CallHash}},
+			fakenode.ExtrinsicSuccess(0),
+		}
+	}
+	ms.approvals = append(ms.approvals, call.Signer)
+	state.multisigs[key] = ms
+	return []fakenode.Event{
+		{Module: "Multisig", Name: "MultisigApproval", Args: []interface{}{call.Signer, when, id, call.CallHash}},
+		fakenode.ExtrinsicSuccess(0),
+	}
+}
+
+// failed returns the ExtrinsicFailed event of a multisig pallet error
+func (c *substrateChain) failed(code uint8) fakenode.Event {
+	return fakenode.Event{
+		Module: "System",
+		Name:   "ExtrinsicFailed",
+		Args: []interface{}{
+			types.DispatchError{HasModule: true, Module: c.multisigIndex, Error: code},
+			types.DispatchInfo{Weight: fakenode.DefaultWeight, Class: types.DispatchClass{IsNormal: true}, PaysFee: types.Pays{IsYes: true}},
+		},
+	}
+}
+
+// paid returns the amount paid out to a recipient by the finalized blocks
+func (c *substrateChain) paid(recipient types.AccountID) *big.Int {
+	state := c.finalizedState()
+	if amount, ok := state.paid[types.HexEncodeToString(recipient[:])]; ok {
+		return new(big.Int).Set(amount)
+	}
+	return big.NewInt(0)
+}
+
+// executions returns the number of multisigs executed by the finalized blocks
+func (c *substrateChain) executions() int {
+	return c.finalizedState().executions
+}
+
+func (c *substrateChain) finalizedState() *palletState {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.states[c.finalized()]
+}
+
+func (c *substrateChain) setDuplicate(duplicate bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.duplicate = duplicate
+}
+
+func sameTimePoint(tp types.TimePoint, other substrate.MultiSignTx) bool {
+	return uint64(tp.Height) == uint64(other.BlockNumber) && uint64(tp.Index) == uint64(other.MultiSignTxId)
+}
+
+func toCallAccounts(accounts []types.AccountID) []subtypes.AccountID {
+	res := make([]subtypes.AccountID, len(accounts))
+	for i, a := range accounts {
+		res[i] = subtypes.AccountID(a)
+	}
+	return res
+}
+
+// depositExtrinsic encodes a signed extrinsic of sender paying amount to multiSign with a remark of recipient,
+// in a Utility.batch_all as users deposit. The signature is not checked by the relayers and is left empty.
+func depositExtrinsic(meta *types.Metadata, sender, multiSign types.AccountID, amount *big.Int, remark string) ([]byte, error) {
+	dest, err := types.NewMultiAddressFromHexAccountID(types.HexEncodeToString(multiSign[:]))
+	if err != nil {
+		return nil, err
+	}
+	transfer, err := types.NewCall(meta, "Balances.transfer_keep_alive", dest, types.NewUCompact(amount))
+	if err != nil {
+		return nil, err
+	}
+	rem, err := types.NewCall(meta, "System.remark", []byte(remark))
+	if err != nil {
+		return nil, err
+	}
+	batch, err := types.NewCall(meta, "Utility.batch_all", []types.Call{transfer, rem})
+	if err != nil {
+		return nil, err
+	}
+	c, err := types.EncodeToBytes(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte{0x84, 0x00} // Signed v4 extrinsic of an account id
+	body = append(body, sender[:]...)
+	body = append(body, 0x01)                // Sr25519
+	body = append(body, make([]byte, 64)...) // Signature
+	body = append(body, 0x00, 0x00, 0x00)    // Immortal era, nonce and tip
+	body = append(body, c...)
+	length, err := types.EncodeToBytes(types.NewUCompactFromUInt(uint64(len(body))))
+	if err != nil {
+		return nil, fmt.Errorf("encode extrinsic length: %w", err)
+	}
+	return append(length, body...), nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package bech32
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ParseAddress strictly parses an Alaya/PlatON account given either as bech32 with the expected human-readable
+// prefix (eg. atp1...) or as 0x hex. Bech32 checksums are always verified, mixed-case hex must match its
+// EIP-55 checksum. Surrounding whitespace is ignored, anything else that is not a valid encoding of a
+// non-zero 20 byte address is rejected.
+func ParseAddress(addr string, prefix string) (common.Address, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return common.Address{}, fmt.Errorf("%w: empty", ErrInvalidAddress)
+	}
+
+	var address common.Address
+	if strings.HasPrefix(addr, "0x") || strings.HasPrefix(addr, "0X") {
+		res, err := parseHexAddress(addr)
+		if err != nil {
+			return common.Address{}, err
+		}
+		address = res
+	} else {
+		if prefix == "" {
+			return common.Address{}, fmt.Errorf("%w: no bech32 prefix configured for %q", ErrInvalidAddress, addr)
+		}
+		hrp, res, err := DecodeAddress(addr)
+		if err != nil {
+			return common.Address{}, err
+		}
+		if hrp != strings.ToLower(prefix) {
+			return common.Address{}, fmt.Errorf("%w: %q has prefix %q, expected %q", ErrInvalidAddress, addr, hrp, prefix)
+		}
+		address = res
+	}
+
+	if address == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: zero address", ErrInvalidAddress)
+	}
+	return address, nil
+}
+
+func parseHexAddress(addr string) (common.Address, error) {
+	if !common.IsHexAddress(addr) {
+		return common.Address{}, fmt.Errorf("%w: %q is not a 20 byte hex address", ErrInvalidAddress, addr)
+	}
+
+	address := common.HexToAddress(addr)
+	digits := addr[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && address.Hex()[2:] != digits {
+		return common.Address{}, fmt.Errorf("%w: %q has an invalid checksum", ErrInvalidAddress, addr)
+	}
+	return address, nil
+}
+
+// DecodeAddress decodes a bech32 account address of any human-readable prefix, returning the prefix
+func DecodeAddress(addr string) (string, common.Address, error) {
+	hrp, data, err := Decode(addr)
+	if err != nil {
+		return "", common.Address{}, fmt.Errorf("%w: %q: %s", ErrInvalidAddress, addr, err)
+	}
+	if len(data) != common.AddressLength {
+		return "", common.Address{}, fmt.Errorf("%w: %q decodes to %d bytes", ErrInvalidAddress, addr, len(data))
+	}
+	return hrp, common.BytesToAddress(data), nil
+}
+
+// FormatAddress encodes an address as bech32 with the given human-readable prefix
+func FormatAddress(address common.Address, prefix string) (string, error) {
+	return Encode(prefix, address.Bytes())
+}
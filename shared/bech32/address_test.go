@@ -0,0 +1,105 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package bech32
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testPrefix = "atp"
+
+var testAddress = common.HexToAddress("0x1dd2D5b2A7a80F7F8d08B7D95DB0E2bd37BDAe8c")
+
+func TestParseAddress(t *testing.T) {
+	atp, err := FormatAddress(testAddress, testPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lat, err := FormatAddress(testAddress, "lat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := []string{
+		atp,
+		strings.ToUpper(atp),
+		" " + atp + "\n",
+		testAddress.Hex(),
+		strings.ToLower(testAddress.Hex()),
+		"0x" + strings.ToUpper(testAddress.Hex()[2:]),
+	}
+	for _, addr := range valid {
+		res, err := ParseAddress(addr, testPrefix)
+		if err != nil {
+			t.Errorf("ParseAddress(%q): unexpected error %s", addr, err)
+			continue
+		}
+		if res != testAddress {
+			t.Errorf("ParseAddress(%q): got %s expected %s", addr, res.Hex(), testAddress.Hex())
+		}
+	}
+
+	invalid := []string{
+		"",
+		lat,
+		atp[:len(atp)-1] + "q",
+		atp + "q",
+		"0x1dd2D5b2A7a80F7F8d08B7D95DB0E2bd37BDAe8C",
+		"0x1dd2d5b2a7a80f7f8d08b7d95db0e2bd37bdae",
+		"0x0000000000000000000000000000000000000000",
+		"1dd2d5b2a7a80f7f8d08b7d95db0e2bd37bdae8c",
+		"atp1 " + atp[4:],
+	}
+	for _, addr := range invalid {
+		if _, err := ParseAddress(addr, testPrefix); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("ParseAddress(%q): expected ErrInvalidAddress, got %v", addr, err)
+		}
+	}
+
+	if _, err := ParseAddress(atp, ""); err == nil {
+		t.Error("expected bech32 address to be rejected without a configured prefix")
+	}
+}
+
+func TestQuickParseAddress(t *testing.T) {
+	// Every non-zero address round-trips through both encodings
+	roundTrip := func(address common.Address) bool {
+		if address == (common.Address{}) {
+			return true
+		}
+		encoded, err := FormatAddress(address, testPrefix)
+		if err != nil {
+			t.Logf("FormatAddress(%s): %s", address.Hex(), err)
+			return false
+		}
+		for _, s := range []string{encoded, strings.ToUpper(encoded), address.Hex(), strings.ToLower(address.Hex())} {
+			res, err := ParseAddress(s, testPrefix)
+			if err != nil || res != address {
+				t.Logf("ParseAddress(%q): got %s %v expected %s", s, res.Hex(), err, address.Hex())
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Arbitrary input is either rejected with ErrInvalidAddress or parses to a non-zero address
+	parse := func(input string) bool {
+		address, err := ParseAddress(input, testPrefix)
+		if err != nil {
+			return errors.Is(err, ErrInvalidAddress)
+		}
+		return address != (common.Address{})
+	}
+	if err := quick.Check(parse, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+/*
+The bech32 package encodes and decodes the bech32 addresses of PlatON and Alaya accounts (eg. atp1..., lat1...).
+
+Encode and Decode implement BIP-173 for any human-readable prefix, checksums are always verified. ParseAddress
+is what every address given by a user or a deposit should go through: it accepts bech32 with the expected prefix or
+0x hex, and returns the account as a common.Address.
+*/
+package bech32
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Longest encoding allowed by BIP-173
+const maxLength = 90
+
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+var ErrInvalidChecksum = errors.New("invalid bech32 checksum")
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	res := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		res = append(res, hrp[i]>>5)
+	}
+	res = append(res, 0)
+	for i := 0; i < len(hrp); i++ {
+		res = append(res, hrp[i]&31)
+	}
+	return res
+}
+
+func checksum(hrp string, data []byte) []byte {
+	values := append(hrpExpand(hrp), data...)
+	mod := polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+	res := make([]byte, 6)
+	for i := range res {
+		res[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+	return res
+}
+
+func validHrp(hrp string) error {
+	if len(hrp) == 0 || len(hrp) > maxLength-7 {
+		return fmt.Errorf("invalid bech32 prefix length %d", len(hrp))
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return fmt.Errorf("invalid character %q in bech32 prefix", hrp[i])
+		}
+	}
+	return nil
+}
+
+// convertBits regroups data of fromBits bits per byte into bytes of toBits bits. When decoding, the padding
+// left must be less than fromBits zero bits.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxv := uint(1)<<toBits - 1
+	res := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, b := range data {
+		if uint(b)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data byte %d", b)
+		}
+		acc = acc<<fromBits | uint(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			res = append(res, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			res = append(res, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errors.New("invalid bech32 padding")
+	}
+	return res, nil
+}
+
+// Encode encodes data as bech32 with the human-readable prefix hrp, which is lowercased
+func Encode(hrp string, data []byte) (string, error) {
+	hrp = strings.ToLower(hrp)
+	if err := validHrp(hrp); err != nil {
+		return "", err
+	}
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	if len(hrp)+1+len(values)+6 > maxLength {
+		return "", fmt.Errorf("bech32 encoding of %d bytes is too long", len(data))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range append(values, checksum(hrp, values)...) {
+		sb.WriteByte(charset[v])
+	}
+	return sb.String(), nil
+}
+
+// Decode decodes a bech32 string of any human-readable prefix, returning the lowercased prefix and the data.
+// Mixed case strings and invalid checksums are rejected.
+func Decode(s string) (string, []byte, error) {
+	if len(s) < 8 || len(s) > maxLength {
+		return "", nil, fmt.Errorf("invalid bech32 length %d", len(s))
+	}
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("mixed case bech32 string")
+	}
+
+	sep := strings.LastIndexByte(lower, '1')
+	if sep < 1 || sep+7 > len(lower) {
+		return "", nil, errors.New("missing bech32 separator")
+	}
+	hrp := lower[:sep]
+	if err := validHrp(hrp); err != nil {
+		return "", nil, err
+	}
+
+	values := make([]byte, 0, len(lower)-sep-1)
+	for i := sep + 1; i < len(lower); i++ {
+		v := strings.IndexByte(charset, lower[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("invalid bech32 character %q", lower[i])
+		}
+		values = append(values, byte(v))
+	}
+	if polymod(append(hrpExpand(hrp), values...)) != 1 {
+		return "", nil, ErrInvalidChecksum
+	}
+
+	data, err := convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
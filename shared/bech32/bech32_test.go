@@ -0,0 +1,199 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package bech32
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Valid and invalid strings of BIP-173
+var validStrings = []string{
+	"A12UEL5L",
+	"a12uel5l",
+	"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+	"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	"?1ezyfcl",
+}
+
+var invalidStrings = []string{
+	"\x201nwldj5",       // HRP character out of range
+	"\x7f1axkwrx",       // HRP character out of range
+	"\x801eym55h",       // HRP character out of range
+	"pzry9x0s0muk",      // No separator
+	"1pzry9x0s0muk",     // Empty HRP
+	"x1b4n0q5v",         // Invalid data character
+	"li1dgmt3",          // Too short checksum
+	"de1lg7wt\xff",      // Invalid character in checksum
+	"A1G7SGD8",          // Checksum calculated with uppercase HRP
+	"10a06t8",           // Empty HRP
+	"1qzzfhee",          // Empty HRP
+	"a12UEL5L",          // Mixed case
+	"atp1qqqqqqqqqqqqq", // Invalid checksum
+	"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx", // Too long
+}
+
+func TestDecode(t *testing.T) {
+	for _, s := range validStrings {
+		hrp, data, err := Decode(s)
+		if err != nil {
+			t.Errorf("Decode(%q): unexpected error %s", s, err)
+			continue
+		}
+		if hrp != strings.ToLower(s[:strings.LastIndexByte(s, '1')]) {
+			t.Errorf("Decode(%q): got prefix %q", s, hrp)
+		}
+		if encoded, err := Encode(hrp, data); err != nil || encoded != strings.ToLower(s) {
+			t.Errorf("Encode(%q, %x): got %s expected %s", hrp, data, encoded, strings.ToLower(s))
+		}
+	}
+
+	for _, s := range invalidStrings {
+		if _, _, err := Decode(s); err == nil {
+			t.Errorf("Decode(%q): expected an error", s)
+		}
+	}
+}
+
+func TestEncodeAddress(t *testing.T) {
+	alice := common.HexToAddress("0xff93B45308FD417dF303D6515aB04D9e89a750Ca")
+	expected := map[string]string{
+		"atp": "atp1l7fmg5cgl4qhmucr6eg44vzdn6y6w5x2q695d9",
+		"lat": "lat1l7fmg5cgl4qhmucr6eg44vzdn6y6w5x2evnvj2",
+	}
+	for hrp, addr := range expected {
+		res, err := FormatAddress(alice, hrp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != addr {
+			t.Fatalf("Got: %s Expected: %s", res, addr)
+		}
+		decodedHrp, decoded, err := DecodeAddress(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodedHrp != hrp || decoded != alice {
+			t.Fatalf("Got: %s %s Expected: %s %s", decodedHrp, decoded.Hex(), hrp, alice.Hex())
+		}
+	}
+
+	if _, _, err := DecodeAddress("a12uel5l"); err == nil {
+		t.Fatal("Got: nil Expected: data of the wrong length is rejected")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, hrp := range []string{"atp", "lat", "a", "bc", strings.Repeat("x", 40)} {
+		// Encodings are at most 90 characters
+		for n := 0; len(hrp)+1+(n*8+4)/5+6 <= maxLength; n++ {
+			data := make([]byte, n)
+			for i := range data {
+				data[i] = byte(i*37 + n)
+			}
+			s, err := Encode(hrp, data)
+			if err != nil {
+				t.Fatalf("Encode(%q, %x): %s", hrp, data, err)
+			}
+			resHrp, res, err := Decode(s)
+			if err != nil {
+				t.Fatalf("Decode(%q): %s", s, err)
+			}
+			if resHrp != hrp || !bytes.Equal(res, data) {
+				t.Fatalf("Got: %s %x Expected: %s %x", resHrp, res, hrp, data)
+			}
+			if _, _, err = Decode(strings.ToUpper(s)); err != nil {
+				t.Fatalf("Decode(%q): %s", strings.ToUpper(s), err)
+			}
+		}
+	}
+
+	if _, err := Encode("", nil); err == nil {
+		t.Fatal("Got: nil Expected: empty prefix is rejected")
+	}
+	if _, err := Encode("atp", make([]byte, 64)); err == nil {
+		t.Fatal("Got: nil Expected: encoding longer than 90 characters is rejected")
+	}
+}
+
+// quickHrp maps arbitrary bytes to a valid human-readable prefix of 1 to 40 characters
+func quickHrp(seed []byte) string {
+	if len(seed) == 0 {
+		seed = []byte{0}
+	}
+	if len(seed) > 40 {
+		seed = seed[:40]
+	}
+	hrp := make([]byte, len(seed))
+	for i, b := range seed {
+		hrp[i] = 33 + b%94
+	}
+	return string(hrp)
+}
+
+func TestQuickRoundTrip(t *testing.T) {
+	roundTrip := func(seed []byte, data []byte) bool {
+		hrp := quickHrp(seed)
+		s, err := Encode(hrp, data)
+		if err != nil {
+			// Only encodings longer than 90 characters are rejected
+			return len(strings.ToLower(hrp))+1+(len(data)*8+4)/5+6 > maxLength
+		}
+		resHrp, res, err := Decode(s)
+		if err != nil {
+			t.Logf("Decode(%q) of Encode(%q, %x): %s", s, hrp, data, err)
+			return false
+		}
+		return resHrp == strings.ToLower(hrp) && bytes.Equal(res, data)
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuickDecodeRejectsSubstitution(t *testing.T) {
+	// A checksum detects any single character substitution in the data part
+	substitute := func(data []byte, pos uint8, shift uint8) bool {
+		if len(data) > 40 {
+			data = data[:40]
+		}
+		s, err := Encode(testPrefix, data)
+		if err != nil {
+			t.Logf("Encode(%q, %x): %s", testPrefix, data, err)
+			return false
+		}
+		i := len(testPrefix) + 1 + int(pos)%(len(s)-len(testPrefix)-1)
+		c := charset[(strings.IndexByte(charset, s[i])+1+int(shift)%31)%32]
+		_, _, err = Decode(s[:i] + string(c) + s[i+1:])
+		return err != nil
+	}
+	if err := quick.Check(substitute, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuickDecode(t *testing.T) {
+	// Every accepted string is the encoding of what it decodes to
+	decode := func(input string) bool {
+		hrp, data, err := Decode(input)
+		if err != nil {
+			return true
+		}
+		encoded, err := Encode(hrp, data)
+		return err == nil && encoded == strings.ToLower(input)
+	}
+	if err := quick.Check(decode, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range append(validStrings, invalidStrings...) {
+		if !decode(s) {
+			t.Fatalf("Decode(%q) does not round-trip", s)
+		}
+	}
+}
@@ -4,10 +4,15 @@
 package utils
 
 import (
+	"context"
+	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/rjman-self/platdot-utils/msg"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/rjman-self/Platdot/bindings/Bridge"
 )
 
@@ -37,6 +42,34 @@ func RegisterResource(client *Client, bridge, handler common.Address, rId msg.Re
 	return nil
 }
 
+// RegisterGenericResource registers the resource rId of the contract at addr with a generic handler. The
+// function signatures are called by the handler on deposit and execution, the depositer is not checked.
+func RegisterGenericResource(client *Client, bridge, handler common.Address, rId msg.ResourceId, addr common.Address, depositSig, executeSig [4]byte) error {
+	instance, err := Bridge.NewBridge(bridge, client.Client)
+	if err != nil {
+		return err
+	}
+
+	err = client.LockNonceAndUpdate()
+	if err != nil {
+		return err
+	}
+
+	tx, err := instance.AdminSetGenericResource(client.Opts, handler, rId, addr, depositSig, big.NewInt(0), executeSig)
+	if err != nil {
+		return err
+	}
+
+	err = WaitForTx(client, tx)
+	if err != nil {
+		return err
+	}
+
+	client.UnlockNonce()
+
+	return nil
+}
+
 func SetBurnable(client *Client, bridge, handler, contract common.Address) error {
 	instance, err := Bridge.NewBridge(bridge, client.Client)
 	if err != nil {
@@ -77,6 +110,98 @@ func GetDepositNonce(client *Client, bridge common.Address, chain msg.ChainId) (
 	return count, nil
 }
 
+// DepositErc20 deposits amount of the token of rId for recipient on destId and pays the fee of the bridge.
+// The handler must be approved to transfer the amount. Returns the nonce of the deposit.
+func DepositErc20(client *Client, bridge common.Address, destId msg.ChainId, rId msg.ResourceId, recipient []byte, amount *big.Int) (msg.Nonce, error) {
+	instance, err := Bridge.NewBridge(bridge, client.Client)
+	if err != nil {
+		return 0, err
+	}
+
+	fee, err := instance.Fee(client.CallOpts)
+	if err != nil {
+		return 0, err
+	}
+
+	err = client.LockNonceAndUpdate()
+	if err != nil {
+		return 0, err
+	}
+
+	client.Opts.Value = fee
+	tx, err := instance.Deposit(client.Opts, uint8(destId), rId, ConstructErc20DepositData(recipient, amount))
+	client.Opts.Value = big.NewInt(0)
+	if err != nil {
+		return 0, err
+	}
+
+	err = WaitForTx(client, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	client.UnlockNonce()
+
+	receipt, err := client.Client.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return 0, err
+	}
+	for _, l := range receipt.Logs {
+		if l.Address != bridge {
+			continue
+		}
+		if evt, err := instance.ParseDeposit(*l); err == nil {
+			return msg.Nonce(evt.DepositNonce), nil
+		}
+	}
+	return 0, fmt.Errorf("no deposit event in transaction %s", tx.Hash().Hex())
+}
+
+// WaitForProposal polls the ProposalEvent logs of the bridge from block start, until the proposal of the deposit
+// with nonce made on src is executed or cancelled. Returns the final status of the proposal.
+func WaitForProposal(client *ethclient.Client, bridge common.Address, src msg.ChainId, nonce msg.Nonce, start uint64, timeout time.Duration) (ProposalStatus, error) {
+	instance, err := Bridge.NewBridge(bridge, client)
+	if err != nil {
+		return Inactive, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		latest, err := client.BlockNumber(context.Background())
+		if err != nil {
+			return Inactive, err
+		}
+		if latest < start {
+			time.Sleep(ProposalPollInterval)
+			continue
+		}
+
+		it, err := instance.FilterProposalEvent(&bind.FilterOpts{Start: start, End: &latest})
+		if err != nil {
+			return Inactive, err
+		}
+		for it.Next() {
+			evt := it.Event
+			if msg.ChainId(evt.OriginChainID) != src || msg.Nonce(evt.DepositNonce) != nonce {
+				continue
+			}
+			if IsExecuted(evt.Status) || ProposalStatus(evt.Status) == Cancelled {
+				it.Close()
+				return ProposalStatus(evt.Status), nil
+			}
+		}
+		err = it.Error()
+		it.Close()
+		if err != nil {
+			return Inactive, err
+		}
+
+		start = latest + 1
+		time.Sleep(ProposalPollInterval)
+	}
+	return Inactive, fmt.Errorf("proposal not finalized after %s", timeout)
+}
+
 func IDAndNonce(srcId msg.ChainId, nonce msg.Nonce) *big.Int {
 	var data []byte
 	data = append(data, nonce.Big().Bytes()...)
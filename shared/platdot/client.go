@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,21 +27,52 @@ const DefaultGasMultiplier = 1
 
 var ExpectedBlockTime = time.Second
 
+// Number of ExpectedBlockTime intervals WaitForTx waits for a receipt
+var TxWaitRetries = 10
+
+// ChainClient is the part of an Alaya client used by the bridge, implemented by an ethclient.Client
+// dialed to a node and by the simulated chain of the tests
+type ChainClient interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethtypes.Header, error)
+	Close()
+}
+
 type Client struct {
-	Client    *ethclient.Client
+	Client    ChainClient
 	Opts      *bind.TransactOpts
 	CallOpts  *bind.CallOpts
 	nonceLock sync.Mutex
 }
 
+// Dial connects to an http(s) or ws(s) endpoint
+func Dial(endpoint string) (*ethclient.Client, error) {
+	var rpcClient *rpc.Client
+	var err error
+	if strings.HasPrefix(endpoint, "http") {
+		rpcClient, err = rpc.DialHTTP(endpoint)
+	} else {
+		rpcClient, err = rpc.DialWebsocket(context.Background(), endpoint, "/ws")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
+
 func NewClient(endpoint string, kp *secp256k1.Keypair) (*Client, error) {
-	ctx := context.Background()
-	rpcClient, err := rpc.DialWebsocket(ctx, endpoint, "/ws")
+	client, err := Dial(endpoint)
 	if err != nil {
 		return nil, err
 	}
-	client := ethclient.NewClient(rpcClient)
+	return NewClientWithChain(client, kp)
+}
 
+// NewClientWithChain returns a client sending the transactions of kp through an already connected chain client
+func NewClientWithChain(client ChainClient, kp *secp256k1.Keypair) (*Client, error) {
+	ctx := context.Background()
 	id, err := client.ChainID(ctx)
 	if err != nil {
 		return nil, err
@@ -81,9 +113,9 @@ func (c *Client) UnlockNonce() {
 }
 
 // WaitForTx will query the chain at ExpectedBlockTime intervals, until a receipt is returned.
-// Returns an error if the tx failed.
+// Returns an error if the tx failed or no receipt is returned after TxWaitRetries queries.
 func WaitForTx(client *Client, tx *ethtypes.Transaction) error {
-	retry := 10
+	retry := TxWaitRetries
 	for retry > 0 {
 		receipt, err := client.Client.TransactionReceipt(context.Background(), tx.Hash())
 		if err != nil {
@@ -101,5 +133,5 @@ func WaitForTx(client *Client, tx *ethtypes.Transaction) error {
 		}
 		return nil
 	}
-	return nil
+	return fmt.Errorf("transaction %s not mined", tx.Hash().Hex())
 }
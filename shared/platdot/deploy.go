@@ -5,11 +5,13 @@ package utils
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 
 	"github.com/rjman-self/platdot-utils/keystore"
+	"github.com/rjman-self/platdot-utils/msg"
 	bridge "github.com/rjman-self/Platdot/bindings/Bridge"
 	erc20Handler "github.com/rjman-self/Platdot/bindings/ERC20Handler"
 	erc721Handler "github.com/rjman-self/Platdot/bindings/ERC721Handler"
@@ -27,6 +29,27 @@ var (
 	ZeroAddress = common.HexToAddress("0x0000000000000000000000000000000000000000")
 )
 
+// BridgeDeployment holds the parameters of a new bridge and of the token it wraps
+type BridgeDeployment struct {
+	ChainID          uint8
+	Relayers         []common.Address
+	RelayerThreshold *big.Int
+	Fee              *big.Int
+	Expiry           *big.Int // Number of blocks after which a proposal may be cancelled
+	TokenName        string
+	TokenSymbol      string
+	ResourceId       msg.ResourceId // Derived from the token address when zero
+}
+
+// DeployedBridge holds the addresses of a bridge deployed with DeployBridgeContracts
+type DeployedBridge struct {
+	BridgeAddress        common.Address
+	ERC20HandlerAddress  common.Address
+	ERC721HandlerAddress common.Address
+	TokenAddress         common.Address
+	ResourceId           msg.ResourceId
+}
+
 type DeployedContracts struct {
 	BridgeAddress       common.Address
 	ERC20HandlerAddress common.Address
@@ -34,7 +57,7 @@ type DeployedContracts struct {
 
 // DeployContracts deploys Bridge, Relayer, ERC20Handler, ERC721Handler and CentrifugeAssetHandler and returns the addresses
 func DeployContracts(client *Client, chainID uint8, initialRelayerThreshold *big.Int) (*DeployedContracts, error) {
-	bridgeAddr, err := deployBridge(client, chainID, RelayerAddresses, initialRelayerThreshold)
+	bridgeAddr, err := deployBridge(client, chainID, RelayerAddresses, initialRelayerThreshold, big.NewInt(0), big.NewInt(100))
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +73,64 @@ func DeployContracts(client *Client, chainID uint8, initialRelayerThreshold *big
 
 }
 
+// DeployBridgeContracts deploys a Bridge with its ERC20Handler and ERC721Handler and an ERC20PresetMinterPauser token.
+// The token is registered as a burnable resource of the ERC20Handler, which is granted the minter role.
+func DeployBridgeContracts(client *Client, d BridgeDeployment) (*DeployedBridge, error) {
+	bridgeAddr, err := deployBridge(client, d.ChainID, d.Relayers, d.RelayerThreshold, d.Fee, d.Expiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy bridge: %w", err)
+	}
+
+	erc20HandlerAddr, err := deployERC20Handler(client, bridgeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy erc20 handler: %w", err)
+	}
+
+	erc721HandlerAddr, err := deployERC721Handler(client, bridgeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy erc721 handler: %w", err)
+	}
+
+	tokenAddr, err := DeployErc20(client, d.TokenName, d.TokenSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy token: %w", err)
+	}
+
+	rId := d.ResourceId
+	if rId == (msg.ResourceId{}) {
+		rId = NewResourceId(tokenAddr, d.ChainID)
+	}
+
+	err = RegisterResource(client, bridgeAddr, erc20HandlerAddr, rId, tokenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register resource: %w", err)
+	}
+
+	err = Erc20AddMinter(client, tokenAddr, erc20HandlerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grant minter role: %w", err)
+	}
+
+	err = SetBurnable(client, bridgeAddr, erc20HandlerAddr, tokenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set burnable: %w", err)
+	}
+
+	return &DeployedBridge{
+		BridgeAddress:        bridgeAddr,
+		ERC20HandlerAddress:  erc20HandlerAddr,
+		ERC721HandlerAddress: erc721HandlerAddr,
+		TokenAddress:         tokenAddr,
+		ResourceId:           rId,
+	}, nil
+}
+
+// NewResourceId derives a resource id from a token contract and the chain it is deployed on:
+// the address padded to 31 bytes followed by the chain id.
+func NewResourceId(token common.Address, chainID uint8) msg.ResourceId {
+	return msg.ResourceIdFromSlice(append(common.LeftPadBytes(token.Bytes(), 31), chainID))
+}
+
 func UpdateNonce(client *Client) error {
 	newNonce, err := client.Client.PendingNonceAt(context.Background(), client.CallOpts.From)
 	if err != nil {
@@ -61,13 +142,13 @@ func UpdateNonce(client *Client) error {
 	return nil
 }
 
-func deployBridge(client *Client, chainID uint8, relayerAddrs []common.Address, initialRelayerThreshold *big.Int) (common.Address, error) {
+func deployBridge(client *Client, chainID uint8, relayerAddrs []common.Address, initialRelayerThreshold, fee, expiry *big.Int) (common.Address, error) {
 	err := client.LockNonceAndUpdate()
 	if err != nil {
 		return ZeroAddress, err
 	}
 
-	bridgeAddr, tx, _, err := bridge.DeployBridge(client.Opts, client.Client, chainID, relayerAddrs, initialRelayerThreshold, big.NewInt(0), big.NewInt(100))
+	bridgeAddr, tx, _, err := bridge.DeployBridge(client.Opts, client.Client, chainID, relayerAddrs, initialRelayerThreshold, fee, expiry)
 	if err != nil {
 		return ZeroAddress, err
 	}
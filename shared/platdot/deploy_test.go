@@ -0,0 +1,20 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var testAddress = common.HexToAddress("0x1dd2D5b2A7a80F7F8d08B7D95DB0E2bd37BDAe8c")
+
+func TestNewResourceId(t *testing.T) {
+	rId := NewResourceId(testAddress, 2)
+	expected := "0000000000000000000000" + "1dd2d5b2a7a80f7f8d08b7d95db0e2bd37bdae8c" + "02"
+	if rId.Hex() != expected {
+		t.Fatalf("Got: %s Expected: %s", rId.Hex(), expected)
+	}
+}
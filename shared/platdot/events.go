@@ -4,6 +4,8 @@
 package utils
 
 import (
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
@@ -22,6 +24,9 @@ const (
 
 type ProposalStatus int
 
+// Time between two queries of WaitForProposal
+var ProposalPollInterval = time.Second * 5
+
 const (
 	Inactive ProposalStatus = iota
 	Active
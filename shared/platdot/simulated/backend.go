@@ -0,0 +1,245 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+/*
+The simulated package provides an in-process Alaya chain for tests, built on the SimulatedBackend of go-ethereum.
+
+A block is mined for every transaction, like a dev node sealing instantly, so receipts and logs are available as
+soon as a transaction is sent. Blocks without transactions are added with Mine, the confirmations of the listener
+are passed this way. The backend implements utils.ChainClient and is used in place of a dialed client with
+utils.NewClientWithChain and connection.NewConnectionWithClient. Several relayers share a chain through the
+clients returned by Client, each can be failed or closed on its own.
+*/
+package simulated
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	eth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	utils "github.com/rjman-self/Platdot/shared/platdot"
+	"github.com/rjman-self/platdot-utils/keystore"
+)
+
+var _ utils.ChainClient = &Backend{}
+
+// ChainID is the chain id of every simulated chain
+var ChainID = big.NewInt(1337)
+
+// GasLimit is the gas limit of the blocks, large enough for any transaction sent with utils.DefaultGasLimit
+const GasLimit = 50000000
+
+// Balance is the genesis balance of the funded accounts
+var Balance = new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil)
+
+var ErrClosed = errors.New("simulated chain closed")
+
+// Backend is a simulated chain mining a block for every transaction
+type Backend struct {
+	sim      *backends.SimulatedBackend
+	lock     *sync.Mutex // Serializes the transactions and the blocks they are mined in, shared with the clients
+	client   bool        // Set for the clients of a chain, closing them leaves the chain running
+	failures map[string][]error
+	failLock sync.Mutex // Guards failures and closed
+	closed   bool
+}
+
+// New starts a simulated chain, the ethereum accounts of the test keyring and accounts are funded with Balance
+func New(accounts ...common.Address) *Backend {
+	alloc := core.GenesisAlloc{}
+	for _, kp := range keystore.TestKeyRing.EthereumKeys {
+		alloc[kp.CommonAddress()] = core.GenesisAccount{Balance: Balance}
+	}
+	for _, addr := range accounts {
+		alloc[addr] = core.GenesisAccount{Balance: Balance}
+	}
+	return &Backend{
+		sim:      backends.NewSimulatedBackend(alloc, GasLimit),
+		lock:     new(sync.Mutex),
+		failures: make(map[string][]error),
+	}
+}
+
+// Client returns a new client of the chain. Its failures are injected with its own FailNext, closing
+// it only disconnects the client, like closing a dialed client.
+func (b *Backend) Client() *Backend {
+	return &Backend{
+		sim:      b.sim,
+		lock:     b.lock,
+		client:   true,
+		failures: make(map[string][]error),
+	}
+}
+
+// Mine adds n blocks without transactions
+func (b *Backend) Mine(n int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i := 0; i < n; i++ {
+		b.sim.Commit()
+	}
+}
+
+// FailNext makes the next call of method fail with err. Methods are named after the ChainClient
+// interface, eg. "SendTransaction"
+func (b *Backend) FailNext(method string, err error) {
+	b.failLock.Lock()
+	defer b.failLock.Unlock()
+	b.failures[method] = append(b.failures[method], err)
+}
+
+// Close stops the chain, every following call fails with ErrClosed. The chain of a client keeps running.
+func (b *Backend) Close() {
+	b.failLock.Lock()
+	defer b.failLock.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	if !b.client {
+		_ = b.sim.Close()
+	}
+}
+
+// fail returns the error the call of method must fail with, if any
+func (b *Backend) fail(method string) error {
+	b.failLock.Lock()
+	defer b.failLock.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	if errs := b.failures[method]; len(errs) > 0 {
+		b.failures[method] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+func (b *Backend) ChainID(ctx context.Context) (*big.Int, error) {
+	if err := b.fail("ChainID"); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(ChainID), nil
+}
+
+func (b *Backend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := b.fail("HeaderByNumber"); err != nil {
+		return nil, err
+	}
+	header, err := b.sim.HeaderByNumber(ctx, number)
+	if err == nil && header == nil {
+		return nil, eth.NotFound
+	}
+	return header, err
+}
+
+func (b *Backend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if err := b.fail("CodeAt"); err != nil {
+		return nil, err
+	}
+	return b.sim.CodeAt(ctx, contract, blockNumber)
+}
+
+func (b *Backend) CallContract(ctx context.Context, call eth.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if err := b.fail("CallContract"); err != nil {
+		return nil, err
+	}
+	return b.sim.CallContract(ctx, call, blockNumber)
+}
+
+func (b *Backend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	if err := b.fail("PendingCodeAt"); err != nil {
+		return nil, err
+	}
+	return b.sim.PendingCodeAt(ctx, account)
+}
+
+func (b *Backend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	if err := b.fail("PendingNonceAt"); err != nil {
+		return 0, err
+	}
+	return b.sim.PendingNonceAt(ctx, account)
+}
+
+func (b *Backend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	if err := b.fail("SuggestGasPrice"); err != nil {
+		return nil, err
+	}
+	return b.sim.SuggestGasPrice(ctx)
+}
+
+func (b *Backend) EstimateGas(ctx context.Context, call eth.CallMsg) (uint64, error) {
+	if err := b.fail("EstimateGas"); err != nil {
+		return 0, err
+	}
+	return b.sim.EstimateGas(ctx, call)
+}
+
+// SendTransaction mines a block with the transaction. Transactions a node would reject are returned the
+// errors of its transaction pool instead of the panic of the SimulatedBackend.
+func (b *Backend) SendTransaction(ctx context.Context, tx *types.Transaction) (err error) {
+	if err := b.fail("SendTransaction"); err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	sender, err := types.Sender(types.LatestSignerForChainID(ChainID), tx)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	nonce, err := b.sim.PendingNonceAt(ctx, sender)
+	if err != nil {
+		return err
+	}
+	if tx.Nonce() < nonce {
+		return core.ErrNonceTooLow
+	} else if tx.Nonce() > nonce {
+		return core.ErrNonceTooHigh
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("transaction rejected: %v", r)
+		}
+	}()
+	err = b.sim.SendTransaction(ctx, tx)
+	if err != nil {
+		return err
+	}
+	b.sim.Commit()
+	return nil
+}
+
+func (b *Backend) FilterLogs(ctx context.Context, query eth.FilterQuery) ([]types.Log, error) {
+	if err := b.fail("FilterLogs"); err != nil {
+		return nil, err
+	}
+	return b.sim.FilterLogs(ctx, query)
+}
+
+func (b *Backend) SubscribeFilterLogs(ctx context.Context, query eth.FilterQuery, ch chan<- types.Log) (eth.Subscription, error) {
+	if err := b.fail("SubscribeFilterLogs"); err != nil {
+		return nil, err
+	}
+	return b.sim.SubscribeFilterLogs(ctx, query, ch)
+}
+
+func (b *Backend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if err := b.fail("TransactionReceipt"); err != nil {
+		return nil, err
+	}
+	receipt, err := b.sim.TransactionReceipt(ctx, txHash)
+	if err == nil && receipt == nil {
+		return nil, eth.NotFound
+	}
+	return receipt, err
+}
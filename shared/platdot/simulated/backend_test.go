@@ -0,0 +1,117 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package simulated
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/platdot-utils/keystore"
+)
+
+var aliceKp = keystore.TestKeyRing.EthereumKeys[keystore.AliceKey]
+var bobKp = keystore.TestKeyRing.EthereumKeys[keystore.BobKey]
+
+func transfer(t *testing.T, nonce uint64) *types.Transaction {
+	to := bobKp.CommonAddress()
+	tx := types.NewTransaction(nonce, to, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(ChainID), aliceKp.PrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestSendTransaction(t *testing.T) {
+	b := New()
+	defer b.Close()
+	ctx := context.Background()
+
+	err := b.SendTransaction(ctx, transfer(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The transaction is mined right away
+	header, err := b.HeaderByNumber(ctx, nil)
+	if err != nil || header.Number.Uint64() != 1 {
+		t.Fatalf("Got: %v %v Expected: block 1", header, err)
+	}
+	nonce, err := b.PendingNonceAt(ctx, aliceKp.CommonAddress())
+	if err != nil || nonce != 1 {
+		t.Fatalf("Got: %d %v Expected: 1", nonce, err)
+	}
+
+	// Invalid nonces are rejected like a node does
+	if err = b.SendTransaction(ctx, transfer(t, 0)); !errors.Is(err, core.ErrNonceTooLow) {
+		t.Fatalf("Got: %v Expected: %s", err, core.ErrNonceTooLow)
+	}
+	if err = b.SendTransaction(ctx, transfer(t, 5)); !errors.Is(err, core.ErrNonceTooHigh) {
+		t.Fatalf("Got: %v Expected: %s", err, core.ErrNonceTooHigh)
+	}
+
+	b.Mine(3)
+	header, err = b.HeaderByNumber(ctx, nil)
+	if err != nil || header.Number.Uint64() != 4 {
+		t.Fatalf("Got: %v %v Expected: block 4", header, err)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	b := New()
+	expected := errors.New("unavailable")
+	b.FailNext("HeaderByNumber", expected)
+
+	if _, err := b.HeaderByNumber(context.Background(), nil); err != expected {
+		t.Fatalf("Got: %v Expected: %s", err, expected)
+	}
+	if _, err := b.HeaderByNumber(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Close()
+	if _, err := b.ChainID(context.Background()); err != ErrClosed {
+		t.Fatalf("Got: %v Expected: %s", err, ErrClosed)
+	}
+}
+
+func TestClient(t *testing.T) {
+	b := New()
+	defer b.Close()
+	client := b.Client()
+
+	// Clients share the chain
+	err := client.SendTransaction(context.Background(), transfer(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := b.HeaderByNumber(context.Background(), nil)
+	if err != nil || header.Number.Uint64() != 1 {
+		t.Fatalf("Got: %v %v Expected: block 1", header, err)
+	}
+
+	// Failures and closing are limited to the client
+	expected := errors.New("unavailable")
+	client.FailNext("ChainID", expected)
+	if _, err = b.ChainID(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.ChainID(context.Background()); err != expected {
+		t.Fatalf("Got: %v Expected: %s", err, expected)
+	}
+
+	client.Close()
+	if _, err = client.ChainID(context.Background()); err != ErrClosed {
+		t.Fatalf("Got: %v Expected: %s", err, ErrClosed)
+	}
+	b.Mine(1)
+	header, err = b.HeaderByNumber(context.Background(), nil)
+	if err != nil || header.Number.Uint64() != 2 {
+		t.Fatalf("Got: %v %v Expected: block 2", header, err)
+	}
+}
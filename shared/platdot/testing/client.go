@@ -21,11 +21,11 @@ func NewClient(t *testing.T, endpoint string, kp *secp256k1.Keypair) *utils.Clie
 }
 
 func GetLatestBlock(t *testing.T, client *utils.Client) *big.Int {
-	block, err := client.Client.BlockByNumber(context.Background(), nil)
+	header, err := client.Client.HeaderByNumber(context.Background(), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	return block.Number()
+	return header.Number
 }
 
 func LockNonceAndUpdate(t *testing.T, client *utils.Client) {
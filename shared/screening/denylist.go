@@ -0,0 +1,358 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+/*
+The screening package checks bridge transfers against a local deny-list before they are relayed.
+
+The deny-list is a JSON file holding the blocked addresses and the reason each one was added. Addresses
+may be written as 0x hex, bech32 (atp/lat) or SS58, they are normalized to lowercase hex before comparison,
+so an account is matched no matter which encoding the transfer used. The file is polled for changes and
+reloaded while the relayer is running.
+
+Every transfer refused by the deny-list is appended to a record file next to the deny-list, so operators
+can audit which deposits were never relayed and why.
+*/
+package screening
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChainSafe/log15"
+	"github.com/JFJun/go-substrate-crypto/ss58"
+	"github.com/rjman-self/Platdot/shared/bech32"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+// Suffix of the file blocked transfers are recorded to, relative to the deny-list file
+const RecordSuffix = ".blocked"
+
+// Frequency of checking the deny-list file for changes
+var ReloadInterval = time.Second * 10
+
+var ErrBlocked = errors.New("address is on the deny-list")
+
+// BlockedError is returned by Check when the sender or recipient of a transfer is denied
+type BlockedError struct {
+	Address string
+	Reason  string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", ErrBlocked, e.Address, e.Reason)
+}
+
+func (e *BlockedError) Unwrap() error {
+	return ErrBlocked
+}
+
+// Entry is a single deny-list entry
+type Entry struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// BlockedTransfer is the record written for every transfer refused by the deny-list
+type BlockedTransfer struct {
+	Time         time.Time `json:"time"`
+	Source       uint8     `json:"source"`
+	Destination  uint8     `json:"destination"`
+	DepositNonce uint64    `json:"depositNonce"`
+	Sender       string    `json:"sender"`
+	Recipient    string    `json:"recipient"`
+	Amount       string    `json:"amount"`
+	Reason       string    `json:"reason"`
+}
+
+type denyListFile struct {
+	Entries []Entry `json:"entries"`
+}
+
+// DenyList is a reloadable set of blocked addresses backed by a JSON file
+type DenyList struct {
+	path    string
+	entries map[string]Entry
+	modTime time.Time
+	lock    sync.RWMutex
+	log     log15.Logger
+}
+
+// NewDenyList loads the deny-list at path. A missing file is treated as an empty deny-list.
+func NewDenyList(path string, log log15.Logger) (*DenyList, error) {
+	fp, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &DenyList{
+		path:    filepath.Clean(fp),
+		entries: make(map[string]Entry),
+		log:     log,
+	}
+	err = d.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Path returns the absolute location of the deny-list file
+func (d *DenyList) Path() string {
+	return d.path
+}
+
+// Reload reads the deny-list file again and replaces the current entries
+func (d *DenyList) Reload() error {
+	info, err := os.Stat(d.path)
+	if os.IsNotExist(err) {
+		d.lock.Lock()
+		d.entries = make(map[string]Entry)
+		d.modTime = time.Time{}
+		d.lock.Unlock()
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+
+	var file denyListFile
+	if len(strings.TrimSpace(string(data))) != 0 {
+		err = json.Unmarshal(data, &file)
+		if err != nil {
+			return fmt.Errorf("unable to parse deny-list %s: %w", d.path, err)
+		}
+	}
+
+	entries := make(map[string]Entry, len(file.Entries))
+	for _, e := range file.Entries {
+		if e.Address == "" {
+			return fmt.Errorf("deny-list %s contains an entry without address", d.path)
+		}
+		entries[Normalize(e.Address)] = e
+	}
+
+	d.lock.Lock()
+	d.entries = entries
+	d.modTime = info.ModTime()
+	d.lock.Unlock()
+	return nil
+}
+
+// Watch polls the deny-list file and reloads it whenever it changes, until stop is closed
+func (d *DenyList) Watch(stop <-chan int) {
+	ticker := time.NewTicker(ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !d.changed() {
+				continue
+			}
+			err := d.Reload()
+			if err != nil {
+				d.log.Error("Failed to reload deny-list, keeping previous entries", "path", d.path, "err", err)
+				continue
+			}
+			d.log.Info("Reloaded deny-list", "path", d.path, "entries", d.Len())
+		}
+	}
+}
+
+func (d *DenyList) changed() bool {
+	var modTime time.Time
+	if info, err := os.Stat(d.path); err == nil {
+		modTime = info.ModTime()
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return !modTime.Equal(d.modTime)
+}
+
+// Check returns a *BlockedError if any of the given addresses is denied
+func (d *DenyList) Check(addresses ...string) error {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for _, addr := range addresses {
+		if addr == "" {
+			continue
+		}
+		if e, ok := d.entries[Normalize(addr)]; ok {
+			return &BlockedError{Address: addr, Reason: e.Reason}
+		}
+	}
+	return nil
+}
+
+// Screen checks the sender and recipient of the transfer m. If either is denied the transfer is
+// recorded and an error wrapping ErrBlocked is returned. A nil DenyList allows every transfer.
+func (d *DenyList) Screen(m msg.Message, sender, recipient string, amount *big.Int) error {
+	if d == nil {
+		return nil
+	}
+	err := d.Check(sender, recipient)
+	if err == nil {
+		return nil
+	}
+
+	b := BlockedTransfer{
+		Source:       uint8(m.Source),
+		Destination:  uint8(m.Destination),
+		DepositNonce: uint64(m.DepositNonce),
+		Sender:       sender,
+		Recipient:    recipient,
+		Reason:       err.Error(),
+	}
+	if amount != nil {
+		b.Amount = amount.String()
+	}
+	d.log.Warn("Transfer refused by deny-list", "src", m.Source, "dst", m.Destination, "nonce", m.DepositNonce, "sender", sender, "recipient", recipient, "reason", err)
+	if recordErr := d.Record(b); recordErr != nil {
+		d.log.Error("Failed to record blocked transfer", "path", d.RecordPath(), "err", recordErr)
+	}
+	return err
+}
+
+// Len returns the number of denied addresses
+func (d *DenyList) Len() int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return len(d.entries)
+}
+
+// Entries returns all entries sorted by address
+func (d *DenyList) Entries() []Entry {
+	d.lock.RLock()
+	entries := make([]Entry, 0, len(d.entries))
+	for _, e := range d.entries {
+		entries = append(entries, e)
+	}
+	d.lock.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Address < entries[j].Address
+	})
+	return entries
+}
+
+// Add inserts or replaces an address and writes the deny-list back to disk
+func (d *DenyList) Add(address, reason string) error {
+	if strings.TrimSpace(address) == "" {
+		return fmt.Errorf("address must not be empty")
+	}
+	d.lock.Lock()
+	d.entries[Normalize(address)] = Entry{Address: address, Reason: reason}
+	d.lock.Unlock()
+	return d.save()
+}
+
+// Remove deletes an address and writes the deny-list back to disk
+func (d *DenyList) Remove(address string) error {
+	key := Normalize(address)
+	d.lock.Lock()
+	if _, ok := d.entries[key]; !ok {
+		d.lock.Unlock()
+		return fmt.Errorf("address %s is not on the deny-list", address)
+	}
+	delete(d.entries, key)
+	d.lock.Unlock()
+	return d.save()
+}
+
+func (d *DenyList) save() error {
+	raw, err := json.MarshalIndent(denyListFile{Entries: d.Entries()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(d.path, raw, 0600)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(d.path); err == nil {
+		d.lock.Lock()
+		d.modTime = info.ModTime()
+		d.lock.Unlock()
+	}
+	return nil
+}
+
+// RecordPath returns the location of the blocked transfer records
+func (d *DenyList) RecordPath() string {
+	return d.path + RecordSuffix
+}
+
+// Record appends a blocked transfer to the record file
+func (d *DenyList) Record(b BlockedTransfer) error {
+	if b.Time.IsZero() {
+		b.Time = time.Now().UTC()
+	}
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(d.RecordPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(raw, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Records reads all blocked transfers recorded so far
+func (d *DenyList) Records() ([]BlockedTransfer, error) {
+	data, err := ioutil.ReadFile(d.RecordPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var records []BlockedTransfer
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var b BlockedTransfer
+		err = json.Unmarshal([]byte(line), &b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse blocked transfer record: %w", err)
+		}
+		records = append(records, b)
+	}
+	return records, nil
+}
+
+// Normalize converts 0x hex, bech32 and SS58 addresses to lowercase 0x hex so that the same
+// account matches regardless of its encoding. Unrecognized strings are only lowercased.
+func Normalize(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(address, "0x") || strings.HasPrefix(address, "0X") {
+		return strings.ToLower(address)
+	}
+	if _, addr, err := bech32.DecodeAddress(address); err == nil {
+		return "0x" + hex.EncodeToString(addr.Bytes())
+	}
+	if pub, err := ss58.DecodeToPub(address); err == nil {
+		return "0x" + hex.EncodeToString(pub)
+	}
+	return strings.ToLower(address)
+}
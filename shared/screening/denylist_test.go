@@ -0,0 +1,150 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package screening
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChainSafe/log15"
+	"github.com/JFJun/go-substrate-crypto/ss58"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/rjman-self/platdot-utils/msg"
+)
+
+var testLogger = log15.New("test", "screening")
+
+func newTestDenyList(t *testing.T) *DenyList {
+	dir, err := ioutil.TempDir(os.TempDir(), "denylist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d, err := NewDenyList(filepath.Join(dir, "denylist.json"), testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestNormalize(t *testing.T) {
+	eth := ethcommon.HexToAddress("0x1dd2D5b2A7a80F7F8d08B7D95DB0E2bd37BDAe8c")
+	atp, err := ethcommon.EthToPlaton(eth.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub := ethcommon.FromHex("0x50a80eb26a7fb43ff4f84ead705fc61c1d4074112e53f781a6b03c0c7504f663")
+	ksm, err := ss58.Encode(pub, ss58.KsmPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{eth.Hex(), "0x1dd2d5b2a7a80f7f8d08b7d95db0e2bd37bdae8c"},
+		{atp, "0x1dd2d5b2a7a80f7f8d08b7d95db0e2bd37bdae8c"},
+		{ksm, "0x50a80eb26a7fb43ff4f84ead705fc61c1d4074112e53f781a6b03c0c7504f663"},
+		{"  0x50A80EB26A7FB43FF4F84EAD705FC61C1D4074112E53F781A6B03C0C7504F663 ", "0x50a80eb26a7fb43ff4f84ead705fc61c1d4074112e53f781a6b03c0c7504f663"},
+		{"Unknown", "unknown"},
+	}
+
+	for _, tt := range tests {
+		if res := Normalize(tt.input); res != tt.expected {
+			t.Errorf("Normalize(%s): got %s expected %s", tt.input, res, tt.expected)
+		}
+	}
+}
+
+func TestDenyListCheck(t *testing.T) {
+	d := newTestDenyList(t)
+
+	eth := ethcommon.HexToAddress("0x1dd2D5b2A7a80F7F8d08B7D95DB0E2bd37BDAe8c")
+	atp, err := ethcommon.EthToPlaton(eth.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Add(atp, "sanctioned")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Check("0x0000000000000000000000000000000000000001"); err != nil {
+		t.Fatalf("unexpected block: %s", err)
+	}
+
+	err = d.Check("", eth.Hex())
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("expected BlockedError, got %v", err)
+	}
+	if blocked.Reason != "sanctioned" {
+		t.Fatalf("Got reason: %s Expected: %s", blocked.Reason, "sanctioned")
+	}
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatal("expected error to wrap ErrBlocked")
+	}
+
+	// Entries must survive a reload from disk
+	reloaded, err := NewDenyList(d.Path(), testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Len() != 1 {
+		t.Fatalf("Got: %d Expected: %d", reloaded.Len(), 1)
+	}
+
+	err = reloaded.Remove(eth.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = reloaded.Check(atp); err != nil {
+		t.Fatalf("unexpected block after remove: %s", err)
+	}
+}
+
+func TestDenyListScreenRecords(t *testing.T) {
+	d := newTestDenyList(t)
+
+	err := d.Add("0x1dd2D5b2A7a80F7F8d08B7D95DB0E2bd37BDAe8c", "stolen funds")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := msg.NewFungibleTransfer(1, 2, 7, big.NewInt(100), msg.ResourceId{}, []byte("0x01"))
+	err = d.Screen(m, "0x0000000000000000000000000000000000000001", "0x01", big.NewInt(100))
+	if err != nil {
+		t.Fatalf("unexpected block: %s", err)
+	}
+
+	err = d.Screen(m, "0x1dd2d5b2a7a80f7f8d08b7d95db0e2bd37bdae8c", "0x01", big.NewInt(100))
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected ErrBlocked, got %v", err)
+	}
+
+	records, err := d.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Got: %d records Expected: %d", len(records), 1)
+	}
+	if records[0].DepositNonce != 7 || records[0].Amount != "100" {
+		t.Fatalf("unexpected record: %+v", records[0])
+	}
+
+	// A nil deny-list allows everything
+	var none *DenyList
+	if err = none.Screen(m, "a", "b", nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Client is a connection to a signer daemon. The connection is dialed again if the daemon restarted.
+type Client struct {
+	path   string
+	client *rpc.Client
+	lock   sync.Mutex // Guards client
+}
+
+// Dial connects to the signer daemon listening on the Unix socket at path
+func Dial(path string) (*Client, error) {
+	c := &Client{path: path}
+	_, err := c.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// rpcClient returns the connection to the daemon, dialing it if it was lost
+func (c *Client) rpcClient() (*rpc.Client, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.client == nil {
+		client, err := jsonrpc.Dial("unix", c.path)
+		if err != nil {
+			return nil, fmt.Errorf("dial signer %s: %w", c.path, err)
+		}
+		c.client = client
+	}
+	return c.client, nil
+}
+
+// call calls the method of the daemon. Signing has no side effect, so the call is made once again on a
+// new connection if the connection failed, eg. when the daemon restarted.
+func (c *Client) call(method string, args interface{}, reply interface{}) error {
+	for attempt := 0; ; attempt++ {
+		client, err := c.rpcClient()
+		if err != nil {
+			return err
+		}
+		err = client.Call(serviceName+"."+method, args, reply)
+		var serverErr rpc.ServerError
+		if errors.As(err, &serverErr) {
+			// Errors of the daemon only keep their message, the policy denials are recognized by it
+			if strings.HasPrefix(string(serverErr), ErrDenied.Error()) {
+				return fmt.Errorf("%w%s", ErrDenied, strings.TrimPrefix(string(serverErr), ErrDenied.Error()))
+			}
+			return err
+		}
+		if err == nil || attempt > 0 {
+			return err
+		}
+		c.lock.Lock()
+		if c.client == client {
+			_ = client.Close()
+			c.client = nil
+		}
+		c.lock.Unlock()
+	}
+}
+
+// Accounts returns the keys held by the daemon
+func (c *Client) Accounts() (*AccountsReply, error) {
+	var reply AccountsReply
+	err := c.call("Accounts", struct{}{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// Ethereum returns the signer of the transactions of addr, which must be held by the daemon
+func (c *Client) Ethereum(addr common.Address) (Ethereum, error) {
+	accounts, err := c.Accounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, held := range accounts.Ethereum {
+		if common.HexToAddress(held) == addr {
+			return &remoteEthereum{client: c, addr: addr}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownKey, addr.Hex())
+}
+
+// Substrate returns the signer of the extrinsics of the account of pub, which must be held by the daemon
+func (c *Client) Substrate(pub []byte) (Substrate, error) {
+	accounts, err := c.Accounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, held := range accounts.Substrate {
+		if bytes.Equal(held, pub) {
+			return &remoteSubstrate{client: c, pub: pub}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %#x", ErrUnknownKey, pub)
+}
+
+// Close disconnects from the daemon
+func (c *Client) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.client != nil {
+		_ = c.client.Close()
+		c.client = nil
+	}
+}
+
+type remoteEthereum struct {
+	client *Client
+	addr   common.Address
+}
+
+func (s *remoteEthereum) CommonAddress() common.Address {
+	return s.addr
+}
+
+func (s *remoteEthereum) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var reply hexutil.Bytes
+	err = s.client.call("SignTx", SignTxArgs{Address: s.addr.Hex(), Tx: data, ChainID: chainID}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	signed := new(types.Transaction)
+	err = signed.UnmarshalBinary(reply)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signed transaction: %w", err)
+	}
+	// The daemon must not change what the relayer asked to sign
+	txSigner := types.LatestSignerForChainID(chainID)
+	if txSigner.Hash(signed) != txSigner.Hash(tx) {
+		return nil, errors.New("signer returned another transaction")
+	}
+	sender, err := types.Sender(txSigner, signed)
+	if err != nil || sender != s.addr {
+		return nil, fmt.Errorf("signer returned a transaction signed by %s, expected %s", sender.Hex(), s.addr.Hex())
+	}
+	return signed, nil
+}
+
+type remoteSubstrate struct {
+	client *Client
+	pub    []byte
+}
+
+func (s *remoteSubstrate) PublicKey() []byte {
+	return s.pub
+}
+
+func (s *remoteSubstrate) Sign(payload []byte) ([]byte, error) {
+	var reply hexutil.Bytes
+	err := s.client.call("SignPayload", SignPayloadArgs{PublicKey: s.pub, Payload: payload}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) != 64 {
+		return nil, fmt.Errorf("signer returned a signature of %d bytes", len(reply))
+	}
+	return reply, nil
+}
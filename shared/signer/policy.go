@@ -0,0 +1,184 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/Platdot/shared/bech32"
+)
+
+var ErrDenied = errors.New("denied by signer policy")
+
+// PayloadCall is the call of an extrinsic payload as checked by the policy
+type PayloadCall struct {
+	Calls     [][2]byte  // Index of the call and of every call nested in it, eg. the transfer of an as_multi
+	Transfers [][32]byte // Accounts receiving a balance transfer of the call or of a nested call
+}
+
+// PayloadDecoder decodes the call of an extrinsic payload with the runtime metadata of its chain
+type PayloadDecoder func(payload []byte) (*PayloadCall, error)
+
+// Policy restricts what the signer daemon signs. Transactions and payloads are denied unless their
+// contract or call is listed.
+type Policy struct {
+	ChainID        *big.Int         // Chain id transactions must be signed for, any when nil
+	Contracts      []common.Address // Contracts transactions may call
+	MaxGasPrice    *big.Int         // Highest gas price of a transaction, any when nil
+	Calls          [][2]byte        // Pallet and call index of the calls extrinsics may make, nested calls included
+	GenesisHash    []byte           // Genesis hash of the substrate chain extrinsics are signed for, any when empty
+	DeniedAccounts [][32]byte       // Accounts transfers must not pay, eg. the relayers and the multisig account
+	Decoder        PayloadDecoder   // Decodes payloads, extrinsics are denied without it
+}
+
+// policyFile is the JSON encoding of a Policy
+type policyFile struct {
+	ChainID        string   `json:"chainId"`
+	Prefix         string   `json:"prefix"` // Bech32 prefix of the contracts, eg. atp
+	Contracts      []string `json:"contracts"`
+	MaxGasPrice    string   `json:"maxGasPrice"`
+	Calls          []string `json:"calls"` // eg. 0x1f01
+	GenesisHash    string   `json:"genesisHash"`
+	DeniedAccounts []string `json:"deniedAccounts"` // 0x hex public keys
+}
+
+// LoadPolicy reads a policy from a JSON file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file policyFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+
+	p := &Policy{}
+	if file.ChainID != "" {
+		id, ok := new(big.Int).SetString(file.ChainID, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid policy chainId %q", file.ChainID)
+		}
+		p.ChainID = id
+	}
+	for _, contract := range file.Contracts {
+		addr, err := bech32.ParseAddress(contract, file.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy contract: %w", err)
+		}
+		p.Contracts = append(p.Contracts, addr)
+	}
+	if file.MaxGasPrice != "" {
+		price, ok := new(big.Int).SetString(file.MaxGasPrice, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid policy maxGasPrice %q", file.MaxGasPrice)
+		}
+		p.MaxGasPrice = price
+	}
+	for _, c := range file.Calls {
+		index, err := hexutil.Decode(c)
+		if err != nil || len(index) != 2 {
+			return nil, fmt.Errorf("invalid policy call %q, expected the 2 byte call index", c)
+		}
+		p.Calls = append(p.Calls, [2]byte{index[0], index[1]})
+	}
+	if file.GenesisHash != "" {
+		hash, err := hexutil.Decode(file.GenesisHash)
+		if err != nil || len(hash) != 32 {
+			return nil, fmt.Errorf("invalid policy genesisHash %q", file.GenesisHash)
+		}
+		p.GenesisHash = hash
+	}
+	for _, account := range file.DeniedAccounts {
+		pub, err := hexutil.Decode(account)
+		if err != nil || len(pub) != 32 {
+			return nil, fmt.Errorf("invalid policy denied account %q, expected a 32 byte public key", account)
+		}
+		var res [32]byte
+		copy(res[:], pub)
+		p.DeniedAccounts = append(p.DeniedAccounts, res)
+	}
+	return p, nil
+}
+
+// CheckTx returns an error wrapping ErrDenied if tx must not be signed for chainID
+func (p *Policy) CheckTx(tx *types.Transaction, chainID *big.Int) error {
+	if p.ChainID != nil && (chainID == nil || p.ChainID.Cmp(chainID) != 0) {
+		return fmt.Errorf("%w: chain id %s, expected %s", ErrDenied, chainID, p.ChainID)
+	}
+	if tx.To() == nil {
+		return fmt.Errorf("%w: contract creation", ErrDenied)
+	}
+	allowed := false
+	for _, contract := range p.Contracts {
+		if *tx.To() == contract {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: contract %s is not allowed", ErrDenied, tx.To().Hex())
+	}
+	if tx.Value().Sign() != 0 {
+		return fmt.Errorf("%w: value %s", ErrDenied, tx.Value())
+	}
+	if p.MaxGasPrice != nil && tx.GasPrice().Cmp(p.MaxGasPrice) > 0 {
+		return fmt.Errorf("%w: gas price %s above %s", ErrDenied, tx.GasPrice(), p.MaxGasPrice)
+	}
+	return nil
+}
+
+// CheckPayload returns an error wrapping ErrDenied if the extrinsic payload must not be signed. The payload
+// starts with the encoded call and ends with the genesis hash and the block hash. The call and every call
+// nested in it must be allowed, and none of its transfers may pay a denied account.
+func (p *Policy) CheckPayload(payload []byte) error {
+	if len(payload) < 2+64 {
+		return fmt.Errorf("%w: payload of %d bytes is too short", ErrDenied, len(payload))
+	}
+	genesisHash := payload[len(payload)-64 : len(payload)-32]
+	if len(p.GenesisHash) != 0 && !bytes.Equal(genesisHash, p.GenesisHash) {
+		return fmt.Errorf("%w: genesis hash %#x, expected %#x", ErrDenied, genesisHash, p.GenesisHash)
+	}
+	if p.Decoder == nil {
+		return fmt.Errorf("%w: no decoder for extrinsic payloads", ErrDenied)
+	}
+	call, err := p.Decoder(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrDenied, err)
+	}
+
+	for _, index := range call.Calls {
+		if !p.allowsCall(index) {
+			return fmt.Errorf("%w: call %#x is not allowed", ErrDenied, index[:])
+		}
+	}
+	for _, to := range call.Transfers {
+		for _, denied := range p.DeniedAccounts {
+			if to == denied {
+				return fmt.Errorf("%w: transfer to denied account %#x", ErrDenied, to[:])
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Policy) allowsCall(index [2]byte) bool {
+	for _, c := range p.Calls {
+		if index == c {
+			return true
+		}
+	}
+	return false
+}
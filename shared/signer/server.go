@@ -0,0 +1,232 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+
+	"github.com/ChainSafe/log15"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Name the signing methods are served under, eg. Signer.SignTx
+const serviceName = "Signer"
+
+var ErrUnknownKey = errors.New("key not held by the signer")
+
+// AccountsReply lists the keys held by the signer
+type AccountsReply struct {
+	Ethereum  []string        // 0x hex addresses
+	Substrate []hexutil.Bytes // Public keys
+}
+
+// SignTxArgs is a request to sign the binary encoded Tx with the key of Address
+type SignTxArgs struct {
+	Address string // 0x hex
+	Tx      hexutil.Bytes
+	ChainID *big.Int
+}
+
+// SignPayloadArgs is a request to sign an extrinsic payload with the key of PublicKey
+type SignPayloadArgs struct {
+	PublicKey hexutil.Bytes
+	Payload   hexutil.Bytes
+}
+
+// Server is the signer daemon. It serves the keys added to it over a Unix socket, and signs only what
+// its policy allows.
+type Server struct {
+	policy    *Policy
+	log       log15.Logger
+	rpc       *rpc.Server
+	ethereum  map[common.Address]Ethereum
+	substrate map[string]Substrate // By hex public key
+	keyLock   sync.RWMutex
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+	connLock  sync.Mutex // Guards listener, conns and closed
+}
+
+// NewServer returns a signer daemon checking every request against policy
+func NewServer(policy *Policy, log log15.Logger) *Server {
+	s := &Server{
+		policy:    policy,
+		log:       log,
+		rpc:       rpc.NewServer(),
+		ethereum:  make(map[common.Address]Ethereum),
+		substrate: make(map[string]Substrate),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	// service only has methods of the rpc signature, registering can't fail
+	_ = s.rpc.RegisterName(serviceName, &service{s: s})
+	return s
+}
+
+// AddEthereum makes the transactions of the account of key signable
+func (s *Server) AddEthereum(key Ethereum) {
+	s.keyLock.Lock()
+	defer s.keyLock.Unlock()
+	s.ethereum[key.CommonAddress()] = key
+}
+
+// AddSubstrate makes the extrinsics of the account of key signable
+func (s *Server) AddSubstrate(key Substrate) {
+	s.keyLock.Lock()
+	defer s.keyLock.Unlock()
+	s.substrate[hexutil.Encode(key.PublicKey())] = key
+}
+
+// ListenAndServe serves the signer on a Unix socket at path, replacing a stale socket of a previous
+// run. The socket is only accessible to the user running the daemon.
+func (s *Server) ListenAndServe(path string) error {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", path)
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called
+func (s *Server) Serve(l net.Listener) error {
+	s.connLock.Lock()
+	if s.closed {
+		s.connLock.Unlock()
+		return l.Close()
+	}
+	s.listener = l
+	s.connLock.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.connLock.Lock()
+			closed := s.closed
+			s.connLock.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.connLock.Lock()
+		s.conns[conn] = struct{}{}
+		s.connLock.Unlock()
+		go func() {
+			s.rpc.ServeCodec(jsonrpc.NewServerCodec(conn))
+			s.connLock.Lock()
+			delete(s.conns, conn)
+			s.connLock.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting connections and disconnects the clients
+func (s *Server) Close() {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	s.closed = true
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// service holds the methods served over rpc
+type service struct {
+	s *Server
+}
+
+func (svc *service) Accounts(_ struct{}, reply *AccountsReply) error {
+	s := svc.s
+	s.keyLock.RLock()
+	defer s.keyLock.RUnlock()
+	for addr := range s.ethereum {
+		reply.Ethereum = append(reply.Ethereum, addr.Hex())
+	}
+	for _, key := range s.substrate {
+		reply.Substrate = append(reply.Substrate, key.PublicKey())
+	}
+	return nil
+}
+
+func (svc *service) SignTx(args SignTxArgs, reply *hexutil.Bytes) error {
+	s := svc.s
+	s.keyLock.RLock()
+	key, ok := s.ethereum[common.HexToAddress(args.Address)]
+	s.keyLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, args.Address)
+	}
+
+	tx := new(types.Transaction)
+	err := tx.UnmarshalBinary(args.Tx)
+	if err != nil {
+		return fmt.Errorf("invalid transaction: %w", err)
+	}
+	err = s.policy.CheckTx(tx, args.ChainID)
+	if err != nil {
+		s.log.Warn("Refused to sign transaction", "address", args.Address, "nonce", tx.Nonce(), "err", err)
+		return err
+	}
+
+	signed, err := key.SignTx(tx, args.ChainID)
+	if err != nil {
+		return err
+	}
+	*reply, err = signed.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s.log.Info("Signed transaction", "address", args.Address, "to", tx.To().Hex(), "nonce", tx.Nonce(), "hash", signed.Hash().Hex())
+	return nil
+}
+
+func (svc *service) SignPayload(args SignPayloadArgs, reply *hexutil.Bytes) error {
+	s := svc.s
+	pub := hexutil.Encode(args.PublicKey)
+	s.keyLock.RLock()
+	key, ok := s.substrate[pub]
+	s.keyLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, pub)
+	}
+
+	err := s.policy.CheckPayload(args.Payload)
+	if err != nil {
+		s.log.Warn("Refused to sign extrinsic", "key", pub, "err", err)
+		return err
+	}
+
+	sig, err := key.Sign(args.Payload)
+	if err != nil {
+		return err
+	}
+	*reply = sig
+	s.log.Info("Signed extrinsic", "key", pub, "call", hexutil.Encode(args.Payload[:2]))
+	return nil
+}
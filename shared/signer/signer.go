@@ -0,0 +1,74 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+/*
+The signer package abstracts the keys of a relayer behind the Ethereum and Substrate signer interfaces.
+
+The local signers wrap a keypair decrypted from the keystore, like the relayer always did. The remote
+signers forward every signing request to a signer daemon listening on a local Unix socket, so the keys
+can live in an isolated process on the same host. The daemon checks each request against its Policy
+before signing: Alaya transactions must target an allowed contract of the expected chain without value,
+and substrate payloads must encode an allowed call of the expected chain. Payloads are decoded with the
+metadata of the chain, the calls nested in a multisig must be allowed too and may not pay the accounts
+the policy denies.
+
+The daemon is started with "platdot signer", relayers use it when the "signer" opt of a chain is set
+to the path of its socket.
+*/
+package signer
+
+import (
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/platdot-utils/crypto/secp256k1"
+)
+
+// Ethereum signs the transactions of an Alaya account
+type Ethereum interface {
+	CommonAddress() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// Substrate signs the extrinsic payloads of an sr25519 account
+type Substrate interface {
+	PublicKey() []byte
+	// Sign signs the SCALE encoded extrinsic payload, payloads over 256 bytes are hashed first
+	Sign(payload []byte) ([]byte, error)
+}
+
+type localEthereum struct {
+	kp *secp256k1.Keypair
+}
+
+// NewEthereum returns a signer of the transactions of kp
+func NewEthereum(kp *secp256k1.Keypair) Ethereum {
+	return &localEthereum{kp: kp}
+}
+
+func (s *localEthereum) CommonAddress() common.Address {
+	return s.kp.CommonAddress()
+}
+
+func (s *localEthereum) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.kp.PrivateKey())
+}
+
+type localSubstrate struct {
+	kr signature.KeyringPair
+}
+
+// NewSubstrate returns a signer of the extrinsics of kr
+func NewSubstrate(kr signature.KeyringPair) Substrate {
+	return &localSubstrate{kr: kr}
+}
+
+func (s *localSubstrate) PublicKey() []byte {
+	return s.kr.PublicKey
+}
+
+func (s *localSubstrate) Sign(payload []byte) ([]byte, error) {
+	return signature.Sign(payload, s.kr.URI)
+}
@@ -0,0 +1,259 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChainSafe/log15"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rjman-self/platdot-utils/keystore"
+)
+
+var aliceKp = keystore.TestKeyRing.EthereumKeys[keystore.AliceKey]
+var bobKp = keystore.TestKeyRing.EthereumKeys[keystore.BobKey]
+
+var chainID = big.NewInt(201030)
+var bridge = common.HexToAddress("0x62877dDCd49aD22f5eDfc6ac108e9a4b5D2bD88B")
+var genesisHash = make([]byte, 32)
+
+var testLogger = newTestLogger()
+
+func newTestLogger() log15.Logger {
+	tLog := log15.Root().New()
+	tLog.SetHandler(log15.LvlFilterHandler(log15.LvlError, tLog.GetHandler()))
+	return tLog
+}
+
+func testPolicy() *Policy {
+	return &Policy{
+		ChainID:     chainID,
+		Contracts:   []common.Address{bridge},
+		MaxGasPrice: big.NewInt(100),
+		Calls:       [][2]byte{{0x1f, 0x01}},
+		GenesisHash: genesisHash,
+		Decoder:     testDecoder,
+	}
+}
+
+// testDecoder decodes the call index of the payloads of payload, 0xffff is an unknown call
+func testDecoder(p []byte) (*PayloadCall, error) {
+	if p[0] == 0xff && p[1] == 0xff {
+		return nil, errors.New("unknown call")
+	}
+	return &PayloadCall{Calls: [][2]byte{{p[0], p[1]}}}, nil
+}
+
+// payload returns an extrinsic payload of the call with the index, for the chain of genesis
+func payload(index [2]byte, genesis []byte) []byte {
+	p := append([]byte{index[0], index[1]}, make([]byte, 20)...)
+	p = append(p, genesis...)
+	return append(p, make([]byte, 32)...)
+}
+
+// startServer serves the keys of alice on a socket of a temporary directory
+func startServer(t *testing.T) (*Server, string) {
+	server := NewServer(testPolicy(), testLogger)
+	server.AddEthereum(NewEthereum(aliceKp))
+	server.AddSubstrate(NewSubstrate(signature.TestKeyringPairAlice))
+
+	path := filepath.Join(t.TempDir(), "signer.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(server.Close)
+	return server, path
+}
+
+func TestRemoteSigner(t *testing.T) {
+	_, path := startServer(t)
+	client, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	eth, err := client.Ethereum(aliceKp.CommonAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTransaction(3, bridge, big.NewInt(0), 21000, big.NewInt(10), []byte{1, 2, 3})
+	signed, err := eth.SignTx(tx, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil || sender != aliceKp.CommonAddress() {
+		t.Fatalf("Got: %s %v Expected: %s", sender.Hex(), err, aliceKp.CommonAddress().Hex())
+	}
+
+	sub, err := client.Substrate(signature.TestKeyringPairAlice.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := payload([2]byte{0x1f, 0x01}, genesisHash)
+	sig, err := sub.Sign(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := signature.Verify(p, sig, signature.TestKeyringPairAlice.URI)
+	if err != nil || !ok {
+		t.Fatalf("Got: %v %v Expected: a valid signature", ok, err)
+	}
+
+	// Only the keys of the daemon are served
+	if _, err = client.Ethereum(bobKp.CommonAddress()); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Got: %v Expected: %s", err, ErrUnknownKey)
+	}
+}
+
+func TestRemoteSignerRedial(t *testing.T) {
+	server, path := startServer(t)
+	client, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	eth, err := client.Ethereum(aliceKp.CommonAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The daemon restarts on the same socket
+	server.Close()
+	restarted := NewServer(testPolicy(), testLogger)
+	restarted.AddEthereum(NewEthereum(aliceKp))
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = restarted.Serve(l) }()
+	defer restarted.Close()
+
+	tx := types.NewTransaction(0, bridge, big.NewInt(0), 21000, big.NewInt(10), nil)
+	if _, err = eth.SignTx(tx, chainID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyDenials(t *testing.T) {
+	_, path := startServer(t)
+	client, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	eth, err := client.Ethereum(aliceKp.CommonAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := client.Substrate(signature.TestKeyringPairAlice.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	txs := map[string]struct {
+		tx      *types.Transaction
+		chainID *big.Int
+	}{
+		"other chain":       {types.NewTransaction(0, bridge, big.NewInt(0), 21000, big.NewInt(10), nil), big.NewInt(1)},
+		"other contract":    {types.NewTransaction(0, other, big.NewInt(0), 21000, big.NewInt(10), nil), chainID},
+		"contract creation": {types.NewContractCreation(0, big.NewInt(0), 21000, big.NewInt(10), nil), chainID},
+		"value":             {types.NewTransaction(0, bridge, big.NewInt(1), 21000, big.NewInt(10), nil), chainID},
+		"gas price":         {types.NewTransaction(0, bridge, big.NewInt(0), 21000, big.NewInt(101), nil), chainID},
+	}
+	for name, tc := range txs {
+		if _, err = eth.SignTx(tc.tx, tc.chainID); !errors.Is(err, ErrDenied) {
+			t.Fatalf("%s Got: %v Expected: %s", name, err, ErrDenied)
+		}
+	}
+
+	otherGenesis := make([]byte, 32)
+	otherGenesis[0] = 1
+	payloads := map[string][]byte{
+		"other call":  payload([2]byte{0x04, 0x03}, genesisHash),
+		"other chain": payload([2]byte{0x1f, 0x01}, otherGenesis),
+		"too short":   {0x1f, 0x01},
+		"undecodable": payload([2]byte{0xff, 0xff}, genesisHash),
+	}
+	for name, p := range payloads {
+		if _, err = sub.Sign(p); !errors.Is(err, ErrDenied) {
+			t.Fatalf("%s Got: %v Expected: %s", name, err, ErrDenied)
+		}
+	}
+}
+
+func TestCheckPayload(t *testing.T) {
+	relayer := [32]byte{1}
+	p := testPolicy()
+	p.DeniedAccounts = [][32]byte{relayer}
+	p.Decoder = func(payload []byte) (*PayloadCall, error) {
+		return &PayloadCall{Calls: [][2]byte{{0x1f, 0x01}}, Transfers: [][32]byte{{2}, {payload[2]}}}, nil
+	}
+
+	allowed := payload([2]byte{0x1f, 0x01}, genesisHash)
+	if err := p.CheckPayload(allowed); err != nil {
+		t.Fatal(err)
+	}
+	denied := payload([2]byte{0x1f, 0x01}, genesisHash)
+	denied[2] = 1
+	if err := p.CheckPayload(denied); !errors.Is(err, ErrDenied) {
+		t.Fatalf("Got: %v Expected: %s", err, ErrDenied)
+	}
+
+	// Payloads can not be checked without a decoder
+	p.Decoder = nil
+	if err := p.CheckPayload(allowed); !errors.Is(err, ErrDenied) {
+		t.Fatalf("Got: %v Expected: %s", err, ErrDenied)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	data := `{
+		"chainId": "201030",
+		"prefix": "atp",
+		"contracts": ["atp1v2rhmhx5ntfz7hklc6kppr56fdwjhkytxgakmg", "0x62877dDCd49aD22f5eDfc6ac108e9a4b5D2bD88B"],
+		"maxGasPrice": "100",
+		"calls": ["0x1f01"],
+		"genesisHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"deniedAccounts": ["0xd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d"]
+	}`
+	err := ioutil.WriteFile(path, []byte(data), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Contracts) != 2 || p.Contracts[0] != bridge || p.Contracts[1] != bridge {
+		t.Fatalf("Got: %v Expected: %s twice", p.Contracts, bridge.Hex())
+	}
+	if p.ChainID.Cmp(chainID) != 0 || p.MaxGasPrice.Int64() != 100 || p.Calls[0] != [2]byte{0x1f, 0x01} {
+		t.Fatalf("Got: %v Expected: %v", p, testPolicy())
+	}
+	if len(p.DeniedAccounts) != 1 || !bytes.Equal(p.DeniedAccounts[0][:], signature.TestKeyringPairAlice.PublicKey) {
+		t.Fatalf("Got: %x Expected: %x", p.DeniedAccounts, signature.TestKeyringPairAlice.PublicKey)
+	}
+
+	err = ioutil.WriteFile(path, []byte(`{"calls": ["0x1f"]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadPolicy(path); err == nil {
+		t.Fatal("Got: nil Expected: invalid call index")
+	}
+}
@@ -129,6 +129,20 @@ func (c *Client) NewNativeTransferCall(amount types.U128, recipient []byte, dest
 	return types.NewCall(c.Meta, string(ExampleTransferNativeMethod), amount, recipient, types.U8(destId))
 }
 
+// NewDepositCall creates the deposit expected by the relayers: a Utility.batch of a transfer to their
+// multisig account followed by a remark with the recipient on the other chain
+func (c *Client) NewDepositCall(multiSign types.AccountID, amount *big.Int, recipient string) (types.Call, error) {
+	transfer, err := types.NewCall(c.Meta, string(BalancesTransferMethod), types.NewMultiAddressFromAccountID(multiSign[:]), types.NewUCompact(amount))
+	if err != nil {
+		return types.Call{}, err
+	}
+	remark, err := types.NewCall(c.Meta, string(SystemRemark), types.NewBytes([]byte(recipient)))
+	if err != nil {
+		return types.Call{}, err
+	}
+	return types.NewCall(c.Meta, string(UtilityBatch), []types.Call{transfer, remark})
+}
+
 // Utility methods
 
 func (c *Client) LatestBlock() (uint64, error) {
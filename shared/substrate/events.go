@@ -169,6 +169,14 @@ type EventRegistryTmp struct {
 	Topics []types.Hash
 }
 
+// EventSystemRemarked is emitted by System.remark_with_event, it carries the hash of the remark
+type EventSystemRemarked struct {
+	Phase  types.Phase
+	Sender types.AccountID
+	Hash   types.Hash
+	Topics []types.Hash
+}
+
 type Events struct {
 	types.EventRecords
 	events.Events
@@ -199,4 +207,5 @@ type Events struct {
 	Registry_Mint                    []EventRegistryMint                   //nolint:stylecheck,golint
 	Registry_RegistryCreated         []EventRegistryRegistryCreated        //nolint:stylecheck,golint
 	Registry_RegistryTmp             []EventRegistryTmp                    //nolint:stylecheck,golint
+	System_Remarked                  []EventSystemRemarked                 //nolint:stylecheck,golint
 }
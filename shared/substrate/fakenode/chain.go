@@ -0,0 +1,314 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package fakenode
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Block is a scripted block. Its extrinsics are encoded extrinsics, its events are stored as System.Events.
+type Block struct {
+	Extrinsics [][]byte
+	Events     []Event
+}
+
+// Event is an event of a block, such as Module "Balances" and Name "Transfer". Args are the encodable
+// values of the event arguments in the order of the metadata.
+type Event struct {
+	Phase  types.Phase
+	Module string
+	Name   string
+	Args   []interface{}
+}
+
+// ApplyExtrinsic returns the phase of the events of the extrinsic at index in its block
+func ApplyExtrinsic(index uint32) types.Phase {
+	return types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: index}
+}
+
+// ExtrinsicSuccess returns the System.ExtrinsicSuccess event of the extrinsic at index
+func ExtrinsicSuccess(index uint32) Event {
+	return Event{
+		Phase:  ApplyExtrinsic(index),
+		Module: "System",
+		Name:   "ExtrinsicSuccess",
+		Args:   []interface{}{types.DispatchInfo{Weight: DefaultWeight, Class: types.DispatchClass{IsNormal: true}, PaysFee: types.Pays{IsYes: true}}},
+	}
+}
+
+type block struct {
+	number     uint32
+	hash       types.Hash
+	header     types.Header
+	extrinsics [][]byte
+	events     []byte // Encoded event records
+}
+
+// AddBlock adds a block on top of the chain and returns its hash. The extrinsics the block includes are
+// reported in block to their watchers.
+func (n *Node) AddBlock(b Block) (types.Hash, error) {
+	n.lock.Lock()
+	added, err := n.appendBlock(b)
+	if err != nil {
+		n.lock.Unlock()
+		return types.Hash{}, err
+	}
+	var included []*watcher
+	for _, ext := range b.Extrinsics {
+		key := types.HexEncodeToString(ext)
+		if w, ok := n.watchers[key]; ok {
+			included = append(included, w)
+			delete(n.watchers, key)
+		}
+	}
+	n.lock.Unlock()
+
+	for _, w := range included {
+		w.notify(map[string]string{"inBlock": added.hash.Hex()})
+	}
+	return added.hash, nil
+}
+
+// AddBlocks adds count empty blocks
+func (n *Node) AddBlocks(count int) error {
+	for i := 0; i < count; i++ {
+		if _, err := n.AddBlock(Block{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rewind removes the blocks above number, so that other blocks can replace them as in a reorg
+func (n *Node) Rewind(number uint32) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if int(number) >= len(n.blocks) {
+		return fmt.Errorf("block %d is above the head %d", number, len(n.blocks)-1)
+	}
+	if n.finalized > int(number) {
+		return fmt.Errorf("block %d is finalized", n.finalized)
+	}
+	n.blocks = n.blocks[:number+1]
+	return nil
+}
+
+// SetFinalized sets the finalized block to number, -1 makes the head block finalized
+func (n *Node) SetFinalized(number int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.finalized = number
+}
+
+// Head returns the number of the head block
+func (n *Node) Head() uint32 {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.head().number
+}
+
+// BlockHash returns the hash of the block at number
+func (n *Node) BlockHash(number uint32) (types.Hash, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if int(number) >= len(n.blocks) {
+		return types.Hash{}, false
+	}
+	return n.blocks[number].hash, true
+}
+
+// SetStorage stores the encoded value at key, for every block
+func (n *Node) SetStorage(key types.StorageKey, value interface{}) error {
+	encoded, err := types.EncodeToBytes(value)
+	if err != nil {
+		return err
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.storage[strings.ToLower(key.Hex())] = encoded
+	return nil
+}
+
+// SetAccount stores the System.Account info of an account, its nonce is incremented by each extrinsic it submits
+func (n *Node) SetAccount(account types.AccountID, info types.AccountInfo) error {
+	fillBalances(&info)
+	encoded, err := types.EncodeToBytes(info)
+	if err != nil {
+		return err
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.storage[n.accountKey(account)] = encoded
+	return nil
+}
+
+// accountKey returns the hex storage key of the System.Account of an account, the caller must hold lock
+func (n *Node) accountKey(account types.AccountID) string {
+	key, err := types.CreateStorageKey(n.meta, "System", "Account", account[:], nil)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(key.Hex())
+}
+
+// fillBalances sets the unset balances of info to zero, a U128 without a value can not be encoded
+func fillBalances(info *types.AccountInfo) {
+	for _, balance := range []*types.U128{&info.Data.Free, &info.Data.Reserved, &info.Data.MiscFrozen, &info.Data.FreeFrozen} {
+		if balance.Int == nil {
+			*balance = types.NewU128(*big.NewInt(0))
+		}
+	}
+}
+
+func (n *Node) head() *block {
+	return n.blocks[len(n.blocks)-1]
+}
+
+func (n *Node) finalizedBlock() *block {
+	if n.finalized < 0 || n.finalized >= len(n.blocks) {
+		return n.head()
+	}
+	return n.blocks[n.finalized]
+}
+
+// appendBlock builds and adds a block on top of the chain, the caller must hold lock
+func (n *Node) appendBlock(b Block) (*block, error) {
+	events, err := n.encodeEvents(b.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent types.Hash
+	number := uint32(len(n.blocks))
+	if number > 0 {
+		parent = n.head().hash
+	}
+	header := types.Header{
+		ParentHash:     parent,
+		Number:         types.BlockNumber(number),
+		StateRoot:      types.NewHash(hash(events)),
+		ExtrinsicsRoot: types.NewHash(hash(bytes.Join(b.Extrinsics, nil))),
+	}
+	encoded, err := types.EncodeToBytes(header)
+	if err != nil {
+		return nil, err
+	}
+
+	added := &block{
+		number:     number,
+		hash:       types.NewHash(hash(encoded)),
+		header:     header,
+		extrinsics: b.Extrinsics,
+		events:     events,
+	}
+	n.blocks = append(n.blocks, added)
+	return added, nil
+}
+
+// encodeEvents encodes the event records of a block, as decoded by types.EventRecordsRaw
+func (n *Node) encodeEvents(events []Event) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := scale.NewEncoder(&buf)
+	err := encoder.Encode(types.NewUCompactFromUInt(uint64(len(events))))
+	if err != nil {
+		return nil, err
+	}
+	for _, evt := range events {
+		id, err := n.eventID(evt.Module, evt.Name)
+		if err != nil {
+			return nil, err
+		}
+		err = encoder.Encode(evt.Phase)
+		if err != nil {
+			return nil, err
+		}
+		err = encoder.Encode(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range evt.Args {
+			err = encoder.Encode(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", evt.Module, evt.Name, err)
+			}
+		}
+		// No topics
+		err = encoder.Encode(types.NewUCompactFromUInt(0))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (n *Node) eventID(module, name string) (types.EventID, error) {
+	for _, mod := range n.meta.AsMetadataV12.Modules {
+		if !mod.HasEvents || string(mod.Name) != module {
+			continue
+		}
+		for i, evt := range mod.Events {
+			if string(evt.Name) == name {
+				return types.EventID{byte(mod.Index), byte(i)}, nil
+			}
+		}
+	}
+	return types.EventID{}, fmt.Errorf("event %s.%s not found in metadata", module, name)
+}
+
+func (b *block) headerJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"parentHash":     b.header.ParentHash.Hex(),
+		"number":         fmt.Sprintf("0x%x", b.number),
+		"stateRoot":      b.header.StateRoot.Hex(),
+		"extrinsicsRoot": b.header.ExtrinsicsRoot.Hex(),
+		"digest":         map[string]interface{}{"logs": []string{}},
+	}
+}
+
+func (b *block) signedBlockJSON() map[string]interface{} {
+	extrinsics := make([]string, len(b.extrinsics))
+	for i, ext := range b.extrinsics {
+		extrinsics[i] = types.HexEncodeToString(ext)
+	}
+	return map[string]interface{}{
+		"block": map[string]interface{}{
+			"header":     b.headerJSON(),
+			"extrinsics": extrinsics,
+		},
+		"justification": nil,
+	}
+}
+
+// extrinsicSigner returns the account of a signed extrinsic whose signer is an account id
+func extrinsicSigner(ext []byte) (types.AccountID, bool) {
+	decoder := scale.NewDecoder(bytes.NewReader(ext))
+	if _, err := decoder.DecodeUintCompact(); err != nil {
+		return types.AccountID{}, false
+	}
+	var version, addressType byte
+	if err := decoder.Decode(&version); err != nil || version&types.ExtrinsicBitSigned == 0 {
+		return types.AccountID{}, false
+	}
+	// MultiAddress::Id
+	if err := decoder.Decode(&addressType); err != nil || addressType != 0 {
+		return types.AccountID{}, false
+	}
+	var signer types.AccountID
+	if err := decoder.Decode(&signer); err != nil {
+		return types.AccountID{}, false
+	}
+	return signer, true
+}
+
+func hash(data []byte) []byte {
+	h := blake2b.Sum256(data)
+	return h[:]
+}
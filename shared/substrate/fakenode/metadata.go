@@ -0,0 +1,33 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package fakenode
+
+import (
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+)
+
+// Metadata returns the Polkadot example metadata with the calls and events the relayer needs added:
+// Utility.batch_all and System.Remarked
+func Metadata() (*types.Metadata, error) {
+	var meta types.Metadata
+	err := types.DecodeFromHexString(types.ExamplaryMetadataV12PolkadotString, &meta)
+	if err != nil {
+		return nil, err
+	}
+	for i, mod := range meta.AsMetadataV12.Modules {
+		switch mod.Name {
+		case "Utility":
+			// batch_all takes the same arguments as batch
+			batchAll := mod.Calls[0]
+			batchAll.Name = "batch_all"
+			meta.AsMetadataV12.Modules[i].Calls = append(mod.Calls, batchAll)
+		case "System":
+			meta.AsMetadataV12.Modules[i].Events = append(mod.Events, types.EventMetadataV4{
+				Name: "Remarked",
+				Args: []types.Type{"AccountId", "Hash"},
+			})
+		}
+	}
+	return &meta, nil
+}
@@ -0,0 +1,405 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+/*
+The fakenode package provides an in-process stand-in for a substrate node, for tests of the relayer.
+
+The node serves the JSON-RPC methods the relayer uses over a websocket: chain_getBlockHash, chain_getHeader,
+chain_getBlock, chain_getFinalizedHead, state_getMetadata, state_getRuntimeVersion, state_getStorage,
+payment_queryInfo and author_submitAndWatchExtrinsic. Blocks are scripted by the test with AddBlock, their
+events are served as the System.Events storage of the block. Submitted extrinsics are recorded, they are
+reported in a block once a block includes them.
+*/
+package fakenode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultWeight is the weight payment_queryInfo returns for any extrinsic
+const DefaultWeight = 1000000000
+
+// Node is a fake substrate node serving scripted blocks
+type Node struct {
+	server *httptest.Server
+	lock   sync.Mutex
+	cond   *sync.Cond // Signalled when an extrinsic is submitted
+
+	meta        *types.Metadata
+	specVersion uint32
+	txVersion   uint32
+	weight      uint64
+	blocks      []*block
+	finalized   int                 // Number of the finalized block, -1 follows the head
+	storage     map[string][]byte   // Storage of the latest state by hex key, except System.Events
+	submitted   [][]byte            // Every submitted extrinsic
+	watchers    map[string]*watcher // Extrinsic subscriptions by hex extrinsic
+	nextSubId   int
+	failures    map[string][]error // Errors returned by the next calls of a method
+	eventsKey   string
+}
+
+type watcher struct {
+	conn *wsConn
+	id   string
+}
+
+// New starts a node serving meta with only the genesis block
+func New(meta *types.Metadata) (*Node, error) {
+	n := &Node{
+		meta:        meta,
+		specVersion: 1,
+		txVersion:   1,
+		weight:      DefaultWeight,
+		finalized:   -1,
+		storage:     make(map[string][]byte),
+		watchers:    make(map[string]*watcher),
+		failures:    make(map[string][]error),
+	}
+	n.cond = sync.NewCond(&n.lock)
+
+	key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	n.eventsKey = key.Hex()
+
+	if _, err = n.appendBlock(Block{}); err != nil {
+		return nil, err
+	}
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveWs))
+	return n, nil
+}
+
+// URL returns the websocket url of the node
+func (n *Node) URL() string {
+	return "ws" + strings.TrimPrefix(n.server.URL, "http")
+}
+
+// Close stops the node and closes the connections of its clients
+func (n *Node) Close() {
+	n.server.CloseClientConnections()
+	n.server.Close()
+}
+
+// SetRuntimeVersion sets the spec and transaction version returned by state_getRuntimeVersion
+func (n *Node) SetRuntimeVersion(specVersion, txVersion uint32) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.specVersion, n.txVersion = specVersion, txVersion
+}
+
+// SetMetadata replaces the metadata, as a runtime upgrade does
+func (n *Node) SetMetadata(meta *types.Metadata) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.meta = meta
+}
+
+// SetWeight sets the weight returned by payment_queryInfo, 0 makes payment_queryInfo fail
+func (n *Node) SetWeight(weight uint64) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.weight = weight
+}
+
+// FailNext makes the next call of method return err, before it is served
+func (n *Node) FailNext(method string, err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.failures[method] = append(n.failures[method], err)
+}
+
+// Submitted returns every extrinsic submitted to the node
+func (n *Node) Submitted() [][]byte {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	res := make([][]byte, len(n.submitted))
+	copy(res, n.submitted)
+	return res
+}
+
+// WaitForSubmitted waits until count extrinsics were submitted and returns them
+func (n *Node) WaitForSubmitted(count int, timeout time.Duration) ([][]byte, error) {
+	timer := time.AfterFunc(timeout, func() {
+		n.lock.Lock()
+		n.cond.Broadcast()
+		n.lock.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(timeout)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for len(n.submitted) < count {
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%d of %d extrinsics submitted after %s", len(n.submitted), count, timeout)
+		}
+		n.cond.Wait()
+	}
+	res := make([][]byte, len(n.submitted))
+	copy(res, n.submitted)
+	return res, nil
+}
+
+// serveWs serves JSON-RPC requests over a websocket, each request is answered in order
+func (n *Node) serveWs(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn := &wsConn{ws: ws}
+	defer n.dropWatchers(conn)
+	defer ws.Close()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req request
+		if err = json.Unmarshal(data, &req); err != nil {
+			_ = conn.send(response{Version: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: -32700, Message: err.Error()}})
+			continue
+		}
+		res := response{Version: "2.0", ID: req.ID}
+		result, err := n.handle(conn, req)
+		if err != nil {
+			res.Error = &rpcError{Code: -32000, Message: err.Error()}
+		} else if res.Result, err = json.Marshal(result); err != nil {
+			res.Error = &rpcError{Code: -32603, Message: err.Error()}
+		}
+		if err = conn.send(res); err != nil {
+			return
+		}
+	}
+}
+
+// handle serves a request, a nil result is sent as null
+func (n *Node) handle(conn *wsConn, req request) (interface{}, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if errs := n.failures[req.Method]; len(errs) > 0 {
+		n.failures[req.Method] = errs[1:]
+		return nil, errs[0]
+	}
+
+	switch req.Method {
+	case "chain_getBlockHash":
+		var number *uint64
+		if err := req.param(0, &number); err != nil {
+			return nil, err
+		}
+		if number == nil {
+			return n.head().hash.Hex(), nil
+		}
+		if *number >= uint64(len(n.blocks)) {
+			return nil, nil
+		}
+		return n.blocks[*number].hash.Hex(), nil
+	case "chain_getFinalizedHead":
+		return n.finalizedBlock().hash.Hex(), nil
+	case "chain_getHeader":
+		b, err := n.blockParam(req, 0)
+		if b == nil || err != nil {
+			return nil, err
+		}
+		return b.headerJSON(), nil
+	case "chain_getBlock":
+		b, err := n.blockParam(req, 0)
+		if b == nil || err != nil {
+			return nil, err
+		}
+		return b.signedBlockJSON(), nil
+	case "state_getMetadata":
+		return types.EncodeToHexString(n.meta)
+	case "state_getRuntimeVersion":
+		return map[string]interface{}{
+			"apis":               []interface{}{},
+			"authoringVersion":   1,
+			"implName":           "fakenode",
+			"implVersion":        1,
+			"specName":           "fakenode",
+			"specVersion":        n.specVersion,
+			"transactionVersion": n.txVersion,
+		}, nil
+	case "state_getStorage":
+		var key string
+		if err := req.param(0, &key); err != nil {
+			return nil, err
+		}
+		b, err := n.blockParam(req, 1)
+		if b == nil || err != nil {
+			return nil, err
+		}
+		var value []byte
+		if strings.EqualFold(key, n.eventsKey) {
+			value = b.events
+		} else if v, ok := n.storage[strings.ToLower(key)]; ok {
+			value = v
+		}
+		if value == nil {
+			return nil, nil
+		}
+		return types.HexEncodeToString(value), nil
+	case "payment_queryInfo":
+		if n.weight == 0 {
+			return nil, fmt.Errorf("unable to query dispatch info")
+		}
+		return map[string]interface{}{"weight": n.weight, "class": "normal", "partialFee": "0"}, nil
+	case "author_submitExtrinsic", "author_submitAndWatchExtrinsic":
+		var ext string
+		if err := req.param(0, &ext); err != nil {
+			return nil, err
+		}
+		data, err := types.HexDecodeString(ext)
+		if err != nil {
+			return nil, err
+		}
+		n.submit(data)
+		if req.Method == "author_submitExtrinsic" {
+			return types.NewHash(hash(data)).Hex(), nil
+		}
+		n.nextSubId++
+		w := &watcher{conn: conn, id: strconv.Itoa(n.nextSubId)}
+		n.watchers[types.HexEncodeToString(data)] = w
+		return w.id, nil
+	case "author_unwatchExtrinsic":
+		var id string
+		if err := req.param(0, &id); err != nil {
+			return nil, err
+		}
+		for ext, w := range n.watchers {
+			if w.conn == conn && w.id == id {
+				delete(n.watchers, ext)
+			}
+		}
+		return true, nil
+	default:
+		return nil, fmt.Errorf("method %s not supported", req.Method)
+	}
+}
+
+// submit records an extrinsic and increments the nonce of its signer
+func (n *Node) submit(ext []byte) {
+	n.submitted = append(n.submitted, ext)
+	n.cond.Broadcast()
+
+	signer, ok := extrinsicSigner(ext)
+	if !ok {
+		return
+	}
+	key := n.accountKey(signer)
+	var info types.AccountInfo
+	if value, ok := n.storage[key]; ok {
+		if err := types.DecodeFromBytes(value, &info); err != nil {
+			return
+		}
+	}
+	fillBalances(&info)
+	info.Nonce++
+	if value, err := types.EncodeToBytes(info); err == nil {
+		n.storage[key] = value
+	}
+}
+
+// blockParam returns the block of the hash parameter at index, the head block without the parameter
+// and nil for an unknown block
+func (n *Node) blockParam(req request, index int) (*block, error) {
+	var hash *string
+	if err := req.param(index, &hash); err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		return n.head(), nil
+	}
+	for _, b := range n.blocks {
+		if strings.EqualFold(b.hash.Hex(), *hash) {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+func (n *Node) dropWatchers(conn *wsConn) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for ext, w := range n.watchers {
+		if w.conn == conn {
+			delete(n.watchers, ext)
+		}
+	}
+}
+
+func (w *watcher) notify(status interface{}) {
+	_ = w.conn.send(notification{
+		Version: "2.0",
+		Method:  "author_extrinsicUpdate",
+		Params:  subscriptionResult{Subscription: w.id, Result: status},
+	})
+}
+
+// wsConn serializes the writes to a websocket
+type wsConn struct {
+	ws   *websocket.Conn
+	lock sync.Mutex
+}
+
+func (c *wsConn) send(msg interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ws.WriteJSON(msg)
+}
+
+type request struct {
+	Version string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// param decodes the parameter at index into v, a missing parameter leaves v unchanged
+func (r request) param(index int, v interface{}) error {
+	if index >= len(r.Params) {
+		return nil
+	}
+	if err := json.Unmarshal(r.Params[index], v); err != nil {
+		return fmt.Errorf("invalid parameter %d of %s: %w", index, r.Method, err)
+	}
+	return nil
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type notification struct {
+	Version string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+type subscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package fakenode
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v2"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
+	utils "github.com/rjman-self/Platdot/shared/substrate"
+)
+
+func newTestNode(t *testing.T) (*Node, *gsrpc.SubstrateAPI) {
+	meta, err := Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := New(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(n.Close)
+	api, err := gsrpc.NewSubstrateAPI(n.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, api
+}
+
+func TestBlocksAndEvents(t *testing.T) {
+	n, api := newTestNode(t)
+	from := types.NewAccountID(signature.TestKeyringPairAlice.PublicKey)
+	to := types.NewAccountID(make([]byte, 32))
+
+	hash, err := n.AddBlock(Block{
+		Extrinsics: [][]byte{{0x04, 0x01}},
+		Events: []Event{
+			ExtrinsicSuccess(0),
+			{Phase: ApplyExtrinsic(0), Module: "Balances", Name: "Transfer", Args: []interface{}{from, to, types.NewU128(*big.NewInt(42))}},
+			{Phase: ApplyExtrinsic(0), Module: "System", Name: "Remarked", Args: []interface{}{from, types.Hash{1}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := api.RPC.Chain.GetBlockHash(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != hash {
+		t.Fatalf("Got: %s Expected: %s", latest.Hex(), hash.Hex())
+	}
+	finalized, err := api.RPC.Chain.GetFinalizedHead()
+	if err != nil || finalized != hash {
+		t.Fatalf("Got: %s %v Expected: %s", finalized.Hex(), err, hash.Hex())
+	}
+	header, err := api.RPC.Chain.GetHeader(hash)
+	if err != nil || header.Number != 1 {
+		t.Fatalf("Got: %v %v Expected: block 1", header, err)
+	}
+	if _, err = api.RPC.Chain.GetBlockHash(2); err == nil {
+		t.Fatal("expected an error for a missing block")
+	}
+
+	meta, err := api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := api.RPC.State.GetStorageRaw(key, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := utils.Events{}
+	err = types.EventRecordsRaw(*raw).DecodeEventRecords(meta, &events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.System_ExtrinsicSuccess) != 1 || len(events.Balances_Transfer) != 1 || len(events.System_Remarked) != 1 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if transfer := events.Balances_Transfer[0]; transfer.From != from || transfer.To != to || transfer.Value.Int64() != 42 {
+		t.Fatalf("unexpected transfer: %+v", transfer)
+	}
+}
+
+func TestSubmitAndWatchExtrinsic(t *testing.T) {
+	n, api := newTestNode(t)
+	meta, err := Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := types.NewAccountID(signature.TestKeyringPairAlice.PublicKey)
+	err = n.SetAccount(alice, types.AccountInfo{Nonce: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := types.NewCall(meta, "System.remark", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := types.NewExtrinsic(c)
+	err = ext.MultiSign(signature.TestKeyringPairAlice, types.SignatureOptions{Nonce: types.NewUCompactFromUInt(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	submitted, err := n.WaitForSubmitted(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The nonce of the signer is incremented
+	key, err := types.CreateStorageKey(meta, "System", "Account", alice[:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info types.AccountInfo
+	if ok, err := api.RPC.State.GetStorageLatest(key, &info); !ok || err != nil || info.Nonce != 4 {
+		t.Fatalf("Got: %d %v %v Expected: nonce 4", info.Nonce, ok, err)
+	}
+
+	hash, err := n.AddBlock(Block{Extrinsics: submitted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case status := <-sub.Chan():
+		if !status.IsInBlock || status.AsInBlock != hash {
+			t.Fatalf("Got: %+v Expected: in block %s", status, hash.Hex())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("extrinsic not reported in block")
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	n, api := newTestNode(t)
+	n.FailNext("chain_getFinalizedHead", errors.New("unavailable"))
+
+	_, err := api.RPC.Chain.GetFinalizedHead()
+	if err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Fatalf("Got: %v Expected: unavailable", err)
+	}
+	if _, err = api.RPC.Chain.GetFinalizedHead(); err != nil {
+		t.Fatal(err)
+	}
+}
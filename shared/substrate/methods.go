@@ -23,5 +23,7 @@ var BalancesTransferMethod Method = "Balances.transfer"
 var BalancesTransferKeepAliveMethod Method = "Balances.transfer_keep_alive"
 var SystemRemark Method = "System.remark"
 var UtilityBatch Method = "Utility.batch"
-var UtilityBatchAll Method = "Utility.batchall"
+var UtilityBatchAll Method = "Utility.batch_all"
 var MultisigAsMulti Method = "Multisig.as_multi"
+var MultisigApproveAsMulti Method = "Multisig.approve_as_multi"
+var MultisigCancelAsMulti Method = "Multisig.cancel_as_multi"
@@ -6,10 +6,14 @@ package utils
 import (
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
 )
 
+// Time between two balance queries of WaitForBalanceChange
+var BalancePollInterval = time.Second * 6
+
 func QueryStorage(client *Client, prefix, method string, arg1, arg2 []byte, result interface{}) (bool, error) {
 	key, err := types.CreateStorageKey(client.Meta, prefix, method, arg1, arg2)
 	if err != nil {
@@ -54,6 +58,22 @@ func BalanceOf(client *Client, publicKey []byte) (*big.Int, error) {
 	return acct.Data.Free.Int, nil
 }
 
+// WaitForBalanceChange polls the free balance of an account until it differs from previous
+func WaitForBalanceChange(client *Client, publicKey []byte, previous *big.Int, timeout time.Duration) (*big.Int, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		balance, err := BalanceOf(client, publicKey)
+		if err != nil {
+			return nil, err
+		}
+		if balance.Cmp(previous) != 0 {
+			return balance, nil
+		}
+		time.Sleep(BalancePollInterval)
+	}
+	return nil, fmt.Errorf("balance unchanged after %s", timeout)
+}
+
 func GetErc721Token(client *Client, id types.U256) (*Erc721Token, error) {
 	var res Erc721Token
 	tokenIdBz, err := types.EncodeToBytes(id)
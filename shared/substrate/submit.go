@@ -12,28 +12,20 @@ import (
 	"github.com/centrifuge/go-substrate-rpc-client/v2/types"
 )
 
-func SubmitTx(client *Client, method Method, args ...interface{}) error {
-	// Create call and extrinsic
-	call, err := types.NewCall(
-		client.Meta,
-		string(method),
-		args...,
-	)
-	if err != nil {
-		return err
-	}
+// signExtrinsic signs call with the client key at the current nonce of its account
+func signExtrinsic(client *Client, call types.Call) (types.Extrinsic, error) {
 	ext := types.NewExtrinsic(call)
 
 	// Get latest runtime version
 	rv, err := client.Api.RPC.State.GetRuntimeVersionLatest()
 	if err != nil {
-		return err
+		return ext, err
 	}
 
 	var acct types.AccountInfo
 	_, err = QueryStorage(client, "System", "Account", client.Key.PublicKey, nil, &acct)
 	if err != nil {
-		return err
+		return ext, err
 	}
 
 	// Sign the extrinsic
@@ -44,31 +36,77 @@ func SubmitTx(client *Client, method Method, args ...interface{}) error {
 		Nonce:              types.NewUCompactFromUInt(uint64(acct.Nonce)),
 		SpecVersion:        rv.SpecVersion,
 		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: 1,
+		TransactionVersion: rv.TransactionVersion,
 	}
 	err = ext.Sign(*client.Key, o)
-	if err != nil {
-		return err
-	}
+	return ext, err
+}
 
-	// Submit and watch the extrinsic
+// submitAndWatch submits a signed extrinsic and returns the hash of the block including it
+func submitAndWatch(client *Client, ext types.Extrinsic) (types.Hash, error) {
 	sub, err := client.Api.RPC.Author.SubmitAndWatchExtrinsic(ext)
 	if err != nil {
-		return err
+		return types.Hash{}, err
 	}
+	defer sub.Unsubscribe()
 
 	for {
 		status := <-sub.Chan()
 		switch {
 		case status.IsInBlock:
 			log15.Info("Extrinsic in block", "block", status.AsInBlock.Hex())
-			return nil
+			return status.AsInBlock, nil
 		case status.IsDropped:
-			return fmt.Errorf("extrinsic dropped")
+			return types.Hash{}, fmt.Errorf("extrinsic dropped")
 		case status.IsInvalid:
-			return fmt.Errorf("extrinsic invalid")
+			return types.Hash{}, fmt.Errorf("extrinsic invalid")
+		}
+	}
+}
+
+func SubmitTx(client *Client, method Method, args ...interface{}) error {
+	// Create call and extrinsic
+	call, err := types.NewCall(
+		client.Meta,
+		string(method),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	ext, err := signExtrinsic(client, call)
+	if err != nil {
+		return err
+	}
+
+	_, err = submitAndWatch(client, ext)
+	return err
+}
+
+// SubmitCall signs and submits a call, then returns the number of the block including it and the index
+// of the extrinsic in the block
+func SubmitCall(client *Client, call types.Call) (uint64, int, error) {
+	ext, err := signExtrinsic(client, call)
+	if err != nil {
+		return 0, 0, err
+	}
+	hash, err := submitAndWatch(client, ext)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	block, err := client.Api.RPC.Chain.GetBlock(hash)
+	if err != nil {
+		return 0, 0, err
+	}
+	nonce := big.Int(ext.Signature.Nonce)
+	for i, e := range block.Block.Extrinsics {
+		other := big.Int(e.Signature.Nonce)
+		if e.IsSigned() && e.Signature.Signer == ext.Signature.Signer && other.Cmp(&nonce) == 0 {
+			return uint64(block.Block.Header.Number), i, nil
 		}
 	}
+	return 0, 0, fmt.Errorf("extrinsic not found in block %s", hash.Hex())
 }
 
 func SubmitSudoTx(client *Client, method Method, args ...interface{}) error {